@@ -0,0 +1,103 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(backfillCmd)
+
+	backfillCmd.Flags().Bool("list", false, "only list the missing ranges; don't download them")
+	viper.BindPFlag("backfill.list", backfillCmd.Flags().Lookup("list"))
+}
+
+var backfillCmd = &cobra.Command{
+	Use:   "backfill [ticker...]",
+	Short: "Download trading days missing from the eod table",
+	Long:  `Compare the eod table against the NYSE trading calendar over the history window and download only the missing ranges. When no tickers are given the active asset universe is checked.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		preflight(ctx)
+
+		var assets []*common.Asset
+		if len(args) > 0 {
+			assets = common.LoadAssetFromDB(ctx, readDSN(), args)
+		} else {
+			assets = common.ReadAssetsFromDatabase(ctx, readDSN(), assetFilter())
+			assets = filterOTCAssets(assets)
+		}
+
+		nyc, _ := time.LoadLocation("America/New_York")
+		startDate, endDate := downloadRange()
+		if endDate.IsZero() {
+			endDate = time.Now().In(nyc).AddDate(0, 0, -1)
+		}
+
+		log.Info().
+			Str("StartDate", startDate.Format("2006-01-02")).
+			Str("EndDate", endDate.Format("2006-01-02")).
+			Int("NumAssets", len(assets)).
+			Msg("checking for missing trading days")
+
+		gaps, err := storage.FindGaps(ctx, readDSN(), assets, startDate, endDate)
+		if err != nil {
+			fatal().Err(err).Msg("could not find gaps")
+			abortRun()
+		}
+
+		numDays := 0
+		for _, gap := range gaps {
+			numDays += gap.NumDays
+		}
+		log.Info().Int("NumGaps", len(gaps)).Int("NumDays", numDays).Msg("found missing trading days")
+
+		if viper.GetBool("backfill.list") {
+			t := table.NewWriter()
+			t.SetOutputMirror(os.Stdout)
+			t.AppendHeader(table.Row{"Ticker", "Composite FIGI", "Start", "End", "Days"})
+			for _, gap := range gaps {
+				t.AppendRow(table.Row{gap.Asset.Ticker, gap.Asset.CompositeFigi, gap.StartDate.Format("2006-01-02"), gap.EndDate.Format("2006-01-02"), gap.NumDays})
+			}
+			t.Render()
+			return
+		}
+
+		if len(gaps) == 0 {
+			return
+		}
+
+		t := tiingoClient()
+		quotes, fetchErrs := t.Backfill(ctx, gaps)
+		checkFetchErrors("download", len(gaps), fetchErrs)
+		exitIfCancelled(ctx)
+		printMetricsReport(t.Metrics())
+
+		log.Info().Int("NumQuotes", len(quotes)).Msg("downloaded missing quotes")
+
+		validQuotes, _ := validateQuotes(quotes)
+		saveQuotesToDatabase(ctx, validQuotes)
+		saveCorporateActions(ctx, validQuotes)
+	},
+}
@@ -0,0 +1,51 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the HTTP response cache",
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached tiingo responses",
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := viper.GetString("cache.dir")
+		if dir == "" {
+			fatal().Msg("no cache directory configured; set --cache-dir")
+			abortRun()
+		}
+
+		numRemoved, err := tiingo.ClearCache(dir)
+		if err != nil {
+			fatal().Err(err).Str("Dir", dir).Msg("could not clear cache")
+			abortRun()
+		}
+		log.Info().Int("NumRemoved", numRemoved).Str("Dir", dir).Msg("cleared cache")
+	},
+}
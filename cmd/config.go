@@ -0,0 +1,402 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// maxRateLimit is the largest tiingo.rate_limit accepted by the config checks;
+// no tiingo plan allows more requests per second
+const maxRateLimit = 100
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configCheckCmd)
+	configCmd.AddCommand(configShowCmd)
+
+	configShowCmd.Flags().Bool("show-secrets", false, "print tokens, keys and passwords instead of redacting them")
+
+	rootCmd.PersistentFlags().Bool("preflight", true, "verify the configuration, tiingo token and database connection before downloading")
+	viper.BindPFlag("preflight", rootCmd.PersistentFlags().Lookup("preflight"))
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and validate the configuration",
+}
+
+var configCheckCmd = &cobra.Command{
+	Use:         "check",
+	Short:       "Verify required settings, the tiingo token and database connectivity",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{skipHealthcheck: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		results := runConfigChecks(cmd.Context())
+
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{"Check", "Status", "Detail"})
+		numFailed := 0
+		for _, result := range results {
+			status, detail := "ok", result.detail
+			if result.err != nil {
+				status, detail = "FAIL", result.err.Error()
+				numFailed++
+			}
+			t.AppendRow(table.Row{result.name, status, detail})
+		}
+		t.Render()
+
+		if numFailed > 0 {
+			log.Error().Int("NumFailed", numFailed).Msg("configuration check failed")
+			runFailed = true
+		}
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:         "show",
+	Short:       "Print the effective configuration and the environment variable for each setting",
+	Long:        "Print the configuration merged from defaults, the config file, " + envPrefix + "_* environment variables and flags; secrets are redacted unless --show-secrets is given",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{skipHealthcheck: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		showSecrets, _ := cmd.Flags().GetBool("show-secrets")
+
+		keys := viper.AllKeys()
+		sort.Strings(keys)
+
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{"Setting", "Environment", "Value"})
+		for _, key := range keys {
+			value := formatSetting(viper.Get(key))
+			if !showSecrets {
+				value = redactSetting(key, value)
+			}
+			t.AppendRow(table.Row{key, envVar(key), value})
+		}
+		t.Render()
+
+		if file := viper.ConfigFileUsed(); file != "" {
+			fmt.Printf("config file: %s\n", file)
+		}
+	},
+}
+
+// secretKeys are settings whose values are redacted by `config show`
+var secretKeys = map[string]bool{
+	"tiingo.token":            true,
+	"tiingo.tokens":           true,
+	"tiingo.proxy":            true,
+	"openfigi.api_key":        true,
+	"s3.access_key_id":        true,
+	"s3.secret_access_key":    true,
+	"vault.token":             true,
+	"notify.slack_url":        true,
+	"notify.discord_url":      true,
+	"notify.webhook_url":      true,
+	"healthcheck.url":         true,
+	"healthcheck.start_url":   true,
+	"healthcheck.success_url": true,
+	"healthcheck.fail_url":    true,
+}
+
+func formatSetting(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(v, ",")
+	case []interface{}:
+		items := make([]string, len(v))
+		for idx, item := range v {
+			items[idx] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// redactSetting hides secret values and the passwords embedded in connection
+// strings
+func redactSetting(key, value string) string {
+	if value == "" || value == "<not-set>" {
+		return value
+	}
+	if secretKeys[key] {
+		return "xxxxx"
+	}
+	if strings.HasSuffix(key, "url") || key == "database.targets" {
+		parts := strings.Split(value, ",")
+		for idx, part := range parts {
+			parts[idx] = common.RedactDSN(part)
+		}
+		return strings.Join(parts, ",")
+	}
+	return value
+}
+
+// configCheck is a single validation of the configuration
+type configCheck struct {
+	name string
+	run  func(ctx context.Context) (string, error)
+}
+
+type configCheckResult struct {
+	name   string
+	detail string
+	err    error
+}
+
+// configChecks returns the checks run by `config check` and at startup. The
+// network checks are skipped when responses are replayed from disk.
+func configChecks() []configCheck {
+	checks := []configCheck{
+		{"tiingo.token", checkTokenSet},
+		{"tiingo.rate_limit", checkRateLimit},
+		{"tiingo.plan", checkPlan},
+		{"tiingo.adjusted_prices", checkAdjustedPrices},
+		{"tiingo.frequency", checkFrequency},
+		{"tiingo.timestamp_policy", checkTimestampPolicy},
+		{"tiingo.proxy", checkNetwork},
+		{"parquet.compression", checkParquetCompression},
+		{"parquet.partition", checkPartition},
+		{"database.url", checkDatabaseURL},
+	}
+	if viper.GetString("replay.dir") == "" {
+		checks = append(checks, configCheck{"tiingo api", checkTokenValid})
+	}
+	checks = append(checks, configCheck{"database", checkDatabaseReachable})
+	return checks
+}
+
+// runConfigChecks runs every check; checks that depend on a failed check are
+// still run so all problems are reported at once
+func runConfigChecks(ctx context.Context) []*configCheckResult {
+	checks := configChecks()
+	results := make([]*configCheckResult, len(checks))
+	for idx, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		detail, err := check.run(checkCtx)
+		cancel()
+		results[idx] = &configCheckResult{name: check.name, detail: detail, err: err}
+	}
+	return results
+}
+
+// preflight validates the configuration before a run starts and exits with
+// the failed checks instead of failing part way through the download
+func preflight(ctx context.Context) {
+	if !viper.GetBool("preflight") {
+		return
+	}
+
+	failed := false
+	for _, result := range runConfigChecks(ctx) {
+		if result.err != nil {
+			log.Error().Str("Check", result.name).Msg(result.err.Error())
+			failed = true
+		}
+	}
+	if failed {
+		fatal().Msg("configuration is invalid; run `import-tiingo config check` for details or pass --preflight=false to skip")
+		abortRun()
+	}
+}
+
+func checkTokenSet(ctx context.Context) (string, error) {
+	token := viper.GetString("tiingo.token")
+	if token == "" || token == "<not-set>" {
+		return "", errors.New("tiingo.token is not set; pass --tiingo-token, set token under [tiingo] in import-tiingo.toml, or use --tiingo-token-file")
+	}
+	return "set", nil
+}
+
+func checkRateLimit(ctx context.Context) (string, error) {
+	rateLimit := viper.GetInt("tiingo.rate_limit")
+	if rateLimit < 1 || rateLimit > maxRateLimit {
+		return "", fmt.Errorf("tiingo.rate_limit is %d; it must be between 1 and %d requests per second (--tiingo-rate-limit)", rateLimit, maxRateLimit)
+	}
+	return fmt.Sprintf("%d requests per second", rateLimit), nil
+}
+
+func checkNetwork(ctx context.Context) (string, error) {
+	network := networkOptions()
+	if network.IsZero() {
+		return "direct connection", nil
+	}
+	if _, err := network.Transport(); err != nil {
+		return "", fmt.Errorf("%v (--tiingo-proxy, --tiingo-ca-bundle, --tiingo-tls-min-version)", err)
+	}
+
+	details := []string{}
+	if network.Proxy != "" {
+		details = append(details, "proxy "+redactProxy(network.Proxy))
+	}
+	if network.CABundle != "" {
+		details = append(details, "CA bundle "+network.CABundle)
+	}
+	if network.TLSMinVersion != "" {
+		details = append(details, "TLS "+network.TLSMinVersion+"+")
+	}
+	return strings.Join(details, ", "), nil
+}
+
+// redactProxy removes the password from a proxy URL
+func redactProxy(proxy string) string {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return proxy
+	}
+	return proxyURL.Redacted()
+}
+
+func checkPlan(ctx context.Context) (string, error) {
+	plan := viper.GetString("tiingo.plan")
+	if _, ok := tiingo.Plans[plan]; plan != "" && !ok {
+		return "", fmt.Errorf("tiingo.plan is %q; use one of %s (--tiingo-plan)", plan, strings.Join(tiingo.PlanNames(), ", "))
+	}
+
+	hourly, daily := tiingoOptions().Budgets()
+	if hourly == 0 && daily == 0 {
+		return "no request budget", nil
+	}
+	if tokens := 1 + len(viper.GetStringSlice("tiingo.tokens")); tokens > 1 {
+		return fmt.Sprintf("%s requests per hour, %s per day for each of %d tokens", budgetString(hourly), budgetString(daily), tokens), nil
+	}
+	return fmt.Sprintf("%s requests per hour, %s per day", budgetString(hourly), budgetString(daily)), nil
+}
+
+func budgetString(budget int) string {
+	if budget == 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(budget)
+}
+
+func checkAdjustedPrices(ctx context.Context) (string, error) {
+	switch adjusted := viper.GetString("tiingo.adjusted_prices"); adjusted {
+	case tiingo.AdjustNone, tiingo.AdjustTiingo, tiingo.AdjustLocal:
+		return adjusted, nil
+	default:
+		return "", fmt.Errorf("tiingo.adjusted_prices is %q; use %s, %s or %s (--adjusted-prices)", adjusted, tiingo.AdjustNone, tiingo.AdjustTiingo, tiingo.AdjustLocal)
+	}
+}
+
+func checkFrequency(ctx context.Context) (string, error) {
+	frequency := viper.GetString("tiingo.frequency")
+	if !tiingo.ValidFrequency(frequency) {
+		return "", fmt.Errorf("tiingo.frequency is %q; use one of %s (--frequency)", frequency, strings.Join(tiingo.Frequencies, ", "))
+	}
+	return frequency, nil
+}
+
+func checkTimestampPolicy(ctx context.Context) (string, error) {
+	policy := viper.GetString("tiingo.timestamp_policy")
+	if !tiingo.ValidTimestampPolicy(policy) {
+		return "", fmt.Errorf("tiingo.timestamp_policy is %q; use one of %s (--timestamp-policy)", policy, strings.Join(tiingo.TimestampPolicies, ", "))
+	}
+	return policy, nil
+}
+
+func checkParquetCompression(ctx context.Context) (string, error) {
+	if _, err := storage.ParquetCompression(viper.GetString("parquet.compression")); err != nil {
+		return "", fmt.Errorf("parquet.compression: %w (--parquet-compression)", err)
+	}
+	return viper.GetString("parquet.compression"), nil
+}
+
+func checkPartition(ctx context.Context) (string, error) {
+	partition := viper.GetString("parquet.partition")
+	if !storage.ValidPartition(partition) {
+		return "", fmt.Errorf("parquet.partition is %q; use one of %s (--parquet-partition)", partition, strings.Join(storage.Partitions, ", "))
+	}
+	if partition == storage.PartitionNone {
+		return "single file", nil
+	}
+	return partition, nil
+}
+
+func checkDatabaseURL(ctx context.Context) (string, error) {
+	url := viper.GetString("database.url")
+	if url == "" && viper.GetString("tickers_file") != "" {
+		// the asset universe is read from the tickers file
+		return "not set; quotes are not saved to a database", nil
+	}
+	if url == "" {
+		return "", errors.New("database.url is not set; pass --database-url or set url under [database] in import-tiingo.toml")
+	}
+	if !common.IsSQLiteDSN(url) && !common.IsClickHouseDSN(url) {
+		if _, err := pgx.ParseConfig(url); err != nil {
+			return "", fmt.Errorf("database.url is not a valid postgres DSN: %w", err)
+		}
+	}
+	return common.RedactDSN(url), nil
+}
+
+func checkTokenValid(ctx context.Context) (string, error) {
+	// the client cannot be created with an invalid rate limit
+	if _, err := checkRateLimit(ctx); err != nil {
+		return "", errors.New("skipped until tiingo.rate_limit is fixed")
+	}
+	if _, err := checkNetwork(ctx); err != nil {
+		return "", errors.New("skipped until the tiingo proxy and TLS settings are fixed")
+	}
+	if _, err := checkTimestampPolicy(ctx); err != nil {
+		return "", errors.New("skipped until tiingo.timestamp_policy is fixed")
+	}
+	if err := newTiingoClient().CheckToken(ctx); err != nil {
+		if errors.Is(err, tiingo.ErrUnauthorized) {
+			return "", fmt.Errorf("tiingo rejected the API token (%v); check tiingo.token against https://www.tiingo.com/account/api/token", err)
+		}
+		return "", fmt.Errorf("could not reach the tiingo API: %v", err)
+	}
+	return "token accepted", nil
+}
+
+func checkDatabaseReachable(ctx context.Context) (string, error) {
+	targets := append([]string{viper.GetString("database.url")}, viper.GetStringSlice("database.targets")...)
+	numReachable := 0
+	for _, target := range targets {
+		if target == "" {
+			continue
+		}
+		if err := storage.PingDatabase(ctx, target); err != nil {
+			return "", fmt.Errorf("could not connect to %s: %v", common.RedactDSN(target), err)
+		}
+		numReachable++
+	}
+	return fmt.Sprintf("%d reachable", numReachable), nil
+}
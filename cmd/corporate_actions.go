@@ -0,0 +1,71 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(corporateActionsCmd)
+
+	corporateActionsCmd.Flags().Bool("all", false, "extract events from the full eod history instead of the history window")
+	viper.BindPFlag("corporate_actions.all", corporateActionsCmd.Flags().Lookup("all"))
+}
+
+var corporateActionsCmd = &cobra.Command{
+	Use:   "corporate-actions",
+	Short: "Populate the corporate_actions table from stored eod rows",
+	Long:  `Extract the non-zero dividends and splits stored in the eod table over the history window (or the full history with --all) into the corporate_actions table.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		since, _ := downloadRange()
+		if viper.GetBool("corporate_actions.all") {
+			since = time.Time{}
+		}
+
+		if skipWrite(viper.GetString("database.url"), 0) {
+			return
+		}
+
+		num, err := storage.PopulateCorporateActions(ctx, viper.GetString("database.url"), since)
+		checkSaveError(err)
+		if err == nil {
+			log.Info().Int64("NumActions", num).Str("Since", since.Format("2006-01-02")).Msg("populated corporate actions")
+		}
+	},
+}
+
+// saveCorporateActions upserts the dividends and splits found in quotes when
+// corporate_actions.enabled is set
+func saveCorporateActions(ctx context.Context, quotes []*tiingo.Eod) {
+	if !viper.GetBool("corporate_actions.enabled") || viper.GetString("database.url") == "" {
+		return
+	}
+
+	actions := tiingo.ExtractCorporateActions(quotes)
+	if len(actions) == 0 || skipWrite(viper.GetString("database.url"), len(actions)) {
+		return
+	}
+	recordWrite("corporate_actions", len(actions), storage.SaveCorporateActionsToDatabase(ctx, viper.GetString("database.url"), actions))
+}
@@ -0,0 +1,61 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(cryptoCmd)
+
+	cryptoCmd.Flags().StringSlice("exchanges", []string{}, "download a separate series for each crypto exchange (default is aggregated across exchanges)")
+	viper.BindPFlag("crypto.exchanges", cryptoCmd.Flags().Lookup("exchanges"))
+}
+
+var cryptoCmd = &cobra.Command{
+	Use:   "crypto [pair...]",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Download daily crypto prices for the given pairs (e.g. btcusd)",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		exchanges := viper.GetStringSlice("crypto.exchanges")
+		startDate, endDate := downloadRange()
+
+		log.Info().
+			Str("StartDate", startDate.Format("2006-01-02")).
+			Str("EndDate", formatEndDate(endDate)).
+			Strs("Pairs", args).
+			Strs("Exchanges", exchanges).
+			Msg("loading crypto pairs")
+
+		t := tiingoClient()
+		quotes, fetchErrs := t.FetchCryptoEod(ctx, args, exchanges, startDate, endDate)
+		checkFetchErrors("crypto", len(args)*len(exchanges), fetchErrs)
+		exitIfCancelled(ctx)
+
+		log.Info().Int("NumQuotes", len(quotes)).Msg("downloaded crypto prices")
+
+		exportQuotes(ctx, quotes, nil)
+
+		if url := viper.GetString("database.url"); url != "" && !skipWrite(url, len(quotes)) {
+			recordWrite("crypto_eod", len(quotes), storage.SaveCryptoToDatabase(ctx, viper.GetString("database.url"), quotes))
+		}
+	},
+}
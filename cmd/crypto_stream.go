@@ -0,0 +1,110 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(cryptoStreamCmd)
+
+	cryptoStreamCmd.Flags().StringSlice("exchanges", []string{}, "only keep trades on these crypto exchanges (default all)")
+	viper.BindPFlag("crypto_stream.exchanges", cryptoStreamCmd.Flags().Lookup("exchanges"))
+
+	cryptoStreamCmd.Flags().Duration("flush-interval", time.Minute, "how often completed bars are written to the database")
+	viper.BindPFlag("crypto_stream.flush_interval", cryptoStreamCmd.Flags().Lookup("flush-interval"))
+
+	cryptoStreamCmd.Flags().Bool("backfill-gaps", true, "download the bars missed while the websocket was disconnected from the crypto prices endpoint")
+	viper.BindPFlag("crypto_stream.backfill_gaps", cryptoStreamCmd.Flags().Lookup("backfill-gaps"))
+}
+
+var cryptoStreamCmd = &cobra.Command{
+	Use:   "crypto-stream [pair...]",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Stream real-time crypto trades and save them as one minute bars",
+	Long:  `Subscribe to tiingo's crypto websocket for the given pairs (e.g. btcusd), aggregate trades into one minute bars per exchange and, every --flush-interval, save completed bars to the crypto_intraday table. When the connection drops it is re-established and the minutes missed in between are downloaded from the crypto prices endpoint. Runs until interrupted; buffered bars are flushed on exit.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		aggregator := tiingo.NewCryptoBarAggregator(viper.GetStringSlice("crypto_stream.exchanges"))
+		t := tiingoClient()
+
+		flush := func(bars []*tiingo.CryptoBar) {
+			if len(bars) == 0 {
+				return
+			}
+			log.Info().Int("NumBars", len(bars)).Int("NumTrades", aggregator.NumTrades()).Msg("flushing crypto bars")
+			if url := viper.GetString("database.url"); url != "" && !skipWrite(url, len(bars)) {
+				recordWrite("crypto_intraday", len(bars), storage.SaveCryptoBarsToDatabase(context.WithoutCancel(ctx), url, bars))
+			}
+		}
+
+		// gaps are backfilled on the flush goroutine so the websocket keeps
+		// reading while the REST requests run
+		gaps := make(chan [2]time.Time, 16)
+		onGap := func(from, to time.Time) {
+			log.Warn().Time("From", from).Time("To", to).Msg("crypto trades were missed while the websocket was disconnected")
+			recordAnomaly("crypto websocket gap from %s to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+			if viper.GetBool("crypto_stream.backfill_gaps") {
+				select {
+				case gaps <- [2]time.Time{from, to}:
+				default:
+					log.Warn().Msg("too many pending gaps; not backfilling")
+				}
+			}
+		}
+
+		log.Info().Strs("Pairs", args).Strs("Exchanges", viper.GetStringSlice("crypto_stream.exchanges")).Msg("streaming crypto trades")
+
+		streamErr := make(chan error, 1)
+		go func() {
+			streamErr <- t.StreamCrypto(ctx, args, aggregator.Add, onGap)
+		}()
+
+		ticker := time.NewTicker(viper.GetDuration("crypto_stream.flush_interval"))
+		defer ticker.Stop()
+
+		var err error
+	loop:
+		for {
+			select {
+			case now := <-ticker.C:
+				flush(aggregator.Flush(now, false))
+			case gap := <-gaps:
+				bars, fetchErrs := t.FetchCryptoBars(ctx, aggregator.Exchanges(), gap[0], gap[1])
+				checkFetchErrors("crypto gaps", len(args), fetchErrs)
+				flush(bars)
+			case err = <-streamErr:
+				break loop
+			}
+		}
+
+		// save the partial bars buffered when the stream stopped
+		flush(aggregator.Flush(time.Now(), true))
+
+		if err != nil {
+			fatal().Err(err).Msg("crypto websocket rejected the subscription")
+			abortRun()
+		}
+	},
+}
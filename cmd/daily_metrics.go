@@ -0,0 +1,67 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(dailyMetricsCmd)
+}
+
+var dailyMetricsCmd = &cobra.Command{
+	Use:   "metrics [ticker...]",
+	Short: "Download daily market cap and valuation ratios from tiingo",
+	Long:  `Download the daily market cap, enterprise value, P/E, P/B and trailing PEG ratio of the given tickers (or the active asset universe if none are given) and save them to the daily_metrics table`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		var assets []*common.Asset
+		if len(args) > 0 {
+			assets = common.LoadAssetFromDB(ctx, readDSN(), args)
+		} else {
+			assets = common.ReadAssetsFromDatabase(ctx, readDSN(), assetFilter())
+			assets = filterOTCAssets(assets)
+		}
+
+		startDate, endDate := downloadRange()
+
+		log.Info().
+			Str("StartDate", startDate.Format("2006-01-02")).
+			Str("EndDate", formatEndDate(endDate)).
+			Int("NumAssets", len(assets)).
+			Msg("loading daily metrics")
+
+		t := tiingoClient()
+		metrics, fetchErrs := t.FetchDailyMetrics(ctx, assets, startDate, endDate)
+		checkFetchErrors("metrics", len(assets), fetchErrs)
+		exitIfCancelled(ctx)
+
+		log.Info().Int("NumRecords", len(metrics)).Msg("downloaded daily metrics")
+
+		if fn := viper.GetString("parquet_file"); fn != "" && !skipWrite(fn, len(metrics)) {
+			recordWrite(fn, len(metrics), storage.SaveDailyMetricsToParquet(ctx, metrics, fn, storageOptions()))
+		}
+
+		if url := viper.GetString("database.url"); url != "" && !skipWrite(url, len(metrics)) {
+			recordWrite("daily_metrics", len(metrics), storage.SaveDailyMetricsToDatabase(ctx, url, metrics))
+		}
+	},
+}
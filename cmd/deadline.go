@@ -0,0 +1,55 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// startedAt is when the process started; max_runtime is measured from it
+var startedAt = time.Now()
+
+// runDeadline returns when downloads must stop, or the zero time when
+// max_runtime is not set
+func runDeadline() time.Time {
+	maxRuntime := viper.GetDuration("max_runtime")
+	if maxRuntime <= 0 {
+		return time.Time{}
+	}
+	return startedAt.Add(maxRuntime)
+}
+
+// recordDeadlineSkips adds the tickers that were not downloaded because the
+// run deadline passed to the run summary and records an anomaly
+func recordDeadlineSkips(phase string, errs []*tiingo.TickerError) {
+	skipped := []string{}
+	for _, err := range errs {
+		if errors.Is(err, tiingo.ErrDeadline) {
+			skipped = append(skipped, err.Ticker)
+		}
+	}
+	if len(skipped) == 0 {
+		return
+	}
+
+	runStats.SkippedByDeadline = append(runStats.SkippedByDeadline, skipped...)
+	log.Warn().Str("Phase", phase).Strs("Tickers", skipped).Msg("tickers skipped due to the run deadline")
+	recordAnomaly("%s: max runtime of %s reached; %d tickers skipped", phase, viper.GetDuration("max_runtime"), len(skipped))
+}
@@ -0,0 +1,108 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().Float64("tolerance", 1e-6, "largest relative difference between two values that is not reported")
+	viper.BindPFlag("diff.tolerance", diffCmd.Flags().Lookup("tolerance"))
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <file.parquet>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Compare the quotes in a parquet file with the database",
+	Long: `Load the quotes in a parquet file and compare them with the quotes stored in
+the database for the same tickers and dates. Quotes only in the file are
+reported as added, quotes only in the database as missing, and quotes whose
+prices, volume, dividend or split differ as changed. Use it to audit the
+restatements tiingo occasionally issues. The command exits non-zero when
+differences are found.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		fn := common.ExpandURI(args[0])
+
+		quotes, err := storage.ReadParquet(ctx, fn, storageOptions())
+		if err != nil {
+			fatal().Err(err).Str("FileName", fn).Msg("could not read parquet file")
+			abortRun()
+		}
+		if len(quotes) == 0 {
+			log.Info().Str("FileName", fn).Msg("parquet file has no quotes")
+			return
+		}
+
+		tickers := []string{}
+		seen := make(map[string]bool)
+		startDate, endDate := quotes[0].Date, quotes[0].Date
+		for _, quote := range quotes {
+			if ticker := strings.ToUpper(quote.Ticker); !seen[ticker] {
+				seen[ticker] = true
+				tickers = append(tickers, quote.Ticker)
+			}
+			if quote.Date.Before(startDate) {
+				startDate = quote.Date
+			}
+			if quote.Date.After(endDate) {
+				endDate = quote.Date
+			}
+		}
+
+		stored, err := storage.LoadStoredQuotes(ctx, readDSN(), tiingo.EodTable(quotes[0].Frequency), tickers, startDate, endDate)
+		if err != nil {
+			fatal().Err(err).Msg("could not load quotes from the database")
+			abortRun()
+		}
+
+		diffs := storage.DiffQuotes(quotes, stored, viper.GetFloat64("diff.tolerance"))
+
+		out := table.NewWriter()
+		out.SetOutputMirror(os.Stdout)
+		out.AppendHeader(table.Row{"Ticker", "Date", "Difference", "Changes"})
+		counts := make(map[string]int)
+		for _, diff := range diffs {
+			counts[diff.Kind]++
+			out.AppendRow(table.Row{diff.Ticker, diff.Date, diff.Kind, strings.Join(diff.Changes, ", ")})
+		}
+
+		log.Info().
+			Int("NumFileQuotes", len(quotes)).
+			Int("NumStoredQuotes", len(stored)).
+			Int("NumAdded", counts[storage.DiffAdded]).
+			Int("NumMissing", counts[storage.DiffMissing]).
+			Int("NumChanged", counts[storage.DiffChanged]).
+			Str("StartDate", startDate.Format("2006-01-02")).
+			Str("EndDate", endDate.Format("2006-01-02")).
+			Msg("diff finished")
+		if len(diffs) > 0 {
+			out.Render()
+			runFailed = true
+		}
+	},
+}
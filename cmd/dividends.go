@@ -0,0 +1,212 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(dividendsCmd)
+	rootCmd.AddCommand(splitsCmd)
+
+	for _, c := range []*cobra.Command{dividendsCmd, splitsCmd} {
+		prefix := c.Name()
+
+		c.Flags().String("from", "", "only list actions going ex on or after this date (YYYY-MM-DD; default one year ago)")
+		viper.BindPFlag(prefix+".from", c.Flags().Lookup("from"))
+
+		c.Flags().String("to", "", "only list actions going ex on or before this date (YYYY-MM-DD; default no limit, including announced actions)")
+		viper.BindPFlag(prefix+".to", c.Flags().Lookup("to"))
+
+		c.Flags().Bool("upcoming", false, "only list actions going ex today or later")
+		viper.BindPFlag(prefix+".upcoming", c.Flags().Lookup("upcoming"))
+
+		c.Flags().StringP("output", "o", OutputTable, "output format; one of `table`, `csv`, `json` or `markdown`")
+		viper.BindPFlag(prefix+".output", c.Flags().Lookup("output"))
+
+		c.Flags().String("output-file", "", "write actions to this file instead of stdout")
+		viper.BindPFlag(prefix+".output_file", c.Flags().Lookup("output-file"))
+	}
+}
+
+var dividendsCmd = &cobra.Command{
+	Use:   "dividends [ticker...]",
+	Short: "List historical and announced dividends from tiingo's corporate actions endpoint",
+	Long:  `Download the distributions of the given tickers (or the active asset universe if none are given) going ex within --from and --to and print them with their payment, record and declaration dates`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		checkCorporateActionOutput("dividends")
+		from, to := exDateRange("dividends")
+		assets := corporateActionAssets(cmd, args)
+
+		t := tiingoClient()
+		distributions, fetchErrs := t.FetchDistributions(ctx, assets, from, to)
+		checkFetchErrors("dividends", len(assets), fetchErrs)
+		exitIfCancelled(ctx)
+
+		header := []string{"ticker", "composite_figi", "ex_date", "amount", "frequency", "payment_date", "record_date", "declaration_date"}
+		rows := make([][]any, len(distributions))
+		for idx, d := range distributions {
+			rows[idx] = []any{
+				d.Ticker, d.CompositeFigi, d.ExDate.Format("2006-01-02"), d.Amount, d.Frequency,
+				formatOptionalDate(d.PaymentDate), formatOptionalDate(d.RecordDate), formatOptionalDate(d.DeclarationDate),
+			}
+		}
+		printCorporateActions("dividends", header, rows)
+	},
+}
+
+var splitsCmd = &cobra.Command{
+	Use:   "splits [ticker...]",
+	Short: "List historical and announced splits from tiingo's corporate actions endpoint",
+	Long:  `Download the splits of the given tickers (or the active asset universe if none are given) going ex within --from and --to and print their ratio and status`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		checkCorporateActionOutput("splits")
+		from, to := exDateRange("splits")
+		assets := corporateActionAssets(cmd, args)
+
+		t := tiingoClient()
+		splits, fetchErrs := t.FetchSplits(ctx, assets, from, to)
+		checkFetchErrors("splits", len(assets), fetchErrs)
+		exitIfCancelled(ctx)
+
+		header := []string{"ticker", "composite_figi", "ex_date", "split_from", "split_to", "split_factor", "status"}
+		rows := make([][]any, len(splits))
+		for idx, s := range splits {
+			rows[idx] = []any{s.Ticker, s.CompositeFigi, s.ExDate.Format("2006-01-02"), s.SplitFrom, s.SplitTo, s.SplitFactor, s.Status}
+		}
+		printCorporateActions("splits", header, rows)
+	},
+}
+
+// exDateRange returns the ex-date window of the dividends or splits command;
+// a zero end date has no limit
+func exDateRange(prefix string) (from, to time.Time) {
+	parse := func(key string) time.Time {
+		value := viper.GetString(key)
+		if value == "" {
+			return time.Time{}
+		}
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			fatal().Err(err).Str("Date", value).Msg("could not parse date; expected YYYY-MM-DD")
+			abortRun()
+		}
+		return date
+	}
+
+	from = time.Now().AddDate(-1, 0, 0)
+	if viper.GetBool(prefix + ".upcoming") {
+		from = time.Now()
+	} else if date := parse(prefix + ".from"); !date.IsZero() {
+		from = date
+	}
+	return from, parse(prefix + ".to")
+}
+
+// checkCorporateActionOutput exits if the output format of the dividends or
+// splits command is unknown; it is called before downloading
+func checkCorporateActionOutput(prefix string) {
+	switch format := viper.GetString(prefix + ".output"); format {
+	case OutputTable, OutputCSV, OutputJSON, OutputMarkdown:
+	default:
+		fatal().Str("Output", format).Msg("unknown output format; must be one of table, csv, json or markdown")
+		abortRun()
+	}
+}
+
+// corporateActionAssets returns the assets named on the command line, or the
+// active asset universe
+func corporateActionAssets(cmd *cobra.Command, args []string) []*common.Asset {
+	if len(args) > 0 {
+		return common.LoadAssetFromDB(cmd.Context(), readDSN(), args)
+	}
+	return filterOTCAssets(common.ReadAssetsFromDatabase(cmd.Context(), readDSN(), assetFilter()))
+}
+
+func formatOptionalDate(date *time.Time) string {
+	if date == nil {
+		return ""
+	}
+	return date.Format("2006-01-02")
+}
+
+// printCorporateActions writes rows in the <prefix>.output format to
+// <prefix>.output_file, or stdout when it is empty or `-`
+func printCorporateActions(prefix string, header []string, rows [][]any) {
+	var out io.Writer = os.Stdout
+	if fn := viper.GetString(prefix + ".output_file"); fn != "" && fn != "-" {
+		fh, err := os.Create(fn)
+		if err != nil {
+			fatal().Err(err).Str("FileName", fn).Msg("could not create output file")
+			abortRun()
+		}
+		defer fh.Close()
+		out = fh
+	}
+
+	if err := writeCorporateActions(out, header, rows, viper.GetString(prefix+".output")); err != nil {
+		fatal().Err(err).Msg("could not write corporate actions")
+		abortRun()
+	}
+}
+
+func writeCorporateActions(out io.Writer, header []string, rows [][]any, format string) error {
+	if format == OutputJSON {
+		records := make([]map[string]any, len(rows))
+		for idx, row := range rows {
+			records[idx] = make(map[string]any, len(header))
+			for col, name := range header {
+				records[idx][name] = row[col]
+			}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+
+	t := table.NewWriter()
+	headerRow := make(table.Row, len(header))
+	for idx, name := range header {
+		headerRow[idx] = name
+	}
+	t.AppendHeader(headerRow)
+	for _, row := range rows {
+		t.AppendRow(table.Row(row))
+	}
+
+	var rendered string
+	switch format {
+	case OutputCSV:
+		rendered = t.RenderCSV()
+	case OutputMarkdown:
+		rendered = t.RenderMarkdown()
+	default:
+		rendered = t.Render()
+	}
+	_, err := fmt.Fprintln(out, rendered)
+	return err
+}
@@ -0,0 +1,111 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/notifications"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// skipWrite returns true when --dry-run is set; the skipped write of
+// numRecords to target is logged
+func skipWrite(target string, numRecords int) bool {
+	if !viper.GetBool("dry_run") {
+		return false
+	}
+	log.Info().Str("Target", common.RedactDSN(target)).Int("NumRecords", numRecords).Msg("dry run: skipping write")
+	return true
+}
+
+// saveQuotesToDatabase saves EOD quotes to the configured databases. In dry
+// run mode a summary of the rows that would be inserted and updated in each
+// database is printed instead.
+func saveQuotesToDatabase(ctx context.Context, quotes []*tiingo.Eod) {
+	if viper.GetString("database.url") == "" {
+		return
+	}
+	quotes = dropUnresolvedQuotes(quotes)
+
+	if !viper.GetBool("dry_run") {
+		recordWrite("eod", len(quotes), storage.SaveToDatabase(ctx, quotes, storageOptions()))
+		return
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Target", "Insert", "Update"})
+	targets := append([]string{viper.GetString("database.url")}, viper.GetStringSlice("database.targets")...)
+	for _, target := range targets {
+		plan, err := storage.PlanEodSave(ctx, quotes, target)
+		if err != nil {
+			t.AppendRow(table.Row{common.RedactDSN(target), "?", "?"})
+			continue
+		}
+		t.AppendRow(table.Row{plan.Target, plan.NumInsert, plan.NumUpdate})
+	}
+	log.Info().Int("NumQuotes", len(quotes)).Msg("dry run: database was not modified")
+	t.Render()
+}
+
+// dropUnresolvedQuotes removes the quotes of assets without a composite FIGI
+// when any database target is keyed by composite FIGI (every target but
+// SQLite); otherwise bars of different unresolved tickers would
+// collide on (”, event_date) and overwrite each other. The tickers are
+// counted as failed in the run summary.
+func dropUnresolvedQuotes(quotes []*tiingo.Eod) []*tiingo.Eod {
+	keyedByFigi := false
+	for _, target := range append([]string{viper.GetString("database.url")}, viper.GetStringSlice("database.targets")...) {
+		if !common.IsSQLiteDSN(target) {
+			keyedByFigi = true
+		}
+	}
+	if !keyedByFigi {
+		return quotes
+	}
+
+	resolved := make([]*tiingo.Eod, 0, len(quotes))
+	dropped := make(map[string]int)
+	for _, quote := range quotes {
+		if quote.CompositeFigi == "" {
+			dropped[quote.Ticker]++
+			continue
+		}
+		resolved = append(resolved, quote)
+	}
+	if len(dropped) == 0 {
+		return quotes
+	}
+
+	for ticker, numQuotes := range dropped {
+		log.Warn().Str("Ticker", ticker).Int("NumQuotes", numQuotes).Msg("ticker has no composite FIGI; not saving its quotes to the database")
+		runStats.Failures = append(runStats.Failures, &notifications.TickerFailure{
+			Phase:  "save",
+			Ticker: ticker,
+			Reason: "no composite FIGI",
+		})
+	}
+	runStats.NumTickersSucceeded -= len(dropped)
+	runStats.NumFailedTickers += len(dropped)
+	recordAnomaly("%d tickers without a composite FIGI were not saved to the database", len(dropped))
+	return resolved
+}
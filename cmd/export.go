@@ -0,0 +1,84 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// eodOutputs maps the settings that name an output file to its format
+var eodOutputs = []struct {
+	key    string
+	format string
+}{
+	{"parquet_file", storage.FormatParquet},
+	{"csv_file", storage.FormatCSV},
+	{"jsonl_file", storage.FormatJSONL},
+	{"arrow_file", storage.FormatArrow},
+	{"s3.uri", storage.FormatParquet},
+}
+
+// exportQuotes writes quotes to every configured output file. Local files are
+// added to manifest when it is not nil.
+func exportQuotes(ctx context.Context, quotes []*tiingo.Eod, manifest *common.Manifest) {
+	for _, output := range eodOutputs {
+		fn := viper.GetString(output.key)
+		if fn == "" {
+			continue
+		}
+		fn = common.ExpandURI(fn)
+		if skipWrite(fn, len(quotes)) {
+			continue
+		}
+
+		if partition := viper.GetString("parquet.partition"); output.format == storage.FormatParquet && partition != storage.PartitionNone {
+			exportPartitioned(ctx, quotes, fn, partition, manifest)
+			continue
+		}
+
+		exporter, err := storage.NewExporter(output.format, viper.GetBool("export.gzip"), storageOptions())
+		if err != nil {
+			log.Error().Err(err).Str("Format", output.format).Msg("could not create exporter")
+			checkSaveError(err)
+			continue
+		}
+
+		err = exporter.Export(ctx, quotes, fn)
+		recordWrite(fn, len(quotes), err)
+		if err == nil && manifest != nil && !common.IsS3URI(fn) {
+			manifest.AddFile(fn, len(quotes))
+		}
+	}
+}
+
+// exportPartitioned writes quotes as a partitioned parquet dataset under dir
+func exportPartitioned(ctx context.Context, quotes []*tiingo.Eod, dir, partition string, manifest *common.Manifest) {
+	files, err := storage.SaveToParquetPartitioned(ctx, quotes, dir, partition, storageOptions())
+	recordWrite(dir, len(quotes), err)
+	if err != nil || manifest == nil || common.IsS3URI(dir) {
+		return
+	}
+	for _, file := range files {
+		manifest.AddFileAs(file.FileName, path.Join(filepath.Base(dir), file.Partition, filepath.Base(file.FileName)), file.NumRecords)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// runFailed is set when a step of the run failed; Execute exits non-zero
+// when it is set
+var runFailed bool
+
+// errRunAborted is the error of a run stopped by abortRun
+var errRunAborted = errors.New("run aborted")
+
+// runAbort is the panic value of abortRun
+type runAbort struct{}
+
+// abortReason is the message of the last fatal log message
+var abortReason string
+
+type abortReasonHook struct{}
+
+func (abortReasonHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	abortReason = msg
+}
+
+// fatal starts a fatal level log message. Unlike log.Fatal it does not exit;
+// follow it with abortRun.
+func fatal() *zerolog.Event {
+	logger := log.Logger.Hook(abortReasonHook{})
+	return logger.WithLevel(zerolog.FatalLevel)
+}
+
+// abortRun stops the command after a fatal error has been logged. log.Fatal
+// exits the process right away; abortRun unwinds to Execute instead, which
+// still records the run as failed, pings the healthcheck, sends
+// notifications and writes the run summary before exiting.
+func abortRun() {
+	panic(runAbort{})
+}
+
+// checkFetchErrors summarizes per-ticker download failures by kind and marks
+// the run failed when the share of failed tickers exceeds failure_threshold.
+// Authorization failures and quotes rejected by the `fail` timestamp policy
+// always fail the run; tickers skipped at the run
+// deadline are reported but don't count as failures.
+func checkFetchErrors(phase string, numRequested int, errs []*tiingo.TickerError) {
+	recordFetchErrors(phase, numRequested, errs)
+	if len(errs) == 0 || numRequested == 0 {
+		return
+	}
+
+	kinds := []error{tiingo.ErrDeadline, tiingo.ErrUnauthorized, tiingo.ErrInvalidTimestamp, tiingo.ErrRateLimited, tiingo.ErrNotFound, tiingo.ErrServer, tiingo.ErrInvalidResponse, tiingo.ErrRequestFailed}
+	counts := make(map[error]int)
+	for _, err := range errs {
+		for _, kind := range kinds {
+			if errors.Is(err, kind) {
+				counts[kind]++
+				break
+			}
+		}
+	}
+
+	event := log.Warn().Str("Phase", phase).Int("NumFailed", len(errs)).Int("NumRequested", numRequested)
+	for _, kind := range kinds {
+		if counts[kind] > 0 {
+			event = event.Int(kind.Error(), counts[kind])
+		}
+	}
+	event.Msg("some tickers could not be downloaded")
+
+	recordDeadlineSkips(phase, errs)
+
+	threshold := viper.GetFloat64("failure_threshold")
+	failureRate := float64(len(errs)-counts[tiingo.ErrDeadline]) / float64(numRequested)
+	if counts[tiingo.ErrUnauthorized] > 0 || counts[tiingo.ErrInvalidTimestamp] > 0 || failureRate > threshold {
+		log.Error().Str("Phase", phase).Float64("FailureRate", failureRate).Float64("Threshold", threshold).Msg("failure threshold exceeded")
+		runFailed = true
+	}
+}
+
+// checkSaveError marks the run failed if saving results failed; the error
+// itself has already been logged
+func checkSaveError(err error) {
+	if err != nil {
+		runFailed = true
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(fundamentalsMetaCmd)
+}
+
+var fundamentalsMetaCmd = &cobra.Command{
+	Use:   "fundamentals-meta [ticker...]",
+	Short: "Sync fundamentals statement definitions and company meta data from tiingo",
+	Long:  `Download the definitions of tiingo's statement data codes (name, statement type and units) into the fundamental_definitions table, and the fundamentals meta data of the given tickers (or the active asset universe if none are given) into the fundamentals_meta table`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		var assets []*common.Asset
+		if len(args) > 0 {
+			assets = common.LoadAssetFromDB(ctx, readDSN(), args)
+		} else {
+			assets = common.ReadAssetsFromDatabase(ctx, readDSN(), assetFilter())
+			assets = filterOTCAssets(assets)
+		}
+
+		t := tiingoClient()
+		definitions, err := t.FetchFundamentalDefinitions(ctx)
+		if err != nil {
+			fatal().Err(err).Msg("could not download fundamental definitions")
+			abortRun()
+		}
+		exitIfCancelled(ctx)
+
+		log.Info().Int("NumDefinitions", len(definitions)).Int("NumAssets", len(assets)).Msg("loading fundamentals meta")
+
+		meta, fetchErrs := t.FetchFundamentalsMeta(ctx, assets)
+		checkFetchErrors("fundamentals meta", len(assets), fetchErrs)
+		exitIfCancelled(ctx)
+
+		log.Info().Int("NumRecords", len(meta)).Msg("downloaded fundamentals meta")
+
+		url := viper.GetString("database.url")
+		if url == "" {
+			return
+		}
+		if !skipWrite(url, len(definitions)) {
+			recordWrite("fundamental_definitions", len(definitions), storage.SaveFundamentalDefinitionsToDatabase(ctx, url, definitions))
+		}
+		if !skipWrite(url, len(meta)) {
+			recordWrite("fundamentals_meta", len(meta), storage.SaveFundamentalsMetaToDatabase(ctx, url, meta))
+		}
+	},
+}
@@ -0,0 +1,57 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(fxCmd)
+}
+
+var fxCmd = &cobra.Command{
+	Use:   "fx [pair...]",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Download daily forex rates for the given currency pairs (e.g. eurusd)",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		startDate, endDate := downloadRange()
+
+		log.Info().
+			Str("StartDate", startDate.Format("2006-01-02")).
+			Str("EndDate", formatEndDate(endDate)).
+			Strs("Pairs", args).
+			Msg("loading currency pairs")
+
+		t := tiingoClient()
+		rates, fetchErrs := t.FetchFxRates(ctx, args, startDate, endDate)
+		checkFetchErrors("fx", len(args), fetchErrs)
+		exitIfCancelled(ctx)
+
+		log.Info().Int("NumRates", len(rates)).Msg("downloaded fx rates")
+
+		if fn := viper.GetString("parquet_file"); fn != "" && !skipWrite(fn, len(rates)) {
+			recordWrite(fn, len(rates), storage.SaveFxToParquet(ctx, rates, fn, storageOptions()))
+		}
+
+		if url := viper.GetString("database.url"); url != "" && !skipWrite(url, len(rates)) {
+			recordWrite("currency_rates", len(rates), storage.SaveFxToDatabase(ctx, viper.GetString("database.url"), rates))
+		}
+	},
+}
@@ -0,0 +1,73 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/notifications"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// skipHealthcheck is a command annotation that disables healthcheck pings,
+// e.g. for long running commands whose child runs ping individually
+const skipHealthcheck = "skip-healthcheck"
+
+// runStats collects statistics about the current run for healthcheck pings
+// and the run summary report
+var runStats = &notifications.RunStats{}
+
+// newHealthcheck creates the healthcheck configured by the healthcheck.*
+// settings
+func newHealthcheck() *notifications.Healthcheck {
+	h := notifications.NewHealthcheck(viper.GetString("healthcheck.url"))
+	if url := viper.GetString("healthcheck.start_url"); url != "" {
+		h.StartURL = url
+	}
+	if url := viper.GetString("healthcheck.success_url"); url != "" {
+		h.SuccessURL = url
+	}
+	if url := viper.GetString("healthcheck.fail_url"); url != "" {
+		h.FailURL = url
+	}
+	return h
+}
+
+// startHealthcheck pings the start URL; it runs before the command once
+// configuration and the run ID are loaded
+func startHealthcheck(cmd *cobra.Command, args []string) {
+	if cmd.Annotations[skipHealthcheck] != "" {
+		return
+	}
+	runStats.RunID = common.RunID
+	runStats.StartTime = time.Now()
+	newHealthcheck().Start(context.Background(), runStats)
+	startRunLedger(cmd, args)
+}
+
+// finishHealthcheck pings the success or failure URL with the run statistics;
+// finishRunStats must be called first
+func finishHealthcheck() {
+	h := newHealthcheck()
+	if !h.Enabled() || runStats.StartTime.IsZero() {
+		return
+	}
+
+	// the run context may already be cancelled; always deliver the final ping
+	h.Finish(context.Background(), runStats)
+}
@@ -0,0 +1,196 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+}
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <file.parquet>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Summarize the quotes in a parquet file",
+	Long: `Print the footer metadata of a parquet file written by import-tiingo and,
+for each ticker, the number of rows, the date coverage, the range of prices
+and volume, and the trading days missing between the first and last quote.
+Use it to verify an export before shipping it downstream.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		fn := common.ExpandURI(args[0])
+
+		metadata, numRows, err := storage.ReadParquetMetadata(ctx, fn, storageOptions())
+		if err != nil {
+			fatal().Err(err).Str("FileName", fn).Msg("could not read parquet file")
+			abortRun()
+		}
+		quotes, err := storage.ReadParquet(ctx, fn, storageOptions())
+		if err != nil {
+			fatal().Err(err).Str("FileName", fn).Msg("could not read parquet file")
+			abortRun()
+		}
+
+		fmt.Printf("%s: %d rows\n", fn, numRows)
+		if len(metadata) > 0 {
+			keys := make([]string, 0, len(metadata))
+			for key := range metadata {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			meta := table.NewWriter()
+			meta.SetOutputMirror(os.Stdout)
+			meta.AppendHeader(table.Row{"Key", "Value"})
+			for _, key := range keys {
+				meta.AppendRow(table.Row{key, metadata[key]})
+			}
+			meta.Render()
+		}
+
+		summaries := inspectQuotes(quotes)
+
+		out := table.NewWriter()
+		out.SetOutputMirror(os.Stdout)
+		out.AppendHeader(table.Row{"Ticker", "Rows", "First", "Last", "Min Low", "Max High", "Min Close", "Max Close", "Min Volume", "Max Volume", "Missing Days"})
+		gaps := table.NewWriter()
+		gaps.SetOutputMirror(os.Stdout)
+		gaps.AppendHeader(table.Row{"Ticker", "Gap Start", "Gap End", "Trading Days"})
+		numGaps := 0
+		for _, summary := range summaries {
+			out.AppendRow(table.Row{
+				summary.ticker, summary.rows,
+				summary.first.Format("2006-01-02"), summary.last.Format("2006-01-02"),
+				summary.minLow, summary.maxHigh, summary.minClose, summary.maxClose,
+				summary.minVolume, summary.maxVolume, summary.missingDays,
+			})
+			for _, gap := range summary.gaps {
+				numGaps++
+				gaps.AppendRow(table.Row{summary.ticker, gap.start.Format("2006-01-02"), gap.end.Format("2006-01-02"), gap.days})
+			}
+		}
+		out.Render()
+		if numGaps > 0 {
+			gaps.Render()
+		}
+	},
+}
+
+// quoteSummary describes the quotes of a single ticker
+type quoteSummary struct {
+	ticker      string
+	rows        int
+	first       time.Time
+	last        time.Time
+	minLow      float64
+	maxHigh     float64
+	minClose    float64
+	maxClose    float64
+	minVolume   int64
+	maxVolume   int64
+	missingDays int
+	gaps        []*quoteGap
+}
+
+// quoteGap is a run of consecutive trading days without a quote
+type quoteGap struct {
+	start time.Time
+	end   time.Time
+	days  int
+}
+
+// inspectQuotes summarizes quotes by ticker in ticker order. Gaps are only
+// detected for daily quotes.
+func inspectQuotes(quotes []*tiingo.Eod) []*quoteSummary {
+	byTicker := make(map[string][]*tiingo.Eod)
+	for _, quote := range quotes {
+		byTicker[quote.Ticker] = append(byTicker[quote.Ticker], quote)
+	}
+
+	tickers := make([]string, 0, len(byTicker))
+	for ticker := range byTicker {
+		tickers = append(tickers, ticker)
+	}
+	sort.Strings(tickers)
+
+	summaries := make([]*quoteSummary, 0, len(tickers))
+	for _, ticker := range tickers {
+		tickerQuotes := byTicker[ticker]
+		sort.Slice(tickerQuotes, func(i, j int) bool {
+			return tickerQuotes[i].Date.Before(tickerQuotes[j].Date)
+		})
+
+		first := tickerQuotes[0]
+		summary := &quoteSummary{
+			ticker:    ticker,
+			rows:      len(tickerQuotes),
+			first:     first.Date,
+			last:      tickerQuotes[len(tickerQuotes)-1].Date,
+			minLow:    first.Low,
+			maxHigh:   first.High,
+			minClose:  first.Close,
+			maxClose:  first.Close,
+			minVolume: first.Volume,
+			maxVolume: first.Volume,
+		}
+
+		present := make(map[string]bool, len(tickerQuotes))
+		daily := true
+		for _, quote := range tickerQuotes {
+			present[quote.Date.Format("2006-01-02")] = true
+			summary.minLow = min(summary.minLow, quote.Low)
+			summary.maxHigh = max(summary.maxHigh, quote.High)
+			summary.minClose = min(summary.minClose, quote.Close)
+			summary.maxClose = max(summary.maxClose, quote.Close)
+			summary.minVolume = min(summary.minVolume, quote.Volume)
+			summary.maxVolume = max(summary.maxVolume, quote.Volume)
+			if quote.Frequency != "" && quote.Frequency != tiingo.FrequencyDaily {
+				daily = false
+			}
+		}
+
+		if daily {
+			cal := common.CalendarFor(first.Exchange)
+			var gap *quoteGap
+			for _, day := range cal.TradingDays(summary.first.In(cal.Location), summary.last.In(cal.Location)) {
+				if present[day.Format("2006-01-02")] {
+					gap = nil
+					continue
+				}
+				summary.missingDays++
+				if gap == nil {
+					gap = &quoteGap{start: day}
+					summary.gaps = append(summary.gaps, gap)
+				}
+				gap.end = day
+				gap.days++
+			}
+		}
+
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
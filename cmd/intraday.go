@@ -0,0 +1,74 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(intradayCmd)
+
+	intradayCmd.Flags().String("frequency", "5min", "resample frequency of intraday bars; one of `"+strings.Join(tiingo.IntradayFrequencies, "`, `")+"`")
+	viper.BindPFlag("intraday.frequency", intradayCmd.Flags().Lookup("frequency"))
+}
+
+var intradayCmd = &cobra.Command{
+	Use:   "intraday [ticker...]",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Download intraday bars from the tiingo IEX endpoint",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		frequency := viper.GetString("intraday.frequency")
+		if !tiingo.ValidIntradayFrequency(frequency) {
+			fatal().Str("Frequency", frequency).Strs("Valid", tiingo.IntradayFrequencies).Msg("unsupported intraday frequency")
+			abortRun()
+		}
+
+		startDate, endDate := downloadRange()
+
+		log.Info().
+			Str("StartDate", startDate.Format("2006-01-02")).
+			Str("EndDate", formatEndDate(endDate)).
+			Str("Frequency", frequency).
+			Int("NumAssets", len(args)).
+			Msg("loading tickers")
+
+		assets := common.LoadAssetFromDB(ctx, readDSN(), args)
+
+		t := tiingoClient()
+		bars, fetchErrs := t.FetchIntradayBars(ctx, assets, startDate, endDate, frequency)
+		checkFetchErrors("intraday", len(assets), fetchErrs)
+		exitIfCancelled(ctx)
+
+		log.Info().Int("NumBars", len(bars)).Msg("downloaded intraday bars")
+
+		if fn := viper.GetString("parquet_file"); fn != "" && !skipWrite(fn, len(bars)) {
+			recordWrite(fn, len(bars), storage.SaveIntradayToParquet(ctx, bars, fn, storageOptions()))
+		}
+
+		if url := viper.GetString("database.url"); url != "" && !skipWrite(url, len(bars)) {
+			recordWrite("intraday", len(bars), storage.SaveIntradayToDatabase(ctx, viper.GetString("database.url"), bars))
+		}
+	},
+}
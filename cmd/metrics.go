@@ -0,0 +1,59 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// printMetricsReport renders per-ticker request metrics sorted by the
+// configured column (descending for numeric columns)
+func printMetricsReport(metrics []*tiingo.RequestMetrics) {
+	if !viper.GetBool("display.metrics_report") {
+		return
+	}
+
+	sortColumns := map[string]table.SortBy{
+		"ticker":  {Name: "Ticker", Mode: table.Asc},
+		"status":  {Name: "Status", Mode: table.AscNumeric},
+		"bytes":   {Name: "Bytes", Mode: table.DscNumeric},
+		"latency": {Name: "Latency (ms)", Mode: table.DscNumeric},
+		"bars":    {Name: "Bars", Mode: table.DscNumeric},
+		"retries": {Name: "Retries", Mode: table.DscNumeric},
+	}
+
+	sortKey := viper.GetString("display.metrics_sort")
+	sortBy, ok := sortColumns[sortKey]
+	if !ok {
+		log.Warn().Str("Sort", sortKey).Msg("unknown metrics sort column ... using latency")
+		sortBy = sortColumns["latency"]
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Ticker", "Status", "Bytes", "Latency (ms)", "Bars", "Retries", "Error"})
+	for _, m := range metrics {
+		t.AppendRow(table.Row{
+			m.Ticker, m.StatusCode, m.Bytes, m.Latency.Milliseconds(), m.NumBars, m.Retries, m.Error,
+		})
+	}
+	t.SortBy([]table.SortBy{sortBy})
+	t.Render()
+}
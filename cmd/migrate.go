@@ -0,0 +1,141 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/migrations"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+	migrateCmd.AddCommand(migrateForceCmd)
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Create or update the database schema",
+	Long: `Create or update the postgres tables used by import-tiingo (assets, eod,
+corporate_actions, fundamentals, ...). Running migrate without a subcommand
+applies all pending migrations. Existing databases are upgraded in place.`,
+	Annotations: map[string]string{skipHealthcheck: "true"},
+	Run:         runMigrateUp,
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:         "up",
+	Short:       "Apply all pending migrations",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{skipHealthcheck: "true"},
+	Run:         runMigrateUp,
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:         "down [steps]",
+	Short:       "Revert the most recent migrations (default 1); this drops tables and data",
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{skipHealthcheck: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		steps := 1
+		if len(args) == 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[0]); err != nil || steps < 1 {
+				fatal().Str("Steps", args[0]).Msg("steps must be a positive integer")
+				abortRun()
+			}
+		}
+
+		url := viper.GetString("database.url")
+		if skipWrite(url, steps) {
+			return
+		}
+		if err := migrations.Down(url, steps); err != nil {
+			fatal().Err(err).Str("Target", common.RedactDSN(url)).Msg("could not revert migrations")
+			abortRun()
+		}
+		printMigrationStatus(url)
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:         "status",
+	Short:       "Show the current and latest schema versions",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{skipHealthcheck: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		printMigrationStatus(viper.GetString("database.url"))
+	},
+}
+
+var migrateForceCmd = &cobra.Command{
+	Use:         "force <version>",
+	Short:       "Set the schema version without running migrations, e.g. after fixing a failed migration by hand",
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{skipHealthcheck: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		version, err := strconv.Atoi(args[0])
+		if err != nil {
+			fatal().Str("Version", args[0]).Msg("version must be an integer")
+			abortRun()
+		}
+
+		url := viper.GetString("database.url")
+		if skipWrite(url, 0) {
+			return
+		}
+		if err := migrations.Force(url, version); err != nil {
+			fatal().Err(err).Str("Target", common.RedactDSN(url)).Msg("could not force schema version")
+			abortRun()
+		}
+		printMigrationStatus(url)
+	},
+}
+
+func runMigrateUp(cmd *cobra.Command, args []string) {
+	url := viper.GetString("database.url")
+	if skipWrite(url, int(migrations.Latest())) {
+		printMigrationStatus(url)
+		return
+	}
+	if err := migrations.Up(url); err != nil {
+		fatal().Err(err).Str("Target", common.RedactDSN(url)).Msg("could not apply migrations")
+		abortRun()
+	}
+	printMigrationStatus(url)
+}
+
+// printMigrationStatus renders the schema version of the database at url
+func printMigrationStatus(url string) {
+	status, err := migrations.CurrentStatus(url)
+	if err != nil {
+		fatal().Err(err).Str("Target", common.RedactDSN(url)).Msg("could not read schema version")
+		abortRun()
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Target", "Version", "Latest", "Dirty"})
+	t.AppendRow(table.Row{common.RedactDSN(url), status.Version, status.Latest, status.Dirty})
+	t.Render()
+}
@@ -0,0 +1,61 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"time"
+
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(newsCmd)
+
+	newsCmd.Flags().StringSlice("news-tags", []string{}, "only download articles with any of these tags")
+	viper.BindPFlag("news.tags", newsCmd.Flags().Lookup("news-tags"))
+}
+
+var newsCmd = &cobra.Command{
+	Use:   "news [ticker...]",
+	Short: "Download news articles from tiingo",
+	Long:  `Download news articles mentioning the given tickers (or all tickers if none are given) and save them to the news table`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		startDate, endDate := downloadRange()
+		if endDate.IsZero() {
+			endDate = time.Now()
+		}
+		tags := viper.GetStringSlice("news.tags")
+
+		log.Info().
+			Str("StartDate", startDate.Format("2006-01-02")).
+			Str("EndDate", endDate.Format("2006-01-02")).
+			Strs("Tickers", args).
+			Strs("Tags", tags).
+			Msg("loading news")
+
+		t := tiingoClient()
+		articles := t.FetchNews(ctx, args, tags, startDate, endDate)
+		exitIfCancelled(ctx)
+
+		if url := viper.GetString("database.url"); url != "" && !skipWrite(url, len(articles)) {
+			recordWrite("news", len(articles), storage.SaveNewsToDatabase(ctx, viper.GetString("database.url"), articles))
+		}
+	},
+}
@@ -0,0 +1,37 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+
+	"github.com/penny-vault/import-tiingo/notifications"
+	"github.com/spf13/viper"
+)
+
+// notifyRun posts the run summary to the configured chat and webhook URLs;
+// finishRunStats must be called first
+func notifyRun() {
+	w := notifications.NewWebhook(
+		viper.GetString("notify.slack_url"),
+		viper.GetString("notify.discord_url"),
+		viper.GetString("notify.webhook_url"),
+		viper.GetStringSlice("notify.events"),
+	)
+	if !w.Enabled() || runStats.StartTime.IsZero() {
+		return
+	}
+	w.Notify(context.Background(), runStats)
+}
@@ -0,0 +1,147 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/spf13/viper"
+)
+
+// readDSN returns the connection string used for reading the asset universe.
+// database.read_url (e.g. a replica) is preferred; database.url is used when
+// it is not set.
+func readDSN() string {
+	return storageOptions().ReadDSN()
+}
+
+// tiingoOptions builds the tiingo client options from the tiingo.*, otc.*,
+// quota.*, cache.*, record.* and replay.* settings
+func tiingoOptions() tiingo.Options {
+	return tiingo.Options{
+		Workers:           viper.GetInt("tiingo.workers"),
+		BaseURL:           viper.GetString("tiingo.base_url"),
+		Tokens:            viper.GetStringSlice("tiingo.tokens"),
+		AdaptiveRateLimit: viper.GetBool("tiingo.adaptive_rate_limit"),
+		MinRateLimit:      viper.GetInt("tiingo.min_rate_limit"),
+		Plan:              viper.GetString("tiingo.plan"),
+		HourlyBudget:      viper.GetInt("tiingo.hourly_budget"),
+		DailyBudget:       viper.GetInt("tiingo.daily_budget"),
+		Frequency:         viper.GetString("tiingo.frequency"),
+		Columns:           viper.GetStringSlice("tiingo.columns"),
+		TrimZeroVolume:    viper.GetBool("tiingo.trim_zero_volume"),
+		Adjust:            adjustOptions(),
+		OTC: tiingo.OTCOptions{
+			RateLimit: viper.GetInt("otc.rate_limit"),
+			MinPrice:  viper.GetFloat64("otc.min_price"),
+			MinVolume: viper.GetInt64("otc.min_volume"),
+		},
+		Quota: tiingo.QuotaOptions{
+			RemainingHeader: viper.GetString("quota.remaining_header"),
+			LimitHeader:     viper.GetString("quota.limit_header"),
+			ResetHeader:     viper.GetString("quota.reset_header"),
+			PauseBelow:      viper.GetInt64("quota.pause_below"),
+			PauseDuration:   viper.GetDuration("quota.pause_duration"),
+			WarnThresholds:  viper.GetIntSlice("quota.warn_thresholds"),
+		},
+		TimestampTolerance: viper.GetDuration("tiingo.timestamp_tolerance"),
+		TimestampPolicy:    viper.GetString("tiingo.timestamp_policy"),
+		LogRequests:        viper.GetBool("tiingo.log_requests"),
+		RequestTimeout:     viper.GetDuration("tiingo.request_timeout"),
+		MaxRetries:         viper.GetInt("tiingo.max_retries"),
+		MaxRetryWait:       viper.GetDuration("tiingo.max_retry_wait"),
+		Deadline:           runDeadline(),
+		CacheDir:           viper.GetString("cache.dir"),
+		CacheTTL:           viper.GetDuration("cache.ttl"),
+		RecordDir:          viper.GetString("record.dir"),
+		ReplayDir:          viper.GetString("replay.dir"),
+		S3:                 s3Options(),
+		Progress:           newProgress,
+	}
+}
+
+// adjustOptions selects the adjusted values from tiingo.adjusted_prices and
+// tiingo.adjusted_volume
+func adjustOptions() tiingo.AdjustOptions {
+	return tiingo.AdjustOptions{
+		Prices: viper.GetString("tiingo.adjusted_prices"),
+		Volume: viper.GetBool("tiingo.adjusted_volume"),
+	}
+}
+
+// storageOptions builds the storage options from the database.*, parquet.*
+// and s3.* settings
+func storageOptions() *storage.Options {
+	return &storage.Options{
+		DatabaseURL:            viper.GetString("database.url"),
+		Targets:                viper.GetStringSlice("database.targets"),
+		ReadURL:                viper.GetString("database.read_url"),
+		Writers:                viper.GetInt("database.writers"),
+		BatchSize:              viper.GetInt("database.batch_size"),
+		Atomic:                 viper.GetBool("database.atomic"),
+		Timescale:              viper.GetBool("database.timescale"),
+		TimescaleChunkInterval: viper.GetDuration("database.timescale_chunk_interval"),
+		TrackCorrections:       viper.GetBool("database.track_corrections"),
+		TrackRevisions:         viper.GetBool("database.track_revisions"),
+		RevisionThreshold:      viper.GetFloat64("database.revision_threshold"),
+		Frequency:              viper.GetString("tiingo.frequency"),
+		Adjust:                 adjustOptions(),
+		Parquet: storage.ParquetOptions{
+			Compression:  viper.GetString("parquet.compression"),
+			RowGroupSize: int64(viper.GetSizeInBytes("parquet.row_group_size")),
+			PageSize:     int64(viper.GetSizeInBytes("parquet.page_size")),
+			Merge:        viper.GetBool("parquet.merge"),
+			Adjust:       adjustOptions(),
+		},
+		S3: s3Options(),
+	}
+}
+
+// s3Options builds the S3 client options from the s3.* settings
+func s3Options() common.S3Options {
+	return common.S3Options{
+		Region:          viper.GetString("s3.region"),
+		Endpoint:        viper.GetString("s3.endpoint"),
+		PathStyle:       viper.GetBool("s3.path_style"),
+		AccessKeyID:     viper.GetString("s3.access_key_id"),
+		SecretAccessKey: viper.GetString("s3.secret_access_key"),
+		ACL:             viper.GetString("s3.acl"),
+	}
+}
+
+// networkOptions builds the proxy and TLS options of the tiingo client from
+// the tiingo.proxy, tiingo.ca_bundle and tiingo.tls_min_version settings
+func networkOptions() common.NetworkOptions {
+	return common.NetworkOptions{
+		Proxy:         viper.GetString("tiingo.proxy"),
+		CABundle:      viper.GetString("tiingo.ca_bundle"),
+		TLSMinVersion: viper.GetString("tiingo.tls_min_version"),
+	}
+}
+
+// secretOptions builds the secret resolver options from the vault.* settings
+func secretOptions() common.SecretOptions {
+	return common.SecretOptions{
+		VaultAddress: viper.GetString("vault.address"),
+		VaultToken:   viper.GetString("vault.token"),
+		Region:       viper.GetString("s3.region"),
+	}
+}
+
+// filterOTCAssets applies otc.policy and otc.allow_list to assets
+func filterOTCAssets(assets []*common.Asset) []*common.Asset {
+	return common.FilterOTCAssets(assets, viper.GetString("otc.policy"), viper.GetStringSlice("otc.allow_list"))
+}
@@ -0,0 +1,119 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/viper"
+)
+
+// newProgress creates a progress reporter for the named phase according to
+// the display.progress setting
+func newProgress(phase string, total int) common.Progress {
+	mode := viper.GetString("display.progress")
+	if viper.GetBool("display.hide_progress") {
+		mode = common.ProgressNone
+	}
+
+	var progress common.Progress
+	switch mode {
+	case common.ProgressJSON:
+		progress = common.NewJSONProgress(os.Stdout, phase, total)
+	case common.ProgressNone:
+		progress = common.NoProgress(phase, total)
+	default:
+		progress = newBarProgress(phase, total)
+	}
+
+	if url := viper.GetString("display.progress_url"); url != "" {
+		progress = common.NewCallbackProgress(progress, url, viper.GetDuration("display.progress_interval"), phase, total)
+	}
+
+	return progress
+}
+
+// barProgress draws a progress bar with the ETA; the description shows the
+// current item, bytes transferred and number of failures
+type barProgress struct {
+	mu      sync.Mutex
+	bar     *progressbar.ProgressBar
+	phase   string
+	current string
+	bytes   int64
+	errors  int
+}
+
+func newBarProgress(phase string, total int) *barProgress {
+	bar := progressbar.NewOptions(total,
+		progressbar.OptionSetDescription(phase),
+		progressbar.OptionSetWriter(os.Stderr),
+		progressbar.OptionSetWidth(10),
+		progressbar.OptionThrottle(65*time.Millisecond),
+		progressbar.OptionShowCount(),
+		progressbar.OptionSetPredictTime(true),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprint(os.Stderr, "\n")
+		}),
+		progressbar.OptionSpinnerType(14),
+		progressbar.OptionFullWidth(),
+		progressbar.OptionSetRenderBlankState(true),
+	)
+	return &barProgress{bar: bar, phase: phase}
+}
+
+func (p *barProgress) Add(n int) {
+	p.bar.Add(n)
+}
+
+func (p *barProgress) Error() {
+	p.mu.Lock()
+	p.errors++
+	p.describe()
+	p.mu.Unlock()
+	p.bar.Add(1)
+}
+
+func (p *barProgress) Current(item string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current = item
+	p.describe()
+}
+
+func (p *barProgress) AddBytes(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bytes += int64(n)
+	p.describe()
+}
+
+// describe updates the bar's description; p.mu must be held
+func (p *barProgress) describe() {
+	description := fmt.Sprintf("%s %-8s %s", p.phase, p.current, common.FormatBytes(p.bytes))
+	if p.errors > 0 {
+		description += fmt.Sprintf(" %d failed", p.errors)
+	}
+	p.bar.Describe(description)
+}
+
+func (p *barProgress) Finish() {
+	p.bar.Finish()
+}
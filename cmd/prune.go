@@ -0,0 +1,74 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+
+	pruneCmd.Flags().Int("min-runs", 3, "number of consecutive runs without data before an asset is listed")
+	viper.BindPFlag("prune.min_runs", pruneCmd.Flags().Lookup("min-runs"))
+
+	pruneCmd.Flags().Bool("deactivate", false, "mark the listed assets inactive")
+	viper.BindPFlag("prune.deactivate", pruneCmd.Flags().Lookup("deactivate"))
+}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "List assets that have stopped returning data",
+	Long:  `List active assets that tiingo has returned no data for (not found or an empty series) for at least --min-runs consecutive runs and optionally mark them inactive`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		candidates, err := storage.FindDelistingCandidates(ctx, readDSN(), viper.GetInt("prune.min_runs"))
+		if err != nil {
+			os.Exit(1)
+		}
+
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{"Ticker", "Composite FIGI", "Last Seen", "Missing Runs"})
+		for _, candidate := range candidates {
+			lastSeen := ""
+			if candidate.LastSeen != nil {
+				lastSeen = candidate.LastSeen.Format("2006-01-02")
+			}
+			t.AppendRow(table.Row{candidate.Ticker, candidate.CompositeFigi, lastSeen, candidate.MissingRuns})
+		}
+		t.Render()
+
+		if !viper.GetBool("prune.deactivate") || len(candidates) == 0 {
+			return
+		}
+
+		if skipWrite(viper.GetString("database.url"), len(candidates)) {
+			return
+		}
+
+		if err := storage.DeactivateAssets(ctx, viper.GetString("database.url"), candidates); err != nil {
+			os.Exit(1)
+		}
+		log.Info().Int("NumDeactivated", len(candidates)).Msg("deactivated assets")
+	},
+}
@@ -0,0 +1,95 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringSlice("kafka-brokers", []string{}, "publish each quote to kafka as it is downloaded using these brokers")
+	viper.BindPFlag("kafka.brokers", rootCmd.PersistentFlags().Lookup("kafka-brokers"))
+
+	rootCmd.PersistentFlags().String("kafka-topic", "eod", "kafka topic quotes are published to")
+	viper.BindPFlag("kafka.topic", rootCmd.PersistentFlags().Lookup("kafka-topic"))
+
+	rootCmd.PersistentFlags().String("kafka-format", storage.MessageJSON, "encoding of kafka messages (json or avro)")
+	viper.BindPFlag("kafka.format", rootCmd.PersistentFlags().Lookup("kafka-format"))
+
+	rootCmd.PersistentFlags().String("nats-url", "", "publish each quote to NATS JetStream as it is downloaded using this server (e.g. nats://localhost:4222)")
+	viper.BindPFlag("nats.url", rootCmd.PersistentFlags().Lookup("nats-url"))
+
+	rootCmd.PersistentFlags().String("nats-subject", storage.DefaultNatsSubject, "subject template for NATS messages; may reference {ticker}, {exchange}, {figi} and {currency}")
+	viper.BindPFlag("nats.subject", rootCmd.PersistentFlags().Lookup("nats-subject"))
+
+	rootCmd.PersistentFlags().String("nats-format", storage.MessageJSON, "encoding of NATS messages (json or avro)")
+	viper.BindPFlag("nats.format", rootCmd.PersistentFlags().Lookup("nats-format"))
+}
+
+// startPublishing streams quotes downloaded by t to the configured message
+// brokers. Quotes are published before validation. The returned function
+// must be called once the download finishes; it flushes pending messages.
+func startPublishing(ctx context.Context, t tiingo.TiingoClient, numAssets int) func() {
+	targets := []string{}
+	publishers := []storage.Publisher{}
+
+	if brokers := viper.GetStringSlice("kafka.brokers"); len(brokers) > 0 {
+		topic := viper.GetString("kafka.topic")
+		target := "kafka://" + strings.Join(brokers, ",") + "/" + topic
+		if !skipWrite(target, numAssets) {
+			publisher, err := storage.NewKafkaPublisher(brokers, topic, viper.GetString("kafka.format"))
+			if err != nil {
+				fatal().Err(err).Msg("could not create kafka publisher")
+				abortRun()
+			}
+			targets = append(targets, target)
+			publishers = append(publishers, publisher)
+		}
+	}
+
+	if url := viper.GetString("nats.url"); url != "" && !skipWrite(url, numAssets) {
+		publisher, err := storage.NewNatsPublisher(url, viper.GetString("nats.subject"), viper.GetString("nats.format"))
+		if err != nil {
+			fatal().Err(err).Str("URL", common.RedactDSN(url)).Msg("could not connect to nats")
+			abortRun()
+		}
+		targets = append(targets, common.RedactDSN(url))
+		publishers = append(publishers, publisher)
+	}
+
+	if len(publishers) == 0 {
+		return func() {}
+	}
+
+	t.SetQuoteHandler(func(quotes []*tiingo.Eod) {
+		for _, publisher := range publishers {
+			publisher.Publish(ctx, quotes)
+		}
+	})
+
+	return func() {
+		t.SetQuoteHandler(nil)
+		for idx, publisher := range publishers {
+			err := publisher.Close()
+			recordWrite(targets[idx], publisher.NumPublished(), err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/penny-vault/import-tiingo/validate"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(qualityCmd)
+
+	rootCmd.PersistentFlags().Bool("quality", false, "score the coverage, stale closes, zero-volume days and validation failures of each asset's quotes and save them to the asset_quality table (see the quality command)")
+	viper.BindPFlag("quality.enabled", rootCmd.PersistentFlags().Lookup("quality"))
+
+	qualityCmd.Flags().Int("limit", 20, "number of assets to list")
+	viper.BindPFlag("quality.limit", qualityCmd.Flags().Lookup("limit"))
+
+	qualityCmd.Flags().Float64("max-score", 100, "only list assets scoring below this value")
+	viper.BindPFlag("quality.max_score", qualityCmd.Flags().Lookup("max-score"))
+}
+
+var qualityCmd = &cobra.Command{
+	Use:         "quality",
+	Short:       "List the assets with the lowest data quality scores in the asset_quality table",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{skipHealthcheck: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		scores, err := storage.WorstAssetQuality(cmd.Context(), readDSN(), viper.GetInt("quality.limit"), viper.GetFloat64("quality.max_score"))
+		if err != nil {
+			fatal().Err(err).Msg("could not list asset quality")
+			abortRun()
+		}
+
+		out := table.NewWriter()
+		out.SetOutputMirror(os.Stdout)
+		out.AppendHeader(table.Row{"Ticker", "Composite FIGI", "Start", "End", "Coverage", "Stale Closes", "Zero Volume", "Validation Failures", "Score"})
+		for _, q := range scores {
+			out.AppendRow(table.Row{
+				q.Ticker, q.CompositeFigi, q.StartDate.Format("2006-01-02"), q.EndDate.Format("2006-01-02"),
+				fmt.Sprintf("%.1f%% (%d/%d)", q.Coverage*100, q.NumQuotes, q.TradingDays),
+				q.StaleCloses, q.ZeroVolumeDays, q.ValidationFailures, fmt.Sprintf("%.1f", q.Score),
+			})
+		}
+		out.Render()
+	},
+}
+
+// saveAssetQuality scores the quotes of each asset and saves the scores to
+// the asset_quality table when --quality is set
+func saveAssetQuality(ctx context.Context, quotes []*tiingo.Eod, issues []*validate.Issue) {
+	if !viper.GetBool("quality.enabled") || len(quotes) == 0 {
+		return
+	}
+
+	scores := validate.Score(quotes, issues)
+	for _, q := range scores[:min(len(scores), 5)] {
+		if q.Score >= 100 {
+			break
+		}
+		log.Info().Str("Ticker", q.Ticker).Float64("Score", q.Score).Float64("Coverage", q.Coverage).
+			Int("StaleCloses", q.StaleCloses).Int("ZeroVolumeDays", q.ZeroVolumeDays).Int("ValidationFailures", q.ValidationFailures).
+			Msg("low quality asset")
+	}
+
+	if url := viper.GetString("database.url"); url != "" && !skipWrite(url, len(scores)) {
+		recordWrite("asset_quality", len(scores), storage.SaveAssetQualityToDatabase(ctx, url, scores))
+	}
+}
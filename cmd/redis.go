@@ -0,0 +1,43 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().String("redis-url", "", "write the latest quote of each asset to redis after the run (e.g. redis://localhost:6379/0)")
+	viper.BindPFlag("redis.url", rootCmd.PersistentFlags().Lookup("redis-url"))
+
+	rootCmd.PersistentFlags().String("redis-prefix", storage.DefaultRedisPrefix, "prefix of the redis hash keys; the composite FIGI is appended")
+	viper.BindPFlag("redis.key_prefix", rootCmd.PersistentFlags().Lookup("redis-prefix"))
+}
+
+// saveLatestQuotes writes the most recent quote of each asset to redis when
+// redis.url is configured
+func saveLatestQuotes(ctx context.Context, quotes []*tiingo.Eod) {
+	url := viper.GetString("redis.url")
+	if url == "" || len(quotes) == 0 || skipWrite(url, len(quotes)) {
+		return
+	}
+	numAssets, err := storage.SaveLatestToRedis(ctx, quotes, url, viper.GetString("redis.key_prefix"))
+	recordWrite(common.RedactDSN(url), numAssets, err)
+}
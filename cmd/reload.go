@@ -0,0 +1,111 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// watchConfigReloads returns a channel that receives the reason for a reload
+// whenever the config file changes or the process receives SIGHUP. viper is
+// not safe for concurrent use, so the config is not re-read here: the
+// receiver calls reloadConfig on the goroutine that reads the settings and
+// takes a fresh snapshot of them. Reloads that arrive while one is pending
+// are coalesced.
+func watchConfigReloads(ctx context.Context) <-chan string {
+	reloads := make(chan string, 1)
+	notify := func(reason string) {
+		select {
+		case reloads <- reason:
+		default:
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	// editors replace the file rather than writing it, so the directory is
+	// watched and events are filtered by name
+	var events chan fsnotify.Event
+	var watchErrors chan error
+	var watcher *fsnotify.Watcher
+	if fn := viper.ConfigFileUsed(); fn != "" {
+		fn = filepath.Clean(fn)
+		var err error
+		watcher, err = fsnotify.NewWatcher()
+		if err == nil {
+			err = watcher.Add(filepath.Dir(fn))
+		}
+		if err != nil {
+			log.Error().Err(err).Str("ConfigFile", fn).Msg("could not watch config file; reload with SIGHUP")
+		} else {
+			events = make(chan fsnotify.Event)
+			watchErrors = watcher.Errors
+			go func() {
+				for event := range watcher.Events {
+					if filepath.Clean(event.Name) == fn && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+						select {
+						case events <- event:
+						case <-ctx.Done():
+							return
+						}
+					}
+				}
+			}()
+		}
+	}
+
+	go func() {
+		defer signal.Stop(hup)
+		if watcher != nil {
+			defer watcher.Close()
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				log.Info().Msg("received SIGHUP; reloading config")
+				notify("SIGHUP")
+			case event := <-events:
+				log.Info().Str("ConfigFile", event.Name).Msg("config file changed")
+				notify("config file changed")
+			case err := <-watchErrors:
+				log.Error().Err(err).Msg("error watching config file")
+			}
+		}
+	}()
+
+	return reloads
+}
+
+// reloadConfig re-reads the config file. It must be called from the only
+// goroutine that reads viper; settings are read once into a snapshot that is
+// handed to other goroutines.
+func reloadConfig() error {
+	if err := viper.ReadInConfig(); err != nil {
+		log.Error().Err(err).Msg("error reloading config file")
+		return err
+	}
+	return nil
+}
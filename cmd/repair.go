@@ -0,0 +1,176 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/penny-vault/import-tiingo/validate"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().Bool("repair", false, "repair single-session price spikes that revert on the next session before quotes are saved")
+	viper.BindPFlag("repair.enabled", rootCmd.PersistentFlags().Lookup("repair"))
+
+	rootCmd.PersistentFlags().Float64("spike-threshold", 0.25, "close-to-close move (fraction) that reverts the next session for a quote to be treated as a spike")
+	viper.BindPFlag("repair.threshold", rootCmd.PersistentFlags().Lookup("spike-threshold"))
+
+	rootCmd.PersistentFlags().Float64("spike-major", 0.5, "spikes moving at least this fraction are major; smaller spikes are minor")
+	viper.BindPFlag("repair.major_threshold", rootCmd.PersistentFlags().Lookup("spike-major"))
+
+	rootCmd.PersistentFlags().String("repair-minor", validate.RepairInterpolate, "policy for minor spikes; one of `"+strings.Join(validate.RepairPolicies, "`, `")+"`")
+	viper.BindPFlag("repair.minor", rootCmd.PersistentFlags().Lookup("repair-minor"))
+
+	rootCmd.PersistentFlags().String("repair-major", validate.RepairRefetch, "policy for major spikes; one of `"+strings.Join(validate.RepairPolicies, "`, `")+"`")
+	viper.BindPFlag("repair.major", rootCmd.PersistentFlags().Lookup("repair-major"))
+}
+
+// repairPolicy returns the configured policy for spikes of the given
+// severity
+func repairPolicy(severity string) string {
+	if severity == validate.SeverityMajor {
+		return viper.GetString("repair.major")
+	}
+	return viper.GetString("repair.minor")
+}
+
+// repairSpikes finds single-session spikes in quotes and interpolates them
+// from the neighbouring sessions, downloads them again from tiingo, flags or
+// skips them according to the policy for their severity. Quotes are repaired
+// in place; a refetched quote that is still a spike is flagged. The
+// adjustment factors were computed from the spiked closes when the quotes were
+// downloaded, so they are recomputed once any quote is repaired.
+func repairSpikes(ctx context.Context, t tiingo.TiingoClient, assets []*common.Asset, quotes []*tiingo.Eod) {
+	if !viper.GetBool("repair.enabled") {
+		return
+	}
+
+	for _, severity := range []string{validate.SeverityMinor, validate.SeverityMajor} {
+		if policy := repairPolicy(severity); !slices.Contains(validate.RepairPolicies, policy) {
+			fatal().Str("Severity", severity).Str("Policy", policy).Strs("Valid", validate.RepairPolicies).Msg("unknown repair policy")
+			abortRun()
+		}
+	}
+
+	threshold := viper.GetFloat64("repair.threshold")
+	spikes := validate.FindSpikes(quotes, threshold, viper.GetFloat64("repair.major_threshold"))
+	if len(spikes) == 0 {
+		return
+	}
+
+	byKey := make(map[string]*common.Asset, len(assets))
+	for _, asset := range assets {
+		byKey[repairKey(asset.CompositeFigi, asset.Ticker)] = asset
+	}
+
+	refetch := []*validate.Spike{}
+	counts := make(map[string]int)
+	for _, spike := range spikes {
+		switch policy := repairPolicy(spike.Severity); policy {
+		case validate.RepairInterpolate:
+			spike.Interpolate()
+			counts[policy]++
+			logSpike(spike, "interpolated price spike from the neighbouring sessions")
+		case validate.RepairRefetch:
+			if _, ok := byKey[repairKey(spike.Quote.CompositeFigi, spike.Quote.Ticker)]; ok {
+				refetch = append(refetch, spike)
+				continue
+			}
+			counts[validate.RepairFlag]++
+			logSpike(spike, "price spike flagged; asset is not known so it cannot be downloaded again")
+		case validate.RepairFlag:
+			counts[policy]++
+			logSpike(spike, "price spike flagged")
+		case validate.RepairNone:
+			counts[policy]++
+			logSpike(spike, "price spike skipped; repair policy is none")
+		}
+	}
+
+	if len(refetch) > 0 {
+		requests := make([]*tiingo.EodRequest, len(refetch))
+		for idx, spike := range refetch {
+			requests[idx] = &tiingo.EodRequest{
+				Asset:     byKey[repairKey(spike.Quote.CompositeFigi, spike.Quote.Ticker)],
+				StartDate: spike.Quote.Date,
+				EndDate:   spike.Quote.Date,
+			}
+		}
+
+		refetched, fetchErrs := t.FetchEodRanges(ctx, requests)
+		checkFetchErrors("repair", len(requests), fetchErrs)
+
+		type quoteKey struct {
+			asset string
+			date  string
+		}
+		refetchedByKey := make(map[quoteKey]*tiingo.Eod, len(refetched))
+		for _, quote := range refetched {
+			refetchedByKey[quoteKey{repairKey(quote.CompositeFigi, quote.Ticker), quote.Date.Format("2006-01-02")}] = quote
+		}
+
+		for _, spike := range refetch {
+			quote, ok := refetchedByKey[quoteKey{repairKey(spike.Quote.CompositeFigi, spike.Quote.Ticker), spike.Quote.Date.Format("2006-01-02")}]
+			if !ok || spike.Persists(quote, threshold) {
+				counts[validate.RepairFlag]++
+				logSpike(spike, "price spike flagged; downloading it again did not fix it")
+				continue
+			}
+			spike.Replace(quote)
+			counts[validate.RepairRefetch]++
+			logSpike(spike, "replaced price spike with a fresh download")
+		}
+	}
+
+	if counts[validate.RepairInterpolate]+counts[validate.RepairRefetch] > 0 {
+		tiingo.ComputeAdjustmentFactors(quotes, adjustOptions())
+	}
+
+	log.Info().
+		Int("NumSpikes", len(spikes)).
+		Int(validate.RepairInterpolate, counts[validate.RepairInterpolate]).
+		Int(validate.RepairRefetch, counts[validate.RepairRefetch]).
+		Int(validate.RepairFlag, counts[validate.RepairFlag]).
+		Int(validate.RepairNone, counts[validate.RepairNone]).
+		Msg("repaired price spikes")
+	if counts[validate.RepairFlag] > 0 {
+		recordAnomaly("%d price spikes were flagged but not repaired", counts[validate.RepairFlag])
+	}
+}
+
+// repairKey identifies the asset of a spike; assets without a composite FIGI
+// (e.g. from a tickers file) are identified by ticker as in FindSpikes
+func repairKey(compositeFigi, ticker string) string {
+	if compositeFigi == "" {
+		return ticker
+	}
+	return compositeFigi
+}
+
+func logSpike(spike *validate.Spike, msg string) {
+	log.Warn().
+		Str("Ticker", spike.Quote.Ticker).
+		Str("CompositeFigi", spike.Quote.CompositeFigi).
+		Str("Date", spike.Quote.Date.Format("2006-01-02")).
+		Str("Severity", spike.Severity).
+		Float64("Move", spike.Move).
+		Msg(msg)
+}
@@ -16,12 +16,22 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/notifications"
+	"github.com/penny-vault/import-tiingo/storage"
 	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/penny-vault/import-tiingo/validate"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -33,50 +43,178 @@ var maxAssets int
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "import-tiingo [tickers]",
-	Short: "Download end-of-day quotes from tiingo",
-	Long:  `Download end-of-day quotes from tiingo and save to penny-vault database`,
+	Use:              "import-tiingo [tickers]",
+	Short:            "Download end-of-day quotes from tiingo",
+	Long:             `Download end-of-day quotes from tiingo and save to penny-vault database`,
+	PersistentPreRun: startHealthcheck,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
-		// validate asset types
-		validatedAssetTypes := getAssetTypes()
+		ctx := cmd.Context()
+		preflight(ctx)
+
+		if viper.GetBool("incremental") {
+			nyc, _ := time.LoadLocation("America/New_York")
+			today := time.Now().In(nyc)
+			if !common.NYSE.IsTradingDay(today) {
+				log.Info().Str("Date", today.Format("2006-01-02")).Msg("market is closed today; nothing to download in incremental mode")
+				return
+			}
+		}
+
+		filter := assetFilter()
+		startDate, endDate := downloadRange()
 
 		log.Info().
-			Strs("asset-types", validatedAssetTypes).
-			Str("History", viper.GetDuration("tiingo.history").String()).
+			Strs("asset-types", filter.AssetTypes).
+			Strs("Exchanges", filter.Exchanges).
+			Float64("MinMarketCap", filter.MinMarketCap).
+			Str("StartDate", startDate.Format("2006-01-02")).
+			Str("EndDate", formatEndDate(endDate)).
 			Msg("loading tickers")
 
-		assets := common.ReadAssetsFromDatabase(validatedAssetTypes)
+		var assets []*common.Asset
+		if viper.GetString("tickers_file") != "" {
+			assets = readTickersFile(ctx)
+		} else {
+			assets = common.ReadAssetsFromDatabase(ctx, readDSN(), filter)
+			assets = filterOTCAssets(assets)
+		}
+		if tags := viper.GetString("tags"); tags != "" {
+			var err error
+			assets, err = common.FilterAssetsByTags(ctx, readDSN(), assets, tags)
+			if err != nil {
+				fatal().Err(err).Str("Tags", tags).Msg("could not filter assets by tags")
+				abortRun()
+			}
+		}
 		if maxAssets > 0 {
 			assets = assets[:maxAssets]
 		}
 
 		log.Info().Int("NumAssets", len(assets)).Msg("downloading assets")
 
-		t := tiingo.New(viper.GetString("tiingo.token"), viper.GetInt("tiingo.rate_limit"))
-		startDate := time.Now().Add(viper.GetDuration("tiingo.history") * -1)
-		quotes := t.FetchEodQuotes(assets, startDate)
+		t := tiingoClient()
 
-		if viper.GetString("parquet_file") != "" {
-			tiingo.SaveToParquet(quotes, viper.GetString("parquet_file"))
+		checkpoint := openCheckpoint(startDate, endDate)
+		if checkpoint != nil {
+			startDate, endDate = checkpoint.StartDate, checkpoint.EndDate
+			t.SetCheckpoint(checkpoint)
+			defer checkpoint.Close()
 		}
 
-		if viper.GetString("database.url") != "" {
-			tiingo.SaveToDatabase(quotes)
+		startDates := loadStartDates(ctx, assets)
+		finishPublishing := startPublishing(ctx, t, len(assets))
+		quotes, fetchErrs := fetchQuotes(ctx, t, assets, startDate, endDate, startDates)
+		finishPublishing()
+		checkFetchErrors("download", len(assets), fetchErrs)
+		runStats.NumQuotes = len(quotes)
+		exitIfCancelled(ctx)
+		if len(quotes) == 0 && len(assets) > 0 {
+			recordAnomaly("no quotes were downloaded for %d assets", len(assets))
+		}
+
+		if url := viper.GetString("database.url"); url != "" && !common.IsSQLiteDSN(url) && !common.IsClickHouseDSN(url) && !skipWrite(url, len(assets)) {
+			checkSaveError(storage.UpdateAssetLifecycle(ctx, viper.GetString("database.url"), assets, quotes, t.MissingTickers(fetchErrs)))
+		}
+		printMetricsReport(t.Metrics())
+		repairSpikes(ctx, t, assets, quotes)
+
+		manifest := common.NewManifest()
+		exportQuotes(ctx, quotes, manifest)
+
+		validQuotes, issues := validateQuotes(quotes)
+		saveQuotesToDatabase(ctx, validQuotes)
+		saveAssetQuality(ctx, quotes, issues)
+		saveCorporateActions(ctx, validQuotes)
+		saveLatestQuotes(ctx, validQuotes)
+
+		if fn := viper.GetString("duckdb"); fn != "" && !skipWrite(fn, len(validQuotes)) {
+			recordWrite(fn, len(validQuotes), storage.SaveToDuckDB(ctx, validQuotes, fn, adjustOptions()))
+		}
+
+		if viper.GetBool("fundamentals.enabled") {
+			fundamentalsStartDate := time.Now().Add(viper.GetDuration("fundamentals.history") * -1)
+			if viper.GetString("tiingo.start_date") != "" {
+				fundamentalsStartDate = startDate
+			}
+			fundamentals, fetchErrs := t.FetchFundamentals(ctx, assets, fundamentalsStartDate, endDate)
+			checkFetchErrors("fundamentals", len(assets), fetchErrs)
+			exitIfCancelled(ctx)
+
+			if fn := viper.GetString("fundamentals.parquet_file"); fn != "" && !skipWrite(fn, len(fundamentals)) {
+				err := storage.SaveFundamentalsToParquet(ctx, fundamentals, fn, storageOptions())
+				recordWrite(fn, len(fundamentals), err)
+				if err == nil {
+					manifest.AddFile(fn, len(fundamentals))
+				}
+			}
+
+			if url := viper.GetString("database.url"); url != "" && !skipWrite(url, len(fundamentals)) {
+				recordWrite("fundamentals", len(fundamentals), storage.SaveFundamentalsToDatabase(ctx, viper.GetString("database.url"), fundamentals))
+			}
+		}
+
+		if fn := viper.GetString("manifest_file"); fn != "" && !skipWrite(fn, len(manifest.Files)) {
+			manifest.Write(fn)
+		}
+
+		// the checkpoint is no longer needed once results are saved; keep it
+		// when tickers were skipped at the deadline so the run can be resumed
+		if checkpoint != nil && !runFailed && len(runStats.SkippedByDeadline) == 0 {
+			checkpoint.Remove()
 		}
 	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// SIGINT and SIGTERM cancel the context passed to commands so in-flight
+// requests and database transactions are aborted cleanly.
 func Execute() {
-	err := rootCmd.Execute()
-	if err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	cmd, err := executeCommand(ctx)
+	if ctx.Err() != nil && (err == nil || errors.Is(err, errRunAborted)) {
+		err = ctx.Err()
+	}
+	stop()
+	finishRunStats(cmd.Name(), err)
+	finishRunLedger()
+	finishHealthcheck()
+	notifyRun()
+	writeRunSummary()
+	if err != nil || runFailed {
 		os.Exit(1)
 	}
 }
 
+// executeCommand runs the command line; a command stopped by abortRun
+// returns errRunAborted with the reason that was logged
+func executeCommand(ctx context.Context) (cmd *cobra.Command, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runAbort); !ok {
+				panic(r)
+			}
+			cmd, _, _ = rootCmd.Find(os.Args[1:])
+			if cmd == nil {
+				cmd = rootCmd
+			}
+			err = fmt.Errorf("%w: %s", errRunAborted, abortReason)
+		}
+	}()
+	return rootCmd.ExecuteContextC(ctx)
+}
+
+// exitIfCancelled stops the run when it was interrupted so that partial
+// results are not saved
+func exitIfCancelled(ctx context.Context) {
+	if err := ctx.Err(); err != nil {
+		fatal().Err(err).Msg("run interrupted; partial results were not saved")
+		abortRun()
+	}
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 	cobra.OnInitialize(initLog)
@@ -89,35 +227,368 @@ func init() {
 	rootCmd.PersistentFlags().Bool("log.json", false, "print logs as json to stderr")
 	viper.BindPFlag("log.json", rootCmd.PersistentFlags().Lookup("log.json"))
 
-	rootCmd.PersistentFlags().StringP("tiingo-token", "t", "<not-set>", "tiingo API key token")
+	rootCmd.PersistentFlags().StringSlice("validate", validate.AllRules, "validation rules applied to quotes before they are saved")
+	viper.BindPFlag("validate.rules", rootCmd.PersistentFlags().Lookup("validate"))
+
+	rootCmd.PersistentFlags().Float64("max-move", 0.5, "largest single-day close-to-close move (fraction) allowed without a split by the large_move rule")
+	viper.BindPFlag("validate.max_move", rootCmd.PersistentFlags().Lookup("max-move"))
+
+	rootCmd.PersistentFlags().Bool("strict", false, "do not save quotes that fail validation to the database")
+	viper.BindPFlag("validate.strict", rootCmd.PersistentFlags().Lookup("strict"))
+
+	rootCmd.PersistentFlags().String("validation-report", "", "write validation issues to a CSV file")
+	viper.BindPFlag("validate.report_file", rootCmd.PersistentFlags().Lookup("validation-report"))
+
+	rootCmd.PersistentFlags().Bool("corporate-actions", false, "also upsert dividends and splits into the corporate_actions table")
+	viper.BindPFlag("corporate_actions.enabled", rootCmd.PersistentFlags().Lookup("corporate-actions"))
+
+	rootCmd.PersistentFlags().String("cache-dir", "", "cache tiingo responses in this directory (default no caching)")
+	viper.BindPFlag("cache.dir", rootCmd.PersistentFlags().Lookup("cache-dir"))
+
+	rootCmd.PersistentFlags().Duration("cache-ttl", 12*time.Hour, "how long cached responses are used (0 for no expiry)")
+	viper.BindPFlag("cache.ttl", rootCmd.PersistentFlags().Lookup("cache-ttl"))
+
+	rootCmd.PersistentFlags().String("record", "", "archive raw tiingo responses to this directory or s3:// URI")
+	viper.BindPFlag("record.dir", rootCmd.PersistentFlags().Lookup("record"))
+
+	rootCmd.PersistentFlags().String("replay", "", "read tiingo responses from a directory written by --record instead of the API")
+	viper.BindPFlag("replay.dir", rootCmd.PersistentFlags().Lookup("replay"))
+
+	rootCmd.PersistentFlags().Bool("dry-run", false, "download data but skip all database and file writes; print a summary of the rows that would be written")
+	viper.BindPFlag("dry_run", rootCmd.PersistentFlags().Lookup("dry-run"))
+
+	rootCmd.PersistentFlags().String("healthcheck-url", "", "healthchecks.io style URL pinged when a run starts (/start), succeeds, or fails (/fail)")
+	viper.BindPFlag("healthcheck.url", rootCmd.PersistentFlags().Lookup("healthcheck-url"))
+
+	rootCmd.PersistentFlags().String("summary-file", "", "write a JSON summary of the run (tickers, failures, rows written, API calls) to this file; - writes to stdout")
+	viper.BindPFlag("summary.file", rootCmd.PersistentFlags().Lookup("summary-file"))
+
+	rootCmd.PersistentFlags().String("slack-webhook", "", "Slack incoming webhook URL that receives a summary of each run")
+	viper.BindPFlag("notify.slack_url", rootCmd.PersistentFlags().Lookup("slack-webhook"))
+
+	rootCmd.PersistentFlags().String("discord-webhook", "", "Discord webhook URL that receives a summary of each run")
+	viper.BindPFlag("notify.discord_url", rootCmd.PersistentFlags().Lookup("discord-webhook"))
+
+	rootCmd.PersistentFlags().String("notify-webhook", "", "URL that receives the run summary as JSON")
+	viper.BindPFlag("notify.webhook_url", rootCmd.PersistentFlags().Lookup("notify-webhook"))
+
+	rootCmd.PersistentFlags().StringSlice("notify-on", []string{notifications.EventSuccess, notifications.EventFailure, notifications.EventAnomaly}, "run outcomes that trigger webhook notifications (success, failure, anomaly)")
+	viper.BindPFlag("notify.events", rootCmd.PersistentFlags().Lookup("notify-on"))
+
+	rootCmd.PersistentFlags().String("run-id", "", "correlation ID for this run (default is a random UUID)")
+	viper.BindPFlag("run_id", rootCmd.PersistentFlags().Lookup("run-id"))
+
+	rootCmd.PersistentFlags().StringP("tiingo-token", "t", "<not-set>", "tiingo API key token (or a vault:// or awssm:// secret reference)")
 	viper.BindPFlag("tiingo.token", rootCmd.PersistentFlags().Lookup("tiingo-token"))
 
-	rootCmd.PersistentFlags().StringP("database-url", "d", "host=localhost port=5432", "DSN for database connection")
+	rootCmd.PersistentFlags().StringSlice("tiingo-tokens", []string{}, "additional tiingo API key tokens; requests rotate among all tokens, each with its own rate limit, budgets and quota")
+	viper.BindPFlag("tiingo.tokens", rootCmd.PersistentFlags().Lookup("tiingo-tokens"))
+
+	rootCmd.PersistentFlags().String("tiingo-base-url", tiingo.DefaultBaseURL, "root URL of the tiingo API, e.g. a proxy or mock server")
+	viper.BindPFlag("tiingo.base_url", rootCmd.PersistentFlags().Lookup("tiingo-base-url"))
+
+	rootCmd.PersistentFlags().String("tiingo-proxy", "", "URL of the HTTP(S) or SOCKS5 proxy used to reach tiingo (default $HTTPS_PROXY)")
+	viper.BindPFlag("tiingo.proxy", rootCmd.PersistentFlags().Lookup("tiingo-proxy"))
+
+	rootCmd.PersistentFlags().String("tiingo-ca-bundle", "", "PEM file of certificate authorities trusted for tiingo connections in addition to the system pool")
+	viper.BindPFlag("tiingo.ca_bundle", rootCmd.PersistentFlags().Lookup("tiingo-ca-bundle"))
+
+	rootCmd.PersistentFlags().String("tiingo-tls-min-version", "", "minimum TLS version of tiingo connections; one of "+strings.Join(common.TLSVersionNames(), ", "))
+	viper.BindPFlag("tiingo.tls_min_version", rootCmd.PersistentFlags().Lookup("tiingo-tls-min-version"))
+
+	rootCmd.PersistentFlags().StringP("database-url", "d", "host=localhost port=5432", "DSN for database connection (postgres, sqlite:///path/to/file.db, or clickhouse://host:9000/db for quotes only)")
 	viper.BindPFlag("database.url", rootCmd.PersistentFlags().Lookup("database-url"))
 
-	rootCmd.PersistentFlags().Duration("history", 24*7*time.Hour, "amount of history to download")
+	rootCmd.PersistentFlags().String("tiingo-token-file", "", "read the tiingo API key token from a file")
+	viper.BindPFlag("tiingo.token_file", rootCmd.PersistentFlags().Lookup("tiingo-token-file"))
+
+	rootCmd.PersistentFlags().String("database-url-file", "", "read the DSN for the database connection from a file")
+	viper.BindPFlag("database.url_file", rootCmd.PersistentFlags().Lookup("database-url-file"))
+
+	rootCmd.PersistentFlags().String("vault-addr", "", "address of the HashiCorp Vault server used to resolve vault:// settings (default $VAULT_ADDR)")
+	viper.BindPFlag("vault.address", rootCmd.PersistentFlags().Lookup("vault-addr"))
+
+	rootCmd.PersistentFlags().String("database-read-url", "", "DSN used for reading the asset universe, e.g. a replica (default is database-url)")
+	viper.BindPFlag("database.read_url", rootCmd.PersistentFlags().Lookup("database-read-url"))
+
+	rootCmd.PersistentFlags().StringSlice("database-targets", []string{}, "additional DSNs that quotes are written to in parallel with database-url")
+	viper.BindPFlag("database.targets", rootCmd.PersistentFlags().Lookup("database-targets"))
+
+	rootCmd.PersistentFlags().Bool("openfigi", true, "look up missing composite FIGIs with OpenFIGI")
+	viper.BindPFlag("openfigi.enabled", rootCmd.PersistentFlags().Lookup("openfigi"))
+
+	rootCmd.PersistentFlags().String("openfigi-api-key", "", "OpenFIGI API key (optional; raises the rate limit)")
+	viper.BindPFlag("openfigi.api_key", rootCmd.PersistentFlags().Lookup("openfigi-api-key"))
+
+	rootCmd.PersistentFlags().String("openfigi-api-key-file", "", "read the OpenFIGI API key from a file")
+	viper.BindPFlag("openfigi.api_key_file", rootCmd.PersistentFlags().Lookup("openfigi-api-key-file"))
+
+	rootCmd.PersistentFlags().Int("openfigi-rate-limit", 0, "OpenFIGI rate limit (requests per minute; 0 uses the published limit)")
+	viper.BindPFlag("openfigi.rate_limit", rootCmd.PersistentFlags().Lookup("openfigi-rate-limit"))
+
+	rootCmd.PersistentFlags().Duration("history", 24*7*time.Hour, "amount of history to download (ignored when --start is given)")
 	viper.BindPFlag("tiingo.history", rootCmd.PersistentFlags().Lookup("history"))
 
+	rootCmd.PersistentFlags().String("start", "", "first date to download (YYYY-MM-DD or RFC3339); defaults to now minus --history")
+	viper.BindPFlag("tiingo.start_date", rootCmd.PersistentFlags().Lookup("start"))
+
+	rootCmd.PersistentFlags().String("frequency", tiingo.FrequencyDaily, "bar frequency of downloaded eod quotes; one of `"+strings.Join(tiingo.Frequencies, "`, `")+"`; resampled bars are stored in eod_<frequency>")
+	viper.BindPFlag("tiingo.frequency", rootCmd.PersistentFlags().Lookup("frequency"))
+
+	rootCmd.PersistentFlags().StringSlice("columns", []string{}, "only download these eod columns to reduce transfer size, e.g. `close,volume`; one of "+strings.Join(tiingo.EodColumns, ", ")+" (default all)")
+	viper.BindPFlag("tiingo.columns", rootCmd.PersistentFlags().Lookup("columns"))
+
+	rootCmd.PersistentFlags().Bool("full-history", false, "download each ticker's entire history starting at the first date reported by tiingo's meta endpoint; combine with --checkpoint-file to resume an interrupted load")
+	viper.BindPFlag("tiingo.full_history", rootCmd.PersistentFlags().Lookup("full-history"))
+
+	rootCmd.PersistentFlags().Duration("full-history-chunk", tiingo.DefaultFullHistoryChunk, "length of each request made by --full-history")
+	viper.BindPFlag("tiingo.full_history_chunk", rootCmd.PersistentFlags().Lookup("full-history-chunk"))
+
+	rootCmd.PersistentFlags().String("end", "", "last date to download (YYYY-MM-DD or RFC3339); defaults to the most recent quote")
+	viper.BindPFlag("tiingo.end_date", rootCmd.PersistentFlags().Lookup("end"))
+
 	rootCmd.PersistentFlags().Int("tiingo-rate-limit", 5, "tiingo rate limit (items per second)")
 	viper.BindPFlag("tiingo.rate_limit", rootCmd.PersistentFlags().Lookup("tiingo-rate-limit"))
 
+	rootCmd.PersistentFlags().Bool("adaptive-rate-limit", false, "halve the tiingo rate limit when tiingo responds with 429 Too Many Requests and ramp back up to --tiingo-rate-limit after requests succeed")
+	viper.BindPFlag("tiingo.adaptive_rate_limit", rootCmd.PersistentFlags().Lookup("adaptive-rate-limit"))
+
+	rootCmd.PersistentFlags().Int("min-rate-limit", 1, "lowest rate (items per second) --adaptive-rate-limit backs off to")
+	viper.BindPFlag("tiingo.min_rate_limit", rootCmd.PersistentFlags().Lookup("min-rate-limit"))
+
+	rootCmd.PersistentFlags().String("tiingo-plan", "", "tiingo subscription tier whose hourly and daily request limits are enforced; one of "+strings.Join(tiingo.PlanNames(), ", ")+" (default unlimited)")
+	viper.BindPFlag("tiingo.plan", rootCmd.PersistentFlags().Lookup("tiingo-plan"))
+
+	rootCmd.PersistentFlags().Int("hourly-budget", 0, "maximum number of tiingo requests per hour; overrides the --tiingo-plan limit (0 uses the plan limit)")
+	viper.BindPFlag("tiingo.hourly_budget", rootCmd.PersistentFlags().Lookup("hourly-budget"))
+
+	rootCmd.PersistentFlags().Int("daily-budget", 0, "maximum number of tiingo requests per day; overrides the --tiingo-plan limit (0 uses the plan limit)")
+	viper.BindPFlag("tiingo.daily_budget", rootCmd.PersistentFlags().Lookup("daily-budget"))
+
+	rootCmd.PersistentFlags().Bool("log-requests", false, "log every request sent to tiingo with its status, size, latency and the remaining API quota")
+	viper.BindPFlag("tiingo.log_requests", rootCmd.PersistentFlags().Lookup("log-requests"))
+
+	rootCmd.PersistentFlags().IntSlice("quota-warn-thresholds", []int{25, 10, 5}, "warn when the remaining tiingo API quota falls to each of these percentages of the limit")
+	viper.BindPFlag("quota.warn_thresholds", rootCmd.PersistentFlags().Lookup("quota-warn-thresholds"))
+
+	rootCmd.PersistentFlags().Int("quota-pause-below", 0, "pause requests until the tiingo API quota resets once the remaining quota falls to this many requests (0 to disable)")
+	viper.BindPFlag("quota.pause_below", rootCmd.PersistentFlags().Lookup("quota-pause-below"))
+
+	rootCmd.PersistentFlags().Duration("quota-pause-duration", time.Hour, "how long --quota-pause-below pauses when tiingo does not report when the quota resets")
+	viper.BindPFlag("quota.pause_duration", rootCmd.PersistentFlags().Lookup("quota-pause-duration"))
+
+	rootCmd.PersistentFlags().Int("workers", 10, "number of concurrent download workers")
+	viper.BindPFlag("tiingo.workers", rootCmd.PersistentFlags().Lookup("workers"))
+
+	rootCmd.PersistentFlags().String("timestamp-policy", "warn", "handling of bars dated in the future or before the requested window; one of `warn`, `drop` or `fail`")
+	viper.BindPFlag("tiingo.timestamp_policy", rootCmd.PersistentFlags().Lookup("timestamp-policy"))
+
+	rootCmd.PersistentFlags().Duration("timestamp-tolerance", 24*time.Hour, "allowed clock skew before a bar timestamp is considered invalid")
+	viper.BindPFlag("tiingo.timestamp_tolerance", rootCmd.PersistentFlags().Lookup("timestamp-tolerance"))
+
+	rootCmd.PersistentFlags().Bool("trim-zero-volume", false, "remove leading and trailing zero-volume padding bars from each ticker")
+	viper.BindPFlag("tiingo.trim_zero_volume", rootCmd.PersistentFlags().Lookup("trim-zero-volume"))
+
+	rootCmd.PersistentFlags().Bool("adjusted-volume", false, "compute split-adjusted volume as an additional column")
+	viper.BindPFlag("tiingo.adjusted_volume", rootCmd.PersistentFlags().Lookup("adjusted-volume"))
+
+	rootCmd.PersistentFlags().String("adjusted-prices", tiingo.AdjustNone, "store split and dividend adjusted open/high/low/close/volume; one of `none`, `tiingo` (use tiingo's adjusted values) or `local` (back-adjust from split factor and dividends)")
+	viper.BindPFlag("tiingo.adjusted_prices", rootCmd.PersistentFlags().Lookup("adjusted-prices"))
+
 	rootCmd.PersistentFlags().String("parquet-file", "", "save results to parquet")
 	viper.BindPFlag("parquet_file", rootCmd.PersistentFlags().Lookup("parquet-file"))
 
+	rootCmd.PersistentFlags().Bool("parquet-merge", false, "merge downloaded quotes into an existing parquet file instead of overwriting it; quotes for the same ticker and date are replaced")
+	viper.BindPFlag("parquet.merge", rootCmd.PersistentFlags().Lookup("parquet-merge"))
+
+	rootCmd.PersistentFlags().String("parquet-compression", "gzip", "parquet compression codec; one of `none`, `snappy`, `gzip` or `zstd`")
+	viper.BindPFlag("parquet.compression", rootCmd.PersistentFlags().Lookup("parquet-compression"))
+
+	rootCmd.PersistentFlags().String("parquet-row-group-size", "128MB", "target size of each parquet row group")
+	viper.BindPFlag("parquet.row_group_size", rootCmd.PersistentFlags().Lookup("parquet-row-group-size"))
+
+	rootCmd.PersistentFlags().String("parquet-page-size", "8KB", "target size of each parquet page")
+	viper.BindPFlag("parquet.page_size", rootCmd.PersistentFlags().Lookup("parquet-page-size"))
+
+	rootCmd.PersistentFlags().String("parquet-partition", storage.PartitionNone, "write parquet output as a Hive-style partitioned directory; one of `year`, `month` or `day` (e.g. event_date=2023-01-02/part-0.parquet)")
+	viper.BindPFlag("parquet.partition", rootCmd.PersistentFlags().Lookup("parquet-partition"))
+
+	rootCmd.PersistentFlags().String("csv-file", "", "save results to CSV")
+	viper.BindPFlag("csv_file", rootCmd.PersistentFlags().Lookup("csv-file"))
+
+	rootCmd.PersistentFlags().String("jsonl-file", "", "save results as JSON lines")
+	viper.BindPFlag("jsonl_file", rootCmd.PersistentFlags().Lookup("jsonl-file"))
+
+	rootCmd.PersistentFlags().String("arrow-file", "", "save results to an Arrow IPC (feather) file")
+	viper.BindPFlag("arrow_file", rootCmd.PersistentFlags().Lookup("arrow-file"))
+
+	rootCmd.PersistentFlags().String("duckdb", "", "upsert results into the eod table of a DuckDB database file")
+	viper.BindPFlag("duckdb", rootCmd.PersistentFlags().Lookup("duckdb"))
+
+	rootCmd.PersistentFlags().Bool("export-gzip", false, "gzip compress CSV and JSON lines output (always on for files ending in .gz)")
+	viper.BindPFlag("export.gzip", rootCmd.PersistentFlags().Lookup("export-gzip"))
+
+	rootCmd.PersistentFlags().String("s3-uri", "", "also save results to S3-compatible storage, e.g. `s3://bucket/prefix/eod-{date}.parquet` ({date} and {run_id} are expanded)")
+	viper.BindPFlag("s3.uri", rootCmd.PersistentFlags().Lookup("s3-uri"))
+
+	rootCmd.PersistentFlags().String("s3-endpoint", "", "S3 endpoint URL for MinIO or other S3-compatible stores (default AWS)")
+	viper.BindPFlag("s3.endpoint", rootCmd.PersistentFlags().Lookup("s3-endpoint"))
+
+	rootCmd.PersistentFlags().String("s3-region", "us-east-1", "S3 region")
+	viper.BindPFlag("s3.region", rootCmd.PersistentFlags().Lookup("s3-region"))
+
+	rootCmd.PersistentFlags().Bool("s3-path-style", false, "use path-style S3 addressing (required by most MinIO deployments)")
+	viper.BindPFlag("s3.path_style", rootCmd.PersistentFlags().Lookup("s3-path-style"))
+
+	rootCmd.PersistentFlags().String("s3-acl", "private", "canned ACL applied to uploaded objects")
+	viper.BindPFlag("s3.acl", rootCmd.PersistentFlags().Lookup("s3-acl"))
+
+	rootCmd.PersistentFlags().Bool("fundamentals", false, "also download quarterly and annual financial statements")
+	viper.BindPFlag("fundamentals.enabled", rootCmd.PersistentFlags().Lookup("fundamentals"))
+
+	rootCmd.PersistentFlags().Duration("fundamentals-history", 365*24*time.Hour, "amount of financial statement history to download")
+	viper.BindPFlag("fundamentals.history", rootCmd.PersistentFlags().Lookup("fundamentals-history"))
+
+	rootCmd.PersistentFlags().String("fundamentals-parquet-file", "", "save financial statements to parquet")
+	viper.BindPFlag("fundamentals.parquet_file", rootCmd.PersistentFlags().Lookup("fundamentals-parquet-file"))
+
+	rootCmd.PersistentFlags().String("manifest-file", "", "write a JSON manifest with SHA-256 checksums and row counts of generated files")
+	viper.BindPFlag("manifest_file", rootCmd.PersistentFlags().Lookup("manifest-file"))
+
 	rootCmd.PersistentFlags().Bool("hide-progress", false, "hide progress bar")
 	viper.BindPFlag("display.hide_progress", rootCmd.PersistentFlags().Lookup("hide-progress"))
 
+	rootCmd.PersistentFlags().String("progress", common.ProgressBar, "progress display; one of `bar`, `json` (single-line JSON events on stdout) or `none`")
+	viper.BindPFlag("display.progress", rootCmd.PersistentFlags().Lookup("progress"))
+
+	rootCmd.PersistentFlags().String("progress-url", "", "URL that receives periodic POSTs with run progress")
+	viper.BindPFlag("display.progress_url", rootCmd.PersistentFlags().Lookup("progress-url"))
+
+	rootCmd.PersistentFlags().Duration("progress-interval", 10*time.Second, "how often progress is posted to the progress URL")
+	viper.BindPFlag("display.progress_interval", rootCmd.PersistentFlags().Lookup("progress-interval"))
+
+	rootCmd.PersistentFlags().Bool("metrics-report", false, "print per-ticker request metrics (response size, latency, bars, retries, errors) after downloading")
+	viper.BindPFlag("display.metrics_report", rootCmd.PersistentFlags().Lookup("metrics-report"))
+
+	rootCmd.PersistentFlags().String("metrics-sort", "latency", "sort column for the metrics report; one of `ticker`, `status`, `bytes`, `latency`, `bars` or `retries`")
+	viper.BindPFlag("display.metrics_sort", rootCmd.PersistentFlags().Lookup("metrics-sort"))
+
 	rootCmd.PersistentFlags().StringSlice("asset-types", []string{"Common Stock", "Preferred Stock", "Exchange Traded Fund", "Exchange Traded Note", "Mutual Fund", "Closed-End Fund", "American Depository Receipt Common"}, "List of asset types to include in download. Valid values include: `Common Stock`, `Preferred Stock`, `Exchange Traded Fund`, `Exchange Traded Note`, `Mutual Fund`, `Closed-End Fund`, `American Depository Receipt Common`")
 	viper.BindPFlag("asset_types", rootCmd.PersistentFlags().Lookup("asset-types"))
 
+	rootCmd.PersistentFlags().StringSlice("exchange", []string{}, "only download assets whose primary exchange is one of the given exchanges, e.g. `NYSE,NASDAQ`")
+	viper.BindPFlag("filter.exchanges", rootCmd.PersistentFlags().Lookup("exchange"))
+
+	rootCmd.PersistentFlags().Float64("min-market-cap", 0, "only download assets with at least this market cap in dollars (requires fundamentals; assets without one such as ETFs are skipped)")
+	viper.BindPFlag("filter.min_market_cap", rootCmd.PersistentFlags().Lookup("min-market-cap"))
+
+	rootCmd.PersistentFlags().StringSlice("include-tickers", []string{}, "tickers that are always downloaded even if removed by the other filters")
+	viper.BindPFlag("filter.include", rootCmd.PersistentFlags().Lookup("include-tickers"))
+
+	rootCmd.PersistentFlags().StringSlice("exclude-tickers", []string{}, "tickers that are never downloaded")
+	viper.BindPFlag("filter.exclude", rootCmd.PersistentFlags().Lookup("exclude-tickers"))
+
+	rootCmd.PersistentFlags().StringSlice("ticker-pattern", []string{}, "only download tickers matching one of the regular expressions, e.g. `^[A-Z]{1,4}$`")
+	viper.BindPFlag("filter.patterns", rootCmd.PersistentFlags().Lookup("ticker-pattern"))
+
+	rootCmd.PersistentFlags().StringSlice("exclude-pattern", []string{}, "skip tickers matching any of the regular expressions, e.g. `\\.(WS|U)$`")
+	viper.BindPFlag("filter.exclude_patterns", rootCmd.PersistentFlags().Lookup("exclude-pattern"))
+
+	rootCmd.PersistentFlags().String("tags", "", "select assets by tag expression, e.g. `sp500 AND NOT financials`")
+	viper.BindPFlag("tags", rootCmd.PersistentFlags().Lookup("tags"))
+
+	rootCmd.PersistentFlags().String("otc", "allow", "policy for OTC / pink-sheet tickers; one of `allow` or `block`")
+	viper.BindPFlag("otc.policy", rootCmd.PersistentFlags().Lookup("otc"))
+
+	rootCmd.PersistentFlags().StringSlice("otc-allow", []string{}, "OTC tickers to download even when the OTC policy is `block`")
+	viper.BindPFlag("otc.allow_list", rootCmd.PersistentFlags().Lookup("otc-allow"))
+
+	rootCmd.PersistentFlags().Int("otc-rate-limit", 0, "additional rate limit applied to OTC tickers (items per second; 0 to disable)")
+	viper.BindPFlag("otc.rate_limit", rootCmd.PersistentFlags().Lookup("otc-rate-limit"))
+
+	rootCmd.PersistentFlags().Float64("otc-min-price", 0, "skip OTC quotes with a close below this price")
+	viper.BindPFlag("otc.min_price", rootCmd.PersistentFlags().Lookup("otc-min-price"))
+
+	rootCmd.PersistentFlags().Int64("otc-min-volume", 0, "skip OTC quotes with volume below this value")
+	viper.BindPFlag("otc.min_volume", rootCmd.PersistentFlags().Lookup("otc-min-volume"))
+
+	rootCmd.PersistentFlags().Bool("incremental", false, "incremental daily run; exits without downloading when the market is closed today")
+	viper.BindPFlag("incremental", rootCmd.PersistentFlags().Lookup("incremental"))
+
+	rootCmd.PersistentFlags().Bool("start-from-db", false, "start each asset's download at its most recent quote in the database instead of the history window")
+	viper.BindPFlag("tiingo.start_from_db", rootCmd.PersistentFlags().Lookup("start-from-db"))
+
+	rootCmd.PersistentFlags().String("start-dates-file", "", "CSV file mapping ticker to start date (TICKER,YYYY-MM-DD); overrides history for listed tickers")
+	viper.BindPFlag("tiingo.start_dates_file", rootCmd.PersistentFlags().Lookup("start-dates-file"))
+
+	rootCmd.PersistentFlags().Float64("failure-threshold", 0.05, "fraction of tickers that may fail to download before the run exits non-zero")
+	viper.BindPFlag("failure_threshold", rootCmd.PersistentFlags().Lookup("failure-threshold"))
+
+	rootCmd.PersistentFlags().Duration("request-timeout", time.Minute, "give up on a tiingo request that has not completed in this time (0 waits forever)")
+	viper.BindPFlag("tiingo.request_timeout", rootCmd.PersistentFlags().Lookup("request-timeout"))
+
+	rootCmd.PersistentFlags().Int("max-retries", 3, "number of times a tiingo request that was rate limited (429) or failed with a server error is retried (0 disables retries)")
+	viper.BindPFlag("tiingo.max_retries", rootCmd.PersistentFlags().Lookup("max-retries"))
+
+	rootCmd.PersistentFlags().Duration("max-retry-wait", time.Minute, "longest time to wait before retrying a tiingo request; Retry-After headers are honoured up to this limit")
+	viper.BindPFlag("tiingo.max_retry_wait", rootCmd.PersistentFlags().Lookup("max-retry-wait"))
+
+	rootCmd.PersistentFlags().Duration("max-runtime", 0, "stop downloading once the run has taken this long; tickers not yet downloaded are skipped and reported, and the results so far are saved (0 is unlimited)")
+	viper.BindPFlag("max_runtime", rootCmd.PersistentFlags().Lookup("max-runtime"))
+
+	rootCmd.PersistentFlags().String("checkpoint-file", "", "journal completed downloads to this file so an interrupted run can be resumed")
+	viper.BindPFlag("checkpoint.file", rootCmd.PersistentFlags().Lookup("checkpoint-file"))
+
+	rootCmd.PersistentFlags().Bool("resume", false, "resume an interrupted run from the checkpoint file, skipping assets already downloaded")
+	viper.BindPFlag("checkpoint.resume", rootCmd.PersistentFlags().Lookup("resume"))
+
+	rootCmd.PersistentFlags().Int("db-batch-size", 10000, "number of quotes written to the database per COPY batch")
+	viper.BindPFlag("database.batch_size", rootCmd.PersistentFlags().Lookup("db-batch-size"))
+
+	rootCmd.PersistentFlags().Int("db-writers", 4, "number of concurrent database connections used to write quotes")
+	viper.BindPFlag("database.writers", rootCmd.PersistentFlags().Lookup("db-writers"))
+
+	rootCmd.PersistentFlags().Bool("atomic", false, "write all quotes to each database in a single transaction that is rolled back on error")
+	viper.BindPFlag("database.atomic", rootCmd.PersistentFlags().Lookup("atomic"))
+
+	rootCmd.PersistentFlags().Bool("timescale", false, "create eod as a TimescaleDB hypertable partitioned by event_date and write chunk-aligned batches")
+	viper.BindPFlag("database.timescale", rootCmd.PersistentFlags().Lookup("timescale"))
+
+	rootCmd.PersistentFlags().Duration("timescale-chunk", 365*24*time.Hour, "time range covered by each eod hypertable chunk")
+	viper.BindPFlag("database.timescale_chunk_interval", rootCmd.PersistentFlags().Lookup("timescale-chunk"))
+
+	rootCmd.PersistentFlags().Bool("track-corrections", false, "record prior values of bars changed by a re-import in the eod_history table")
+	viper.BindPFlag("database.track_corrections", rootCmd.PersistentFlags().Lookup("track-corrections"))
+
+	rootCmd.PersistentFlags().Bool("track-revisions", false, "record the old and new close and volume of stored bars restated by tiingo in the eod_revisions table")
+	viper.BindPFlag("database.track_revisions", rootCmd.PersistentFlags().Lookup("track-revisions"))
+
+	rootCmd.PersistentFlags().Float64("revision-threshold", 0.001, "smallest relative change of close or volume recorded by --track-revisions")
+	viper.BindPFlag("database.revision_threshold", rootCmd.PersistentFlags().Lookup("revision-threshold"))
+
 	// local
 	rootCmd.Flags().IntVar(&maxAssets, "max", -1, "maximum assets to download")
 }
 
 func initLog() {
+	if runID := viper.GetString("run_id"); runID != "" {
+		common.RunID = runID
+	}
+
 	if !viper.GetBool("log.json") {
 		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	}
+	baseLogger = log.Logger
+	setRunID(common.RunID)
+}
+
+// baseLogger is the configured logger without run context
+var baseLogger zerolog.Logger
+
+// setRunID changes the run ID used for lineage and logging
+func setRunID(runID string) {
+	common.RunID = runID
+	log.Logger = baseLogger.With().Str("RunID", runID).Logger()
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -138,7 +609,7 @@ func initConfig() {
 		viper.SetConfigName("import-tiingo")
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
+	bindEnv()
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
@@ -146,6 +617,296 @@ func initConfig() {
 	} else {
 		log.Error().Err(err).Msg("error reading config file")
 	}
+
+	loadSecretFiles()
+	resolveSecrets()
+}
+
+// envPrefix is prepended to the environment variable of every setting, e.g.
+// tiingo.rate_limit is read from IMPORT_TIINGO_TIINGO_RATE_LIMIT
+const envPrefix = "IMPORT_TIINGO"
+
+// configOnlyKeys are settings that have no command line flag and would
+// otherwise not be bound to an environment variable
+var configOnlyKeys = []string{
+	"healthcheck.fail_url",
+	"healthcheck.start_url",
+	"healthcheck.success_url",
+	"quota.limit_header",
+	"quota.remaining_header",
+	"quota.reset_header",
+	"s3.access_key_id",
+	"s3.secret_access_key",
+	"vault.token",
+}
+
+// envVar returns the environment variable that key is read from
+func envVar(key string) string {
+	return envPrefix + "_" + envKeyReplacer.Replace(strings.ToUpper(key))
+}
+
+var envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// bindEnv explicitly binds every setting to its environment variable; viper's
+// AutomaticEnv only matches keys that are requested directly and misses nested
+// keys when the whole config is read (e.g. by `config show`)
+func bindEnv() {
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(envKeyReplacer)
+	viper.AutomaticEnv()
+
+	for _, key := range append(viper.AllKeys(), configOnlyKeys...) {
+		if err := viper.BindEnv(key, envVar(key)); err != nil {
+			log.Error().Err(err).Str("Setting", key).Msg("could not bind environment variable")
+		}
+	}
+}
+
+// resolveSecrets replaces settings that reference a secret in HashiCorp Vault
+// or AWS Secrets Manager (e.g. vault://secret/tiingo#token) with the secret's
+// value
+func resolveSecrets() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	resolve := func(key, value string) string {
+		if !common.IsSecretURI(value) {
+			return value
+		}
+		secret, err := common.ResolveSecret(ctx, value, secretOptions())
+		if err != nil {
+			fatal().Err(err).Str("Setting", key).Msg("could not resolve secret")
+			abortRun()
+		}
+		return secret
+	}
+
+	for _, key := range viper.AllKeys() {
+		switch value := viper.Get(key).(type) {
+		case string:
+			if common.IsSecretURI(value) {
+				viper.Set(key, resolve(key, value))
+			}
+		case []string:
+			for idx, item := range value {
+				value[idx] = resolve(key, item)
+			}
+			viper.Set(key, value)
+		case []interface{}:
+			for idx, item := range value {
+				if str, ok := item.(string); ok {
+					value[idx] = resolve(key, str)
+				}
+			}
+			viper.Set(key, value)
+		}
+	}
+}
+
+// loadSecretFiles reads credentials from files (e.g. Docker or Kubernetes
+// secrets) when a *_file setting is present. Values read from a file take
+// precedence over the plain setting.
+func loadSecretFiles() {
+	secrets := map[string]string{
+		"tiingo.token_file":         "tiingo.token",
+		"database.url_file":         "database.url",
+		"openfigi.api_key_file":     "openfigi.api_key",
+		"s3.secret_access_key_file": "s3.secret_access_key",
+	}
+
+	for fileKey, key := range secrets {
+		fn := viper.GetString(fileKey)
+		if fn == "" {
+			continue
+		}
+
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			fatal().Err(err).Str("FileName", fn).Str("Setting", key).Msg("could not read secret file")
+			abortRun()
+		}
+		viper.Set(key, strings.TrimSpace(string(data)))
+	}
+}
+
+// openCheckpoint opens the checkpoint journal if one is configured. When
+// resuming, the run adopts the run ID recorded in the journal so lineage of
+// the combined results is consistent, and the download range of the
+// interrupted run so completed requests are found in the journal.
+func openCheckpoint(startDate, endDate time.Time) *tiingo.Checkpoint {
+	fn := viper.GetString("checkpoint.file")
+	resume := viper.GetBool("checkpoint.resume")
+	if fn == "" {
+		if resume {
+			fatal().Msg("--resume requires --checkpoint-file")
+			abortRun()
+		}
+		return nil
+	}
+
+	checkpoint, err := tiingo.OpenCheckpoint(fn, common.RunID, startDate, endDate, resume)
+	if err != nil {
+		fatal().Err(err).Str("FileName", fn).Msg("could not open checkpoint")
+		abortRun()
+	}
+
+	if checkpoint.RunID != common.RunID {
+		log.Info().
+			Str("CheckpointRunID", checkpoint.RunID).
+			Str("StartDate", checkpoint.StartDate.Format("2006-01-02")).
+			Str("EndDate", formatEndDate(checkpoint.EndDate)).
+			Msg("resuming run")
+		setRunID(checkpoint.RunID)
+	}
+	return checkpoint
+}
+
+// loadStartDates returns the per-asset start dates. With
+// tiingo.start_from_db each asset starts at its most recent stored quote (the
+// overlapping day provides the previous close for dividend adjustments);
+// entries in tiingo.start_dates_file take precedence.
+func loadStartDates(ctx context.Context, assets []*common.Asset) map[string]time.Time {
+	startDates := map[string]time.Time{}
+	if viper.GetBool("tiingo.start_from_db") && readDSN() != "" {
+		latest, err := storage.LatestQuoteDates(ctx, assets, storageOptions())
+		if err != nil {
+			fatal().Err(err).Msg("could not load latest quote dates")
+			abortRun()
+		}
+		startDates = latest
+	}
+
+	fn := viper.GetString("tiingo.start_dates_file")
+	if fn == "" {
+		return startDates
+	}
+
+	fileDates, err := common.LoadStartDates(fn)
+	if err != nil {
+		fatal().Err(err).Msg("could not load start dates")
+		abortRun()
+	}
+	for ticker, date := range fileDates {
+		startDates[ticker] = date
+	}
+	return startDates
+}
+
+// downloadRange returns the dates to download from tiingo.start_date and
+// tiingo.end_date; without a start date the range begins tiingo.history ago
+// and a zero end date downloads through the most recent quote
+func downloadRange() (startDate, endDate time.Time) {
+	startDate = time.Now().Add(viper.GetDuration("tiingo.history") * -1)
+	if value := viper.GetString("tiingo.start_date"); value != "" {
+		startDate = parseDateSetting("tiingo.start_date", value)
+	}
+	if value := viper.GetString("tiingo.end_date"); value != "" {
+		endDate = parseDateSetting("tiingo.end_date", value)
+		// the full history starts at each ticker's first quote
+		if endDate.Before(startDate) && !viper.GetBool("tiingo.full_history") {
+			fatal().Str("StartDate", startDate.Format("2006-01-02")).Str("EndDate", endDate.Format("2006-01-02")).Msg("end date is before the start date")
+			abortRun()
+		}
+	}
+	return startDate, endDate
+}
+
+// fetchQuotes downloads the eod quotes of assets over the download range, or
+// their entire history when tiingo.full_history is set
+func fetchQuotes(ctx context.Context, t tiingo.TiingoClient, assets []*common.Asset, startDate, endDate time.Time, startDates map[string]time.Time) ([]*tiingo.Eod, []*tiingo.TickerError) {
+	if frequency := viper.GetString("tiingo.frequency"); !tiingo.ValidFrequency(frequency) {
+		fatal().Str("Frequency", frequency).Strs("Valid", tiingo.Frequencies).Msg("unsupported eod frequency")
+		abortRun()
+	}
+	columns := viper.GetStringSlice("tiingo.columns")
+	if err := tiingo.ValidateColumns(columns); err != nil {
+		fatal().Err(err).Msg("invalid --columns")
+		abortRun()
+	}
+	if !tiingo.HasColumn(columns, "divCash") || !tiingo.HasColumn(columns, "splitFactor") {
+		log.Warn().Msg("divCash or splitFactor is not downloaded; split and dividend adjustment factors will be 1")
+	}
+	if viper.GetBool("tiingo.full_history") {
+		return t.FetchFullHistory(ctx, assets, endDate, viper.GetDuration("tiingo.full_history_chunk"))
+	}
+	return t.FetchEodQuotes(ctx, assets, startDate, endDate, startDates)
+}
+
+// parseDateSetting parses a YYYY-MM-DD (in New York time) or RFC3339 date
+func parseDateSetting(key, value string) time.Time {
+	nyc, _ := time.LoadLocation("America/New_York")
+	if date, err := time.ParseInLocation("2006-01-02", value, nyc); err == nil {
+		return date
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		fatal().Str("Setting", key).Str("Value", value).Msg("invalid date; use YYYY-MM-DD or RFC3339")
+		abortRun()
+	}
+	return date
+}
+
+// formatEndDate formats the end of the download range for logging
+func formatEndDate(endDate time.Time) string {
+	if endDate.IsZero() {
+		return "latest"
+	}
+	return endDate.Format("2006-01-02")
+}
+
+// newTiingoClient creates the tiingo client used by commands; it is a
+// variable so the API can be replaced by a test double
+var newTiingoClient = func() tiingo.TiingoClient {
+	if policy := viper.GetString("tiingo.timestamp_policy"); !tiingo.ValidTimestampPolicy(policy) {
+		fatal().Str("Policy", policy).Strs("Valid", tiingo.TimestampPolicies).Msg("unknown timestamp policy")
+		abortRun()
+	}
+
+	var opts []tiingo.Option
+	if network := networkOptions(); !network.IsZero() {
+		transport, err := network.Transport()
+		if err != nil {
+			fatal().Err(err).Msg("invalid tiingo proxy or TLS settings")
+			abortRun()
+		}
+		opts = append(opts, tiingo.WithHTTPClient(&http.Client{Transport: transport}))
+	}
+	return tiingo.New(viper.GetString("tiingo.token"), viper.GetInt("tiingo.rate_limit"), tiingoOptions(), opts...)
+}
+
+// assetFilter builds the asset universe filter from the asset type, exchange,
+// market cap and ticker settings
+func assetFilter() *common.AssetFilter {
+	return &common.AssetFilter{
+		AssetTypes:      getAssetTypes(),
+		Exchanges:       viper.GetStringSlice("filter.exchanges"),
+		Include:         upperTickers(viper.GetStringSlice("filter.include")),
+		Exclude:         upperTickers(viper.GetStringSlice("filter.exclude")),
+		Patterns:        compilePatterns("filter.patterns"),
+		ExcludePatterns: compilePatterns("filter.exclude_patterns"),
+		MinMarketCap:    viper.GetFloat64("filter.min_market_cap"),
+	}
+}
+
+func upperTickers(tickers []string) []string {
+	upper := make([]string, len(tickers))
+	for idx, ticker := range tickers {
+		upper[idx] = strings.ToUpper(strings.TrimSpace(ticker))
+	}
+	return upper
+}
+
+func compilePatterns(key string) []*regexp.Regexp {
+	var patterns []*regexp.Regexp
+	for _, expr := range viper.GetStringSlice(key) {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			fatal().Err(err).Str("Setting", key).Str("Pattern", expr).Msg("invalid ticker pattern")
+			abortRun()
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
 }
 
 func getAssetTypes() []string {
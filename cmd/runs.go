@@ -0,0 +1,175 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(runsCmd)
+
+	rootCmd.PersistentFlags().Bool("record-runs", false, "record each run's parameters, times, row counts and outcome in the import_runs table (see the runs command)")
+	viper.BindPFlag("runs.record", rootCmd.PersistentFlags().Lookup("record-runs"))
+
+	runsCmd.Flags().Int("limit", 20, "number of runs to list")
+	viper.BindPFlag("runs.limit", runsCmd.Flags().Lookup("limit"))
+}
+
+var runsCmd = &cobra.Command{
+	Use:         "runs",
+	Short:       "List recent runs recorded in the import_runs table",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{skipHealthcheck: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		runs, err := common.ListRuns(cmd.Context(), readDSN(), viper.GetInt("runs.limit"))
+		if err != nil {
+			fatal().Err(err).Msg("could not list runs")
+			abortRun()
+		}
+
+		out := table.NewWriter()
+		out.SetOutputMirror(os.Stdout)
+		out.AppendHeader(table.Row{"Run ID", "Command", "Started", "Duration", "Status", "Tickers", "Failed", "Quotes", "Rows Written", "API Calls", "Error"})
+		for _, run := range runs {
+			duration := ""
+			if run.EndTime != nil {
+				duration = run.EndTime.Sub(run.StartTime).Round(time.Second).String()
+			}
+			out.AppendRow(table.Row{
+				run.RunID, run.Command, run.StartTime.Local().Format("2006-01-02 15:04:05"), duration, run.Status,
+				run.NumTickersAttempted, run.NumFailedTickers, run.NumQuotes, formatRowsWritten(run.RowsWritten),
+				run.APICalls, run.Error,
+			})
+		}
+		out.Render()
+	},
+}
+
+// formatRowsWritten formats the rows written to each target, e.g.
+// "eod=100, quotes.parquet=100"
+func formatRowsWritten(rowsWritten map[string]int) string {
+	targets := make([]string, 0, len(rowsWritten))
+	for target := range rowsWritten {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	parts := make([]string, len(targets))
+	for idx, target := range targets {
+		parts[idx] = fmt.Sprintf("%s=%d", common.RedactDSN(target), rowsWritten[target])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// runParameters are the arguments and flags set on the command line
+var runParameters map[string]string
+
+// secretFlagWords mark flags whose values are not recorded in the ledger
+var secretFlagWords = []string{"token", "secret", "password", "api-key", "access-key", "webhook", "slack", "discord", "healthcheck"}
+
+// redactFlag hides secrets and database passwords in flag values
+func redactFlag(name, value string) string {
+	for _, word := range secretFlagWords {
+		if strings.Contains(name, word) {
+			return "xxxxx"
+		}
+	}
+	if strings.HasSuffix(name, "url") || strings.Contains(name, "database") {
+		return common.RedactDSN(value)
+	}
+	return value
+}
+
+// runLedgerEnabled returns true if the current run is recorded in the
+// import_runs table
+func runLedgerEnabled() bool {
+	return viper.GetBool("runs.record") && viper.GetString("database.url") != "" && !viper.GetBool("dry_run") && !runStats.StartTime.IsZero()
+}
+
+// startRunLedger records the run as running; startHealthcheck must be
+// called first
+func startRunLedger(cmd *cobra.Command, args []string) {
+	runParameters = make(map[string]string)
+	if len(args) > 0 {
+		runParameters["args"] = strings.Join(args, " ")
+	}
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		runParameters[flag.Name] = redactFlag(flag.Name, flag.Value.String())
+	})
+
+	if !runLedgerEnabled() {
+		return
+	}
+	recordRun(&common.RunRecord{
+		RunID:      common.RunID,
+		Command:    cmd.Name(),
+		Parameters: runParameters,
+		StartTime:  runStats.StartTime,
+		Status:     common.RunRunning,
+	})
+}
+
+// finishRunLedger records the outcome of the run; finishRunStats must be
+// called first
+func finishRunLedger() {
+	if !runLedgerEnabled() {
+		return
+	}
+
+	endTime := time.Now()
+	status := common.RunSucceeded
+	if runStats.Failed {
+		status = common.RunFailed
+	}
+	recordRun(&common.RunRecord{
+		RunID:               common.RunID,
+		Command:             runStats.Command,
+		Parameters:          runParameters,
+		StartTime:           runStats.StartTime,
+		EndTime:             &endTime,
+		Status:              status,
+		NumTickersAttempted: runStats.NumTickersAttempted,
+		NumTickersSucceeded: runStats.NumTickersSucceeded,
+		NumFailedTickers:    runStats.NumFailedTickers,
+		NumQuotes:           runStats.NumQuotes,
+		RowsWritten:         runStats.RowsWritten,
+		APICalls:            runStats.APICalls,
+		Error:               runStats.Error,
+	})
+}
+
+// recordRun writes run to the ledger; failures are logged but do not fail
+// the run
+func recordRun(run *common.RunRecord) {
+	// the run context may already be cancelled; always record the outcome
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := common.RecordRun(ctx, viper.GetString("database.url"), run); err != nil {
+		log.Warn().Err(err).Str("Status", run.Status).Msg("could not record run in import_runs")
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().String("schedule", "30 21 * * MON-FRI", "cron expression for when the import runs")
+	viper.BindPFlag("serve.schedule", serveCmd.Flags().Lookup("schedule"))
+
+	serveCmd.Flags().String("timezone", "America/New_York", "timezone the schedule is evaluated in")
+	viper.BindPFlag("serve.timezone", serveCmd.Flags().Lookup("timezone"))
+
+	serveCmd.Flags().String("status-addr", ":8080", "listen address of the HTTP status endpoint (empty to disable)")
+	viper.BindPFlag("serve.status_addr", serveCmd.Flags().Lookup("status-addr"))
+
+	serveCmd.Flags().Int("retries", 2, "number of times a failed run is retried")
+	viper.BindPFlag("serve.retries", serveCmd.Flags().Lookup("retries"))
+
+	serveCmd.Flags().Duration("retry-delay", 15*time.Minute, "time to wait before retrying a failed run")
+	viper.BindPFlag("serve.retry_delay", serveCmd.Flags().Lookup("retry-delay"))
+
+	serveCmd.Flags().Bool("ignore-calendar", false, "run on days the market is closed")
+	viper.BindPFlag("serve.ignore_calendar", serveCmd.Flags().Lookup("ignore-calendar"))
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run the import on a schedule",
+	Long: `Run the import on a cron schedule without an external scheduler. Each run
+is executed as a separate import-tiingo process with the same global flags;
+days the market is closed are skipped and failed runs are retried. The
+schedule is reloaded when the config file changes or on SIGHUP. Run status is
+served as JSON from /status on the status address.`,
+	Annotations: map[string]string{skipHealthcheck: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		s := &scheduler{args: childArgs(cmd)}
+		settings, err := loadServeSettings()
+		if err == nil {
+			err = s.schedule(settings)
+		}
+		if err != nil {
+			fatal().Err(err).Str("Schedule", viper.GetString("serve.schedule")).Msg("invalid schedule")
+			abortRun()
+		}
+
+		reloads := watchConfigReloads(ctx)
+
+		if addr := viper.GetString("serve.status_addr"); addr != "" {
+			server := &http.Server{Addr: addr, Handler: s.handler()}
+			go func() {
+				log.Info().Str("Addr", addr).Msg("serving run status")
+				if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Error().Err(err).Str("Addr", addr).Msg("status endpoint failed")
+				}
+			}()
+			defer server.Close()
+		}
+
+		// config reloads are applied here so viper is only used from this
+		// goroutine; runs read the snapshot taken by the scheduler
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info().Msg("shutting down scheduler")
+				s.stop()
+				return
+			case reason := <-reloads:
+				log.Info().Str("Reason", reason).Msg("reloading schedule")
+				if err := reloadConfig(); err != nil {
+					continue
+				}
+				settings, err := loadServeSettings()
+				if err == nil {
+					err = s.schedule(settings)
+				}
+				if err != nil {
+					log.Error().Err(err).Str("Schedule", viper.GetString("serve.schedule")).Msg("invalid schedule; keeping previous schedule")
+				}
+			}
+		}
+	},
+}
+
+// childArgs returns the command line used for scheduled runs: the global
+// flags that were explicitly set on this invocation
+func childArgs(cmd *cobra.Command) []string {
+	args := []string{}
+	inherited := cmd.InheritedFlags()
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if inherited.Lookup(flag.Name) == nil {
+			return
+		}
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			for _, value := range slice.GetSlice() {
+				args = append(args, "--"+flag.Name+"="+value)
+			}
+			return
+		}
+		args = append(args, "--"+flag.Name+"="+flag.Value.String())
+	})
+	return args
+}
+
+// runStatus describes a scheduled run
+type runStatus struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time,omitempty"`
+	Attempts  int       `json:"attempts"`
+	Succeeded bool      `json:"succeeded"`
+	Skipped   string    `json:"skipped,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// serveSettings is a snapshot of the serve settings; runs use the snapshot
+// taken when the schedule was last (re)loaded rather than reading viper
+type serveSettings struct {
+	spec           string
+	location       *time.Location
+	retries        int
+	retryDelay     time.Duration
+	ignoreCalendar bool
+}
+
+// loadServeSettings reads the serve settings from the config
+func loadServeSettings() (serveSettings, error) {
+	loc, err := time.LoadLocation(viper.GetString("serve.timezone"))
+	if err != nil {
+		return serveSettings{}, err
+	}
+	return serveSettings{
+		spec:           viper.GetString("serve.schedule"),
+		location:       loc,
+		retries:        viper.GetInt("serve.retries"),
+		retryDelay:     viper.GetDuration("serve.retry_delay"),
+		ignoreCalendar: viper.GetBool("serve.ignore_calendar"),
+	}, nil
+}
+
+// scheduler runs the import according to serve.schedule
+type scheduler struct {
+	args []string
+
+	mu       sync.Mutex
+	cron     *cron.Cron
+	settings serveSettings
+	running  bool
+	last     *runStatus
+	wg       sync.WaitGroup
+	cancel   context.CancelFunc
+}
+
+// schedule (re)starts the cron scheduler with the given settings; a run in
+// progress keeps the settings it started with
+func (s *scheduler) schedule(settings serveSettings) error {
+	c := cron.New(cron.WithLocation(settings.location))
+	if _, err := c.AddFunc(settings.spec, s.trigger); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.cron != nil {
+		s.cron.Stop()
+	}
+	s.cron = c
+	s.settings = settings
+	s.mu.Unlock()
+
+	c.Start()
+	log.Info().Str("Schedule", settings.spec).Str("Timezone", settings.location.String()).Time("NextRun", c.Entries()[0].Next).Msg("scheduled import")
+	return nil
+}
+
+func (s *scheduler) stop() {
+	s.mu.Lock()
+	s.cron.Stop()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// trigger starts a run unless one is already in progress
+func (s *scheduler) trigger() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		log.Warn().Msg("previous import still running; skipping scheduled run")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.running = true
+	s.cancel = cancel
+	settings := s.settings
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	status := s.run(ctx, settings)
+
+	s.mu.Lock()
+	s.running = false
+	s.cancel = nil
+	s.last = status
+	s.mu.Unlock()
+	cancel()
+	s.wg.Done()
+}
+
+// run executes the import, retrying failures up to serve.retries times
+func (s *scheduler) run(ctx context.Context, settings serveSettings) *runStatus {
+	status := &runStatus{StartTime: time.Now()}
+	defer func() { status.EndTime = time.Now() }()
+
+	if !settings.ignoreCalendar {
+		today := time.Now().In(common.NYSE.Location)
+		if !common.NYSE.IsTradingDay(today) {
+			log.Info().Str("Date", today.Format("2006-01-02")).Msg("market is closed today; skipping scheduled run")
+			status.Skipped = "market closed"
+			status.Succeeded = true
+			return status
+		}
+	}
+
+	for {
+		status.Attempts++
+		err := s.exec(ctx)
+		if err == nil {
+			status.Succeeded = true
+			status.Error = ""
+			log.Info().Int("Attempt", status.Attempts).Msg("scheduled import finished")
+			return status
+		}
+
+		status.Error = err.Error()
+		log.Error().Err(err).Int("Attempt", status.Attempts).Msg("scheduled import failed")
+		if status.Attempts > settings.retries {
+			return status
+		}
+
+		select {
+		case <-ctx.Done():
+			return status
+		case <-time.After(settings.retryDelay):
+		}
+	}
+}
+
+// exec runs a single import as a child process
+func (s *scheduler) exec(ctx context.Context) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	child := exec.CommandContext(ctx, executable, s.args...)
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	// give the import a chance to roll back before it is killed
+	child.Cancel = func() error { return child.Process.Signal(syscall.SIGTERM) }
+	child.WaitDelay = time.Minute
+
+	log.Info().Str("Command", executable+" "+strings.Join(s.args, " ")).Msg("starting scheduled import")
+	return child.Run()
+}
+
+// handler serves the scheduler status
+func (s *scheduler) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		status := struct {
+			Schedule string     `json:"schedule"`
+			Running  bool       `json:"running"`
+			NextRun  time.Time  `json:"next_run"`
+			LastRun  *runStatus `json:"last_run"`
+		}{
+			Schedule: s.settings.spec,
+			Running:  s.running,
+			LastRun:  s.last,
+		}
+		if entries := s.cron.Entries(); len(entries) > 0 {
+			status.NextRun = entries[0].Next
+		}
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if status.LastRun != nil && !status.LastRun.Succeeded {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
+	})
+	return mux
+}
@@ -0,0 +1,169 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(streamCmd)
+
+	streamCmd.Flags().String("frequency", "1min", "length of the aggregated bars; one of `"+strings.Join(tiingo.IntradayFrequencies, "`, `")+"`")
+	viper.BindPFlag("stream.frequency", streamCmd.Flags().Lookup("frequency"))
+
+	streamCmd.Flags().Duration("flush-interval", time.Minute, "how often completed bars are written to the database and published")
+	viper.BindPFlag("stream.flush_interval", streamCmd.Flags().Lookup("flush-interval"))
+
+	streamCmd.Flags().Bool("trades-only", false, "subscribe to last trade updates only; without quotes, intervals with no trades produce no bar")
+	viper.BindPFlag("stream.trades_only", streamCmd.Flags().Lookup("trades-only"))
+
+	streamCmd.Flags().String("bars-topic", "intraday", "kafka topic streamed bars are published to")
+	viper.BindPFlag("stream.kafka_topic", streamCmd.Flags().Lookup("bars-topic"))
+
+	streamCmd.Flags().String("bars-subject", "intraday.{ticker}", "subject template for streamed bars published to NATS; may reference {ticker}, {figi} and {frequency}")
+	viper.BindPFlag("stream.nats_subject", streamCmd.Flags().Lookup("bars-subject"))
+}
+
+var streamCmd = &cobra.Command{
+	Use:   "stream [ticker...]",
+	Short: "Stream real-time IEX trades and quotes and save them as intraday bars",
+	Long:  `Subscribe to tiingo's IEX websocket for the given tickers (or the active asset universe if none are given), aggregate trades and quotes into intraday bars and, every --flush-interval, save completed bars to the intraday table and publish them to the configured kafka brokers and NATS server. Runs until interrupted; buffered bars are flushed on exit.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		frequency := viper.GetString("stream.frequency")
+		if !tiingo.ValidIntradayFrequency(frequency) {
+			fatal().Str("Frequency", frequency).Strs("Valid", tiingo.IntradayFrequencies).Msg("unsupported intraday frequency")
+			abortRun()
+		}
+
+		var assets []*common.Asset
+		if len(args) > 0 {
+			assets = common.LoadAssetFromDB(ctx, readDSN(), args)
+		} else {
+			assets = common.ReadAssetsFromDatabase(ctx, readDSN(), assetFilter())
+			assets = filterOTCAssets(assets)
+		}
+		if len(assets) == 0 {
+			fatal().Msg("no assets to stream")
+			abortRun()
+		}
+
+		aggregator, err := tiingo.NewBarAggregator(frequency, assets)
+		if err != nil {
+			fatal().Err(err).Msg("could not create bar aggregator")
+			abortRun()
+		}
+
+		tickers := make([]string, len(assets))
+		for idx, asset := range assets {
+			tickers[idx] = asset.Ticker
+		}
+
+		thresholdLevel := tiingo.StreamQuotesAndTrades
+		if viper.GetBool("stream.trades_only") {
+			thresholdLevel = tiingo.StreamTrades
+		}
+
+		publishers, targets := newBarPublishers()
+
+		flush := func(bars []*tiingo.IntradayBar) {
+			if len(bars) == 0 {
+				return
+			}
+			numTrades, numQuotes := aggregator.Counts()
+			log.Info().Int("NumBars", len(bars)).Int("NumTrades", numTrades).Int("NumQuotes", numQuotes).Msg("flushing streamed bars")
+
+			if url := viper.GetString("database.url"); url != "" && !skipWrite(url, len(bars)) {
+				recordWrite("intraday", len(bars), storage.SaveIntradayToDatabase(context.WithoutCancel(ctx), url, bars))
+			}
+			for _, publisher := range publishers {
+				publisher.PublishBars(context.WithoutCancel(ctx), bars)
+			}
+		}
+
+		log.Info().Str("Frequency", frequency).Int("NumAssets", len(assets)).Msg("streaming iex updates")
+
+		t := tiingoClient()
+		streamErr := make(chan error, 1)
+		go func() {
+			streamErr <- t.StreamIEX(ctx, tickers, thresholdLevel, aggregator.Add)
+		}()
+
+		ticker := time.NewTicker(viper.GetDuration("stream.flush_interval"))
+		defer ticker.Stop()
+
+	loop:
+		for {
+			select {
+			case now := <-ticker.C:
+				flush(aggregator.Flush(now, false))
+			case err = <-streamErr:
+				break loop
+			}
+		}
+
+		// save the partial bars buffered when the stream stopped
+		flush(aggregator.Flush(time.Now(), true))
+		for idx, publisher := range publishers {
+			recordWrite(targets[idx], publisher.NumPublished(), publisher.Close())
+		}
+
+		if err != nil {
+			fatal().Err(err).Msg("iex websocket rejected the subscription")
+			abortRun()
+		}
+	},
+}
+
+// newBarPublishers connects to the kafka brokers and NATS server streamed
+// bars are published to; targets names each publisher in the run summary
+func newBarPublishers() (publishers []storage.Publisher, targets []string) {
+	if brokers := viper.GetStringSlice("kafka.brokers"); len(brokers) > 0 {
+		topic := viper.GetString("stream.kafka_topic")
+		target := "kafka://" + strings.Join(brokers, ",") + "/" + topic
+		if !skipWrite(target, 0) {
+			publisher, err := storage.NewKafkaPublisher(brokers, topic, viper.GetString("kafka.format"))
+			if err != nil {
+				fatal().Err(err).Msg("could not create kafka publisher")
+				abortRun()
+			}
+			targets = append(targets, target)
+			publishers = append(publishers, publisher)
+		}
+	}
+
+	if url := viper.GetString("nats.url"); url != "" && !skipWrite(url, 0) {
+		publisher, err := storage.NewNatsPublisher(url, viper.GetString("stream.nats_subject"), viper.GetString("nats.format"))
+		if err != nil {
+			fatal().Err(err).Str("URL", common.RedactDSN(url)).Msg("could not connect to nats")
+			abortRun()
+		}
+		targets = append(targets, common.RedactDSN(url))
+		publishers = append(publishers, publisher)
+	}
+
+	return publishers, targets
+}
@@ -0,0 +1,130 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/notifications"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// recordFetchErrors adds the outcome of a download phase to the run summary
+func recordFetchErrors(phase string, numRequested int, errs []*tiingo.TickerError) {
+	runStats.NumTickersAttempted += numRequested
+	runStats.NumTickersSucceeded += numRequested - len(errs)
+	runStats.NumFailedTickers += len(errs)
+	for _, err := range errs {
+		runStats.Failures = append(runStats.Failures, &notifications.TickerFailure{
+			Phase:      phase,
+			Ticker:     err.Ticker,
+			StatusCode: err.StatusCode,
+			Reason:     err.Reason(),
+		})
+	}
+}
+
+// tiingoClients are the clients created during the run; their API calls are
+// counted in the run summary
+var tiingoClients []tiingo.TiingoClient
+
+// tiingoClient creates a tiingo client and tracks it for the run summary
+func tiingoClient() tiingo.TiingoClient {
+	t := newTiingoClient()
+	tiingoClients = append(tiingoClients, t)
+	return t
+}
+
+// recordWrite marks the run failed when err is not nil and otherwise adds the
+// number of rows written to target to the run summary
+func recordWrite(target string, numRows int, err error) {
+	checkSaveError(err)
+	if err != nil {
+		return
+	}
+	if runStats.RowsWritten == nil {
+		runStats.RowsWritten = make(map[string]int)
+	}
+	runStats.RowsWritten[target] += numRows
+}
+
+// recordAnomaly notes an unexpected but non-fatal condition in the run
+// summary; anomalies trigger webhook notifications
+func recordAnomaly(format string, args ...any) {
+	anomaly := fmt.Sprintf(format, args...)
+	log.Warn().Str("Anomaly", anomaly).Msg("anomaly detected")
+	runStats.Anomalies = append(runStats.Anomalies, anomaly)
+}
+
+// finishRunStats fills in the final outcome of the run
+func finishRunStats(command string, err error) {
+	if runStats.StartTime.IsZero() {
+		return
+	}
+
+	elapsed := time.Since(runStats.StartTime)
+	runStats.Command = command
+	runStats.RunID = common.RunID
+	runStats.Duration = elapsed.Round(time.Millisecond).String()
+	runStats.ElapsedSeconds = elapsed.Seconds()
+	runStats.APICalls = 0
+	var quota *tiingo.Quota
+	for _, t := range tiingoClients {
+		runStats.APICalls += int(t.APICalls())
+		if q := t.Quota(); q != nil && (quota == nil || q.UpdatedAt.After(quota.UpdatedAt)) {
+			quota = q
+		}
+	}
+	if quota != nil {
+		runStats.QuotaRemaining = &quota.Remaining
+		log.Info().Int64("Remaining", quota.Remaining).Int64("Limit", quota.Limit).Msg("tiingo API quota")
+	}
+	runStats.Failed = err != nil || runFailed
+	if err != nil {
+		runStats.Error = err.Error()
+	}
+}
+
+// writeRunSummary writes the run statistics as JSON to summary.file; "-"
+// writes to stdout
+func writeRunSummary() {
+	fn := viper.GetString("summary.file")
+	if fn == "" || runStats.StartTime.IsZero() {
+		return
+	}
+
+	data, err := json.MarshalIndent(runStats, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("could not marshal run summary")
+		return
+	}
+	data = append(data, '\n')
+
+	if fn == "-" {
+		os.Stdout.Write(data)
+		return
+	}
+
+	fn = common.ExpandURI(fn)
+	if err := os.WriteFile(fn, data, 0644); err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not write run summary")
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	syncCmd.Flags().Bool("prune", false, "remove eod rows for tickers no longer in the active universe")
+	viper.BindPFlag("sync.prune", syncCmd.Flags().Lookup("prune"))
+
+	syncCmd.Flags().String("prune-policy", storage.PruneArchive, "how pruned rows are handled; one of `archive` (copy to eod_archive), `deactivate` (soft-delete) or `delete`")
+	viper.BindPFlag("sync.prune_policy", syncCmd.Flags().Lookup("prune-policy"))
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Align the eod table with the active asset universe",
+	Long:  `List tickers with eod rows that are no longer active in the assets table and optionally prune them`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		softDeleted := viper.GetString("sync.prune_policy") == storage.PruneDeactivate
+		orphans, err := storage.FindOrphanedTickers(ctx, viper.GetString("database.url"), softDeleted)
+		if err != nil {
+			os.Exit(1)
+		}
+
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{"Ticker", "Composite FIGI", "Rows"})
+		for _, orphan := range orphans {
+			t.AppendRow(table.Row{orphan.Ticker, orphan.CompositeFigi, orphan.NumRows})
+		}
+		t.Render()
+
+		if !viper.GetBool("sync.prune") || len(orphans) == 0 {
+			return
+		}
+
+		if skipWrite(viper.GetString("database.url"), len(orphans)) {
+			return
+		}
+
+		if err := storage.PruneOrphanedTickers(ctx, viper.GetString("database.url"), orphans, viper.GetString("sync.prune_policy")); err != nil {
+			log.Error().Err(err).Msg("prune failed")
+			os.Exit(1)
+		}
+	},
+}
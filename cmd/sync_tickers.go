@@ -0,0 +1,81 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(syncTickersCmd)
+
+	syncTickersCmd.Flags().Bool("include-delisted", false, "also sync tickers that are no longer priced by tiingo")
+	viper.BindPFlag("sync_tickers.include_delisted", syncTickersCmd.Flags().Lookup("include-delisted"))
+
+	syncTickersCmd.Flags().StringSlice("exchanges", []string{}, "only sync tickers listed on these exchanges (default all)")
+	viper.BindPFlag("sync_tickers.exchanges", syncTickersCmd.Flags().Lookup("exchanges"))
+
+	syncTickersCmd.Flags().Bool("detect-changes", true, "detect renamed tickers (same composite FIGI, new symbol) and record them in ticker_changes")
+	viper.BindPFlag("ticker_changes.enabled", syncTickersCmd.Flags().Lookup("detect-changes"))
+}
+
+var syncTickersCmd = &cobra.Command{
+	Use:   "sync-tickers",
+	Short: "Update the assets table from tiingo's list of supported tickers",
+	Long:  `Download tiingo's supported_tickers.zip and upsert ticker, exchange, asset type, currency and listing dates into the assets table`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		t := tiingoClient()
+		tickers, err := t.FetchSupportedTickers(ctx)
+		if err != nil {
+			fatal().Err(err).Msg("could not download supported tickers")
+			abortRun()
+		}
+		exitIfCancelled(ctx)
+
+		applyTickerChanges(ctx, tickers)
+
+		exchanges := make(map[string]bool)
+		for _, exchange := range viper.GetStringSlice("sync_tickers.exchanges") {
+			exchanges[strings.ToUpper(exchange)] = true
+		}
+		includeDelisted := viper.GetBool("sync_tickers.include_delisted")
+
+		filtered := make([]*tiingo.SupportedTicker, 0, len(tickers))
+		for _, ticker := range tickers {
+			if !includeDelisted && !ticker.IsActive() {
+				continue
+			}
+			if len(exchanges) > 0 && !exchanges[strings.ToUpper(ticker.Exchange)] {
+				continue
+			}
+			filtered = append(filtered, ticker)
+		}
+
+		log.Info().Int("NumSupported", len(tickers)).Int("NumSelected", len(filtered)).Msg("loaded supported tickers")
+
+		if skipWrite(viper.GetString("database.url"), len(filtered)) {
+			return
+		}
+		recordWrite("assets", len(filtered), storage.SaveSupportedTickersToDatabase(ctx, viper.GetString("database.url"), filtered))
+	},
+}
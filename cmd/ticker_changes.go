@@ -0,0 +1,49 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/openfigi"
+	"github.com/penny-vault/import-tiingo/storage"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// applyTickerChanges detects assets that tiingo lists under a new symbol and
+// renames them in the assets table. It runs before supported tickers are
+// saved so renamed assets are not inserted a second time.
+func applyTickerChanges(ctx context.Context, supported []*tiingo.SupportedTicker) {
+	if !viper.GetBool("ticker_changes.enabled") || viper.GetString("database.url") == "" {
+		return
+	}
+
+	assets := common.ReadAssetsFromDatabase(ctx, readDSN(), &common.AssetFilter{AssetTypes: getAssetTypes()})
+	o := openfigi.New(viper.GetString("openfigi.api_key"), viper.GetInt("openfigi.rate_limit"))
+	changes, err := tiingo.DetectTickerChanges(ctx, supported, assets, o.CurrentTickers)
+	if err != nil {
+		log.Error().Err(err).Msg("could not detect ticker changes")
+		return
+	}
+
+	log.Info().Int("NumChanges", len(changes)).Msg("detected ticker changes")
+	if len(changes) == 0 || skipWrite(viper.GetString("database.url"), len(changes)) {
+		return
+	}
+	checkSaveError(storage.SaveTickerChanges(ctx, viper.GetString("database.url"), changes))
+}
@@ -0,0 +1,77 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/guptarohit/asciigraph"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/spf13/viper"
+)
+
+// printCharts renders a line chart of the closes of each ticker when
+// ticker.chart is enabled. Charts go to stdout unless stdout carries csv or
+// json quotes, in which case they go to stderr so the data stays parseable.
+func printCharts(quotes []*tiingo.Eod) {
+	if !viper.GetBool("ticker.chart") {
+		return
+	}
+
+	var out io.Writer = os.Stdout
+	toStdout := viper.GetString("ticker.output_file") == "" || viper.GetString("ticker.output_file") == "-"
+	if format := viper.GetString("ticker.output"); toStdout && format != OutputTable && format != OutputMarkdown {
+		out = os.Stderr
+	}
+
+	height := viper.GetInt("ticker.chart_height")
+	width := viper.GetInt("ticker.chart_width")
+
+	byTicker := make(map[string][]*tiingo.Eod)
+	tickers := []string{}
+	for _, quote := range quotes {
+		if _, ok := byTicker[quote.Ticker]; !ok {
+			tickers = append(tickers, quote.Ticker)
+		}
+		byTicker[quote.Ticker] = append(byTicker[quote.Ticker], quote)
+	}
+
+	for _, ticker := range tickers {
+		tickerQuotes := byTicker[ticker]
+		sort.SliceStable(tickerQuotes, func(i, j int) bool {
+			return tickerQuotes[i].Date.Before(tickerQuotes[j].Date)
+		})
+
+		closes := make([]float64, len(tickerQuotes))
+		for idx, quote := range tickerQuotes {
+			closes[idx] = quote.Close
+		}
+
+		caption := fmt.Sprintf("%s close %s to %s", ticker,
+			tickerQuotes[0].Date.Format("2006-01-02"), tickerQuotes[len(tickerQuotes)-1].Date.Format("2006-01-02"))
+		options := []asciigraph.Option{asciigraph.Height(height), asciigraph.Caption(caption), asciigraph.Precision(2)}
+		// asciigraph interpolates when the width differs from the number of
+		// points; short histories are drawn one column per day
+		if width > 0 && len(closes) > width {
+			options = append(options, asciigraph.Width(width))
+		}
+
+		fmt.Fprintln(out, asciigraph.Plot(closes, options...))
+		fmt.Fprintln(out)
+	}
+}
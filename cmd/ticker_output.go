@@ -0,0 +1,153 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/spf13/viper"
+)
+
+// Output formats of the ticker command
+const (
+	OutputTable    = "table"
+	OutputCSV      = "csv"
+	OutputJSON     = "json"
+	OutputMarkdown = "markdown"
+)
+
+// quoteSortKeys compare two quotes by a column of the ticker output
+var quoteSortKeys = map[string]func(a, b *tiingo.Eod) int{
+	"date":   func(a, b *tiingo.Eod) int { return a.Date.Compare(b.Date) },
+	"ticker": func(a, b *tiingo.Eod) int { return strings.Compare(a.Ticker, b.Ticker) },
+	"open":   func(a, b *tiingo.Eod) int { return cmp.Compare(a.Open, b.Open) },
+	"high":   func(a, b *tiingo.Eod) int { return cmp.Compare(a.High, b.High) },
+	"low":    func(a, b *tiingo.Eod) int { return cmp.Compare(a.Low, b.Low) },
+	"close":  func(a, b *tiingo.Eod) int { return cmp.Compare(a.Close, b.Close) },
+	"volume": func(a, b *tiingo.Eod) int { return cmp.Compare(a.Volume, b.Volume) },
+}
+
+// checkQuoteOutput exits if the ticker output format or sort order is
+// unknown; it is called before downloading so a typo doesn't waste API calls
+func checkQuoteOutput() {
+	switch format := viper.GetString("ticker.output"); format {
+	case OutputTable, OutputCSV, OutputJSON, OutputMarkdown:
+	default:
+		fatal().Str("Output", format).Msg("unknown output format; must be one of table, csv, json or markdown")
+		abortRun()
+	}
+	for _, key := range viper.GetStringSlice("ticker.sort") {
+		if _, ok := quoteSortKeys[strings.TrimPrefix(key, "-")]; !ok {
+			fatal().Str("Sort", key).Msg("unknown sort column; must be one of date, ticker, open, high, low, close or volume")
+			abortRun()
+		}
+	}
+}
+
+// sortQuotes orders quotes by the ticker.sort columns; a column prefixed
+// with `-` sorts descending
+func sortQuotes(quotes []*tiingo.Eod, keys []string) {
+	sort.SliceStable(quotes, func(i, j int) bool {
+		for _, key := range keys {
+			cmp := quoteSortKeys[strings.TrimPrefix(key, "-")](quotes[i], quotes[j])
+			if strings.HasPrefix(key, "-") {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+}
+
+// quoteRow is a quote as written by the json output format
+type quoteRow struct {
+	Date     string  `json:"date"`
+	Ticker   string  `json:"ticker"`
+	Open     float64 `json:"open"`
+	High     float64 `json:"high"`
+	Low      float64 `json:"low"`
+	Close    float64 `json:"close"`
+	Volume   int64   `json:"volume"`
+	Dividend float64 `json:"dividend"`
+	Split    float64 `json:"split"`
+}
+
+// printQuotes writes quotes in the ticker.output format to
+// ticker.output_file, or stdout when it is empty or `-`
+func printQuotes(quotes []*tiingo.Eod) {
+	sortQuotes(quotes, viper.GetStringSlice("ticker.sort"))
+
+	var out io.Writer = os.Stdout
+	if fn := viper.GetString("ticker.output_file"); fn != "" && fn != "-" {
+		fh, err := os.Create(fn)
+		if err != nil {
+			fatal().Err(err).Str("FileName", fn).Msg("could not create output file")
+			abortRun()
+		}
+		defer fh.Close()
+		out = fh
+	}
+
+	if err := writeQuotes(out, quotes, viper.GetString("ticker.output")); err != nil {
+		fatal().Err(err).Msg("could not write quotes")
+		abortRun()
+	}
+}
+
+func writeQuotes(out io.Writer, quotes []*tiingo.Eod, format string) error {
+	if format == OutputJSON {
+		rows := make([]*quoteRow, len(quotes))
+		for idx, quote := range quotes {
+			rows[idx] = &quoteRow{
+				Date: quote.Date.Format("2006-01-02"), Ticker: quote.Ticker,
+				Open: quote.Open, High: quote.High, Low: quote.Low, Close: quote.Close,
+				Volume: quote.Volume, Dividend: quote.Dividend, Split: quote.Split,
+			}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	}
+
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"Date", "Ticker", "Open", "High", "Low", "Close", "Volume", "Dividend", "Split"})
+	for _, quote := range quotes {
+		t.AppendRow(table.Row{
+			quote.Date.Format("2006-01-02"), quote.Ticker, quote.Open, quote.High, quote.Low, quote.Close, quote.Volume, quote.Dividend, quote.Split,
+		})
+	}
+
+	var rendered string
+	switch format {
+	case OutputCSV:
+		rendered = t.RenderCSV()
+	case OutputMarkdown:
+		rendered = t.RenderMarkdown()
+	default:
+		rendered = t.Render()
+	}
+	_, err := fmt.Fprintln(out, rendered)
+	return err
+}
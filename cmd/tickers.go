@@ -15,12 +15,11 @@
 package cmd
 
 import (
-	"os"
-	"time"
+	"context"
+	"strings"
 
-	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/penny-vault/import-tiingo/common"
-	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/penny-vault/import-tiingo/openfigi"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -28,40 +27,110 @@ import (
 
 func init() {
 	rootCmd.AddCommand(tickerCmd)
+
+	rootCmd.PersistentFlags().String("tickers-file", "", "read the tickers to download from a file (`-` for stdin) with one ticker, optionally followed by `,composite_figi`, per line instead of the database")
+	viper.BindPFlag("tickers_file", rootCmd.PersistentFlags().Lookup("tickers-file"))
+
+	tickerCmd.Flags().StringP("output", "o", OutputTable, "output format; one of `table`, `csv`, `json` or `markdown`")
+	viper.BindPFlag("ticker.output", tickerCmd.Flags().Lookup("output"))
+
+	tickerCmd.Flags().StringSlice("sort", []string{}, "sort quotes by the given columns (`date`, `ticker`, `open`, `high`, `low`, `close` or `volume`), prefix a column with - to sort descending, e.g. `ticker,-date` (default is download order)")
+	viper.BindPFlag("ticker.sort", tickerCmd.Flags().Lookup("sort"))
+
+	tickerCmd.Flags().String("output-file", "", "write quotes to this file instead of stdout")
+	viper.BindPFlag("ticker.output_file", tickerCmd.Flags().Lookup("output-file"))
+
+	tickerCmd.Flags().Bool("chart", false, "render a line chart of each ticker's closes in the terminal")
+	viper.BindPFlag("ticker.chart", tickerCmd.Flags().Lookup("chart"))
+
+	tickerCmd.Flags().Int("chart-height", 15, "height of each chart in lines")
+	viper.BindPFlag("ticker.chart_height", tickerCmd.Flags().Lookup("chart-height"))
+
+	tickerCmd.Flags().Int("chart-width", 100, "maximum width of each chart in columns; longer histories are resampled to fit (0 for one column per quote)")
+	viper.BindPFlag("ticker.chart_width", tickerCmd.Flags().Lookup("chart-width"))
 }
 
-func printTable(quotes []*tiingo.Eod) {
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
-	t.AppendHeader(table.Row{"Date", "Ticker", "Open", "High", "Low", "Close", "Volume", "Dividend", "Split"})
-	for _, quote := range quotes {
-		t.AppendRow(table.Row{
-			quote.Date, quote.Ticker, quote.Open, quote.High, quote.Low, quote.Close, quote.Volume, quote.Dividend, quote.Split,
-		})
+// readTickersFile returns the assets listed in the tickers file; assets
+// without a composite FIGI are looked up with OpenFIGI
+func readTickersFile(ctx context.Context) []*common.Asset {
+	fn := viper.GetString("tickers_file")
+	assets, err := common.ReadTickersFile(fn)
+	if err != nil {
+		fatal().Err(err).Str("FileName", fn).Msg("could not read tickers file")
+		abortRun()
+	}
+	log.Info().Str("FileName", fn).Int("NumAssets", len(assets)).Msg("read tickers file")
+	enrichAssets(ctx, assets)
+	return assets
+}
+
+// enrichAssets looks up the composite FIGI of assets that don't have one
+func enrichAssets(ctx context.Context, assets []*common.Asset) {
+	if viper.GetBool("openfigi.enabled") {
+		o := openfigi.New(viper.GetString("openfigi.api_key"), viper.GetInt("openfigi.rate_limit"))
+		if err := o.EnrichAssets(ctx, assets); err != nil {
+			log.Error().Err(err).Msg("could not look up composite FIGIs")
+		}
 	}
-	t.Render()
 }
 
 var tickerCmd = &cobra.Command{
-	Use:   "ticker [ticker...]",
-	Args:  cobra.MinimumNArgs(1),
+	Use: "ticker [ticker...]",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if viper.GetString("tickers_file") != "" {
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
 	Short: "Download eod quotes for the given tickers",
 	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		checkQuoteOutput()
+		startDate, endDate := downloadRange()
+
 		log.Info().
-			Str("History", viper.GetDuration("tiingo.history").String()).
+			Str("StartDate", startDate.Format("2006-01-02")).
+			Str("EndDate", formatEndDate(endDate)).
 			Int("NumAssets", len(args)).
 			Msg("loading tickers")
 
-		assets := common.LoadAssetFromDB(args)
-
-		t := tiingo.New(viper.GetString("tiingo.token"), viper.GetInt("tiingo.rate_limit"))
-		startDate := time.Now().Add(viper.GetDuration("tiingo.history") * -1)
-		quotes := t.FetchEodQuotes(assets, startDate)
+		var assets []*common.Asset
+		if len(args) > 0 {
+			assets = common.LoadAssetFromDB(ctx, readDSN(), args)
+		}
 
-		printTable(quotes)
+		// tickers that are not in the assets table are still downloaded
+		found := make(map[string]bool, len(assets))
+		for _, asset := range assets {
+			found[strings.ToUpper(asset.Ticker)] = true
+		}
+		for _, ticker := range args {
+			if !found[strings.ToUpper(ticker)] {
+				assets = append(assets, &common.Asset{Ticker: strings.ToUpper(ticker)})
+			}
+		}
 
-		if viper.GetString("database.url") != "" {
-			tiingo.SaveToDatabase(quotes)
+		enrichAssets(ctx, assets)
+		if viper.GetString("tickers_file") != "" {
+			assets = append(assets, readTickersFile(ctx)...)
 		}
+
+		t := tiingoClient()
+		startDates := loadStartDates(ctx, assets)
+		finishPublishing := startPublishing(ctx, t, len(assets))
+		quotes, fetchErrs := fetchQuotes(ctx, t, assets, startDate, endDate, startDates)
+		finishPublishing()
+		checkFetchErrors("download", len(assets), fetchErrs)
+		exitIfCancelled(ctx)
+		printMetricsReport(t.Metrics())
+		repairSpikes(ctx, t, assets, quotes)
+
+		printQuotes(quotes)
+		printCharts(quotes)
+
+		validQuotes, _ := validateQuotes(quotes)
+		saveQuotesToDatabase(ctx, validQuotes)
+		saveCorporateActions(ctx, validQuotes)
+		saveLatestQuotes(ctx, validQuotes)
 	},
 }
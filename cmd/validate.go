@@ -0,0 +1,118 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"os"
+
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/penny-vault/import-tiingo/validate"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// validateQuotes checks quotes with the configured validation rules and
+// writes a report of the issues found. The quotes that should be saved to
+// the database are returned along with the issues; with --strict quotes that
+// have issues are removed.
+func validateQuotes(quotes []*tiingo.Eod) ([]*tiingo.Eod, []*validate.Issue) {
+	ruleNames := applicableRules(viper.GetStringSlice("validate.rules"))
+	if len(ruleNames) == 0 {
+		return quotes, nil
+	}
+
+	v, err := validate.New(ruleNames, viper.GetFloat64("validate.max_move"))
+	if err != nil {
+		fatal().Err(err).Strs("Rules", ruleNames).Msg("invalid validation rules")
+		abortRun()
+	}
+
+	issues := v.Validate(quotes)
+	counts := make(map[string]int)
+	for _, issue := range issues {
+		counts[issue.Rule]++
+		log.Warn().
+			Str("Ticker", issue.Quote.Ticker).
+			Str("CompositeFigi", issue.Quote.CompositeFigi).
+			Str("Date", issue.Quote.Date.Format("2006-01-02")).
+			Str("Rule", issue.Rule).
+			Msg(issue.Message)
+	}
+
+	event := log.Info().Int("NumQuotes", len(quotes)).Int("NumIssues", len(issues))
+	for _, name := range ruleNames {
+		event = event.Int(name, counts[name])
+	}
+	event.Msg("validated quotes")
+	if len(issues) > 0 {
+		recordAnomaly("%d quotes failed validation", len(issues))
+	}
+
+	if fn := viper.GetString("validate.report_file"); fn != "" && !skipWrite(fn, len(issues)) {
+		writeValidationReport(fn, issues)
+	}
+
+	if !viper.GetBool("validate.strict") {
+		return quotes, issues
+	}
+
+	valid := validate.Reject(quotes, issues)
+	if len(valid) < len(quotes) {
+		log.Warn().Int("NumRejected", len(quotes)-len(valid)).Msg("strict validation: quotes with issues will not be saved to the database")
+	}
+	return valid, issues
+}
+
+// applicableRules removes the rules that need a column that isn't downloaded
+// (see tiingo.columns)
+func applicableRules(ruleNames []string) []string {
+	applicable := make([]string, 0, len(ruleNames))
+	for _, name := range ruleNames {
+		missing := ""
+		for _, column := range validate.RequiredColumns(name) {
+			if !tiingo.HasColumn(viper.GetStringSlice("tiingo.columns"), column) {
+				missing = column
+				break
+			}
+		}
+		if missing != "" {
+			log.Info().Str("Rule", name).Str("Column", missing).Msg("skipping validation rule; column is not downloaded")
+			continue
+		}
+		applicable = append(applicable, name)
+	}
+	return applicable
+}
+
+// writeValidationReport saves issues to fn as CSV
+func writeValidationReport(fn string, issues []*validate.Issue) {
+	fh, err := os.Create(fn)
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not create validation report")
+		return
+	}
+	defer fh.Close()
+
+	w := csv.NewWriter(fh)
+	w.Write([]string{"ticker", "composite_figi", "date", "rule", "message"})
+	for _, issue := range issues {
+		w.Write([]string{issue.Quote.Ticker, issue.Quote.CompositeFigi, issue.Quote.Date.Format("2006-01-02"), issue.Rule, issue.Message})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not write validation report")
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/stooq"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().Int("sample", 20, "number of tickers sampled from the asset universe")
+	viper.BindPFlag("verify.sample", verifyCmd.Flags().Lookup("sample"))
+
+	verifyCmd.Flags().Float64("tolerance", 0.01, "largest relative close price difference that is not reported")
+	viper.BindPFlag("verify.tolerance", verifyCmd.Flags().Lookup("tolerance"))
+
+	verifyCmd.Flags().String("stooq-api-key", "", "stooq API key (only needed when stooq requires one)")
+	viper.BindPFlag("stooq.api_key", verifyCmd.Flags().Lookup("stooq-api-key"))
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [ticker...]",
+	Short: "Compare tiingo prices against a secondary source",
+	Long: `Sample tickers from the asset universe (or use the given tickers), download
+the history window from tiingo and stooq, and report days where the split
+adjusted close prices differ by more than the tolerance. The command exits
+non-zero when discrepancies are found.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		var assets []*common.Asset
+		if len(args) > 0 {
+			assets = common.LoadAssetFromDB(ctx, readDSN(), args)
+		} else {
+			assets = common.ReadAssetsFromDatabase(ctx, readDSN(), assetFilter())
+			assets = filterOTCAssets(assets)
+			rand.Shuffle(len(assets), func(i, j int) { assets[i], assets[j] = assets[j], assets[i] })
+			if sample := viper.GetInt("verify.sample"); sample < len(assets) {
+				assets = assets[:sample]
+			}
+		}
+
+		nyc, _ := time.LoadLocation("America/New_York")
+		startDate, endDate := downloadRange()
+		if endDate.IsZero() {
+			endDate = time.Now().In(nyc)
+		}
+
+		log.Info().Int("NumAssets", len(assets)).Str("StartDate", startDate.Format("2006-01-02")).Msg("verifying prices")
+
+		t := tiingoClient()
+		quotes, fetchErrs := t.FetchEodQuotes(ctx, assets, startDate, endDate, nil)
+		checkFetchErrors("verify", len(assets), fetchErrs)
+		exitIfCancelled(ctx)
+
+		byTicker := make(map[string][]*tiingo.Eod)
+		for _, quote := range quotes {
+			byTicker[quote.Ticker] = append(byTicker[quote.Ticker], quote)
+		}
+
+		tolerance := viper.GetFloat64("verify.tolerance")
+		s := stooq.New(viper.GetString("stooq.api_key"), 0)
+
+		out := table.NewWriter()
+		out.SetOutputMirror(os.Stdout)
+		out.AppendHeader(table.Row{"Ticker", "Date", "Tiingo", "Stooq", "Difference"})
+
+		numCompared := 0
+		numDiscrepancies := 0
+		for _, asset := range assets {
+			if ctx.Err() != nil {
+				break
+			}
+
+			bars, err := s.FetchDaily(ctx, asset.Ticker, startDate, endDate)
+			if err != nil {
+				log.Warn().Err(err).Str("Ticker", asset.Ticker).Msg("could not verify ticker")
+				continue
+			}
+
+			reference := make(map[string]*stooq.Bar, len(bars))
+			for _, bar := range bars {
+				reference[bar.Date.Format("2006-01-02")] = bar
+			}
+
+			for _, quote := range byTicker[asset.Ticker] {
+				date := quote.Date.Format("2006-01-02")
+				bar, ok := reference[date]
+				if !ok || bar.Close == 0 {
+					continue
+				}
+
+				numCompared++
+				// stooq prices are split adjusted relative to today
+				tiingoClose := quote.Close * quote.SplitAdjustFactor
+				diff := (tiingoClose - bar.Close) / bar.Close
+				if math.Abs(diff) > tolerance {
+					numDiscrepancies++
+					out.AppendRow(table.Row{asset.Ticker, date, tiingoClose, bar.Close, fmt.Sprintf("%.2f%%", diff*100)})
+				}
+			}
+		}
+
+		log.Info().Int("NumCompared", numCompared).Int("NumDiscrepancies", numDiscrepancies).Msg("verification finished")
+		if numDiscrepancies > 0 {
+			out.Render()
+			runFailed = true
+		}
+	},
+}
@@ -2,12 +2,12 @@ package common
 
 import (
 	"context"
+	"strings"
 
 	"github.com/georgysavva/scany/pgxscan"
 	"github.com/jackc/pgx/v4"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"github.com/spf13/viper"
 )
 
 type AssetType string
@@ -28,6 +28,7 @@ type Asset struct {
 	PrimaryExchange      string    `json:"primary_exchange" parquet:"name=primary_exchange, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 	AssetType            AssetType `json:"asset_type" parquet:"name=asset_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 	CompositeFigi        string    `json:"composite_figi" parquet:"name=composite_figi, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Currency             string    `json:"currency" parquet:"name=currency, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 	ShareClassFigi       string    `json:"share_class_figi" parquet:"name=share_class_figi, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 	CUSIP                string    `json:"cusip" parquet:"name=cusip, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 	ISIN                 string    `json:"isin" parquet:"name=isin, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
@@ -46,9 +47,12 @@ type Asset struct {
 	Source               string    `json:"source" parquet:"name=source, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 }
 
-func LoadAssetFromDB(tickers []string) []*Asset {
-	ctx := context.Background()
-	conn, err := pgx.Connect(ctx, viper.GetString("database.url"))
+func LoadAssetFromDB(ctx context.Context, dsn string, tickers []string) []*Asset {
+	if IsSQLiteDSN(dsn) {
+		return readSQLiteAssets(ctx, dsn, "ticker", tickers)
+	}
+
+	conn, err := pgx.Connect(ctx, dsn)
 	if err != nil {
 		log.Error().Err(err).Msg("could not connect to database")
 		return []*Asset{}
@@ -56,14 +60,19 @@ func LoadAssetFromDB(tickers []string) []*Asset {
 	defer conn.Close(ctx)
 
 	var assets []*Asset
-	pgxscan.Select(ctx, conn, &assets, `SELECT ticker, name, asset_type, composite_figi FROM assets WHERE active='t' and ticker = any($1)`, tickers)
+	pgxscan.Select(ctx, conn, &assets, `SELECT ticker, name, primary_exchange, asset_type, composite_figi, COALESCE(currency, '') AS currency FROM assets WHERE active='t' and ticker = any($1)`, tickers)
 	return assets
 }
 
-func ReadAssetsFromDatabase(assetTypes []string) []*Asset {
+// ReadAssetsFromDatabase returns the active assets of the database at dsn
+// selected by filter
+func ReadAssetsFromDatabase(ctx context.Context, dsn string, filter *AssetFilter) []*Asset {
 	log.Info().Msg("reading from database")
-	ctx := context.Background()
-	conn, err := pgx.Connect(ctx, viper.GetString("database.url"))
+	if IsSQLiteDSN(dsn) {
+		return filter.apply(ctx, dsn, readSQLiteAssets(ctx, dsn, "asset_type", filter.AssetTypes))
+	}
+
+	conn, err := pgx.Connect(ctx, dsn)
 	if err != nil {
 		log.Error().Err(err).Msg("could not connect to database")
 		return []*Asset{}
@@ -71,8 +80,57 @@ func ReadAssetsFromDatabase(assetTypes []string) []*Asset {
 	defer conn.Close(ctx)
 
 	var assets []*Asset
-	pgxscan.Select(ctx, conn, &assets, `SELECT ticker, name, asset_type, composite_figi FROM assets WHERE active='t' and asset_type = any($1)`, assetTypes)
-	return assets
+	pgxscan.Select(ctx, conn, &assets, `SELECT ticker, name, primary_exchange, asset_type, composite_figi, COALESCE(currency, '') AS currency FROM assets WHERE active='t' and asset_type = any($1)`, filter.AssetTypes)
+	return filter.apply(ctx, dsn, assets)
+}
+
+// IsOTC returns true if the asset trades over-the-counter (OTC markets, pink
+// sheets, grey market)
+func (asset *Asset) IsOTC() bool {
+	exchange := strings.ToUpper(asset.PrimaryExchange)
+	for _, marker := range []string{"OTC", "PINK", "GREY"} {
+		if strings.Contains(exchange, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultCurrency is the currency assumed for assets that don't specify one
+const DefaultCurrency = "USD"
+
+// QuoteCurrency returns the currency the asset's prices are denominated in
+func (asset *Asset) QuoteCurrency() string {
+	if asset.Currency == "" {
+		return DefaultCurrency
+	}
+	return strings.ToUpper(asset.Currency)
+}
+
+// FilterOTCAssets applies an OTC policy to the list of assets. When policy is
+// "block" OTC assets are removed unless they appear in allowList.
+func FilterOTCAssets(assets []*Asset, policy string, allowList []string) []*Asset {
+	if strings.ToLower(policy) != "block" {
+		return assets
+	}
+
+	allowed := make(map[string]bool)
+	for _, ticker := range allowList {
+		allowed[strings.ToUpper(ticker)] = true
+	}
+
+	filtered := make([]*Asset, 0, len(assets))
+	numBlocked := 0
+	for _, asset := range assets {
+		if asset.IsOTC() && !allowed[strings.ToUpper(asset.Ticker)] {
+			numBlocked++
+			continue
+		}
+		filtered = append(filtered, asset)
+	}
+
+	log.Info().Int("NumBlocked", numBlocked).Msg("removed OTC assets from download list")
+	return filtered
 }
 
 func (asset *Asset) MarshalZerologObject(e *zerolog.Event) {
@@ -82,6 +140,7 @@ func (asset *Asset) MarshalZerologObject(e *zerolog.Event) {
 	e.Str("PrimaryExchange", asset.PrimaryExchange)
 	e.Str("AssetType", string(asset.AssetType))
 	e.Str("CompositeFigi", asset.CompositeFigi)
+	e.Str("Currency", asset.Currency)
 	e.Str("ShareClassFigi", asset.ShareClassFigi)
 	e.Str("CUSIP", asset.CUSIP)
 	e.Str("ISIN", asset.ISIN)
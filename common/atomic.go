@@ -0,0 +1,48 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TempPath returns a hidden temporary file name in the same directory as fn.
+// Output is written to the temporary file and moved into place with
+// CommitFile so consumers never observe a partially written file.
+func TempPath(fn string) string {
+	dir, base := filepath.Split(fn)
+	return filepath.Join(dir, fmt.Sprintf(".%s.%s.tmp", base, RunID))
+}
+
+// CommitFile atomically renames tmp to fn
+func CommitFile(tmp, fn string) error {
+	if err := os.Rename(tmp, fn); err != nil {
+		log.Error().Err(err).Str("TempFile", tmp).Str("FileName", fn).Msg("could not move temporary file into place")
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// AbortFile removes a temporary file after a failed write
+func AbortFile(tmp string) {
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Str("TempFile", tmp).Msg("could not remove temporary file")
+	}
+}
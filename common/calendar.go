@@ -0,0 +1,312 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Calendar describes the trading sessions of an exchange. Dates are taken
+// from the year, month and day of the time passed in, without converting it
+// to the exchange's time zone; convert with In(cal.Location) first if needed.
+type Calendar struct {
+	Name     string
+	Location *time.Location
+
+	// session times as hours and minutes after midnight in Location
+	Open       time.Duration
+	Close      time.Duration
+	EarlyClose time.Duration
+
+	// Weekends is set for venues that trade seven days a week
+	Weekends bool
+
+	holidays    func(year int) map[string]string
+	earlyCloses func(year int) map[string]string
+
+	mu    sync.Mutex
+	cache map[int]*calendarYear
+}
+
+type calendarYear struct {
+	holidays    map[string]string
+	earlyCloses map[string]string
+}
+
+func newUSCalendar(name string) *Calendar {
+	nyc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		nyc = time.UTC
+	}
+	return &Calendar{
+		Name:        name,
+		Location:    nyc,
+		Open:        9*time.Hour + 30*time.Minute,
+		Close:       16 * time.Hour,
+		EarlyClose:  13 * time.Hour,
+		holidays:    nyseHolidays,
+		earlyCloses: nyseEarlyCloses,
+		cache:       make(map[int]*calendarYear),
+	}
+}
+
+// newCalendar returns the calendar of an exchange in the time zone named zone
+// whose holidays are not known; every weekday is treated as a trading day
+func newCalendar(name, zone string, open, close time.Duration) *Calendar {
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		location = time.UTC
+	}
+	return &Calendar{
+		Name:        name,
+		Location:    location,
+		Open:        open,
+		Close:       close,
+		EarlyClose:  close,
+		holidays:    noDates,
+		earlyCloses: noDates,
+		cache:       make(map[int]*calendarYear),
+	}
+}
+
+// NYSE and NASDAQ observe the same holidays and early closes
+var (
+	NYSE   = newUSCalendar("NYSE")
+	NASDAQ = newUSCalendar("NASDAQ")
+)
+
+// Non-US exchanges covered by tiingo. Their holidays are not tracked.
+var (
+	LSE = newCalendar("LSE", "Europe/London", 8*time.Hour, 16*time.Hour+30*time.Minute)
+	TSE = newCalendar("TSE", "Asia/Tokyo", 9*time.Hour, 15*time.Hour+30*time.Minute)
+)
+
+// Crypto trades around the clock; daily bars cover the UTC day and are
+// stamped with its last second
+var Crypto = &Calendar{
+	Name:        "CRYPTO",
+	Location:    time.UTC,
+	Close:       24*time.Hour - time.Second,
+	EarlyClose:  24*time.Hour - time.Second,
+	Weekends:    true,
+	holidays:    noDates,
+	earlyCloses: noDates,
+	cache:       make(map[int]*calendarYear),
+}
+
+// CalendarFor returns the calendar of the given primary exchange. US venues
+// other than NASDAQ, and unknown exchanges, use the NYSE calendar.
+func CalendarFor(exchange string) *Calendar {
+	exchange = strings.ToUpper(exchange)
+	switch exchange {
+	case "LSE", "LON", "XLON":
+		return LSE
+	case "TSE", "TYO", "XTKS", "JPX":
+		return TSE
+	case "CRYPTO":
+		return Crypto
+	}
+	if strings.Contains(exchange, "NASDAQ") {
+		return NASDAQ
+	}
+	return NYSE
+}
+
+func (c *Calendar) year(year int) *calendarYear {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.cache[year]; ok {
+		return cached
+	}
+	cached := &calendarYear{holidays: c.holidays(year), earlyCloses: c.earlyCloses(year)}
+	c.cache[year] = cached
+	return cached
+}
+
+// Holiday returns the name of the market holiday on the date of t, if any
+func (c *Calendar) Holiday(t time.Time) (string, bool) {
+	name, ok := c.year(t.Year()).holidays[dateKey(t)]
+	return name, ok
+}
+
+// IsTradingDay returns true if the exchange is open on the date of t
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	if !c.Weekends && (t.Weekday() == time.Saturday || t.Weekday() == time.Sunday) {
+		return false
+	}
+	_, isHoliday := c.Holiday(t)
+	return !isHoliday
+}
+
+// IsEarlyClose returns true if the date of t is a trading day on which the
+// exchange closes early
+func (c *Calendar) IsEarlyClose(t time.Time) bool {
+	_, ok := c.year(t.Year()).earlyCloses[dateKey(t)]
+	return ok && c.IsTradingDay(t)
+}
+
+// CloseTime returns the time the exchange closes on the date of t, taking
+// early closes into account
+func (c *Calendar) CloseTime(t time.Time) time.Time {
+	closeAt := c.Close
+	if c.IsEarlyClose(t) {
+		closeAt = c.EarlyClose
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.Location).Add(closeAt)
+}
+
+// NextTradingDay returns the first trading day after the date of t
+func (c *Calendar) NextTradingDay(t time.Time) time.Time {
+	t = t.AddDate(0, 0, 1)
+	for !c.IsTradingDay(t) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// PrevTradingDay returns the last trading day before the date of t
+func (c *Calendar) PrevTradingDay(t time.Time) time.Time {
+	t = t.AddDate(0, 0, -1)
+	for !c.IsTradingDay(t) {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// TradingDays returns the trading days between the dates of start and end,
+// inclusive
+func (c *Calendar) TradingDays(start, end time.Time) []time.Time {
+	days := []time.Time{}
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	last := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, start.Location())
+	for ; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if c.IsTradingDay(day) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// nyseHolidays returns the full-day market holidays observed by the NYSE
+// for the given year, keyed by YYYY-MM-DD
+func nyseHolidays(year int) map[string]string {
+	holidays := make(map[string]string)
+	add := func(d time.Time, name string) {
+		holidays[dateKey(d)] = name
+	}
+
+	// New Year's Day; when it falls on a Saturday it is not observed
+	newYears := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if newYears.Weekday() == time.Sunday {
+		newYears = newYears.AddDate(0, 0, 1)
+	}
+	if newYears.Weekday() != time.Saturday {
+		add(newYears, "New Year's Day")
+	}
+
+	if year >= 1998 {
+		add(nthWeekday(year, time.January, time.Monday, 3), "Martin Luther King, Jr. Day")
+	}
+	add(nthWeekday(year, time.February, time.Monday, 3), "Washington's Birthday")
+	add(easter(year).AddDate(0, 0, -2), "Good Friday")
+	add(lastWeekday(year, time.May, time.Monday), "Memorial Day")
+	if year >= 2022 {
+		add(observed(time.Date(year, time.June, 19, 0, 0, 0, 0, time.UTC)), "Juneteenth National Independence Day")
+	}
+	add(observed(time.Date(year, time.July, 4, 0, 0, 0, 0, time.UTC)), "Independence Day")
+	add(nthWeekday(year, time.September, time.Monday, 1), "Labor Day")
+	add(nthWeekday(year, time.November, time.Thursday, 4), "Thanksgiving Day")
+	add(observed(time.Date(year, time.December, 25, 0, 0, 0, 0, time.UTC)), "Christmas Day")
+
+	return holidays
+}
+
+// nyseEarlyCloses returns the days the NYSE closes at 1:00 pm: the day before
+// Independence Day, the day after Thanksgiving and Christmas Eve. July 3 and
+// December 24 only close early on Monday through Thursday; on a Friday they
+// are the observed holiday.
+func nyseEarlyCloses(year int) map[string]string {
+	earlyCloses := make(map[string]string)
+	isMonToThu := func(d time.Time) bool {
+		return d.Weekday() >= time.Monday && d.Weekday() <= time.Thursday
+	}
+
+	if july3 := time.Date(year, time.July, 3, 0, 0, 0, 0, time.UTC); isMonToThu(july3) {
+		earlyCloses[dateKey(july3)] = "Independence Day Eve"
+	}
+	earlyCloses[dateKey(nthWeekday(year, time.November, time.Thursday, 4).AddDate(0, 0, 1))] = "Day after Thanksgiving"
+	if christmasEve := time.Date(year, time.December, 24, 0, 0, 0, 0, time.UTC); isMonToThu(christmasEve) {
+		earlyCloses[dateKey(christmasEve)] = "Christmas Eve"
+	}
+
+	return earlyCloses
+}
+
+// noDates is used for the holidays and early closes of exchanges whose
+// calendar is not known
+func noDates(year int) map[string]string {
+	return map[string]string{}
+}
+
+func dateKey(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// observed moves holidays that fall on a weekend to the nearest weekday
+func observed(d time.Time) time.Time {
+	switch d.Weekday() {
+	case time.Saturday:
+		return d.AddDate(0, 0, -1)
+	case time.Sunday:
+		return d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// nthWeekday returns the nth occurrence of weekday in the given month
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	d := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(weekday) - int(d.Weekday()) + 7) % 7
+	return d.AddDate(0, 0, offset+7*(n-1))
+}
+
+// lastWeekday returns the last occurrence of weekday in the given month
+func lastWeekday(year int, month time.Month, weekday time.Weekday) time.Time {
+	d := time.Date(year, month+1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	offset := (int(d.Weekday()) - int(weekday) + 7) % 7
+	return d.AddDate(0, 0, -offset)
+}
+
+// easter computes the date of Easter Sunday using the anonymous Gregorian
+// algorithm
+func easter(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := ((h + l - 7*m + 114) % 31) + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
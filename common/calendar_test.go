@@ -0,0 +1,165 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNyseHolidays(t *testing.T) {
+	tests := []struct {
+		year int
+		want map[string]string
+	}{
+		{
+			// New Year's Day on a Saturday is not observed; Juneteenth on a
+			// Sunday and Christmas on a Sunday move to Monday
+			year: 2022,
+			want: map[string]string{
+				"2022-01-17": "Martin Luther King, Jr. Day",
+				"2022-02-21": "Washington's Birthday",
+				"2022-04-15": "Good Friday",
+				"2022-05-30": "Memorial Day",
+				"2022-06-20": "Juneteenth National Independence Day",
+				"2022-07-04": "Independence Day",
+				"2022-09-05": "Labor Day",
+				"2022-11-24": "Thanksgiving Day",
+				"2022-12-26": "Christmas Day",
+			},
+		},
+		{
+			// Independence Day on a Sunday moves to Monday and Christmas on a
+			// Saturday to Friday; Juneteenth is not yet a market holiday
+			year: 2021,
+			want: map[string]string{
+				"2021-01-01": "New Year's Day",
+				"2021-01-18": "Martin Luther King, Jr. Day",
+				"2021-02-15": "Washington's Birthday",
+				"2021-04-02": "Good Friday",
+				"2021-05-31": "Memorial Day",
+				"2021-07-05": "Independence Day",
+				"2021-09-06": "Labor Day",
+				"2021-11-25": "Thanksgiving Day",
+				"2021-12-24": "Christmas Day",
+			},
+		},
+		{
+			// New Year's Day on a Sunday moves to Monday
+			year: 2017,
+			want: map[string]string{
+				"2017-01-02": "New Year's Day",
+				"2017-01-16": "Martin Luther King, Jr. Day",
+				"2017-02-20": "Washington's Birthday",
+				"2017-04-14": "Good Friday",
+				"2017-05-29": "Memorial Day",
+				"2017-07-04": "Independence Day",
+				"2017-09-04": "Labor Day",
+				"2017-11-23": "Thanksgiving Day",
+				"2017-12-25": "Christmas Day",
+			},
+		},
+		{
+			// Independence Day on a Saturday moves to Friday
+			year: 2026,
+			want: map[string]string{
+				"2026-01-01": "New Year's Day",
+				"2026-01-19": "Martin Luther King, Jr. Day",
+				"2026-02-16": "Washington's Birthday",
+				"2026-04-03": "Good Friday",
+				"2026-05-25": "Memorial Day",
+				"2026-06-19": "Juneteenth National Independence Day",
+				"2026-07-03": "Independence Day",
+				"2026-09-07": "Labor Day",
+				"2026-11-26": "Thanksgiving Day",
+				"2026-12-25": "Christmas Day",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if got := nyseHolidays(test.year); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d: expected %v, got %v", test.year, test.want, got)
+		}
+	}
+}
+
+func TestEaster(t *testing.T) {
+	tests := map[int]string{
+		2008: "2008-03-23",
+		2011: "2011-04-24",
+		2019: "2019-04-21",
+		2024: "2024-03-31",
+		2025: "2025-04-20",
+		2038: "2038-04-25",
+	}
+
+	for year, want := range tests {
+		if got := dateKey(easter(year)); got != want {
+			t.Errorf("%d: expected %s, got %s", year, want, got)
+		}
+	}
+}
+
+func TestNyseEarlyCloses(t *testing.T) {
+	tests := []struct {
+		year int
+		want map[string]string
+	}{
+		{
+			year: 2024,
+			want: map[string]string{
+				"2024-07-03": "Independence Day Eve",
+				"2024-11-29": "Day after Thanksgiving",
+				"2024-12-24": "Christmas Eve",
+			},
+		},
+		{
+			// July 3 is the observed Independence Day
+			year: 2026,
+			want: map[string]string{
+				"2026-11-27": "Day after Thanksgiving",
+				"2026-12-24": "Christmas Eve",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if got := nyseEarlyCloses(test.year); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d: expected %v, got %v", test.year, test.want, got)
+		}
+	}
+}
+
+func TestCalendarTradingDays(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+
+	if NYSE.IsTradingDay(day("2024-03-29")) {
+		t.Errorf("expected Good Friday 2024-03-29 not to be a trading day")
+	}
+	if got := dateKey(NYSE.NextTradingDay(day("2024-03-28"))); got != "2024-04-01" {
+		t.Errorf("expected the trading day after 2024-03-28 to be 2024-04-01, got %s", got)
+	}
+	if got := dateKey(NYSE.PrevTradingDay(day("2022-12-27"))); got != "2022-12-23" {
+		t.Errorf("expected the trading day before 2022-12-27 to be 2022-12-23, got %s", got)
+	}
+	if got := len(NYSE.TradingDays(day("2024-01-01"), day("2024-12-31"))); got != 252 {
+		t.Errorf("expected 252 trading days in 2024, got %d", got)
+	}
+}
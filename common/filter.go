@@ -0,0 +1,170 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrUnsupportedDatabase is returned when a filter needs data that the
+// configured database does not store
+var ErrUnsupportedDatabase = errors.New("not supported by this database")
+
+// AssetFilter selects the asset universe read from the database
+type AssetFilter struct {
+	// AssetTypes is required; only assets of these types are read
+	AssetTypes []string
+
+	// Exchanges restricts assets to the given primary exchanges (e.g. NYSE,
+	// NASDAQ); matching is case-insensitive
+	Exchanges []string
+
+	// Include lists tickers that are always downloaded, even if they are
+	// removed by the other filters
+	Include []string
+
+	// Exclude lists tickers that are never downloaded
+	Exclude []string
+
+	// Patterns, when set, require the ticker to match at least one expression
+	Patterns []*regexp.Regexp
+
+	// ExcludePatterns removes assets whose ticker matches any expression
+	ExcludePatterns []*regexp.Regexp
+
+	// MinMarketCap removes assets whose market cap (most recent shares
+	// outstanding times the most recent close) is below the value. Assets
+	// without fundamentals, e.g. ETFs, have no market cap and are removed.
+	MinMarketCap float64
+}
+
+// apply removes the assets that do not pass the filter and then adds the
+// assets listed in Include
+func (filter *AssetFilter) apply(ctx context.Context, dsn string, assets []*Asset) []*Asset {
+	exchanges := make(map[string]bool, len(filter.Exchanges))
+	for _, exchange := range filter.Exchanges {
+		exchanges[strings.ToUpper(exchange)] = true
+	}
+	excluded := make(map[string]bool, len(filter.Exclude))
+	for _, ticker := range filter.Exclude {
+		excluded[strings.ToUpper(ticker)] = true
+	}
+
+	var marketCaps map[string]float64
+	if filter.MinMarketCap > 0 {
+		var err error
+		marketCaps, err = LoadMarketCaps(ctx, dsn)
+		if err != nil {
+			return []*Asset{}
+		}
+	}
+
+	filtered := make([]*Asset, 0, len(assets))
+	seen := make(map[string]bool, len(assets))
+	for _, asset := range assets {
+		ticker := strings.ToUpper(asset.Ticker)
+		switch {
+		case len(exchanges) > 0 && !exchanges[strings.ToUpper(asset.PrimaryExchange)]:
+			continue
+		case excluded[ticker]:
+			continue
+		case len(filter.Patterns) > 0 && !matchesAny(filter.Patterns, asset.Ticker):
+			continue
+		case matchesAny(filter.ExcludePatterns, asset.Ticker):
+			continue
+		case marketCaps != nil && marketCaps[asset.CompositeFigi] < filter.MinMarketCap:
+			continue
+		}
+		filtered = append(filtered, asset)
+		seen[ticker] = true
+	}
+
+	if len(filter.Include) > 0 {
+		for _, asset := range LoadAssetFromDB(ctx, dsn, filter.Include) {
+			if !seen[strings.ToUpper(asset.Ticker)] {
+				filtered = append(filtered, asset)
+				seen[strings.ToUpper(asset.Ticker)] = true
+			}
+		}
+	}
+
+	if len(filtered) != len(assets) {
+		log.Info().Int("NumRead", len(assets)).Int("NumMatched", len(filtered)).Msg("filtered asset universe")
+	}
+	return filtered
+}
+
+func matchesAny(patterns []*regexp.Regexp, ticker string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(ticker) {
+			return true
+		}
+	}
+	return false
+}
+
+type marketCap struct {
+	CompositeFigi string  `db:"composite_figi"`
+	MarketCap     float64 `db:"market_cap"`
+}
+
+// LoadMarketCaps computes the market cap of every asset with fundamentals
+// from its most recent shares outstanding and most recent close, keyed by
+// composite figi
+func LoadMarketCaps(ctx context.Context, dsn string) (map[string]float64, error) {
+	if IsSQLiteDSN(dsn) {
+		log.Error().Msg("the market cap filter requires fundamentals, which are not stored in sqlite")
+		return nil, ErrUnsupportedDatabase
+	}
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("could not connect to database")
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	var rows []*marketCap
+	err = pgxscan.Select(ctx, conn, &rows, `SELECT f.composite_figi, f.shares_outstanding * e.close AS market_cap
+		FROM (
+			SELECT DISTINCT ON (composite_figi) composite_figi, shares_outstanding
+			FROM fundamentals
+			WHERE shares_outstanding IS NOT NULL
+			ORDER BY composite_figi, event_date DESC
+		) f
+		JOIN LATERAL (
+			SELECT close FROM eod
+			WHERE eod.composite_figi = f.composite_figi AND close IS NOT NULL
+			ORDER BY event_date DESC
+			LIMIT 1
+		) e ON true`)
+	if err != nil {
+		log.Error().Err(err).Msg("could not compute market caps")
+		return nil, err
+	}
+
+	marketCaps := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		marketCaps[row.CompositeFigi] = row.MarketCap
+	}
+	return marketCaps, nil
+}
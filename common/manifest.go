@@ -0,0 +1,104 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ManifestFile describes a single output file
+type ManifestFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Bytes  int64  `json:"bytes"`
+	Rows   int    `json:"rows"`
+}
+
+// Manifest lists the files generated by a run along with their checksums so
+// downstream transfers can verify integrity
+type Manifest struct {
+	RunID   string          `json:"run_id"`
+	Created time.Time       `json:"created"`
+	Files   []*ManifestFile `json:"files"`
+}
+
+func NewManifest() *Manifest {
+	return &Manifest{
+		RunID:   RunID,
+		Created: time.Now(),
+		Files:   []*ManifestFile{},
+	}
+}
+
+// AddFile computes the checksum of fn and adds it to the manifest
+func (m *Manifest) AddFile(fn string, rows int) error {
+	return m.AddFileAs(fn, filepath.Base(fn), rows)
+}
+
+// AddFileAs computes the checksum of fn and adds it to the manifest under
+// name, e.g. a path relative to the output directory
+func (m *Manifest) AddFileAs(fn, name string, rows int) error {
+	fh, err := os.Open(fn)
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not open file for checksum")
+		return err
+	}
+	defer fh.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, fh)
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not compute checksum")
+		return err
+	}
+
+	m.Files = append(m.Files, &ManifestFile{
+		Path:   name,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+		Bytes:  size,
+		Rows:   rows,
+	})
+	return nil
+}
+
+// Write saves the manifest as JSON to fn
+func (m *Manifest) Write(fn string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := TempPath(fn)
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not write manifest")
+		AbortFile(tmp)
+		return err
+	}
+
+	if err := CommitFile(tmp, fn); err != nil {
+		return err
+	}
+
+	log.Info().Str("FileName", fn).Int("NumFiles", len(m.Files)).Msg("wrote manifest")
+	return nil
+}
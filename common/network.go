@@ -0,0 +1,108 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+)
+
+// TLSVersions maps the accepted names of a minimum TLS version to its
+// crypto/tls constant
+var TLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSVersionNames returns the names of the TLS versions in sorted order
+func TLSVersionNames() []string {
+	names := make([]string, 0, len(TLSVersions))
+	for name := range TLSVersions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NetworkOptions configure how HTTP clients reach the internet, e.g. from
+// inside a corporate network without direct egress
+type NetworkOptions struct {
+	// Proxy is the URL of an HTTP(S) or SOCKS5 proxy; empty uses the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	Proxy string
+
+	// CABundle is a PEM file of certificate authorities trusted in addition
+	// to the system pool, e.g. for a TLS-intercepting proxy
+	CABundle string
+
+	// TLSMinVersion is one of TLSVersions; empty uses Go's default
+	TLSMinVersion string
+}
+
+// IsZero returns true if no network setting is configured
+func (opts NetworkOptions) IsZero() bool {
+	return opts == NetworkOptions{}
+}
+
+// Transport returns a copy of http.DefaultTransport that uses the configured
+// proxy, certificate authorities and minimum TLS version
+func (opts NetworkOptions) Transport() (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		if proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q: expected scheme://host:port", opts.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{}
+	if opts.TLSMinVersion != "" {
+		version, ok := TLSVersions[opts.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS version %q", opts.TLSMinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if opts.CABundle != "" {
+		data, err := os.ReadFile(opts.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", opts.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go-source/s3"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// OutputTarget is an open output file; Commit makes the written file visible
+// and Abort discards it
+type OutputTarget struct {
+	File   source.ParquetFile
+	Commit func() error
+	Abort  func()
+}
+
+// OpenOutput opens fn for writing. Local files are written to a
+// temporary location and renamed on commit; s3:// URIs are uploaded with a
+// multipart upload, using the client and ACL configured by s3Opts, that is
+// aborted if the write fails.
+func OpenOutput(ctx context.Context, fn string, s3Opts S3Options) (*OutputTarget, error) {
+	if IsS3URI(fn) {
+		bucket, key, err := ParseS3URI(fn)
+		if err != nil {
+			return nil, err
+		}
+		client, err := NewS3Client(s3Opts)
+		if err != nil {
+			return nil, err
+		}
+
+		uploadCtx, cancel := context.WithCancel(ctx)
+		fh, err := s3.NewS3FileWriterWithClient(uploadCtx, client, bucket, key, s3Opts.ACL, nil)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return &OutputTarget{
+			File: fh,
+			Commit: func() error {
+				defer cancel()
+				return fh.Close()
+			},
+			Abort: func() {
+				cancel()
+				fh.Close()
+			},
+		}, nil
+	}
+
+	tmp := TempPath(fn)
+	fh, err := local.NewLocalFileWriter(tmp)
+	if err != nil {
+		return nil, err
+	}
+	return &OutputTarget{
+		File: fh,
+		Commit: func() error {
+			if err := fh.Close(); err != nil {
+				AbortFile(tmp)
+				return err
+			}
+			return CommitFile(tmp, fn)
+		},
+		Abort: func() {
+			fh.Close()
+			AbortFile(tmp)
+		},
+	}, nil
+}
+
+// OpenInput opens fn, a local path or s3:// URI, for reading; an error
+// wrapping os.ErrNotExist is returned if the file does not exist
+func OpenInput(ctx context.Context, fn string, s3Opts S3Options) (source.ParquetFile, error) {
+	if !IsS3URI(fn) {
+		return local.NewLocalFileReader(fn)
+	}
+
+	bucket, key, err := ParseS3URI(fn)
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewS3Client(s3Opts)
+	if err != nil {
+		return nil, err
+	}
+	fh, err := s3.NewS3FileReaderWithClient(ctx, client, bucket, key)
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && (awsErr.Code() == "NotFound" || awsErr.Code() == "NoSuchKey") {
+		return nil, fmt.Errorf("%s: %w", fn, os.ErrNotExist)
+	}
+	return fh, err
+}
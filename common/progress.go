@@ -0,0 +1,185 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	ProgressBar  = "bar"
+	ProgressJSON = "json"
+	ProgressNone = "none"
+)
+
+// Progress reports the progress of a long running phase of the import. Items
+// are counted when they complete (successfully or not) rather than when they
+// are scheduled so the progress matches the data that has arrived.
+type Progress interface {
+	// Add marks n items as successfully completed
+	Add(n int)
+
+	// Error marks an item as completed with a failure
+	Error()
+
+	// Current reports the item that was most recently started, e.g. a ticker
+	Current(item string)
+
+	// AddBytes records n bytes transferred
+	AddBytes(n int)
+
+	// Finish is called once the phase is complete
+	Finish()
+}
+
+// ProgressFunc creates a progress reporter for the named phase of total
+// items
+type ProgressFunc func(phase string, total int) Progress
+
+// NoProgress is a ProgressFunc whose reporters discard all updates
+func NoProgress(phase string, total int) Progress {
+	return &noProgress{}
+}
+
+// NewJSONProgress creates a progress reporter that writes ProgressEvents to
+// out as single-line JSON
+func NewJSONProgress(out io.Writer, phase string, total int) Progress {
+	return &jsonProgress{
+		out:   out,
+		phase: phase,
+		total: total,
+		runID: RunID,
+		start: time.Now(),
+	}
+}
+
+type noProgress struct{}
+
+func (p *noProgress) Add(n int)           {}
+func (p *noProgress) Error()              {}
+func (p *noProgress) Current(item string) {}
+func (p *noProgress) AddBytes(n int)      {}
+func (p *noProgress) Finish()             {}
+
+// FormatBytes formats n as a human readable size, e.g. 12.3 MB
+func FormatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for value := n / unit; value >= unit; value /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+// ProgressEvent is emitted as single-line JSON in json progress mode
+type ProgressEvent struct {
+	RunID      string    `json:"run_id"`
+	Phase      string    `json:"phase"`
+	Completed  int       `json:"completed"`
+	Total      int       `json:"total"`
+	Errors     int       `json:"errors"`
+	Bytes      int64     `json:"bytes"`
+	Current    string    `json:"current,omitempty"`
+	ETASeconds float64   `json:"eta_seconds"`
+	Done       bool      `json:"done"`
+	Time       time.Time `json:"time"`
+}
+
+type jsonProgress struct {
+	mu        sync.Mutex
+	out       io.Writer
+	runID     string
+	phase     string
+	completed int
+	total     int
+	errors    int
+	bytes     int64
+	current   string
+	start     time.Time
+	lastEmit  time.Time
+}
+
+func (p *jsonProgress) Add(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.completed += n
+	// throttle events to at most one per second
+	if time.Since(p.lastEmit) >= time.Second {
+		p.emit(false)
+	}
+}
+
+func (p *jsonProgress) Error() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errors++
+	p.completed++
+}
+
+func (p *jsonProgress) Current(item string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current = item
+}
+
+func (p *jsonProgress) AddBytes(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bytes += int64(n)
+}
+
+func (p *jsonProgress) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.emit(true)
+}
+
+func (p *jsonProgress) emit(done bool) {
+	p.lastEmit = time.Now()
+	data, err := json.Marshal(ProgressEvent{
+		RunID:      p.runID,
+		Phase:      p.phase,
+		Completed:  p.completed,
+		Total:      p.total,
+		Errors:     p.errors,
+		Bytes:      p.bytes,
+		Current:    p.current,
+		ETASeconds: eta(p.start, p.completed, p.total, done),
+		Done:       done,
+		Time:       p.lastEmit,
+	})
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(p.out, string(data))
+}
+
+// eta estimates the seconds remaining from the rate items have completed at
+// since start
+func eta(start time.Time, completed, total int, done bool) float64 {
+	if completed == 0 || done {
+		return 0
+	}
+	elapsed := time.Since(start).Seconds()
+	return elapsed / float64(completed) * float64(total-completed)
+}
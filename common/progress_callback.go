@@ -0,0 +1,163 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// ProgressStatus is POSTed to the progress callback URL
+type ProgressStatus struct {
+	RunID      string  `json:"run_id"`
+	Phase      string  `json:"phase"`
+	Completed  int     `json:"completed"`
+	Total      int     `json:"total"`
+	Errors     int     `json:"errors"`
+	Bytes      int64   `json:"bytes"`
+	Current    string  `json:"current,omitempty"`
+	Percent    float64 `json:"percent"`
+	ETASeconds float64 `json:"eta_seconds"`
+	Done       bool    `json:"done"`
+}
+
+// callbackProgress wraps another progress reporter and periodically POSTs
+// the current status to a URL
+type callbackProgress struct {
+	mu        sync.Mutex
+	inner     Progress
+	client    *resty.Client
+	url       string
+	phase     string
+	total     int
+	completed int
+	errors    int
+	bytes     int64
+	current   string
+	start     time.Time
+	stop      chan struct{}
+	done      sync.WaitGroup
+}
+
+// NewCallbackProgress wraps inner and POSTs the status of the phase to url
+// every interval (default 10s) and once more when it finishes
+func NewCallbackProgress(inner Progress, url string, interval time.Duration, phase string, total int) Progress {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	p := &callbackProgress{
+		inner:  inner,
+		client: resty.New().SetTimeout(5 * time.Second),
+		url:    url,
+		phase:  phase,
+		total:  total,
+		start:  time.Now(),
+		stop:   make(chan struct{}),
+	}
+
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.post(false)
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+func (p *callbackProgress) Add(n int) {
+	p.mu.Lock()
+	p.completed += n
+	p.mu.Unlock()
+	p.inner.Add(n)
+}
+
+func (p *callbackProgress) Error() {
+	p.mu.Lock()
+	p.errors++
+	p.completed++
+	p.mu.Unlock()
+	p.inner.Error()
+}
+
+func (p *callbackProgress) Current(item string) {
+	p.mu.Lock()
+	p.current = item
+	p.mu.Unlock()
+	p.inner.Current(item)
+}
+
+func (p *callbackProgress) AddBytes(n int) {
+	p.mu.Lock()
+	p.bytes += int64(n)
+	p.mu.Unlock()
+	p.inner.AddBytes(n)
+}
+
+func (p *callbackProgress) Finish() {
+	close(p.stop)
+	p.done.Wait()
+	p.post(true)
+	p.inner.Finish()
+}
+
+func (p *callbackProgress) status(done bool) ProgressStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := ProgressStatus{
+		RunID:      RunID,
+		Phase:      p.phase,
+		Completed:  p.completed,
+		Total:      p.total,
+		Errors:     p.errors,
+		Bytes:      p.bytes,
+		Current:    p.current,
+		ETASeconds: eta(p.start, p.completed, p.total, done),
+		Done:       done,
+	}
+
+	if p.total > 0 {
+		status.Percent = float64(p.completed) / float64(p.total) * 100
+	}
+
+	return status
+}
+
+func (p *callbackProgress) post(done bool) {
+	resp, err := p.client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(p.status(done)).
+		Post(p.url)
+	if err != nil {
+		log.Warn().Err(err).Str("Url", p.url).Msg("could not post progress")
+		return
+	}
+	if resp.StatusCode() >= 400 {
+		log.Warn().Int("StatusCode", resp.StatusCode()).Str("Url", p.url).Msg("progress callback returned an error")
+	}
+}
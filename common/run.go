@@ -0,0 +1,28 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RunID is a unique identifier for the current invocation. It is included in
+// every log line and stored in the lineage columns of rows written by the run.
+var RunID = uuid.NewString()
+
+// RunStarted is the time the current invocation started
+var RunStarted = time.Now()
@@ -0,0 +1,200 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// Run outcomes stored in import_runs
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// RunRecord is a row of the import_runs ledger
+type RunRecord struct {
+	RunID               string            `db:"run_id" json:"run_id"`
+	Command             string            `db:"command" json:"command"`
+	Parameters          map[string]string `db:"-" json:"parameters,omitempty"`
+	StartTime           time.Time         `db:"start_time" json:"start_time"`
+	EndTime             *time.Time        `db:"end_time" json:"end_time,omitempty"`
+	Status              string            `db:"status" json:"status"`
+	NumTickersAttempted int               `db:"num_tickers_attempted" json:"num_tickers_attempted"`
+	NumTickersSucceeded int               `db:"num_tickers_succeeded" json:"num_tickers_succeeded"`
+	NumFailedTickers    int               `db:"num_failed_tickers" json:"num_failed_tickers"`
+	NumQuotes           int               `db:"num_quotes" json:"num_quotes"`
+	RowsWritten         map[string]int    `db:"-" json:"rows_written,omitempty"`
+	APICalls            int               `db:"api_calls" json:"api_calls"`
+	Error               string            `db:"error" json:"error,omitempty"`
+}
+
+// runColumns are the columns of import_runs in the order of runValues
+const runColumns = `run_id, command, parameters, start_time, end_time, status, num_tickers_attempted,
+	num_tickers_succeeded, num_failed_tickers, num_quotes, rows_written, api_calls, error`
+
+// runUpdates are the columns updated when a run is recorded again
+const runUpdates = `command = EXCLUDED.command, parameters = EXCLUDED.parameters, end_time = EXCLUDED.end_time,
+	status = EXCLUDED.status, num_tickers_attempted = EXCLUDED.num_tickers_attempted,
+	num_tickers_succeeded = EXCLUDED.num_tickers_succeeded, num_failed_tickers = EXCLUDED.num_failed_tickers,
+	num_quotes = EXCLUDED.num_quotes, rows_written = EXCLUDED.rows_written, api_calls = EXCLUDED.api_calls,
+	error = EXCLUDED.error`
+
+// sqliteRunsSchemaSQL creates the import_runs table in a sqlite database
+const sqliteRunsSchemaSQL = `CREATE TABLE IF NOT EXISTS import_runs (
+	run_id TEXT PRIMARY KEY,
+	command TEXT,
+	parameters TEXT,
+	start_time TIMESTAMP NOT NULL,
+	end_time TIMESTAMP,
+	status TEXT NOT NULL,
+	num_tickers_attempted INTEGER,
+	num_tickers_succeeded INTEGER,
+	num_failed_tickers INTEGER,
+	num_quotes INTEGER,
+	rows_written TEXT,
+	api_calls INTEGER,
+	error TEXT
+)`
+
+func (r *RunRecord) values() []any {
+	parameters, _ := json.Marshal(r.Parameters)
+	rowsWritten, _ := json.Marshal(r.RowsWritten)
+	return []any{
+		r.RunID, r.Command, string(parameters), r.StartTime, r.EndTime, r.Status, r.NumTickersAttempted,
+		r.NumTickersSucceeded, r.NumFailedTickers, r.NumQuotes, string(rowsWritten), r.APICalls, r.Error,
+	}
+}
+
+// RecordRun upserts run into the import_runs table of the database selected
+// by dsn. Runs are keyed on their run id so a run may be recorded when it
+// starts and again when it finishes.
+func RecordRun(ctx context.Context, dsn string, run *RunRecord) error {
+	switch {
+	case IsClickHouseDSN(dsn):
+		log.Debug().Msg("the run ledger is not stored in clickhouse")
+		return nil
+	case IsSQLiteDSN(dsn):
+		db, err := OpenSQLite(ctx, dsn)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		if _, err := db.ExecContext(ctx, sqliteRunsSchemaSQL); err != nil {
+			return err
+		}
+		_, err = db.ExecContext(ctx, `INSERT INTO import_runs (`+runColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (run_id) DO UPDATE SET `+runUpdates, run.values()...)
+		return err
+	default:
+		conn, err := pgx.Connect(ctx, dsn)
+		if err != nil {
+			return err
+		}
+		defer conn.Close(ctx)
+		_, err = conn.Exec(ctx, `INSERT INTO import_runs (`+runColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			ON CONFLICT (run_id) DO UPDATE SET `+runUpdates, run.values()...)
+		return err
+	}
+}
+
+// runRow is a row of import_runs with the JSON columns as text
+type runRow struct {
+	RunRecord
+	ParametersJSON  sql.NullString `db:"parameters"`
+	RowsWrittenJSON sql.NullString `db:"rows_written"`
+}
+
+// ListRuns returns the most recent limit runs recorded in the database
+// selected by dsn, newest first
+func ListRuns(ctx context.Context, dsn string, limit int) ([]*RunRecord, error) {
+	query := `SELECT ` + runColumns + ` FROM import_runs ORDER BY start_time DESC LIMIT `
+	var rows []*runRow
+
+	switch {
+	case IsClickHouseDSN(dsn):
+		return nil, ErrUnsupportedDatabase
+	case IsSQLiteDSN(dsn):
+		db, err := OpenSQLite(ctx, dsn)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+		if _, err := db.ExecContext(ctx, sqliteRunsSchemaSQL); err != nil {
+			return nil, err
+		}
+
+		result, err := db.QueryContext(ctx, query+`?`, limit)
+		if err != nil {
+			return nil, err
+		}
+		defer result.Close()
+		for result.Next() {
+			r := &runRow{}
+			var command, status, runErr sql.NullString
+			var attempted, succeeded, failed, quotes, apiCalls sql.NullInt64
+			var endTime sql.NullTime
+			if err := result.Scan(&r.RunID, &command, &r.ParametersJSON, &r.StartTime, &endTime, &status, &attempted,
+				&succeeded, &failed, &quotes, &r.RowsWrittenJSON, &apiCalls, &runErr); err != nil {
+				return nil, err
+			}
+			r.Command, r.Status, r.Error = command.String, status.String, runErr.String
+			r.NumTickersAttempted, r.NumTickersSucceeded = int(attempted.Int64), int(succeeded.Int64)
+			r.NumFailedTickers, r.NumQuotes, r.APICalls = int(failed.Int64), int(quotes.Int64), int(apiCalls.Int64)
+			if endTime.Valid {
+				r.EndTime = &endTime.Time
+			}
+			rows = append(rows, r)
+		}
+		if err := result.Err(); err != nil {
+			return nil, err
+		}
+	default:
+		conn, err := pgx.Connect(ctx, dsn)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close(ctx)
+		if err := pgxscan.Select(ctx, conn, &rows, `SELECT run_id, COALESCE(command, '') AS command, parameters::text AS parameters,
+			start_time, end_time, status, COALESCE(num_tickers_attempted, 0) AS num_tickers_attempted,
+			COALESCE(num_tickers_succeeded, 0) AS num_tickers_succeeded, COALESCE(num_failed_tickers, 0) AS num_failed_tickers,
+			COALESCE(num_quotes, 0) AS num_quotes, rows_written::text AS rows_written, COALESCE(api_calls, 0) AS api_calls,
+			COALESCE(error, '') AS error
+			FROM import_runs ORDER BY start_time DESC LIMIT $1`, limit); err != nil {
+			return nil, err
+		}
+	}
+
+	runs := make([]*RunRecord, len(rows))
+	for idx, r := range rows {
+		if r.ParametersJSON.Valid {
+			json.Unmarshal([]byte(r.ParametersJSON.String), &r.Parameters)
+		}
+		if r.RowsWrittenJSON.Valid {
+			json.Unmarshal([]byte(r.RowsWrittenJSON.String), &r.RowsWritten)
+		}
+		run := r.RunRecord
+		runs[idx] = &run
+	}
+	return runs, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// IsS3URI returns true if uri refers to an object in S3-compatible storage
+func IsS3URI(uri string) bool {
+	return strings.HasPrefix(uri, "s3://")
+}
+
+// ExpandURI replaces the {date} and {run_id} placeholders in an output
+// location, e.g. s3://bucket/prefix/eod-{date}.parquet
+func ExpandURI(uri string) string {
+	uri = strings.ReplaceAll(uri, "{date}", time.Now().Format("2006-01-02"))
+	uri = strings.ReplaceAll(uri, "{run_id}", RunID)
+	return uri
+}
+
+// ParseS3URI splits an s3://bucket/key URI into bucket and key
+func ParseS3URI(uri string) (string, string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid s3 uri '%s'; expected s3://bucket/key", uri)
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if key == "" {
+		return "", "", fmt.Errorf("s3 uri '%s' has no object key", uri)
+	}
+	return u.Host, key, nil
+}
+
+// S3Options configures access to S3-compatible storage; see the s3.*
+// settings
+type S3Options struct {
+	Region          string
+	Endpoint        string
+	PathStyle       bool
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// ACL is the canned ACL applied to uploaded objects
+	ACL string
+}
+
+// NewS3Client creates an S3 client from opts. When no access key is
+// configured credentials are resolved by the AWS SDK (environment, shared
+// credentials file, instance role). Set Endpoint and PathStyle to use MinIO
+// or another S3-compatible store.
+func NewS3Client(opts S3Options) (*s3.S3, error) {
+	cfg := aws.NewConfig().WithRegion(opts.Region)
+	if opts.Endpoint != "" {
+		cfg = cfg.WithEndpoint(opts.Endpoint)
+	}
+	if opts.PathStyle {
+		cfg = cfg.WithS3ForcePathStyle(true)
+	}
+	if opts.AccessKeyID != "" {
+		cfg = cfg.WithCredentials(credentials.NewStaticCredentials(opts.AccessKeyID, opts.SecretAccessKey, ""))
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return s3.New(sess), nil
+}
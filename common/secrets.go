@@ -0,0 +1,207 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-resty/resty/v2"
+)
+
+// Secret reference schemes
+const (
+	VaultScheme          = "vault"
+	SecretsManagerScheme = "awssm"
+)
+
+// IsSecretURI returns true if value references a secret stored in HashiCorp
+// Vault (vault://secret/tiingo#token) or AWS Secrets Manager
+// (awssm://prod/tiingo#token)
+func IsSecretURI(value string) bool {
+	return strings.HasPrefix(value, VaultScheme+"://") || strings.HasPrefix(value, SecretsManagerScheme+"://")
+}
+
+// SecretOptions locates the secret stores used by ResolveSecret
+type SecretOptions struct {
+	// VaultAddress and VaultToken default to $VAULT_ADDR and $VAULT_TOKEN
+	VaultAddress string
+	VaultToken   string
+
+	// Region is the AWS region used when the reference does not set one
+	Region string
+}
+
+// ResolveSecret returns the secret referenced by uri. The fragment selects a
+// field of the secret; it may be omitted for AWS secrets stored as plain
+// strings.
+//
+// Vault secrets are read from opts.VaultAddress using opts.VaultToken; both
+// KV version 2 and version 1 mounts are supported. AWS secrets use the SDK's
+// default credential chain; set the region with ?region= or opts.Region
+// (default $AWS_REGION).
+func ResolveSecret(ctx context.Context, uri string, opts SecretOptions) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("invalid secret reference: %w", err)
+	}
+	path := strings.Trim(u.Host+u.Path, "/")
+	if path == "" {
+		return "", fmt.Errorf("secret reference %s has no path", redactSecretURI(uri))
+	}
+
+	switch u.Scheme {
+	case VaultScheme:
+		if u.Fragment == "" {
+			return "", fmt.Errorf("vault secret reference %s must name a field, e.g. vault://secret/tiingo#token", redactSecretURI(uri))
+		}
+		return readVaultSecret(ctx, path, u.Fragment, opts)
+	case SecretsManagerScheme:
+		region := u.Query().Get("region")
+		if region == "" {
+			region = opts.Region
+		}
+		return readAWSSecret(ctx, path, u.Fragment, region)
+	default:
+		return "", fmt.Errorf("unknown secret scheme %q", u.Scheme)
+	}
+}
+
+// redactSecretURI drops the query string of a secret reference for messages
+func redactSecretURI(uri string) string {
+	if idx := strings.Index(uri, "?"); idx >= 0 {
+		return uri[:idx]
+	}
+	return uri
+}
+
+// readVaultSecret reads field from the vault secret at path. The path is
+// first read as a KV version 2 secret (mount/data/rest) and then as a KV
+// version 1 secret.
+func readVaultSecret(ctx context.Context, path, field string, opts SecretOptions) (string, error) {
+	address := opts.VaultAddress
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	token := opts.VaultToken
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if address == "" || token == "" {
+		return "", fmt.Errorf("vault secret %s requested but vault.address and vault.token (or VAULT_ADDR and VAULT_TOKEN) are not set", path)
+	}
+
+	client := resty.New().
+		SetBaseURL(strings.TrimSuffix(address, "/")).
+		SetTimeout(10*time.Second).
+		SetRetryCount(2).
+		SetHeader("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		client.SetHeader("X-Vault-Namespace", namespace)
+	}
+
+	mount, rest, _ := strings.Cut(path, "/")
+	candidates := []string{path}
+	if rest != "" {
+		candidates = []string{mount + "/data/" + rest, path}
+	}
+
+	for _, candidate := range candidates {
+		resp, err := client.R().SetContext(ctx).Get("/v1/" + candidate)
+		if err != nil {
+			return "", fmt.Errorf("could not reach vault: %w", err)
+		}
+		if resp.StatusCode() == http.StatusNotFound {
+			continue
+		}
+		if resp.StatusCode() >= 400 {
+			return "", fmt.Errorf("vault returned status %d reading %s", resp.StatusCode(), path)
+		}
+
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal(resp.Body(), &body); err != nil {
+			return "", fmt.Errorf("could not parse vault response for %s: %w", path, err)
+		}
+
+		// KV version 2 nests the secret's fields under data.data
+		data := body.Data
+		if nested, ok := data["data"].(map[string]interface{}); ok {
+			if _, isV2 := data["metadata"]; isV2 {
+				data = nested
+			}
+		}
+		return secretField(data, path, field)
+	}
+
+	return "", fmt.Errorf("vault secret %s does not exist", path)
+}
+
+// readAWSSecret reads an AWS Secrets Manager secret. When field is set the
+// secret must be a JSON object and the field's value is returned.
+func readAWSSecret(ctx context.Context, name, field, region string) (string, error) {
+	cfg := aws.NewConfig()
+	if region != "" {
+		cfg = cfg.WithRegion(region)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *cfg,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	out, err := secretsmanager.New(sess).GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not read aws secret %s: %w", name, err)
+	}
+
+	value := aws.StringValue(out.SecretString)
+	if field == "" {
+		return value, nil
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return "", fmt.Errorf("aws secret %s is not a JSON object; remove #%s to use the whole value", name, field)
+	}
+	return secretField(data, name, field)
+}
+
+func secretField(data map[string]interface{}, path, field string) (string, error) {
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no field %q", path, field)
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
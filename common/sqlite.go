@@ -0,0 +1,96 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteAssetsSchemaSQL creates the subset of the assets table used by
+// import-tiingo in a sqlite database
+const sqliteAssetsSchemaSQL = `CREATE TABLE IF NOT EXISTS assets (
+	ticker TEXT NOT NULL,
+	name TEXT,
+	primary_exchange TEXT,
+	asset_type TEXT,
+	composite_figi TEXT NOT NULL,
+	currency TEXT,
+	active BOOLEAN NOT NULL DEFAULT 1,
+	PRIMARY KEY (ticker, composite_figi)
+)`
+
+// OpenSQLite opens the sqlite database selected by dsn and creates the
+// assets table if it does not exist
+func OpenSQLite(ctx context.Context, dsn string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", SQLitePath(dsn))
+	if err != nil {
+		return nil, err
+	}
+
+	// sqlite allows a single writer; serialize on one connection
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.ExecContext(ctx, sqliteAssetsSchemaSQL); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// readSQLiteAssets returns active assets from a sqlite database whose column
+// matches one of values
+func readSQLiteAssets(ctx context.Context, dsn string, column string, values []string) []*Asset {
+	db, err := OpenSQLite(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("could not open sqlite database")
+		return []*Asset{}
+	}
+	defer db.Close()
+
+	assets := []*Asset{}
+	if len(values) == 0 {
+		return assets
+	}
+
+	placeholders := make([]string, len(values))
+	args := make([]interface{}, len(values))
+	for idx, value := range values {
+		placeholders[idx] = "?"
+		args[idx] = value
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT ticker, COALESCE(name, ''), COALESCE(primary_exchange, ''), COALESCE(asset_type, ''), composite_figi, COALESCE(currency, '')
+		FROM assets WHERE active AND `+column+` IN (`+strings.Join(placeholders, ", ")+`)`, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query sqlite assets")
+		return assets
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		asset := &Asset{}
+		if err := rows.Scan(&asset.Ticker, &asset.Name, &asset.PrimaryExchange, &asset.AssetType, &asset.CompositeFigi, &asset.Currency); err != nil {
+			log.Error().Err(err).Msg("could not scan sqlite asset")
+			continue
+		}
+		assets = append(assets, asset)
+	}
+	return assets
+}
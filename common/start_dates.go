@@ -0,0 +1,72 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// LoadStartDates reads a mapping of ticker to start date from a CSV file. Each
+// line has the form `TICKER,YYYY-MM-DD`; blank lines and lines starting with #
+// are ignored.
+func LoadStartDates(fn string) (map[string]time.Time, error) {
+	fh, err := os.Open(fn)
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not open start date file")
+		return nil, err
+	}
+	defer fh.Close()
+
+	reader := csv.NewReader(fh)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	startDates := make(map[string]time.Time)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Error().Err(err).Str("FileName", fn).Msg("could not parse start date file")
+			return nil, err
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("invalid start date record %v; expected ticker,date", record)
+		}
+
+		ticker := strings.ToUpper(strings.TrimSpace(record[0]))
+		startDate, err := time.Parse("2006-01-02", strings.TrimSpace(record[1]))
+		if err != nil {
+			log.Error().Err(err).Str("Ticker", ticker).Str("Date", record[1]).Msg("could not parse start date")
+			return nil, err
+		}
+		startDates[ticker] = startDate
+	}
+
+	log.Info().Int("NumTickers", len(startDates)).Str("FileName", fn).Msg("loaded per-asset start dates")
+	return startDates, nil
+}
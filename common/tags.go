@@ -0,0 +1,226 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrInvalidTagExpr = errors.New("invalid tag expression")
+
+// TagExpr is a boolean expression over asset tags, e.g.
+// `sp500 AND NOT (financials OR utilities)`
+type TagExpr interface {
+	Eval(tags map[string]bool) bool
+}
+
+type tagLiteral string
+type tagNot struct{ expr TagExpr }
+type tagAnd struct{ left, right TagExpr }
+type tagOr struct{ left, right TagExpr }
+
+func (e tagLiteral) Eval(tags map[string]bool) bool { return tags[string(e)] }
+func (e tagNot) Eval(tags map[string]bool) bool     { return !e.expr.Eval(tags) }
+func (e tagAnd) Eval(tags map[string]bool) bool     { return e.left.Eval(tags) && e.right.Eval(tags) }
+func (e tagOr) Eval(tags map[string]bool) bool      { return e.left.Eval(tags) || e.right.Eval(tags) }
+
+// ParseTagExpr parses a tag expression. Tags are combined with AND, OR and NOT
+// (case-insensitive) and may be grouped with parentheses. NOT binds tighter
+// than AND, which binds tighter than OR.
+func ParseTagExpr(expr string) (TagExpr, error) {
+	p := &tagParser{tokens: tokenizeTagExpr(expr)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("%w: empty expression", ErrInvalidTagExpr)
+	}
+
+	result, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected '%s'", ErrInvalidTagExpr, p.tokens[p.pos])
+	}
+	return result, nil
+}
+
+func tokenizeTagExpr(expr string) []string {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range expr {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+type tagParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *tagParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *tagParser) isKeyword(keyword string) bool {
+	return strings.EqualFold(p.peek(), keyword)
+}
+
+func (p *tagParser) parseOr() (TagExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = tagOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *tagParser) parseAnd() (TagExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = tagAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *tagParser) parseNot() (TagExpr, error) {
+	if p.isKeyword("NOT") {
+		p.pos++
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return tagNot{expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *tagParser) parsePrimary() (TagExpr, error) {
+	token := p.peek()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("%w: unexpected end of expression", ErrInvalidTagExpr)
+	case token == "(":
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("%w: missing ')'", ErrInvalidTagExpr)
+		}
+		p.pos++
+		return expr, nil
+	case token == ")" || p.isKeyword("AND") || p.isKeyword("OR"):
+		return nil, fmt.Errorf("%w: unexpected '%s'", ErrInvalidTagExpr, token)
+	}
+	p.pos++
+	return tagLiteral(strings.ToLower(token)), nil
+}
+
+type assetTag struct {
+	CompositeFigi string `db:"composite_figi"`
+	Tag           string `db:"tag"`
+}
+
+// LoadAssetTags reads the tags of all assets from the asset_tags table of the
+// database at dsn, keyed by composite figi. Tags are lower-cased.
+func LoadAssetTags(ctx context.Context, dsn string) (map[string]map[string]bool, error) {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("could not connect to database")
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	var rows []*assetTag
+	if err := pgxscan.Select(ctx, conn, &rows, `SELECT composite_figi, tag FROM asset_tags`); err != nil {
+		log.Error().Err(err).Msg("could not load asset tags")
+		return nil, err
+	}
+
+	tags := make(map[string]map[string]bool)
+	for _, row := range rows {
+		if _, ok := tags[row.CompositeFigi]; !ok {
+			tags[row.CompositeFigi] = make(map[string]bool)
+		}
+		tags[row.CompositeFigi][strings.ToLower(row.Tag)] = true
+	}
+	return tags, nil
+}
+
+// FilterAssetsByTags returns the assets whose tags match the tag expression
+func FilterAssetsByTags(ctx context.Context, dsn string, assets []*Asset, expr string) ([]*Asset, error) {
+	tagExpr, err := ParseTagExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := LoadAssetTags(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]*Asset, 0, len(assets))
+	for _, asset := range assets {
+		if tagExpr.Eval(tags[asset.CompositeFigi]) {
+			filtered = append(filtered, asset)
+		}
+	}
+
+	log.Info().Str("Tags", expr).Int("NumMatched", len(filtered)).Msg("filtered assets by tag expression")
+	return filtered, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseTagExpr(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want TagExpr
+	}{
+		{
+			name: "single tag is lower-cased",
+			expr: "SP500",
+			want: tagLiteral("sp500"),
+		},
+		{
+			name: "AND binds tighter than OR",
+			expr: "a OR b AND c",
+			want: tagOr{tagLiteral("a"), tagAnd{tagLiteral("b"), tagLiteral("c")}},
+		},
+		{
+			name: "NOT binds tighter than AND",
+			expr: "NOT a AND b",
+			want: tagAnd{tagNot{tagLiteral("a")}, tagLiteral("b")},
+		},
+		{
+			name: "operators are left associative",
+			expr: "a AND b AND c OR d OR e",
+			want: tagOr{tagOr{tagAnd{tagAnd{tagLiteral("a"), tagLiteral("b")}, tagLiteral("c")}, tagLiteral("d")}, tagLiteral("e")},
+		},
+		{
+			name: "parentheses override precedence",
+			expr: "(a OR b) AND c",
+			want: tagAnd{tagOr{tagLiteral("a"), tagLiteral("b")}, tagLiteral("c")},
+		},
+		{
+			name: "nested parentheses",
+			expr: "a AND NOT ((b OR c) AND (d))",
+			want: tagAnd{tagLiteral("a"), tagNot{tagAnd{tagOr{tagLiteral("b"), tagLiteral("c")}, tagLiteral("d")}}},
+		},
+		{
+			name: "keywords are case-insensitive",
+			expr: "a and not b Or c",
+			want: tagOr{tagAnd{tagLiteral("a"), tagNot{tagLiteral("b")}}, tagLiteral("c")},
+		},
+		{
+			name: "double negation",
+			expr: "NOT NOT a",
+			want: tagNot{tagNot{tagLiteral("a")}},
+		},
+		{
+			name: "parentheses need no surrounding spaces",
+			expr: "a AND(b OR c)",
+			want: tagAnd{tagLiteral("a"), tagOr{tagLiteral("b"), tagLiteral("c")}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseTagExpr(test.expr)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %#v, got %#v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTagExprErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"a AND",
+		"(a",
+		"a b",
+		")",
+		"a OR OR b",
+		"NOT",
+		"()",
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			_, err := ParseTagExpr(expr)
+			if !errors.Is(err, ErrInvalidTagExpr) {
+				t.Errorf("expected ErrInvalidTagExpr, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTagExprEval(t *testing.T) {
+	expr, err := ParseTagExpr("sp500 AND NOT (financials OR utilities)")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		tags []string
+		want bool
+	}{
+		{tags: []string{"sp500"}, want: true},
+		{tags: []string{"sp500", "tech"}, want: true},
+		{tags: []string{"sp500", "financials"}, want: false},
+		{tags: []string{"sp500", "utilities"}, want: false},
+		{tags: []string{"tech"}, want: false},
+		{tags: nil, want: false},
+	}
+
+	for _, test := range tests {
+		tags := make(map[string]bool)
+		for _, tag := range test.tags {
+			tags[tag] = true
+		}
+		if got := expr.Eval(tags); got != test.want {
+			t.Errorf("tags %v: expected %v, got %v", test.tags, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2021
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ReadTickersFile reads the assets to download from fn, or from stdin when fn
+// is "-". Each line holds a ticker optionally followed by a comma and the
+// asset's composite FIGI; lines starting with # are ignored. A header row
+// naming the columns (ticker, composite_figi) may be used to put them in any
+// order.
+func ReadTickersFile(fn string) ([]*Asset, error) {
+	var reader io.Reader = os.Stdin
+	if fn != "-" {
+		fh, err := os.Open(fn)
+		if err != nil {
+			return nil, err
+		}
+		defer fh.Close()
+		reader = fh
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.Comment = '#'
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	tickerCol, figiCol := 0, 1
+	assets := []*Asset{}
+	seen := make(map[string]bool)
+	for lineNum := 1; ; lineNum++ {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+
+		if lineNum == 1 && isTickersHeader(record) {
+			tickerCol, figiCol = -1, -1
+			for idx, column := range record {
+				switch strings.ToLower(strings.TrimSpace(column)) {
+				case "ticker", "symbol":
+					tickerCol = idx
+				case "composite_figi", "compositefigi", "figi":
+					figiCol = idx
+				}
+			}
+			if tickerCol < 0 {
+				return nil, fmt.Errorf("%s: header does not have a ticker column", fn)
+			}
+			continue
+		}
+
+		if tickerCol >= len(record) {
+			continue
+		}
+		ticker := strings.ToUpper(strings.TrimSpace(record[tickerCol]))
+		if ticker == "" || seen[ticker] {
+			continue
+		}
+		seen[ticker] = true
+
+		asset := &Asset{Ticker: ticker}
+		if figiCol >= 0 && figiCol < len(record) {
+			asset.CompositeFigi = strings.ToUpper(strings.TrimSpace(record[figiCol]))
+		}
+		assets = append(assets, asset)
+	}
+
+	return assets, nil
+}
+
+func isTickersHeader(record []string) bool {
+	for _, column := range record {
+		switch strings.ToLower(strings.TrimSpace(column)) {
+		case "ticker", "symbol":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS assets (
+    ticker TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    primary_exchange TEXT NOT NULL DEFAULT '',
+    asset_type TEXT NOT NULL DEFAULT '',
+    composite_figi TEXT NOT NULL DEFAULT '',
+    currency TEXT,
+    listing_date DATE,
+    delisting_date DATE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    source TEXT,
+    last_updated BIGINT,
+    CONSTRAINT assets_pkey PRIMARY KEY (ticker, composite_figi)
+);
+
+CREATE INDEX IF NOT EXISTS assets_composite_figi_idx ON assets (composite_figi);
+CREATE INDEX IF NOT EXISTS assets_asset_type_idx ON assets (asset_type) WHERE active;
+
+CREATE TABLE IF NOT EXISTS asset_tags (
+    composite_figi TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    CONSTRAINT asset_tags_pkey PRIMARY KEY (composite_figi, tag)
+);
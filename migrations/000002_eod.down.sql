@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS eod_history;
+DROP TABLE IF EXISTS eod;
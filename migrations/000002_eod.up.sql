@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS eod (
+    ticker TEXT NOT NULL,
+    composite_figi TEXT NOT NULL,
+    currency TEXT,
+    event_date DATE NOT NULL,
+    open REAL,
+    high REAL,
+    low REAL,
+    close REAL,
+    volume REAL,
+    dividend REAL,
+    split_factor REAL,
+    split_adjust_factor REAL,
+    dividend_adjust_factor REAL,
+    adjusted_volume REAL,
+    adj_open REAL,
+    adj_high REAL,
+    adj_low REAL,
+    adj_close REAL,
+    source TEXT,
+    run_id TEXT,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    deactivated_at TIMESTAMPTZ,
+    CONSTRAINT eod_pkey PRIMARY KEY (composite_figi, event_date)
+);
+
+CREATE INDEX IF NOT EXISTS eod_event_date_idx ON eod (event_date);
+
+-- prior values of bars changed by a re-import (--track-corrections)
+CREATE TABLE IF NOT EXISTS eod_history (
+    ticker TEXT NOT NULL,
+    composite_figi TEXT NOT NULL,
+    event_date DATE NOT NULL,
+    open REAL,
+    high REAL,
+    low REAL,
+    close REAL,
+    volume REAL,
+    dividend REAL,
+    split_factor REAL,
+    source TEXT,
+    run_id TEXT,
+    valid_from TIMESTAMPTZ,
+    valid_to TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS eod_history_figi_date_idx ON eod_history (composite_figi, event_date);
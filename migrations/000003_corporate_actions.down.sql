@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS ticker_changes;
+DROP TABLE IF EXISTS corporate_actions;
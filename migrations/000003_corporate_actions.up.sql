@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS corporate_actions (
+    composite_figi TEXT NOT NULL,
+    ticker TEXT NOT NULL,
+    ex_date DATE NOT NULL,
+    action_type TEXT NOT NULL,
+    value REAL NOT NULL,
+    source TEXT,
+    run_id TEXT,
+    CONSTRAINT corporate_actions_pkey PRIMARY KEY (composite_figi, ex_date, action_type)
+);
+
+CREATE TABLE IF NOT EXISTS ticker_changes (
+    composite_figi TEXT NOT NULL,
+    old_ticker TEXT NOT NULL,
+    new_ticker TEXT NOT NULL,
+    change_date DATE,
+    source TEXT,
+    run_id TEXT,
+    CONSTRAINT ticker_changes_pkey PRIMARY KEY (composite_figi, old_ticker, new_ticker)
+);
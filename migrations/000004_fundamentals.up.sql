@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS fundamentals (
+    ticker TEXT NOT NULL,
+    composite_figi TEXT NOT NULL,
+    event_date DATE NOT NULL,
+    year INTEGER NOT NULL,
+    quarter INTEGER NOT NULL,
+    revenue DOUBLE PRECISION,
+    cost_of_revenue DOUBLE PRECISION,
+    gross_profit DOUBLE PRECISION,
+    operating_expenses DOUBLE PRECISION,
+    operating_income DOUBLE PRECISION,
+    research_and_development DOUBLE PRECISION,
+    selling_general_admin DOUBLE PRECISION,
+    interest_expense DOUBLE PRECISION,
+    tax_expense DOUBLE PRECISION,
+    ebit DOUBLE PRECISION,
+    ebitda DOUBLE PRECISION,
+    net_income DOUBLE PRECISION,
+    eps DOUBLE PRECISION,
+    eps_diluted DOUBLE PRECISION,
+    shares_weighted_avg DOUBLE PRECISION,
+    shares_weighted_avg_diluted DOUBLE PRECISION,
+    total_assets DOUBLE PRECISION,
+    current_assets DOUBLE PRECISION,
+    cash_and_equivalents DOUBLE PRECISION,
+    inventory DOUBLE PRECISION,
+    accounts_receivable DOUBLE PRECISION,
+    total_liabilities DOUBLE PRECISION,
+    current_liabilities DOUBLE PRECISION,
+    accounts_payable DOUBLE PRECISION,
+    total_debt DOUBLE PRECISION,
+    equity DOUBLE PRECISION,
+    retained_earnings DOUBLE PRECISION,
+    shares_outstanding DOUBLE PRECISION,
+    operating_cash_flow DOUBLE PRECISION,
+    investing_cash_flow DOUBLE PRECISION,
+    financing_cash_flow DOUBLE PRECISION,
+    capital_expenditure DOUBLE PRECISION,
+    free_cash_flow DOUBLE PRECISION,
+    dividends_paid DOUBLE PRECISION,
+    stock_based_compensation DOUBLE PRECISION,
+    depreciation_amortization DOUBLE PRECISION,
+    run_id TEXT,
+    CONSTRAINT fundamentals_pkey PRIMARY KEY (composite_figi, event_date, quarter)
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS news;
+DROP TABLE IF EXISTS intraday;
+DROP TABLE IF EXISTS crypto_eod;
+DROP TABLE IF EXISTS currency_rates;
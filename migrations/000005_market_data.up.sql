@@ -0,0 +1,58 @@
+CREATE TABLE IF NOT EXISTS currency_rates (
+    base_currency TEXT NOT NULL,
+    quote_currency TEXT NOT NULL,
+    event_date DATE NOT NULL,
+    open DOUBLE PRECISION,
+    high DOUBLE PRECISION,
+    low DOUBLE PRECISION,
+    close DOUBLE PRECISION,
+    source TEXT,
+    run_id TEXT,
+    CONSTRAINT currency_rates_pkey PRIMARY KEY (base_currency, quote_currency, event_date)
+);
+
+CREATE TABLE IF NOT EXISTS crypto_eod (
+    ticker TEXT NOT NULL,
+    exchange TEXT NOT NULL DEFAULT '',
+    currency TEXT,
+    event_date DATE NOT NULL,
+    open REAL,
+    high REAL,
+    low REAL,
+    close REAL,
+    volume REAL,
+    source TEXT,
+    run_id TEXT,
+    CONSTRAINT crypto_eod_pkey PRIMARY KEY (ticker, exchange, event_date)
+);
+
+CREATE TABLE IF NOT EXISTS intraday (
+    ticker TEXT NOT NULL,
+    composite_figi TEXT NOT NULL,
+    event_time TIMESTAMPTZ NOT NULL,
+    frequency TEXT NOT NULL,
+    open REAL,
+    high REAL,
+    low REAL,
+    close REAL,
+    volume REAL,
+    source TEXT,
+    run_id TEXT,
+    CONSTRAINT intraday_pkey PRIMARY KEY (composite_figi, frequency, event_time)
+);
+
+CREATE TABLE IF NOT EXISTS news (
+    id BIGINT NOT NULL,
+    title TEXT,
+    url TEXT,
+    description TEXT,
+    source TEXT,
+    published_date TIMESTAMPTZ,
+    crawl_date TIMESTAMPTZ,
+    tickers TEXT[],
+    tags TEXT[],
+    run_id TEXT,
+    CONSTRAINT news_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS news_tickers_idx ON news USING GIN (tickers);
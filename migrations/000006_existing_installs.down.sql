@@ -0,0 +1,3 @@
+-- the columns added for existing installs are part of the base schema and
+-- are dropped with their tables
+DROP TABLE IF EXISTS eod_archive;
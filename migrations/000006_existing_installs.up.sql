@@ -0,0 +1,24 @@
+-- databases created before migrations were introduced already have the
+-- tables above; add the columns used by newer features
+ALTER TABLE assets
+    ADD COLUMN IF NOT EXISTS listing_date DATE,
+    ADD COLUMN IF NOT EXISTS delisting_date DATE,
+    ADD COLUMN IF NOT EXISTS source TEXT,
+    ADD COLUMN IF NOT EXISTS last_updated BIGINT;
+
+ALTER TABLE eod
+    ADD COLUMN IF NOT EXISTS currency TEXT,
+    ADD COLUMN IF NOT EXISTS split_adjust_factor REAL,
+    ADD COLUMN IF NOT EXISTS dividend_adjust_factor REAL,
+    ADD COLUMN IF NOT EXISTS adjusted_volume REAL,
+    ADD COLUMN IF NOT EXISTS adj_open REAL,
+    ADD COLUMN IF NOT EXISTS adj_high REAL,
+    ADD COLUMN IF NOT EXISTS adj_low REAL,
+    ADD COLUMN IF NOT EXISTS adj_close REAL,
+    ADD COLUMN IF NOT EXISTS run_id TEXT,
+    ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMPTZ;
+
+-- quotes of delisted assets removed by prune; created after the columns above
+-- so the copy of the eod layout is complete
+CREATE TABLE IF NOT EXISTS eod_archive (LIKE eod INCLUDING ALL);
@@ -0,0 +1,4 @@
+ALTER TABLE assets
+    DROP COLUMN IF EXISTS possibly_delisted,
+    DROP COLUMN IF EXISTS missing_runs,
+    DROP COLUMN IF EXISTS last_seen;
@@ -0,0 +1,5 @@
+-- delisting detection (see the prune command)
+ALTER TABLE assets
+    ADD COLUMN IF NOT EXISTS last_seen DATE,
+    ADD COLUMN IF NOT EXISTS missing_runs INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS possibly_delisted BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS eod_annually;
+DROP TABLE IF EXISTS eod_monthly;
+DROP TABLE IF EXISTS eod_weekly;
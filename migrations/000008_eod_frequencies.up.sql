@@ -0,0 +1,15 @@
+-- resampled bars downloaded with --frequency weekly|monthly|annually
+CREATE TABLE IF NOT EXISTS eod_weekly (
+    LIKE eod INCLUDING DEFAULTS,
+    CONSTRAINT eod_weekly_pkey PRIMARY KEY (composite_figi, event_date)
+);
+
+CREATE TABLE IF NOT EXISTS eod_monthly (
+    LIKE eod INCLUDING DEFAULTS,
+    CONSTRAINT eod_monthly_pkey PRIMARY KEY (composite_figi, event_date)
+);
+
+CREATE TABLE IF NOT EXISTS eod_annually (
+    LIKE eod INCLUDING DEFAULTS,
+    CONSTRAINT eod_annually_pkey PRIMARY KEY (composite_figi, event_date)
+);
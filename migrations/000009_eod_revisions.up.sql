@@ -0,0 +1,16 @@
+-- restatements of stored close or volume detected by a re-import
+-- (--track-revisions)
+CREATE TABLE IF NOT EXISTS eod_revisions (
+    ticker TEXT NOT NULL,
+    composite_figi TEXT NOT NULL,
+    event_date DATE NOT NULL,
+    old_close REAL,
+    new_close REAL,
+    old_volume REAL,
+    new_volume REAL,
+    run_id TEXT,
+    revised_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS eod_revisions_figi_date_idx ON eod_revisions (composite_figi, event_date);
+CREATE INDEX IF NOT EXISTS eod_revisions_revised_at_idx ON eod_revisions (revised_at);
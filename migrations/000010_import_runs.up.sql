@@ -0,0 +1,18 @@
+-- ledger of import-tiingo runs (--record-runs); see the runs subcommand
+CREATE TABLE IF NOT EXISTS import_runs (
+    run_id TEXT PRIMARY KEY,
+    command TEXT,
+    parameters JSONB,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ,
+    status TEXT NOT NULL,
+    num_tickers_attempted INTEGER,
+    num_tickers_succeeded INTEGER,
+    num_failed_tickers INTEGER,
+    num_quotes INTEGER,
+    rows_written JSONB,
+    api_calls INTEGER,
+    error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS import_runs_start_time_idx ON import_runs (start_time);
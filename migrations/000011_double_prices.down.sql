@@ -0,0 +1,111 @@
+ALTER TABLE eod
+    ALTER COLUMN open TYPE REAL,
+    ALTER COLUMN high TYPE REAL,
+    ALTER COLUMN low TYPE REAL,
+    ALTER COLUMN close TYPE REAL,
+    ALTER COLUMN dividend TYPE REAL,
+    ALTER COLUMN split_factor TYPE REAL,
+    ALTER COLUMN split_adjust_factor TYPE REAL,
+    ALTER COLUMN dividend_adjust_factor TYPE REAL,
+    ALTER COLUMN adj_open TYPE REAL,
+    ALTER COLUMN adj_high TYPE REAL,
+    ALTER COLUMN adj_low TYPE REAL,
+    ALTER COLUMN adj_close TYPE REAL,
+    ALTER COLUMN volume TYPE REAL,
+    ALTER COLUMN adjusted_volume TYPE REAL;
+
+ALTER TABLE eod_archive
+    ALTER COLUMN open TYPE REAL,
+    ALTER COLUMN high TYPE REAL,
+    ALTER COLUMN low TYPE REAL,
+    ALTER COLUMN close TYPE REAL,
+    ALTER COLUMN dividend TYPE REAL,
+    ALTER COLUMN split_factor TYPE REAL,
+    ALTER COLUMN split_adjust_factor TYPE REAL,
+    ALTER COLUMN dividend_adjust_factor TYPE REAL,
+    ALTER COLUMN adj_open TYPE REAL,
+    ALTER COLUMN adj_high TYPE REAL,
+    ALTER COLUMN adj_low TYPE REAL,
+    ALTER COLUMN adj_close TYPE REAL,
+    ALTER COLUMN volume TYPE REAL,
+    ALTER COLUMN adjusted_volume TYPE REAL;
+
+ALTER TABLE eod_weekly
+    ALTER COLUMN open TYPE REAL,
+    ALTER COLUMN high TYPE REAL,
+    ALTER COLUMN low TYPE REAL,
+    ALTER COLUMN close TYPE REAL,
+    ALTER COLUMN dividend TYPE REAL,
+    ALTER COLUMN split_factor TYPE REAL,
+    ALTER COLUMN split_adjust_factor TYPE REAL,
+    ALTER COLUMN dividend_adjust_factor TYPE REAL,
+    ALTER COLUMN adj_open TYPE REAL,
+    ALTER COLUMN adj_high TYPE REAL,
+    ALTER COLUMN adj_low TYPE REAL,
+    ALTER COLUMN adj_close TYPE REAL,
+    ALTER COLUMN volume TYPE REAL,
+    ALTER COLUMN adjusted_volume TYPE REAL;
+
+ALTER TABLE eod_monthly
+    ALTER COLUMN open TYPE REAL,
+    ALTER COLUMN high TYPE REAL,
+    ALTER COLUMN low TYPE REAL,
+    ALTER COLUMN close TYPE REAL,
+    ALTER COLUMN dividend TYPE REAL,
+    ALTER COLUMN split_factor TYPE REAL,
+    ALTER COLUMN split_adjust_factor TYPE REAL,
+    ALTER COLUMN dividend_adjust_factor TYPE REAL,
+    ALTER COLUMN adj_open TYPE REAL,
+    ALTER COLUMN adj_high TYPE REAL,
+    ALTER COLUMN adj_low TYPE REAL,
+    ALTER COLUMN adj_close TYPE REAL,
+    ALTER COLUMN volume TYPE REAL,
+    ALTER COLUMN adjusted_volume TYPE REAL;
+
+ALTER TABLE eod_annually
+    ALTER COLUMN open TYPE REAL,
+    ALTER COLUMN high TYPE REAL,
+    ALTER COLUMN low TYPE REAL,
+    ALTER COLUMN close TYPE REAL,
+    ALTER COLUMN dividend TYPE REAL,
+    ALTER COLUMN split_factor TYPE REAL,
+    ALTER COLUMN split_adjust_factor TYPE REAL,
+    ALTER COLUMN dividend_adjust_factor TYPE REAL,
+    ALTER COLUMN adj_open TYPE REAL,
+    ALTER COLUMN adj_high TYPE REAL,
+    ALTER COLUMN adj_low TYPE REAL,
+    ALTER COLUMN adj_close TYPE REAL,
+    ALTER COLUMN volume TYPE REAL,
+    ALTER COLUMN adjusted_volume TYPE REAL;
+
+ALTER TABLE eod_history
+    ALTER COLUMN open TYPE REAL,
+    ALTER COLUMN high TYPE REAL,
+    ALTER COLUMN low TYPE REAL,
+    ALTER COLUMN close TYPE REAL,
+    ALTER COLUMN dividend TYPE REAL,
+    ALTER COLUMN split_factor TYPE REAL,
+    ALTER COLUMN volume TYPE REAL;
+
+ALTER TABLE eod_revisions
+    ALTER COLUMN old_close TYPE REAL,
+    ALTER COLUMN new_close TYPE REAL,
+    ALTER COLUMN old_volume TYPE REAL,
+    ALTER COLUMN new_volume TYPE REAL;
+
+ALTER TABLE crypto_eod
+    ALTER COLUMN open TYPE REAL,
+    ALTER COLUMN high TYPE REAL,
+    ALTER COLUMN low TYPE REAL,
+    ALTER COLUMN close TYPE REAL,
+    ALTER COLUMN volume TYPE REAL;
+
+ALTER TABLE intraday
+    ALTER COLUMN open TYPE REAL,
+    ALTER COLUMN high TYPE REAL,
+    ALTER COLUMN low TYPE REAL,
+    ALTER COLUMN close TYPE REAL,
+    ALTER COLUMN volume TYPE REAL;
+
+ALTER TABLE corporate_actions
+    ALTER COLUMN value TYPE REAL;
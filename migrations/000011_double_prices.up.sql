@@ -0,0 +1,113 @@
+-- store prices as double precision and volumes as whole shares; float32
+-- prices lose cents on high-priced tickers such as BRK.A
+ALTER TABLE eod
+    ALTER COLUMN open TYPE DOUBLE PRECISION,
+    ALTER COLUMN high TYPE DOUBLE PRECISION,
+    ALTER COLUMN low TYPE DOUBLE PRECISION,
+    ALTER COLUMN close TYPE DOUBLE PRECISION,
+    ALTER COLUMN dividend TYPE DOUBLE PRECISION,
+    ALTER COLUMN split_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN split_adjust_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN dividend_adjust_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_open TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_high TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_low TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_close TYPE DOUBLE PRECISION,
+    ALTER COLUMN volume TYPE BIGINT USING round(volume)::BIGINT,
+    ALTER COLUMN adjusted_volume TYPE BIGINT USING round(adjusted_volume)::BIGINT;
+
+ALTER TABLE eod_archive
+    ALTER COLUMN open TYPE DOUBLE PRECISION,
+    ALTER COLUMN high TYPE DOUBLE PRECISION,
+    ALTER COLUMN low TYPE DOUBLE PRECISION,
+    ALTER COLUMN close TYPE DOUBLE PRECISION,
+    ALTER COLUMN dividend TYPE DOUBLE PRECISION,
+    ALTER COLUMN split_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN split_adjust_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN dividend_adjust_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_open TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_high TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_low TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_close TYPE DOUBLE PRECISION,
+    ALTER COLUMN volume TYPE BIGINT USING round(volume)::BIGINT,
+    ALTER COLUMN adjusted_volume TYPE BIGINT USING round(adjusted_volume)::BIGINT;
+
+ALTER TABLE eod_weekly
+    ALTER COLUMN open TYPE DOUBLE PRECISION,
+    ALTER COLUMN high TYPE DOUBLE PRECISION,
+    ALTER COLUMN low TYPE DOUBLE PRECISION,
+    ALTER COLUMN close TYPE DOUBLE PRECISION,
+    ALTER COLUMN dividend TYPE DOUBLE PRECISION,
+    ALTER COLUMN split_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN split_adjust_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN dividend_adjust_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_open TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_high TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_low TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_close TYPE DOUBLE PRECISION,
+    ALTER COLUMN volume TYPE BIGINT USING round(volume)::BIGINT,
+    ALTER COLUMN adjusted_volume TYPE BIGINT USING round(adjusted_volume)::BIGINT;
+
+ALTER TABLE eod_monthly
+    ALTER COLUMN open TYPE DOUBLE PRECISION,
+    ALTER COLUMN high TYPE DOUBLE PRECISION,
+    ALTER COLUMN low TYPE DOUBLE PRECISION,
+    ALTER COLUMN close TYPE DOUBLE PRECISION,
+    ALTER COLUMN dividend TYPE DOUBLE PRECISION,
+    ALTER COLUMN split_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN split_adjust_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN dividend_adjust_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_open TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_high TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_low TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_close TYPE DOUBLE PRECISION,
+    ALTER COLUMN volume TYPE BIGINT USING round(volume)::BIGINT,
+    ALTER COLUMN adjusted_volume TYPE BIGINT USING round(adjusted_volume)::BIGINT;
+
+ALTER TABLE eod_annually
+    ALTER COLUMN open TYPE DOUBLE PRECISION,
+    ALTER COLUMN high TYPE DOUBLE PRECISION,
+    ALTER COLUMN low TYPE DOUBLE PRECISION,
+    ALTER COLUMN close TYPE DOUBLE PRECISION,
+    ALTER COLUMN dividend TYPE DOUBLE PRECISION,
+    ALTER COLUMN split_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN split_adjust_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN dividend_adjust_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_open TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_high TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_low TYPE DOUBLE PRECISION,
+    ALTER COLUMN adj_close TYPE DOUBLE PRECISION,
+    ALTER COLUMN volume TYPE BIGINT USING round(volume)::BIGINT,
+    ALTER COLUMN adjusted_volume TYPE BIGINT USING round(adjusted_volume)::BIGINT;
+
+ALTER TABLE eod_history
+    ALTER COLUMN open TYPE DOUBLE PRECISION,
+    ALTER COLUMN high TYPE DOUBLE PRECISION,
+    ALTER COLUMN low TYPE DOUBLE PRECISION,
+    ALTER COLUMN close TYPE DOUBLE PRECISION,
+    ALTER COLUMN dividend TYPE DOUBLE PRECISION,
+    ALTER COLUMN split_factor TYPE DOUBLE PRECISION,
+    ALTER COLUMN volume TYPE BIGINT USING round(volume)::BIGINT;
+
+ALTER TABLE eod_revisions
+    ALTER COLUMN old_close TYPE DOUBLE PRECISION,
+    ALTER COLUMN new_close TYPE DOUBLE PRECISION,
+    ALTER COLUMN old_volume TYPE BIGINT USING round(old_volume)::BIGINT,
+    ALTER COLUMN new_volume TYPE BIGINT USING round(new_volume)::BIGINT;
+
+ALTER TABLE crypto_eod
+    ALTER COLUMN open TYPE DOUBLE PRECISION,
+    ALTER COLUMN high TYPE DOUBLE PRECISION,
+    ALTER COLUMN low TYPE DOUBLE PRECISION,
+    ALTER COLUMN close TYPE DOUBLE PRECISION,
+    ALTER COLUMN volume TYPE BIGINT USING round(volume)::BIGINT;
+
+ALTER TABLE intraday
+    ALTER COLUMN open TYPE DOUBLE PRECISION,
+    ALTER COLUMN high TYPE DOUBLE PRECISION,
+    ALTER COLUMN low TYPE DOUBLE PRECISION,
+    ALTER COLUMN close TYPE DOUBLE PRECISION,
+    ALTER COLUMN volume TYPE BIGINT USING round(volume)::BIGINT;
+
+ALTER TABLE corporate_actions
+    ALTER COLUMN value TYPE DOUBLE PRECISION;
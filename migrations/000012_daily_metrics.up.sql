@@ -0,0 +1,15 @@
+-- daily valuation metrics downloaded by the metrics subcommand
+CREATE TABLE IF NOT EXISTS daily_metrics (
+    ticker TEXT NOT NULL,
+    composite_figi TEXT NOT NULL,
+    event_date DATE NOT NULL,
+    market_cap DOUBLE PRECISION,
+    enterprise_value DOUBLE PRECISION,
+    pe_ratio DOUBLE PRECISION,
+    pb_ratio DOUBLE PRECISION,
+    peg_ratio DOUBLE PRECISION,
+    run_id TEXT,
+    CONSTRAINT daily_metrics_pkey PRIMARY KEY (composite_figi, event_date)
+);
+
+CREATE INDEX IF NOT EXISTS daily_metrics_event_date_idx ON daily_metrics (event_date);
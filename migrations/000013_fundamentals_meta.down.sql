@@ -0,0 +1,4 @@
+COMMENT ON COLUMN fundamentals.year IS NULL;
+COMMENT ON COLUMN fundamentals.quarter IS NULL;
+DROP TABLE IF EXISTS fundamentals_meta;
+DROP TABLE IF EXISTS fundamental_definitions;
@@ -0,0 +1,37 @@
+-- statement data code definitions and per-company fundamentals meta data
+-- downloaded by the fundamentals-meta subcommand
+CREATE TABLE IF NOT EXISTS fundamental_definitions (
+    data_code TEXT NOT NULL,
+    name TEXT,
+    description TEXT,
+    statement_type TEXT,
+    units TEXT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fundamental_definitions_pkey PRIMARY KEY (data_code)
+);
+
+CREATE TABLE IF NOT EXISTS fundamentals_meta (
+    composite_figi TEXT NOT NULL,
+    ticker TEXT NOT NULL,
+    perma_ticker TEXT,
+    name TEXT,
+    is_active BOOLEAN,
+    is_adr BOOLEAN,
+    sector TEXT,
+    industry TEXT,
+    sic_code INTEGER,
+    sic_sector TEXT,
+    sic_industry TEXT,
+    reporting_currency TEXT,
+    location TEXT,
+    company_website TEXT,
+    sec_filing_website TEXT,
+    statement_last_updated TIMESTAMPTZ,
+    daily_last_updated TIMESTAMPTZ,
+    run_id TEXT,
+    CONSTRAINT fundamentals_meta_pkey PRIMARY KEY (composite_figi)
+);
+
+COMMENT ON COLUMN fundamentals.year IS 'fiscal year of the statement as reported by the company';
+COMMENT ON COLUMN fundamentals.quarter IS 'fiscal quarter 1-4, or 0 for the annual statement';
+COMMENT ON COLUMN fundamentals_meta.reporting_currency IS 'currency statement values are reported in; units per data code are in fundamental_definitions';
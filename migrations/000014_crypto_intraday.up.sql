@@ -0,0 +1,17 @@
+-- one minute crypto bars per exchange written by the crypto-stream subcommand
+CREATE TABLE IF NOT EXISTS crypto_intraday (
+    ticker TEXT NOT NULL,
+    exchange TEXT NOT NULL,
+    event_time TIMESTAMPTZ NOT NULL,
+    open DOUBLE PRECISION,
+    high DOUBLE PRECISION,
+    low DOUBLE PRECISION,
+    close DOUBLE PRECISION,
+    volume DOUBLE PRECISION,
+    num_trades INTEGER,
+    source TEXT,
+    run_id TEXT,
+    CONSTRAINT crypto_intraday_pkey PRIMARY KEY (ticker, exchange, event_time)
+);
+
+CREATE INDEX IF NOT EXISTS crypto_intraday_event_time_idx ON crypto_intraday (event_time);
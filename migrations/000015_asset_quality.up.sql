@@ -0,0 +1,19 @@
+-- data quality score of each asset's most recently imported quotes
+CREATE TABLE IF NOT EXISTS asset_quality (
+    composite_figi TEXT NOT NULL,
+    ticker TEXT NOT NULL,
+    start_date DATE,
+    end_date DATE,
+    trading_days INTEGER,
+    num_quotes INTEGER,
+    coverage DOUBLE PRECISION,
+    stale_closes INTEGER,
+    zero_volume_days INTEGER,
+    validation_failures INTEGER,
+    score DOUBLE PRECISION,
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    run_id TEXT,
+    CONSTRAINT asset_quality_pkey PRIMARY KEY (composite_figi)
+);
+
+CREATE INDEX IF NOT EXISTS asset_quality_score_idx ON asset_quality (score);
@@ -0,0 +1,2 @@
+ALTER TABLE assets
+    DROP COLUMN IF EXISTS deactivated_at;
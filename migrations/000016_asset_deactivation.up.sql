@@ -0,0 +1,3 @@
+-- when the prune command deactivated an asset
+ALTER TABLE assets
+    ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMPTZ;
@@ -0,0 +1,161 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package migrations embeds the SQL migrations that create and update the
+// postgres schema used by import-tiingo
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratepgx "github.com/golang-migrate/migrate/v4/database/pgx"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// MigrationsTable records the applied schema version. It is specific to
+// import-tiingo so other tools can version the same database independently.
+const MigrationsTable = "import_tiingo_schema_migrations"
+
+//go:embed *.sql
+var files embed.FS
+
+// Status describes the schema version of a database
+type Status struct {
+	Version uint
+	Dirty   bool
+	Latest  uint
+}
+
+// open creates a migrator for the postgres database identified by dsn; both
+// URL and keyword/value DSNs are accepted
+func open(dsn string) (*migrate.Migrate, error) {
+	if common.IsSQLiteDSN(dsn) || common.IsClickHouseDSN(dsn) {
+		return nil, fmt.Errorf("migrations only support postgres databases; sqlite and clickhouse tables are created automatically")
+	}
+
+	source, err := iofs.New(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	driver, err := migratepgx.WithInstance(db, &migratepgx.Config{MigrationsTable: MigrationsTable})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "pgx", driver)
+	if err != nil {
+		driver.Close()
+		return nil, err
+	}
+	m.Log = migrateLogger{}
+	return m, nil
+}
+
+// Up applies all pending migrations
+func Up(dsn string) error {
+	m, err := open(dsn)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// Down reverts the given number of migrations
+func Down(dsn string, steps int) error {
+	m, err := open(dsn)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return m.Steps(-steps)
+}
+
+// Force sets the schema version without running migrations, e.g. to clear
+// the dirty flag after fixing a failed migration by hand
+func Force(dsn string, version int) error {
+	m, err := open(dsn)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return m.Force(version)
+}
+
+// CurrentStatus returns the applied and latest available schema versions
+func CurrentStatus(dsn string) (*Status, error) {
+	m, err := open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	status := &Status{Latest: Latest()}
+	status.Version, status.Dirty, err = m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Latest returns the version of the newest embedded migration
+func Latest() uint {
+	source, err := iofs.New(files, ".")
+	if err != nil {
+		return 0
+	}
+	defer source.Close()
+
+	version, err := source.First()
+	for err == nil {
+		next, nextErr := source.Next(version)
+		if nextErr != nil {
+			break
+		}
+		version = next
+	}
+	return version
+}
+
+// migrateLogger forwards migration progress to zerolog
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...interface{}) {
+	log.Info().Msg(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (migrateLogger) Verbose() bool {
+	return false
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// RunStats summarizes an import run; it is sent as the body of healthcheck
+// pings and written as the run summary report
+type RunStats struct {
+	RunID               string           `json:"run_id"`
+	Command             string           `json:"command,omitempty"`
+	StartTime           time.Time        `json:"start_time"`
+	Duration            string           `json:"duration,omitempty"`
+	ElapsedSeconds      float64          `json:"elapsed_seconds"`
+	NumTickersAttempted int              `json:"num_tickers_attempted"`
+	NumTickersSucceeded int              `json:"num_tickers_succeeded"`
+	NumQuotes           int              `json:"num_quotes"`
+	NumFailedTickers    int              `json:"num_failed_tickers"`
+	Failures            []*TickerFailure `json:"failures,omitempty"`
+	SkippedByDeadline   []string         `json:"skipped_by_deadline,omitempty"`
+	RowsWritten         map[string]int   `json:"rows_written,omitempty"`
+	Anomalies           []string         `json:"anomalies,omitempty"`
+	APICalls            int              `json:"api_calls"`
+	QuotaRemaining      *int64           `json:"quota_remaining,omitempty"`
+	Failed              bool             `json:"failed"`
+	Error               string           `json:"error,omitempty"`
+}
+
+// TickerFailure records why a ticker could not be downloaded
+type TickerFailure struct {
+	Phase      string `json:"phase"`
+	Ticker     string `json:"ticker"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Reason     string `json:"reason"`
+}
+
+// Healthcheck pings a monitoring service when a run starts, succeeds or
+// fails. Services like healthchecks.io and cronitor alert when a ping is
+// missed or a failure is reported.
+type Healthcheck struct {
+	StartURL   string
+	SuccessURL string
+	FailURL    string
+
+	client *resty.Client
+}
+
+// NewHealthcheck returns a healthcheck that pings url on success and uses
+// healthchecks.io style url/start and url/fail endpoints for the start and
+// failure pings. Any of the URLs may be overridden on the returned value;
+// empty URLs are not pinged.
+func NewHealthcheck(url string) *Healthcheck {
+	h := &Healthcheck{
+		client: resty.New().SetTimeout(10 * time.Second).SetRetryCount(2),
+	}
+	if url != "" {
+		url = strings.TrimSuffix(url, "/")
+		h.StartURL = url + "/start"
+		h.SuccessURL = url
+		h.FailURL = url + "/fail"
+	}
+	return h
+}
+
+// Enabled returns true if any ping URL is configured
+func (h *Healthcheck) Enabled() bool {
+	return h.StartURL != "" || h.SuccessURL != "" || h.FailURL != ""
+}
+
+// Start signals that a run has started
+func (h *Healthcheck) Start(ctx context.Context, stats *RunStats) {
+	h.ping(ctx, h.StartURL, stats)
+}
+
+// Finish signals that a run has completed; the failure URL is pinged when
+// stats.Failed is set
+func (h *Healthcheck) Finish(ctx context.Context, stats *RunStats) {
+	if stats.Failed {
+		h.ping(ctx, h.FailURL, stats)
+		return
+	}
+	h.ping(ctx, h.SuccessURL, stats)
+}
+
+// ping posts stats to url. Failures are logged but never fail the run.
+func (h *Healthcheck) ping(ctx context.Context, url string, stats *RunStats) {
+	if url == "" {
+		return
+	}
+
+	body, err := json.Marshal(stats)
+	if err != nil {
+		log.Error().Err(err).Msg("could not marshal run stats")
+		return
+	}
+
+	resp, err := h.client.
+		R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(body).
+		Post(url)
+	if err != nil {
+		log.Warn().Err(err).Str("URL", url).Msg("healthcheck ping failed")
+		return
+	}
+	if resp.StatusCode() >= 400 {
+		log.Warn().Int("StatusCode", resp.StatusCode()).Str("URL", url).Msg("healthcheck ping rejected")
+	}
+}
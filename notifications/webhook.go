@@ -0,0 +1,169 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// Events that trigger a webhook notification
+const (
+	EventSuccess = "success"
+	EventFailure = "failure"
+	EventAnomaly = "anomaly"
+)
+
+// maxListedFailures is the number of failed tickers included in chat messages
+const maxListedFailures = 10
+
+// discordLimit is the maximum length of a discord message
+const discordLimit = 2000
+
+// Webhook posts a summary of each run to Slack, Discord, or a generic webhook
+// that receives the run statistics as JSON
+type Webhook struct {
+	SlackURL   string
+	DiscordURL string
+	URL        string
+
+	// Events lists the events that are posted; an empty list posts all events
+	Events []string
+
+	client *resty.Client
+}
+
+// NewWebhook returns a webhook that posts the given events to the configured
+// URLs; empty URLs are skipped
+func NewWebhook(slackURL, discordURL, url string, events []string) *Webhook {
+	return &Webhook{
+		SlackURL:   slackURL,
+		DiscordURL: discordURL,
+		URL:        url,
+		Events:     events,
+		client:     resty.New().SetTimeout(10 * time.Second).SetRetryCount(2),
+	}
+}
+
+// Enabled returns true if any webhook URL is configured
+func (w *Webhook) Enabled() bool {
+	return w.SlackURL != "" || w.DiscordURL != "" || w.URL != ""
+}
+
+// Event classifies a finished run as a failure, a successful run with
+// anomalies, or a success
+func Event(stats *RunStats) string {
+	switch {
+	case stats.Failed:
+		return EventFailure
+	case len(stats.Anomalies) > 0:
+		return EventAnomaly
+	default:
+		return EventSuccess
+	}
+}
+
+// Notify posts the run summary to each configured URL when the run's event is
+// enabled. Failures are logged but never fail the run.
+func (w *Webhook) Notify(ctx context.Context, stats *RunStats) {
+	event := Event(stats)
+	if !w.wants(event) {
+		return
+	}
+
+	message := FormatSummary(event, stats)
+	if w.SlackURL != "" {
+		w.post(ctx, w.SlackURL, map[string]string{"text": message})
+	}
+	if w.DiscordURL != "" {
+		if len(message) > discordLimit {
+			message = message[:discordLimit-3] + "..."
+		}
+		w.post(ctx, w.DiscordURL, map[string]string{"content": message})
+	}
+	if w.URL != "" {
+		w.post(ctx, w.URL, map[string]any{"event": event, "summary": stats})
+	}
+}
+
+func (w *Webhook) wants(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if strings.EqualFold(e, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatSummary renders the run statistics as a short plain-text message
+func FormatSummary(event string, stats *RunStats) string {
+	var sb strings.Builder
+
+	status := map[string]string{
+		EventSuccess: "completed",
+		EventFailure: "FAILED",
+		EventAnomaly: "completed with anomalies",
+	}[event]
+	command := stats.Command
+	if command == "" {
+		command = "import"
+	}
+	fmt.Fprintf(&sb, "import-tiingo %s %s (run %s)\n", command, status, stats.RunID)
+	fmt.Fprintf(&sb, "Tickers: %d attempted, %d succeeded, %d failed\n",
+		stats.NumTickersAttempted, stats.NumTickersSucceeded, stats.NumFailedTickers)
+	fmt.Fprintf(&sb, "Quotes: %d, API calls: %d, duration: %s\n", stats.NumQuotes, stats.APICalls, stats.Duration)
+
+	if stats.Error != "" {
+		fmt.Fprintf(&sb, "Error: %s\n", stats.Error)
+	}
+	for _, anomaly := range stats.Anomalies {
+		fmt.Fprintf(&sb, "Anomaly: %s\n", anomaly)
+	}
+	for idx, failure := range stats.Failures {
+		if idx == maxListedFailures {
+			fmt.Fprintf(&sb, "... and %d more failures\n", len(stats.Failures)-maxListedFailures)
+			break
+		}
+		fmt.Fprintf(&sb, "Failed %s %s: %s\n", failure.Phase, failure.Ticker, failure.Reason)
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// post sends body as JSON to url
+func (w *Webhook) post(ctx context.Context, url string, body any) {
+	resp, err := w.client.
+		R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(body).
+		Post(url)
+	if err != nil {
+		log.Warn().Err(err).Msg("webhook notification failed")
+		return
+	}
+	if resp.StatusCode() >= 400 {
+		log.Warn().Int("StatusCode", resp.StatusCode()).Msg("webhook notification rejected")
+	}
+}
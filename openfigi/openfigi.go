@@ -0,0 +1,247 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package openfigi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+	"go.uber.org/ratelimit"
+)
+
+const mappingURL = "https://api.openfigi.com/v3/mapping"
+
+// OpenFIGI limits requests per minute and the number of jobs per request;
+// both are higher when an API key is supplied
+const (
+	anonymousRateLimit = 25
+	anonymousBatchSize = 10
+	apiKeyRateLimit    = 250
+	apiKeyBatchSize    = 100
+)
+
+type OpenFigiApi struct {
+	apiKey    string
+	rate      ratelimit.Limiter
+	batchSize int
+}
+
+// Job is a single identifier lookup
+type Job struct {
+	IDType   string `json:"idType"`
+	IDValue  string `json:"idValue"`
+	ExchCode string `json:"exchCode,omitempty"`
+}
+
+// Result is an instrument matched by a job
+type Result struct {
+	Figi           string `json:"figi"`
+	CompositeFigi  string `json:"compositeFIGI"`
+	ShareClassFigi string `json:"shareClassFIGI"`
+	Ticker         string `json:"ticker"`
+	ExchCode       string `json:"exchCode"`
+	Name           string `json:"name"`
+	SecurityType   string `json:"securityType"`
+}
+
+type jobResponse struct {
+	Data    []*Result `json:"data"`
+	Error   string    `json:"error"`
+	Warning string    `json:"warning"`
+}
+
+// New creates an OpenFIGI client. rateLimit is in requests per minute; when 0
+// the published limit for the key (or anonymous access) is used.
+func New(apiKey string, rateLimit int) *OpenFigiApi {
+	batchSize := anonymousBatchSize
+	if rateLimit <= 0 {
+		rateLimit = anonymousRateLimit
+		if apiKey != "" {
+			rateLimit = apiKeyRateLimit
+		}
+	}
+	if apiKey != "" {
+		batchSize = apiKeyBatchSize
+	}
+
+	return &OpenFigiApi{
+		apiKey:    apiKey,
+		rate:      ratelimit.New(rateLimit, ratelimit.Per(time.Minute)),
+		batchSize: batchSize,
+	}
+}
+
+// Map runs the jobs against the OpenFIGI mapping API in batches. The returned
+// slice has one entry per job; jobs without a match have no results.
+func (o *OpenFigiApi) Map(ctx context.Context, jobs []*Job) ([][]*Result, error) {
+	client := resty.New()
+	results := make([][]*Result, 0, len(jobs))
+
+	for start := 0; start < len(jobs); start += o.batchSize {
+		end := start + o.batchSize
+		if end > len(jobs) {
+			end = len(jobs)
+		}
+		batch := jobs[start:end]
+
+		o.rate.Take()
+
+		req := client.
+			R().
+			SetContext(ctx).
+			SetHeader("Content-Type", "application/json").
+			SetBody(batch)
+		if o.apiKey != "" {
+			req.SetHeader("X-OPENFIGI-APIKEY", o.apiKey)
+		}
+
+		resp, err := req.Post(mappingURL)
+		if err != nil {
+			log.Error().Err(err).Msg("error when requesting openfigi mapping")
+			return results, err
+		}
+		if resp.StatusCode() >= 400 {
+			log.Error().Int("StatusCode", resp.StatusCode()).Bytes("Body", resp.Body()).Msg("error when requesting openfigi mapping")
+			return results, fmt.Errorf("openfigi mapping failed with status %d", resp.StatusCode())
+		}
+
+		var responses []*jobResponse
+		if err := json.Unmarshal(resp.Body(), &responses); err != nil {
+			log.Error().Err(err).Msg("could not unmarshal openfigi json")
+			return results, err
+		}
+		if len(responses) != len(batch) {
+			return results, fmt.Errorf("openfigi returned %d results for %d jobs", len(responses), len(batch))
+		}
+
+		for idx, jobResp := range responses {
+			if jobResp.Error != "" {
+				log.Warn().Str("IDValue", batch[idx].IDValue).Str("Error", jobResp.Error).Msg("openfigi job failed")
+			}
+			results = append(results, jobResp.Data)
+		}
+	}
+
+	return results, nil
+}
+
+// usExchanges are primary exchanges whose composite FIGI is assigned under the
+// US composite exchange code
+var usExchanges = []string{"NYSE", "NASDAQ", "AMEX", "ARCA", "BATS", "CBOE", "IEX", "OTC", "PINK", "GREY"}
+
+// exchangeCode translates a primary exchange to an OpenFIGI exchange code
+func exchangeCode(exchange string) string {
+	exchange = strings.ToUpper(exchange)
+	for _, us := range usExchanges {
+		if strings.Contains(exchange, us) {
+			return "US"
+		}
+	}
+	return ""
+}
+
+// EnrichAssets looks up the composite and share class FIGI of assets that are
+// missing a composite FIGI. Lookups use ticker and exchange; when a lookup
+// matches more than one composite FIGI the asset is left unchanged.
+func (o *OpenFigiApi) EnrichAssets(ctx context.Context, assets []*common.Asset) error {
+	missing := make([]*common.Asset, 0)
+	jobs := make([]*Job, 0)
+	for _, asset := range assets {
+		if asset.CompositeFigi != "" {
+			continue
+		}
+		missing = append(missing, asset)
+		jobs = append(jobs, &Job{
+			IDType:   "TICKER",
+			IDValue:  asset.Ticker,
+			ExchCode: exchangeCode(asset.PrimaryExchange),
+		})
+	}
+
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	log.Info().Int("NumAssets", len(jobs)).Msg("looking up missing composite FIGIs")
+	results, err := o.Map(ctx, jobs)
+
+	numFound := 0
+	for idx, matches := range results {
+		asset := missing[idx]
+		composites := make(map[string]*Result)
+		for _, match := range matches {
+			if match.CompositeFigi != "" {
+				composites[match.CompositeFigi] = match
+			}
+		}
+
+		if len(composites) != 1 {
+			if len(composites) > 1 {
+				log.Warn().Str("Ticker", asset.Ticker).Int("NumMatches", len(composites)).Msg("ambiguous openfigi match ... skipping")
+			}
+			continue
+		}
+
+		for _, match := range composites {
+			asset.CompositeFigi = match.CompositeFigi
+			if asset.ShareClassFigi == "" {
+				asset.ShareClassFigi = match.ShareClassFigi
+			}
+			if asset.Name == "" {
+				asset.Name = match.Name
+			}
+		}
+		numFound++
+	}
+
+	log.Info().Int("NumFound", numFound).Int("NumMissing", len(jobs)-numFound).Msg("finished composite FIGI lookup")
+	return err
+}
+
+// CurrentTickers looks up the ticker each composite FIGI currently trades
+// under. The returned map only contains FIGIs with a match.
+func (o *OpenFigiApi) CurrentTickers(ctx context.Context, figis []string) (map[string]string, error) {
+	jobs := make([]*Job, len(figis))
+	for idx, figi := range figis {
+		jobs[idx] = &Job{
+			IDType:   "COMPOSITE_ID_BB_GLOBAL",
+			IDValue:  figi,
+			ExchCode: "US",
+		}
+	}
+
+	tickers := make(map[string]string, len(figis))
+	if len(jobs) == 0 {
+		return tickers, nil
+	}
+
+	results, err := o.Map(ctx, jobs)
+	for idx, matches := range results {
+		for _, match := range matches {
+			if match.Ticker != "" && (match.CompositeFigi == "" || match.CompositeFigi == figis[idx]) {
+				tickers[figis[idx]] = match.Ticker
+				break
+			}
+		}
+	}
+	return tickers, err
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package stooq
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+	"go.uber.org/ratelimit"
+)
+
+const dailyURL = "https://stooq.com/q/d/l/"
+
+// ErrNoData is returned when stooq has no prices for a ticker
+var ErrNoData = errors.New("no data")
+
+// StooqApi downloads daily prices from stooq.com. Prices are split adjusted
+// but not dividend adjusted.
+type StooqApi struct {
+	apiKey string
+	rate   ratelimit.Limiter
+}
+
+// Bar is a daily price bar
+type Bar struct {
+	Date   time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// New creates a stooq client limited to rateLimit requests per minute. An
+// API key is only needed when stooq requires one for bulk downloads.
+func New(apiKey string, rateLimit int) *StooqApi {
+	if rateLimit <= 0 {
+		rateLimit = 30
+	}
+	return &StooqApi{
+		apiKey: apiKey,
+		rate:   ratelimit.New(rateLimit, ratelimit.Per(time.Minute)),
+	}
+}
+
+// Symbol returns the stooq symbol of a US listed ticker, e.g. BRK-B becomes
+// brk-b.us
+func Symbol(ticker string) string {
+	return strings.ToLower(ticker) + ".us"
+}
+
+// FetchDaily downloads the daily bars of ticker between startDate and
+// endDate inclusive
+func (s *StooqApi) FetchDaily(ctx context.Context, ticker string, startDate, endDate time.Time) ([]*Bar, error) {
+	s.rate.Take()
+
+	params := map[string]string{
+		"s":  Symbol(ticker),
+		"d1": startDate.Format("20060102"),
+		"d2": endDate.Format("20060102"),
+		"i":  "d",
+	}
+	if s.apiKey != "" {
+		params["apikey"] = s.apiKey
+	}
+
+	resp, err := resty.New().
+		R().
+		SetContext(ctx).
+		SetQueryParams(params).
+		Get(dailyURL)
+	if err != nil {
+		log.Error().Err(err).Str("Ticker", ticker).Msg("error when requesting stooq prices")
+		return nil, err
+	}
+	if resp.StatusCode() >= 400 {
+		log.Error().Int("StatusCode", resp.StatusCode()).Str("Ticker", ticker).Msg("error when requesting stooq prices")
+		return nil, fmt.Errorf("stooq request failed with status %d", resp.StatusCode())
+	}
+
+	return parseDaily(resp.Body())
+}
+
+// parseDaily parses the Date,Open,High,Low,Close,Volume CSV returned by stooq
+func parseDaily(body []byte) ([]*Bar, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, ErrNoData
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(header) < 5 || header[0] != "Date" {
+		// stooq answers with a plain text message like "No data"
+		return nil, fmt.Errorf("%w: %s", ErrNoData, strings.TrimSpace(string(body)))
+	}
+
+	bars := []*Bar{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		date, err := time.Parse("2006-01-02", record[0])
+		if err != nil {
+			return nil, err
+		}
+
+		bar := &Bar{Date: date}
+		fields := []*float64{&bar.Open, &bar.High, &bar.Low, &bar.Close, &bar.Volume}
+		for idx, field := range fields {
+			if idx+1 >= len(record) {
+				break
+			}
+			if *field, err = strconv.ParseFloat(record[idx+1], 64); err != nil {
+				return nil, err
+			}
+		}
+		bars = append(bars, bar)
+	}
+
+	if len(bars) == 0 {
+		return nil, ErrNoData
+	}
+	return bars, nil
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// Adjustment factors are cumulative relative to the newest quote of an asset,
+// so the factors of every stored quote before a new split or dividend change
+// when it is saved. Quotes downloaded in an earlier window were adjusted
+// relative to a different quote, and quotes of a window that ends before the
+// stored history (e.g. a backfill with --end) are relative to the last quote
+// of the window; the factors of those assets are therefore recomputed over
+// the whole stored history.
+
+// staleFactorAssets returns the distinct keys of the assets whose quotes
+// include a split or dividend or end before the latest stored quote; latest
+// maps keys to the date (YYYY-MM-DD) of the latest stored quote
+func staleFactorAssets(quotes []*tiingo.Eod, key func(*tiingo.Eod) string, latest map[string]string) []string {
+	lastDate := make(map[string]string)
+	stale := make(map[string]bool)
+	for _, quote := range quotes {
+		k := key(quote)
+		if k == "" {
+			continue
+		}
+		if date := quote.Date.Format("2006-01-02"); date > lastDate[k] {
+			lastDate[k] = date
+		}
+		if quote.Dividend != 0 || (quote.Split != 0 && quote.Split != 1) {
+			stale[k] = true
+		}
+	}
+
+	assets := []string{}
+	for k, date := range lastDate {
+		if stale[k] || latest[k] > date {
+			assets = append(assets, k)
+		}
+	}
+	sort.Strings(assets)
+	return assets
+}
+
+// assetKeys returns the distinct keys of the assets of quotes
+func assetKeys(quotes []*tiingo.Eod, key func(*tiingo.Eod) string) []string {
+	seen := make(map[string]bool)
+	keys := []string{}
+	for _, quote := range quotes {
+		if k := key(quote); k != "" && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// storedFactorQuote is a stored quote with the columns needed to recompute
+// its adjustment factors
+type storedFactorQuote struct {
+	Ticker         string
+	CompositeFigi  string
+	EventDate      string
+	Open           float64
+	High           float64
+	Low            float64
+	Close          float64
+	Volume         int64
+	Dividend       float64
+	Split          float64
+	AdjustedVolume *int64
+	AdjOpen        *float64
+	AdjHigh        *float64
+	AdjLow         *float64
+	AdjClose       *float64
+}
+
+// storedFactorsSQL selects the stored history used to recompute factors; %s
+// is the expression formatting event_date as YYYY-MM-DD and %s the table
+const storedFactorsSQL = `SELECT ticker, coalesce(composite_figi, ''), %s,
+	coalesce(open, 0), coalesce(high, 0), coalesce(low, 0), coalesce(close, 0), coalesce(volume, 0),
+	coalesce(dividend, 0), coalesce(split_factor, 1), adjusted_volume, adj_open, adj_high, adj_low, adj_close
+	FROM %s`
+
+// recomputeFactors computes the adjustment factors of the full stored history
+// of each asset in rows
+func recomputeFactors(rows []*storedFactorQuote, adjust tiingo.AdjustOptions) ([]*tiingo.Eod, error) {
+	quotes := make([]*tiingo.Eod, len(rows))
+	for idx, row := range rows {
+		date, err := time.Parse("2006-01-02", row.EventDate)
+		if err != nil {
+			return nil, err
+		}
+		quote := &tiingo.Eod{
+			Date: date, Ticker: row.Ticker, CompositeFigi: row.CompositeFigi,
+			Open: row.Open, High: row.High, Low: row.Low, Close: row.Close, Volume: row.Volume,
+			Dividend: row.Dividend, Split: row.Split,
+			AdjustedVolume: row.AdjustedVolume,
+			AdjOpen:        row.AdjOpen, AdjHigh: row.AdjHigh, AdjLow: row.AdjLow, AdjClose: row.AdjClose,
+		}
+		// the stored adjusted volume came from tiingo; keep it
+		if adjust.Prices == tiingo.AdjustTiingo && row.AdjustedVolume != nil {
+			vendorVolume := float64(*row.AdjustedVolume)
+			quote.VendorAdjVolume = &vendorVolume
+		}
+		quotes[idx] = quote
+	}
+
+	tiingo.ComputeAdjustmentFactors(quotes, adjust)
+	return quotes, nil
+}
+
+// eodFactorsStagingSQL creates the temporary table recomputed factors are
+// copied into before they are applied
+const eodFactorsStagingSQL = `CREATE TEMP TABLE eod_factors (
+	composite_figi TEXT,
+	event_date DATE,
+	split_adjust_factor DOUBLE PRECISION,
+	dividend_adjust_factor DOUBLE PRECISION,
+	adjusted_volume BIGINT,
+	adj_open DOUBLE PRECISION,
+	adj_high DOUBLE PRECISION,
+	adj_low DOUBLE PRECISION,
+	adj_close DOUBLE PRECISION
+) ON COMMIT DROP`
+
+// eodFactorsUpdateSQL applies the staged factors to table
+func eodFactorsUpdateSQL(table string) string {
+	return fmt.Sprintf(`UPDATE %s e SET
+		split_adjust_factor = f.split_adjust_factor,
+		dividend_adjust_factor = f.dividend_adjust_factor,
+		adjusted_volume = f.adjusted_volume,
+		adj_open = f.adj_open,
+		adj_high = f.adj_high,
+		adj_low = f.adj_low,
+		adj_close = f.adj_close
+	FROM eod_factors f
+	WHERE e.composite_figi = f.composite_figi AND e.event_date = f.event_date`, table)
+}
+
+// recomputeStoredFactors recomputes the adjustment factors of the stored
+// history of the assets of quotes that have a new split or dividend or that
+// end before the stored history as part of tx
+func recomputeStoredFactors(ctx context.Context, tx pgx.Tx, table string, quotes []*tiingo.Eod, adjust tiingo.AdjustOptions) error {
+	byFigi := func(q *tiingo.Eod) string { return q.CompositeFigi }
+	latest := make(map[string]string)
+	latestRows, err := tx.Query(ctx, fmt.Sprintf(`SELECT composite_figi, to_char(max(event_date), 'YYYY-MM-DD')
+		FROM %s WHERE composite_figi = any($1) GROUP BY composite_figi`, table), assetKeys(quotes, byFigi))
+	if err != nil {
+		return err
+	}
+	for latestRows.Next() {
+		var figi, date string
+		if err := latestRows.Scan(&figi, &date); err != nil {
+			latestRows.Close()
+			return err
+		}
+		latest[figi] = date
+	}
+	latestRows.Close()
+	if err := latestRows.Err(); err != nil {
+		return err
+	}
+
+	figis := staleFactorAssets(quotes, byFigi, latest)
+	if len(figis) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf(storedFactorsSQL, "to_char(event_date, 'YYYY-MM-DD')", table) +
+		` WHERE composite_figi = any($1) ORDER BY composite_figi, event_date`
+	result, err := tx.Query(ctx, query, figis)
+	if err != nil {
+		return err
+	}
+	rows := []*storedFactorQuote{}
+	for result.Next() {
+		r := &storedFactorQuote{}
+		if err := result.Scan(&r.Ticker, &r.CompositeFigi, &r.EventDate, &r.Open, &r.High, &r.Low, &r.Close, &r.Volume,
+			&r.Dividend, &r.Split, &r.AdjustedVolume, &r.AdjOpen, &r.AdjHigh, &r.AdjLow, &r.AdjClose); err != nil {
+			result.Close()
+			return err
+		}
+		rows = append(rows, r)
+	}
+	result.Close()
+	if err := result.Err(); err != nil {
+		return err
+	}
+
+	history, err := recomputeFactors(rows, adjust)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, eodFactorsStagingSQL); err != nil {
+		return err
+	}
+	factors := make([][]interface{}, len(history))
+	for idx, q := range history {
+		factors[idx] = []interface{}{q.CompositeFigi, q.Date, q.SplitAdjustFactor, q.DividendAdjustFactor, q.AdjustedVolume, q.AdjOpen, q.AdjHigh, q.AdjLow, q.AdjClose}
+	}
+	columns := []string{"composite_figi", "event_date", "split_adjust_factor", "dividend_adjust_factor", "adjusted_volume", "adj_open", "adj_high", "adj_low", "adj_close"}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"eod_factors"}, columns, pgx.CopyFromRows(factors)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, eodFactorsUpdateSQL(table)); err != nil {
+		return err
+	}
+
+	log.Info().Str("Table", table).Int("NumAssets", len(figis)).Int("NumQuotes", len(history)).Msg("recomputed adjustment factors of stored history")
+	return nil
+}
+
+// recomputeStoredFactorsSQL is recomputeStoredFactors for the embedded
+// databases, whose eod tables are keyed by ticker
+func recomputeStoredFactorsSQL(ctx context.Context, tx *sql.Tx, table string, quotes []*tiingo.Eod, adjust tiingo.AdjustOptions) error {
+	byTicker := func(q *tiingo.Eod) string { return q.Ticker }
+	dateExpr := "substr(CAST(event_date AS VARCHAR), 1, 10)"
+	latest := make(map[string]string)
+	if keys := assetKeys(quotes, byTicker); len(keys) > 0 {
+		args := make([]any, len(keys))
+		for idx, ticker := range keys {
+			args[idx] = ticker
+		}
+		latestRows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT ticker, max(%s) FROM %s WHERE ticker IN (%s) GROUP BY ticker`,
+			dateExpr, table, strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")), args...)
+		if err != nil {
+			return err
+		}
+		for latestRows.Next() {
+			var ticker, date string
+			if err := latestRows.Scan(&ticker, &date); err != nil {
+				latestRows.Close()
+				return err
+			}
+			latest[ticker] = date
+		}
+		latestRows.Close()
+		if err := latestRows.Err(); err != nil {
+			return err
+		}
+	}
+
+	tickers := staleFactorAssets(quotes, byTicker, latest)
+	if len(tickers) == 0 {
+		return nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tickers)), ", ")
+	query := fmt.Sprintf(storedFactorsSQL, dateExpr, table) +
+		` WHERE ticker IN (` + placeholders + `) ORDER BY ticker, event_date`
+	args := make([]any, len(tickers))
+	for idx, ticker := range tickers {
+		args[idx] = ticker
+	}
+
+	result, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rows := []*storedFactorQuote{}
+	for result.Next() {
+		r := &storedFactorQuote{}
+		if err := result.Scan(&r.Ticker, &r.CompositeFigi, &r.EventDate, &r.Open, &r.High, &r.Low, &r.Close, &r.Volume,
+			&r.Dividend, &r.Split, &r.AdjustedVolume, &r.AdjOpen, &r.AdjHigh, &r.AdjLow, &r.AdjClose); err != nil {
+			result.Close()
+			return err
+		}
+		// group by ticker; the embedded tables may lack composite FIGIs
+		r.CompositeFigi = ""
+		rows = append(rows, r)
+	}
+	result.Close()
+	if err := result.Err(); err != nil {
+		return err
+	}
+
+	history, err := recomputeFactors(rows, adjust)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`UPDATE %s SET split_adjust_factor = ?, dividend_adjust_factor = ?, adjusted_volume = ?,
+		adj_open = ?, adj_high = ?, adj_low = ?, adj_close = ? WHERE ticker = ? AND event_date = ?`, table))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, q := range history {
+		if _, err := stmt.ExecContext(ctx, q.SplitAdjustFactor, q.DividendAdjustFactor, q.AdjustedVolume,
+			q.AdjOpen, q.AdjHigh, q.AdjLow, q.AdjClose, q.Ticker, q.Date.Format("2006-01-02")); err != nil {
+			return err
+		}
+	}
+
+	log.Info().Str("Table", table).Int("NumAssets", len(tickers)).Int("NumQuotes", len(history)).Msg("recomputed adjustment factors of stored history")
+	return nil
+}
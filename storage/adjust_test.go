@@ -0,0 +1,168 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+)
+
+// storedQuote returns a quote of ticker on the given date
+func storedQuote(ticker, date string, close, dividend, split float64) *tiingo.Eod {
+	eventDate, _ := time.Parse("2006-01-02", date)
+	return &tiingo.Eod{
+		Date:   eventDate,
+		Ticker: ticker,
+		Open:   close, High: close, Low: close, Close: close,
+		Volume:   1000,
+		Dividend: dividend,
+		Split:    split,
+	}
+}
+
+func TestStaleFactorAssets(t *testing.T) {
+	byTicker := func(q *tiingo.Eod) string { return q.Ticker }
+	tests := []struct {
+		name   string
+		quotes []*tiingo.Eod
+		latest map[string]string
+		want   []string
+	}{
+		{
+			name:   "no events",
+			quotes: []*tiingo.Eod{storedQuote("AAA", "2024-01-02", 10, 0, 1)},
+			want:   []string{},
+		},
+		{
+			name: "split and dividend",
+			quotes: []*tiingo.Eod{
+				storedQuote("CCC", "2024-01-02", 10, 0, 2),
+				storedQuote("BBB", "2024-01-02", 10, 0.5, 1),
+				storedQuote("AAA", "2024-01-02", 10, 0, 1),
+			},
+			want: []string{"BBB", "CCC"},
+		},
+		{
+			name:   "window before the stored history",
+			quotes: []*tiingo.Eod{storedQuote("AAA", "2008-01-02", 10, 0, 1), storedQuote("BBB", "2024-01-02", 10, 0, 1)},
+			latest: map[string]string{"AAA": "2024-01-02", "BBB": "2024-01-02"},
+			want:   []string{"AAA"},
+		},
+		{
+			name:   "blank keys are skipped",
+			quotes: []*tiingo.Eod{storedQuote("", "2024-01-02", 10, 1, 2)},
+			want:   []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := staleFactorAssets(test.quotes, byTicker, test.latest)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestRecomputeStoredFactors(t *testing.T) {
+	tests := []struct {
+		name         string
+		batches      [][]*tiingo.Eod
+		wantSplit    map[string]float64
+		wantDividend map[string]float64
+	}{
+		{
+			name: "split in a later window",
+			batches: [][]*tiingo.Eod{
+				{storedQuote("AAA", "2024-01-02", 100, 0, 1), storedQuote("AAA", "2024-01-03", 100, 0, 1)},
+				{storedQuote("AAA", "2024-01-04", 50, 0, 2)},
+			},
+			wantSplit:    map[string]float64{"2024-01-02": 0.5, "2024-01-03": 0.5, "2024-01-04": 1},
+			wantDividend: map[string]float64{"2024-01-02": 1, "2024-01-03": 1, "2024-01-04": 1},
+		},
+		{
+			name: "dividend in a later window",
+			batches: [][]*tiingo.Eod{
+				{storedQuote("AAA", "2024-01-02", 100, 0, 1)},
+				{storedQuote("AAA", "2024-01-03", 98, 2, 1)},
+			},
+			wantSplit:    map[string]float64{"2024-01-02": 1, "2024-01-03": 1},
+			wantDividend: map[string]float64{"2024-01-02": 0.98, "2024-01-03": 1},
+		},
+		{
+			name: "window before the stored history",
+			batches: [][]*tiingo.Eod{
+				{storedQuote("AAA", "2024-01-02", 100, 0, 1), storedQuote("AAA", "2024-01-03", 50, 0, 2)},
+				{storedQuote("AAA", "2008-01-02", 100, 0, 1)},
+			},
+			wantSplit:    map[string]float64{"2008-01-02": 0.5, "2024-01-02": 0.5, "2024-01-03": 1},
+			wantDividend: map[string]float64{"2008-01-02": 1, "2024-01-02": 1, "2024-01-03": 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			dsn := "sqlite:" + filepath.Join(t.TempDir(), "eod.db")
+			adjust := tiingo.AdjustOptions{Prices: tiingo.AdjustLocal}
+			for _, batch := range test.batches {
+				tiingo.ComputeAdjustmentFactors(batch, adjust)
+				if err := saveToSQLite(ctx, batch, dsn, adjust); err != nil {
+					t.Fatalf("expected no error saving quotes, got %v", err)
+				}
+			}
+
+			db, err := common.OpenSQLite(ctx, dsn)
+			if err != nil {
+				t.Fatalf("expected no error opening database, got %v", err)
+			}
+			defer db.Close()
+
+			rows, err := db.QueryContext(ctx, `SELECT substr(CAST(event_date AS VARCHAR), 1, 10), split_adjust_factor, dividend_adjust_factor FROM eod`)
+			if err != nil {
+				t.Fatalf("expected no error querying eod, got %v", err)
+			}
+			defer rows.Close()
+
+			numRows := 0
+			for rows.Next() {
+				var date string
+				var splitFactor, dividendFactor float64
+				if err := rows.Scan(&date, &splitFactor, &dividendFactor); err != nil {
+					t.Fatalf("expected no error scanning eod, got %v", err)
+				}
+				numRows++
+				if math.Abs(splitFactor-test.wantSplit[date]) > 1e-9 {
+					t.Errorf("%s: expected split adjust factor %v, got %v", date, test.wantSplit[date], splitFactor)
+				}
+				if math.Abs(dividendFactor-test.wantDividend[date]) > 1e-9 {
+					t.Errorf("%s: expected dividend adjust factor %v, got %v", date, test.wantDividend[date], dividendFactor)
+				}
+			}
+			if numRows != len(test.wantSplit) {
+				t.Errorf("expected %d stored quotes, got %d", len(test.wantSplit), numRows)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// arrowBatchSize is the number of quotes written per arrow record batch
+const arrowBatchSize = 64 * 1024
+
+var eodArrowSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "date", Type: arrow.FixedWidthTypes.Date32},
+	{Name: "ticker", Type: arrow.BinaryTypes.String},
+	{Name: "composite_figi", Type: arrow.BinaryTypes.String},
+	{Name: "exchange", Type: arrow.BinaryTypes.String},
+	{Name: "currency", Type: arrow.BinaryTypes.String},
+	{Name: "open", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "high", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "low", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "close", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "volume", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "dividend", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "split", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "split_adjust_factor", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "dividend_adjust_factor", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "adjusted_volume", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	{Name: "adj_open", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	{Name: "adj_high", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	{Name: "adj_low", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	{Name: "adj_close", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	{Name: "run_id", Type: arrow.BinaryTypes.String},
+}, nil)
+
+// arrowExporter writes quotes as an Arrow IPC (Feather v2) file that can be
+// memory-mapped by pyarrow and polars
+type arrowExporter struct {
+	opts *Options
+}
+
+func (e arrowExporter) Export(ctx context.Context, quotes []*tiingo.Eod, fn string) error {
+	target, err := common.OpenOutput(ctx, fn, e.opts.S3)
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("cannot create arrow file")
+		return err
+	}
+
+	mem := memory.NewGoAllocator()
+	writer, err := ipc.NewFileWriter(target.File, ipc.WithSchema(eodArrowSchema), ipc.WithAllocator(mem))
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("cannot create arrow writer")
+		target.Abort()
+		return err
+	}
+
+	builder := array.NewRecordBuilder(mem, eodArrowSchema)
+	defer builder.Release()
+
+	for start := 0; start < len(quotes) && err == nil; start += arrowBatchSize {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
+		end := start + arrowBatchSize
+		if end > len(quotes) {
+			end = len(quotes)
+		}
+		for _, quote := range quotes[start:end] {
+			appendArrowQuote(builder, quote)
+		}
+		record := builder.NewRecord()
+		err = writer.Write(record)
+		record.Release()
+	}
+
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("arrow write failed")
+		target.Abort()
+		return err
+	}
+
+	if err := target.Commit(); err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not save arrow file")
+		return err
+	}
+
+	log.Info().Int("NumRecords", len(quotes)).Str("FileName", fn).Msg("arrow export finished")
+	return nil
+}
+
+func appendArrowQuote(b *array.RecordBuilder, q *tiingo.Eod) {
+	date := time.Date(q.Date.Year(), q.Date.Month(), q.Date.Day(), 0, 0, 0, 0, time.UTC)
+	b.Field(0).(*array.Date32Builder).Append(arrow.Date32FromTime(date))
+	b.Field(1).(*array.StringBuilder).Append(q.Ticker)
+	b.Field(2).(*array.StringBuilder).Append(q.CompositeFigi)
+	b.Field(3).(*array.StringBuilder).Append(q.Exchange)
+	b.Field(4).(*array.StringBuilder).Append(q.Currency)
+
+	prices := []float64{q.Open, q.High, q.Low, q.Close}
+	for idx, v := range prices {
+		b.Field(5 + idx).(*array.Float64Builder).Append(v)
+	}
+	b.Field(9).(*array.Int64Builder).Append(q.Volume)
+
+	factors := []float64{q.Dividend, q.Split, q.SplitAdjustFactor, q.DividendAdjustFactor}
+	for idx, v := range factors {
+		b.Field(10 + idx).(*array.Float64Builder).Append(v)
+	}
+
+	if q.AdjustedVolume == nil {
+		b.Field(14).(*array.Int64Builder).AppendNull()
+	} else {
+		b.Field(14).(*array.Int64Builder).Append(*q.AdjustedVolume)
+	}
+
+	adjusted := []*float64{q.AdjOpen, q.AdjHigh, q.AdjLow, q.AdjClose}
+	for idx, v := range adjusted {
+		fb := b.Field(15 + idx).(*array.Float64Builder)
+		if v == nil {
+			fb.AppendNull()
+		} else {
+			fb.Append(*v)
+		}
+	}
+
+	b.Field(19).(*array.StringBuilder).Append(q.RunID)
+}
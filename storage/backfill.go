@@ -0,0 +1,124 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// FindGaps compares the eod table against the trading calendar of each
+// asset's primary exchange and
+// returns the ranges of trading days between startDate and endDate that are
+// missing for each asset. Days before an asset's first stored quote are not
+// considered missing; assets without any stored quotes in the window are
+// reported as a single gap covering the whole window.
+func FindGaps(ctx context.Context, dsn string, assets []*common.Asset, startDate, endDate time.Time) ([]*tiingo.Gap, error) {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("could not connect to database")
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	figis := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		if asset.CompositeFigi != "" {
+			figis = append(figis, asset.CompositeFigi)
+		}
+	}
+
+	startDate = time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, time.UTC)
+	endDate = time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 0, 0, 0, 0, time.UTC)
+
+	var rows []*tiingo.StoredQuote
+	err = pgxscan.Select(ctx, conn, &rows, `SELECT
+		composite_figi,
+		to_char(event_date, 'YYYY-MM-DD') AS event_date,
+		COALESCE(split_factor, 1) AS split_factor,
+		COALESCE(dividend, 0) AS dividend,
+		COALESCE(split_adjust_factor, 1) AS split_adjust_factor,
+		COALESCE(dividend_adjust_factor, 1) AS dividend_adjust_factor
+	FROM eod
+	WHERE composite_figi = any($1) AND event_date >= $2
+	ORDER BY composite_figi, event_date`, figis, startDate)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query stored eod dates")
+		return nil, err
+	}
+
+	stored := make(map[string]map[string]*tiingo.StoredQuote)
+	first := make(map[string]string)
+	for _, row := range rows {
+		if _, ok := stored[row.CompositeFigi]; !ok {
+			stored[row.CompositeFigi] = make(map[string]*tiingo.StoredQuote)
+			first[row.CompositeFigi] = row.EventDate
+		}
+		stored[row.CompositeFigi][row.EventDate] = row
+	}
+
+	gaps := []*tiingo.Gap{}
+	for _, asset := range assets {
+		if asset.CompositeFigi == "" {
+			log.Warn().Str("Ticker", asset.Ticker).Msg("asset has no composite figi ... skipping gap detection")
+			continue
+		}
+
+		cal := common.CalendarFor(asset.PrimaryExchange)
+		quotes, ok := stored[asset.CompositeFigi]
+		if !ok {
+			if numDays := len(cal.TradingDays(startDate, endDate)); numDays > 0 {
+				gaps = append(gaps, &tiingo.Gap{Asset: asset, StartDate: startDate, EndDate: endDate, NumDays: numDays})
+			}
+			continue
+		}
+
+		from := startDate
+		if firstDate, err := time.Parse("2006-01-02", first[asset.CompositeFigi]); err == nil && firstDate.After(from) {
+			from = firstDate
+		}
+
+		var current *tiingo.Gap
+		for _, day := range cal.TradingDays(from, endDate) {
+			if quote, ok := quotes[day.Format("2006-01-02")]; ok {
+				if current != nil {
+					current.Next = quote
+					gaps = append(gaps, current)
+					current = nil
+				}
+				continue
+			}
+
+			if current == nil {
+				current = &tiingo.Gap{Asset: asset, StartDate: day}
+			}
+			current.EndDate = day
+			current.NumDays++
+		}
+
+		if current != nil {
+			gaps = append(gaps, current)
+		}
+	}
+
+	return gaps, nil
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/common"
+)
+
+// PingDatabase connects to the postgres, sqlite or clickhouse database
+// identified by url and verifies that it responds
+func PingDatabase(ctx context.Context, url string) error {
+	switch {
+	case common.IsSQLiteDSN(url):
+		db, err := common.OpenSQLite(ctx, url)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		return db.PingContext(ctx)
+	case common.IsClickHouseDSN(url):
+		opts, err := clickhouse.ParseDSN(url)
+		if err != nil {
+			return err
+		}
+		db := clickhouse.OpenDB(opts)
+		defer db.Close()
+		return db.PingContext(ctx)
+	default:
+		conn, err := pgx.Connect(ctx, url)
+		if err != nil {
+			return err
+		}
+		defer conn.Close(ctx)
+		return conn.Ping(ctx)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// clickhouseSchemaSQL creates the eod table in ClickHouse. Re-imported quotes
+// are inserted as new rows; ReplacingMergeTree keeps the most recently
+// inserted version of each (composite_figi, event_date) when parts merge, so
+// queries that need exact results should use FINAL.
+const clickhouseSchemaSQL = `CREATE TABLE IF NOT EXISTS %s (
+	ticker LowCardinality(String),
+	composite_figi String,
+	currency LowCardinality(String),
+	event_date Date,
+	open Float64,
+	high Float64,
+	low Float64,
+	close Float64,
+	volume Int64,
+	dividend Float64,
+	split_factor Float64,
+	split_adjust_factor Float64,
+	dividend_adjust_factor Float64,
+	adjusted_volume Nullable(Int64),
+	adj_open Nullable(Float64),
+	adj_high Nullable(Float64),
+	adj_low Nullable(Float64),
+	adj_close Nullable(Float64),
+	source LowCardinality(String),
+	run_id String,
+	inserted_at DateTime64(3) DEFAULT now64(3)
+) ENGINE = ReplacingMergeTree(inserted_at)
+PARTITION BY toYear(event_date)
+ORDER BY (composite_figi, event_date)`
+
+// saveToClickHouse writes quotes to the eod table of the ClickHouse server
+// identified by url using the native protocol. Batches of batchSize quotes
+// are sent as asynchronous inserts; each insert waits until the server has
+// flushed it so failures are reported.
+func saveToClickHouse(ctx context.Context, quotes []*tiingo.Eod, url string, batchSize int) error {
+	target := common.RedactDSN(url)
+	log.Info().Str("Target", target).Msg("saving to clickhouse")
+
+	opts, err := clickhouse.ParseDSN(url)
+	if err != nil {
+		log.Error().Err(err).Str("Target", target).Msg("could not parse clickhouse url")
+		return err
+	}
+	conn, err := clickhouse.Open(opts)
+	if err != nil {
+		log.Error().Err(err).Str("Target", target).Msg("could not connect to clickhouse")
+		return err
+	}
+	defer conn.Close()
+
+	table := quotesTable(quotes)
+	if err := conn.Exec(ctx, fmt.Sprintf(clickhouseSchemaSQL, table)); err != nil {
+		log.Error().Err(err).Str("Target", target).Msg("could not create eod table")
+		return reportUnsaved(target, quotes)
+	}
+
+	if batchSize <= 0 {
+		batchSize = len(quotes)
+	}
+
+	insertCtx := clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+		"async_insert":          1,
+		"wait_for_async_insert": 1,
+	}))
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s)", table, strings.Join(eodColumns, ", "))
+
+	unsaved := []*tiingo.Eod{}
+	for _, batch := range fixedBatches(len(quotes), batchSize) {
+		if err := insertClickHouseBatch(insertCtx, conn, insertSQL, quotes[batch.start:batch.end]); err != nil {
+			log.Error().Err(err).Str("Target", target).Int("BatchStart", batch.start).Int("BatchEnd", batch.end).Msg("error saving EOD quotes to clickhouse")
+			unsaved = append(unsaved, quotes[batch.start:batch.end]...)
+		}
+	}
+
+	log.Info().Str("Target", target).Int("NumSaved", len(quotes)-len(unsaved)).Int("NumErrors", len(unsaved)).Msg("finished saving to clickhouse")
+	if len(unsaved) > 0 {
+		return reportUnsaved(target, unsaved)
+	}
+	return nil
+}
+
+func insertClickHouseBatch(ctx context.Context, conn clickhouse.Conn, insertSQL string, quotes []*tiingo.Eod) error {
+	batch, err := conn.PrepareBatch(ctx, insertSQL)
+	if err != nil {
+		return err
+	}
+	for _, quote := range quotes {
+		if err := batch.Append(eodRow(quote)...); err != nil {
+			batch.Abort()
+			return err
+		}
+	}
+	return batch.Send()
+}
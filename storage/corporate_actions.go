@@ -0,0 +1,123 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// corporateActionsMergeSQL upserts staged actions keyed on (composite_figi,
+// ex_date, action_type)
+const corporateActionsMergeSQL = `INSERT INTO corporate_actions (
+		composite_figi, ticker, ex_date, action_type, value, source, run_id
+	) SELECT DISTINCT ON (composite_figi, ex_date, action_type)
+		composite_figi, ticker, ex_date, action_type, value, 'api.tiingo.com', run_id
+	FROM corporate_actions_staging
+	ON CONFLICT (composite_figi, ex_date, action_type)
+	DO UPDATE SET
+		ticker = EXCLUDED.ticker,
+		value = EXCLUDED.value,
+		source = EXCLUDED.source,
+		run_id = EXCLUDED.run_id`
+
+// SaveCorporateActionsToDatabase upserts corporate actions into the
+// corporate_actions table
+func SaveCorporateActionsToDatabase(ctx context.Context, dsn string, actions []*tiingo.CorporateAction) error {
+	log.Info().Int("NumActions", len(actions)).Msg("saving corporate actions to database")
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `CREATE TEMP TABLE corporate_actions_staging (
+		composite_figi text,
+		ticker text,
+		ex_date date,
+		action_type text,
+		value real,
+		run_id text
+	) ON COMMIT DROP`); err != nil {
+		log.Error().Err(err).Msg("could not create staging table")
+		return err
+	}
+
+	rows := make([][]interface{}, 0, len(actions))
+	for _, action := range actions {
+		if action.CompositeFigi == "" {
+			continue
+		}
+		rows = append(rows, []interface{}{action.CompositeFigi, action.Ticker, action.ExDate, action.Type, action.Value, action.RunID})
+	}
+
+	columns := []string{"composite_figi", "ticker", "ex_date", "action_type", "value", "run_id"}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"corporate_actions_staging"}, columns, pgx.CopyFromRows(rows)); err != nil {
+		log.Error().Err(err).Msg("could not copy corporate actions")
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, corporateActionsMergeSQL); err != nil {
+		log.Error().Err(err).Msg("could not merge corporate actions")
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// PopulateCorporateActions extracts the dividends and splits stored in eod
+// rows on or after since into the corporate_actions table and returns the
+// number of actions upserted
+func PopulateCorporateActions(ctx context.Context, dsn string, since time.Time) (int64, error) {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return 0, err
+	}
+	defer conn.Close(ctx)
+
+	tag, err := conn.Exec(ctx, `INSERT INTO corporate_actions (
+			composite_figi, ticker, ex_date, action_type, value, source, run_id
+		) SELECT composite_figi, ticker, event_date, 'dividend', dividend, source, run_id
+			FROM eod WHERE event_date >= $1 AND dividend <> 0
+		UNION ALL
+		SELECT composite_figi, ticker, event_date, 'split', split_factor, source, run_id
+			FROM eod WHERE event_date >= $1 AND split_factor <> 0 AND split_factor <> 1
+		ON CONFLICT (composite_figi, ex_date, action_type)
+		DO UPDATE SET
+			ticker = EXCLUDED.ticker,
+			value = EXCLUDED.value,
+			source = EXCLUDED.source,
+			run_id = EXCLUDED.run_id`, since)
+	if err != nil {
+		log.Error().Err(err).Msg("could not populate corporate actions")
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
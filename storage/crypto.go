@@ -0,0 +1,128 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// SaveCryptoToDatabase saves crypto prices to the crypto_eod table
+func SaveCryptoToDatabase(ctx context.Context, dsn string, quotes []*tiingo.Eod) error {
+	log.Info().Msg("saving crypto prices to database")
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	numErrors := 0
+	for _, quote := range quotes {
+		_, err := conn.Exec(ctx, `INSERT INTO crypto_eod (
+			"ticker",
+			"exchange",
+			"currency",
+			"event_date",
+			"open",
+			"high",
+			"low",
+			"close",
+			"volume",
+			"source",
+			"run_id"
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+		) ON CONFLICT ON CONSTRAINT crypto_eod_pkey
+		DO UPDATE SET
+			currency = EXCLUDED.currency,
+			open = EXCLUDED.open,
+			high = EXCLUDED.high,
+			low = EXCLUDED.low,
+			close = EXCLUDED.close,
+			volume = EXCLUDED.volume,
+			source = EXCLUDED.source,
+			run_id = EXCLUDED.run_id;`,
+			quote.Ticker, quote.Exchange, quote.Currency, quote.Date,
+			quote.Open, quote.High, quote.Low, quote.Close, quote.Volume,
+			"api.tiingo.com", quote.RunID)
+		if err != nil {
+			numErrors++
+			log.Error().Err(err).Str("Ticker", quote.Ticker).Str("Exchange", quote.Exchange).Str("Date", quote.Date.Format("2006-01-02")).Msg("error saving crypto price to database")
+		}
+	}
+
+	if numErrors > 0 {
+		return fmt.Errorf("%d crypto prices could not be saved", numErrors)
+	}
+	return nil
+}
+
+// SaveCryptoBarsToDatabase saves one minute crypto bars to the
+// crypto_intraday table
+func SaveCryptoBarsToDatabase(ctx context.Context, dsn string, bars []*tiingo.CryptoBar) error {
+	log.Info().Msg("saving crypto bars to database")
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	numErrors := 0
+	for _, bar := range bars {
+		_, err := conn.Exec(ctx, `INSERT INTO crypto_intraday (
+			"ticker",
+			"exchange",
+			"event_time",
+			"open",
+			"high",
+			"low",
+			"close",
+			"volume",
+			"num_trades",
+			"source",
+			"run_id"
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+		) ON CONFLICT ON CONSTRAINT crypto_intraday_pkey
+		DO UPDATE SET
+			open = EXCLUDED.open,
+			high = EXCLUDED.high,
+			low = EXCLUDED.low,
+			close = EXCLUDED.close,
+			volume = EXCLUDED.volume,
+			num_trades = EXCLUDED.num_trades,
+			source = EXCLUDED.source,
+			run_id = EXCLUDED.run_id;`,
+			bar.Ticker, bar.Exchange, bar.Date,
+			bar.Open, bar.High, bar.Low, bar.Close, bar.Volume, bar.NumTrades,
+			bar.Source, bar.RunID)
+		if err != nil {
+			numErrors++
+			log.Error().Err(err).Str("Ticker", bar.Ticker).Str("Exchange", bar.Exchange).Time("Time", bar.Date).Msg("error saving crypto bar to database")
+		}
+	}
+
+	if numErrors > 0 {
+		return fmt.Errorf("%d crypto bars could not be saved", numErrors)
+	}
+	return nil
+}
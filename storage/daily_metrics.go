@@ -0,0 +1,79 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// SaveDailyMetricsToParquet saves daily valuation metrics to a parquet file
+func SaveDailyMetricsToParquet(ctx context.Context, records []*tiingo.DailyMetrics, fn string, opts *Options) error {
+	return writeParquet(ctx, records, fn, opts)
+}
+
+// SaveDailyMetricsToDatabase saves daily valuation metrics to the
+// daily_metrics table
+func SaveDailyMetricsToDatabase(ctx context.Context, dsn string, records []*tiingo.DailyMetrics) error {
+	log.Info().Msg("saving daily metrics to database")
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	numErrors := 0
+	for _, r := range records {
+		_, err := conn.Exec(ctx, `INSERT INTO daily_metrics (
+			"ticker",
+			"composite_figi",
+			"event_date",
+			"market_cap",
+			"enterprise_value",
+			"pe_ratio",
+			"pb_ratio",
+			"peg_ratio",
+			"run_id"
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9
+		) ON CONFLICT ON CONSTRAINT daily_metrics_pkey
+		DO UPDATE SET
+			ticker = EXCLUDED.ticker,
+			market_cap = EXCLUDED.market_cap,
+			enterprise_value = EXCLUDED.enterprise_value,
+			pe_ratio = EXCLUDED.pe_ratio,
+			pb_ratio = EXCLUDED.pb_ratio,
+			peg_ratio = EXCLUDED.peg_ratio,
+			run_id = EXCLUDED.run_id;`,
+			r.Ticker, r.CompositeFigi, r.Date,
+			r.MarketCap, r.EnterpriseValue, r.PERatio, r.PBRatio, r.PEGRatio,
+			r.RunID)
+		if err != nil {
+			numErrors++
+			log.Error().Err(err).Str("Ticker", r.Ticker).Str("Date", r.DateStr).Msg("error saving daily metrics to database")
+		}
+	}
+
+	if numErrors > 0 {
+		return fmt.Errorf("%d daily metrics could not be saved", numErrors)
+	}
+	return nil
+}
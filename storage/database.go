@@ -0,0 +1,449 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// eodColumns are the columns written to the eod table
+var eodColumns = []string{
+	"ticker",
+	"composite_figi",
+	"currency",
+	"event_date",
+	"open",
+	"high",
+	"low",
+	"close",
+	"volume",
+	"dividend",
+	"split_factor",
+	"split_adjust_factor",
+	"dividend_adjust_factor",
+	"adjusted_volume",
+	"adj_open",
+	"adj_high",
+	"adj_low",
+	"adj_close",
+	"source",
+	"run_id",
+}
+
+func eodRow(quote *tiingo.Eod) []interface{} {
+	return []interface{}{
+		quote.Ticker, quote.CompositeFigi, quote.Currency, quote.Date,
+		quote.Open, quote.High, quote.Low, quote.Close, quote.Volume,
+		quote.Dividend, quote.Split, quote.SplitAdjustFactor, quote.DividendAdjustFactor, quote.AdjustedVolume,
+		quote.AdjOpen, quote.AdjHigh, quote.AdjLow, quote.AdjClose,
+		"api.tiingo.com", quote.RunID,
+	}
+}
+
+// eodStagingSQL creates the temporary table quotes are copied into before
+// being merged into table
+func eodStagingSQL(table string) string {
+	return fmt.Sprintf(`CREATE TEMP TABLE eod_staging (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP`, table)
+}
+
+// eodDedupSQL removes duplicate quotes from the staging table keeping the
+// most recently copied row
+const eodDedupSQL = `DELETE FROM eod_staging a USING eod_staging b
+	WHERE a.ctid < b.ctid AND a.composite_figi = b.composite_figi AND a.event_date = b.event_date`
+
+// eodHistorySQL records the previously stored values of bars that are changed
+// by the merge in eod_history. Each history row is valid from the end of the
+// prior correction (NULL for the original load) until the time of the merge.
+const eodHistorySQL = `INSERT INTO eod_history (
+		"ticker",
+		"composite_figi",
+		"event_date",
+		"open",
+		"high",
+		"low",
+		"close",
+		"volume",
+		"dividend",
+		"split_factor",
+		"source",
+		"run_id",
+		"valid_from",
+		"valid_to"
+	) SELECT
+		e.ticker,
+		e.composite_figi,
+		e.event_date,
+		e.open,
+		e.high,
+		e.low,
+		e.close,
+		e.volume,
+		e.dividend,
+		e.split_factor,
+		e.source,
+		e.run_id,
+		(SELECT max(h.valid_to) FROM eod_history h WHERE h.composite_figi = e.composite_figi AND h.event_date = e.event_date),
+		now()
+	FROM eod e
+	JOIN eod_staging s ON s.composite_figi = e.composite_figi AND s.event_date = e.event_date
+	WHERE (e.open, e.high, e.low, e.close, e.volume, e.dividend, e.split_factor)
+		IS DISTINCT FROM (s.open, s.high, s.low, s.close, s.volume, s.dividend, s.split_factor)`
+
+// eodRevisionsSQL records staged quotes whose close or volume differs from
+// the stored value by more than the relative threshold $1 in eod_revisions;
+// $2 is the start of the run
+const eodRevisionsSQL = `INSERT INTO eod_revisions (
+		"ticker",
+		"composite_figi",
+		"event_date",
+		"old_close",
+		"new_close",
+		"old_volume",
+		"new_volume",
+		"run_id",
+		"revised_at"
+	) SELECT
+		s.ticker,
+		s.composite_figi,
+		s.event_date,
+		e.close,
+		s.close,
+		e.volume,
+		s.volume,
+		s.run_id,
+		$2
+	FROM eod e
+	JOIN eod_staging s ON s.composite_figi = e.composite_figi AND s.event_date = e.event_date
+	WHERE abs(s.close - e.close) > $1 * abs(e.close)
+		OR abs(s.volume - e.volume) > $1 * abs(e.volume)`
+
+// eodMergeSQL upserts the staged quotes into table. Re-imported rows that
+// were soft-deleted by sync --prune are reactivated.
+func eodMergeSQL(table string) string {
+	updates := make([]string, 0, len(eodColumns)+2)
+	for _, column := range eodColumns {
+		if column != "composite_figi" && column != "event_date" {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+		}
+	}
+	updates = append(updates, "active = true", "deactivated_at = NULL")
+
+	columns := strings.Join(eodColumns, ", ")
+	return fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM eod_staging
+	ON CONFLICT ON CONSTRAINT %s_pkey
+	DO UPDATE SET %s`, table, columns, columns, table, strings.Join(updates, ", "))
+}
+
+// SaveToDatabase saves EOD quotes to the penny vault database. Quotes are
+// written in parallel to opts.DatabaseURL and any additional opts.Targets;
+// an error is returned if any target failed.
+func SaveToDatabase(ctx context.Context, quotes []*tiingo.Eod, opts *Options) error {
+	targets := append([]string{opts.DatabaseURL}, opts.Targets...)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(targets))
+	for idx, target := range targets {
+		wg.Add(1)
+		go func(myIdx int, myTarget string) {
+			defer wg.Done()
+			errs[myIdx] = saveToDatabaseURL(ctx, quotes, myTarget, opts)
+		}(idx, target)
+	}
+	wg.Wait()
+
+	numFailed := 0
+	for idx, err := range errs {
+		if err != nil {
+			numFailed++
+			log.Error().Err(err).Str("Target", common.RedactDSN(targets[idx])).Msg("database target failed")
+		}
+	}
+
+	if numFailed > 0 {
+		return fmt.Errorf("%d of %d database targets failed", numFailed, len(targets))
+	}
+	return nil
+}
+
+// saveToDatabaseURL saves EOD quotes to the database identified by url. Quotes
+// are written in batches of opts.BatchSize; each batch is copied into a
+// temporary table and merged into eod (or the table of the quotes' frequency,
+// see EodTable). Batches are committed individually by
+// up to opts.Writers concurrent connections unless opts.Atomic is
+// set, in which case the entire save is a single transaction that is rolled
+// back on any error.
+func saveToDatabaseURL(ctx context.Context, quotes []*tiingo.Eod, url string, opts *Options) error {
+	if common.IsSQLiteDSN(url) {
+		return saveToSQLite(ctx, quotes, url, opts.Adjust)
+	}
+	if common.IsClickHouseDSN(url) {
+		return saveToClickHouse(ctx, quotes, url, opts.BatchSize)
+	}
+
+	target := common.RedactDSN(url)
+	table := quotesTable(quotes)
+	log.Info().Str("Target", target).Str("Table", table).Msg("saving to database")
+
+	writers := opts.Writers
+	if writers < 1 {
+		writers = 1
+	}
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		log.Error().Err(err).Str("Target", target).Msg("Could not parse database url")
+		return err
+	}
+	config.MaxConns = int32(writers)
+
+	pool, err := pgxpool.ConnectConfig(ctx, config)
+	if err != nil {
+		log.Error().Err(err).Str("Target", target).Msg("Could not connect to database")
+		return err
+	}
+	defer pool.Close()
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = len(quotes)
+	}
+
+	var ranges []batchRange
+	// only the daily eod table is a hypertable
+	if opts.Timescale && table == tiingo.EodTable(tiingo.FrequencyDaily) {
+		chunkInterval := opts.TimescaleChunkInterval
+		if err := ensureHypertable(ctx, pool, chunkInterval); err != nil {
+			log.Error().Err(err).Str("Target", target).Msg("could not create eod hypertable")
+			return reportUnsaved(target, quotes)
+		}
+		quotes, ranges = chunkBatches(quotes, chunkInterval, batchSize)
+	} else {
+		ranges = fixedBatches(len(quotes), batchSize)
+	}
+
+	atomic := opts.Atomic
+	var tx pgx.Tx
+	if atomic {
+		tx, err = pool.Begin(ctx)
+		if err != nil {
+			log.Error().Err(err).Str("Target", target).Msg("could not begin transaction")
+			return reportUnsaved(target, quotes)
+		}
+		defer tx.Rollback(ctx)
+	}
+
+	batches := make(chan batchRange)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	unsaved := []*tiingo.Eod{}
+	for ii := 0; ii < writers; ii++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				start, end := batch.start, batch.end
+				if err := saveBatch(ctx, pool, table, quotes[start:end], opts); err != nil {
+					log.Error().Err(err).Str("Target", target).Int("BatchStart", start).Int("BatchEnd", end).Msg("error saving EOD quotes to database")
+					mu.Lock()
+					unsaved = append(unsaved, quotes[start:end]...)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, batch := range ranges {
+		// a single transaction can only be used by one writer
+		if atomic {
+			start, end := batch.start, batch.end
+			if err := mergeBatch(ctx, tx, table, quotes[start:end], opts); err != nil {
+				log.Error().Err(err).Str("Target", target).Int("BatchStart", start).Int("BatchEnd", end).Msg("error saving EOD quotes to database; rolling back")
+				close(batches)
+				wg.Wait()
+				return reportUnsaved(target, quotes)
+			}
+			continue
+		}
+
+		batches <- batch
+	}
+	close(batches)
+	wg.Wait()
+
+	// factors of earlier windows are relative to a different quote once a
+	// new split or dividend is saved
+	if len(unsaved) == 0 {
+		if atomic {
+			if err := recomputeStoredFactors(ctx, tx, table, quotes, opts.Adjust); err != nil {
+				log.Error().Err(err).Str("Target", target).Msg("could not recompute adjustment factors; rolling back")
+				return reportUnsaved(target, quotes)
+			}
+		} else if err := recomputeFactorsTx(ctx, pool, table, quotes, opts.Adjust); err != nil {
+			log.Error().Err(err).Str("Target", target).Msg("could not recompute adjustment factors of stored history")
+			return err
+		}
+	}
+
+	if atomic {
+		if err := tx.Commit(ctx); err != nil {
+			log.Error().Err(err).Str("Target", target).Msg("could not commit transaction")
+			return reportUnsaved(target, quotes)
+		}
+	}
+
+	log.Info().Str("Target", target).Int("NumSaved", len(quotes)-len(unsaved)).Int("NumErrors", len(unsaved)).Msg("finished saving to database")
+	if len(unsaved) > 0 {
+		return reportUnsaved(target, unsaved)
+	}
+	return nil
+}
+
+// batchRange is the half-open range [start, end) of quotes written as one
+// batch
+type batchRange struct {
+	start int
+	end   int
+}
+
+// fixedBatches splits n quotes into consecutive batches of batchSize
+func fixedBatches(n, batchSize int) []batchRange {
+	ranges := []batchRange{}
+	for start := 0; start < n; start += batchSize {
+		end := start + batchSize
+		if end > n {
+			end = n
+		}
+		ranges = append(ranges, batchRange{start: start, end: end})
+	}
+	return ranges
+}
+
+// reportUnsaved logs the tickers whose quotes were not persisted to target
+// and returns an error describing them
+func reportUnsaved(target string, quotes []*tiingo.Eod) error {
+	counts := make(map[string]int)
+	for _, quote := range quotes {
+		counts[quote.Ticker]++
+	}
+
+	tickers := make([]string, 0, len(counts))
+	for ticker := range counts {
+		tickers = append(tickers, ticker)
+	}
+	sort.Strings(tickers)
+
+	for _, ticker := range tickers {
+		log.Warn().Str("Target", target).Str("Ticker", ticker).Int("NumQuotes", counts[ticker]).Msg("quotes not saved")
+	}
+
+	return fmt.Errorf("%d quotes for %d tickers could not be saved", len(quotes), len(tickers))
+}
+
+// recomputeFactorsTx recomputes stored adjustment factors in its own
+// transaction
+func recomputeFactorsTx(ctx context.Context, pool *pgxpool.Pool, table string, quotes []*tiingo.Eod, adjust tiingo.AdjustOptions) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := recomputeStoredFactors(ctx, tx, table, quotes, adjust); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// saveBatch writes a batch of quotes to table in its own transaction
+func saveBatch(ctx context.Context, pool *pgxpool.Pool, table string, quotes []*tiingo.Eod, opts *Options) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := mergeBatch(ctx, tx, table, quotes, opts); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// mergeBatch copies a batch of quotes into a staging table and merges them
+// into table as part of tx
+func mergeBatch(ctx context.Context, tx pgx.Tx, table string, quotes []*tiingo.Eod, opts *Options) error {
+	if _, err := tx.Exec(ctx, eodStagingSQL(table)); err != nil {
+		return err
+	}
+
+	rows := make([][]interface{}, len(quotes))
+	for idx, quote := range quotes {
+		rows[idx] = eodRow(quote)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"eod_staging"}, eodColumns, pgx.CopyFromRows(rows)); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, eodDedupSQL); err != nil {
+		return err
+	}
+
+	// eod_history only tracks corrections of daily bars
+	if opts.TrackCorrections && table == tiingo.EodTable(tiingo.FrequencyDaily) {
+		if _, err := tx.Exec(ctx, eodHistorySQL); err != nil {
+			return err
+		}
+	}
+
+	// restatements are only audited for daily bars
+	if opts.TrackRevisions && table == tiingo.EodTable(tiingo.FrequencyDaily) {
+		tag, err := tx.Exec(ctx, eodRevisionsSQL, opts.RevisionThreshold, common.RunStarted)
+		if err != nil {
+			return err
+		}
+		if n := tag.RowsAffected(); n > 0 {
+			log.Warn().Int64("NumRevisions", n).Msg("tiingo restated previously stored quotes; recorded in eod_revisions")
+		}
+	}
+
+	if _, err := tx.Exec(ctx, eodMergeSQL(table)); err != nil {
+		return err
+	}
+
+	// the staging table is recreated by the next batch of the same transaction
+	_, err := tx.Exec(ctx, `DROP TABLE eod_staging`)
+	return err
+}
+
+// quotesTable returns the table quotes are stored in; all quotes of a run
+// have the same frequency
+func quotesTable(quotes []*tiingo.Eod) string {
+	if len(quotes) == 0 {
+		return tiingo.EodTable(tiingo.FrequencyDaily)
+	}
+	return tiingo.EodTable(quotes[0].Frequency)
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// Kinds of differences reported by DiffQuotes
+const (
+	// DiffAdded is a quote that is in the file but not the database
+	DiffAdded = "added"
+
+	// DiffMissing is a quote that is in the database but not the file
+	DiffMissing = "missing"
+
+	// DiffChanged is a quote whose values differ between the file and the
+	// database
+	DiffChanged = "changed"
+)
+
+// QuoteDiff is a difference between a quote in a file and the database
+type QuoteDiff struct {
+	Kind   string
+	Ticker string
+	Date   string
+
+	// File and Stored are the quote in the file and in the database; one of
+	// them is nil for added and missing quotes
+	File   *tiingo.Eod
+	Stored *tiingo.Eod
+
+	// Changes describes each changed value as the stored value followed by
+	// the value in the file, e.g. "close 10.1 -> 10.2"
+	Changes []string
+}
+
+// storedQuoteRow is a quote read from the database
+type storedQuoteRow struct {
+	Ticker        string  `db:"ticker"`
+	CompositeFigi string  `db:"composite_figi"`
+	EventDate     string  `db:"event_date"`
+	Open          float64 `db:"open"`
+	High          float64 `db:"high"`
+	Low           float64 `db:"low"`
+	Close         float64 `db:"close"`
+	Volume        int64   `db:"volume"`
+	Dividend      float64 `db:"dividend"`
+	Split         float64 `db:"split_factor"`
+}
+
+// storedQuotesSQL selects the quotes of the tickers in a date range; %s is
+// the table
+const storedQuotesSQL = `SELECT ticker, COALESCE(composite_figi, '') AS composite_figi, %s AS event_date,
+	COALESCE(open, 0) AS open, COALESCE(high, 0) AS high, COALESCE(low, 0) AS low, COALESCE(close, 0) AS close,
+	CAST(COALESCE(volume, 0) AS BIGINT) AS volume, COALESCE(dividend, 0) AS dividend, COALESCE(split_factor, 0) AS split_factor
+	FROM %s`
+
+// LoadStoredQuotes reads the quotes of tickers between startDate and endDate
+// (inclusive) stored in table of the database at dsn
+func LoadStoredQuotes(ctx context.Context, dsn, table string, tickers []string, startDate, endDate time.Time) ([]*tiingo.Eod, error) {
+	var rows []*storedQuoteRow
+	start, end := startDate.Format("2006-01-02"), endDate.Format("2006-01-02")
+
+	switch {
+	case common.IsClickHouseDSN(dsn):
+		return nil, fmt.Errorf("reading stored quotes is %w", common.ErrUnsupportedDatabase)
+	case common.IsSQLiteDSN(dsn):
+		db, err := common.OpenSQLite(ctx, dsn)
+		if err != nil {
+			log.Error().Err(err).Msg("could not open sqlite database")
+			return nil, err
+		}
+		defer db.Close()
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tickers)), ", ")
+		query := fmt.Sprintf(storedQuotesSQL, "substr(event_date, 1, 10)", table) +
+			` WHERE ticker IN (` + placeholders + `) AND substr(event_date, 1, 10) BETWEEN ? AND ?`
+		args := make([]any, 0, len(tickers)+2)
+		for _, ticker := range tickers {
+			args = append(args, ticker)
+		}
+		args = append(args, start, end)
+
+		result, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			log.Error().Err(err).Msg("could not query stored quotes")
+			return nil, err
+		}
+		defer result.Close()
+		for result.Next() {
+			r := &storedQuoteRow{}
+			if err := result.Scan(&r.Ticker, &r.CompositeFigi, &r.EventDate, &r.Open, &r.High, &r.Low, &r.Close, &r.Volume, &r.Dividend, &r.Split); err != nil {
+				return nil, err
+			}
+			rows = append(rows, r)
+		}
+		if err := result.Err(); err != nil {
+			return nil, err
+		}
+	default:
+		conn, err := pgx.Connect(ctx, dsn)
+		if err != nil {
+			log.Error().Err(err).Msg("could not connect to database")
+			return nil, err
+		}
+		defer conn.Close(ctx)
+
+		query := fmt.Sprintf(storedQuotesSQL, "to_char(event_date, 'YYYY-MM-DD')", table) +
+			` WHERE ticker = any($1) AND source = 'api.tiingo.com' AND event_date BETWEEN $2 AND $3`
+		if err := pgxscan.Select(ctx, conn, &rows, query, tickers, start, end); err != nil {
+			log.Error().Err(err).Msg("could not query stored quotes")
+			return nil, err
+		}
+	}
+
+	// the exchange is not stored; quotes are dated at the NYSE close
+	quotes := make([]*tiingo.Eod, 0, len(rows))
+	for _, r := range rows {
+		quote := &tiingo.Eod{
+			Ticker:        r.Ticker,
+			CompositeFigi: r.CompositeFigi,
+			Open:          r.Open,
+			High:          r.High,
+			Low:           r.Low,
+			Close:         r.Close,
+			Volume:        r.Volume,
+			Dividend:      r.Dividend,
+			Split:         r.Split,
+		}
+		if date, err := time.Parse("2006-01-02", r.EventDate); err == nil {
+			quote.Date = common.NYSE.CloseTime(date)
+		}
+		quotes = append(quotes, quote)
+	}
+
+	log.Info().Int("NumQuotes", len(quotes)).Str("Table", table).Msg("loaded stored quotes")
+	return quotes, nil
+}
+
+// DiffQuotes compares the quotes of a file with the quotes stored in the
+// database, matching on ticker and date. Stored quotes outside the date range
+// of each ticker in the file are ignored. Values whose relative difference is
+// at most tolerance are considered equal.
+func DiffQuotes(file, stored []*tiingo.Eod, tolerance float64) []*QuoteDiff {
+	key := func(q *tiingo.Eod) string {
+		return strings.ToUpper(q.Ticker) + "|" + q.Date.Format("2006-01-02")
+	}
+
+	type dateRange struct{ first, last time.Time }
+	ranges := make(map[string]*dateRange)
+	fileQuotes := make(map[string]*tiingo.Eod, len(file))
+	for _, q := range file {
+		fileQuotes[key(q)] = q
+		ticker := strings.ToUpper(q.Ticker)
+		r, ok := ranges[ticker]
+		if !ok {
+			ranges[ticker] = &dateRange{first: q.Date, last: q.Date}
+			continue
+		}
+		if q.Date.Before(r.first) {
+			r.first = q.Date
+		}
+		if q.Date.After(r.last) {
+			r.last = q.Date
+		}
+	}
+
+	diffs := []*QuoteDiff{}
+	matched := make(map[string]bool, len(stored))
+	for _, s := range stored {
+		k := key(s)
+		r, ok := ranges[strings.ToUpper(s.Ticker)]
+		if !ok || s.Date.Before(r.first) || s.Date.After(r.last) {
+			continue
+		}
+
+		f, ok := fileQuotes[k]
+		if !ok {
+			diffs = append(diffs, &QuoteDiff{Kind: DiffMissing, Ticker: s.Ticker, Date: s.Date.Format("2006-01-02"), Stored: s})
+			continue
+		}
+		matched[k] = true
+		if changes := compareQuotes(f, s, tolerance); len(changes) > 0 {
+			diffs = append(diffs, &QuoteDiff{Kind: DiffChanged, Ticker: f.Ticker, Date: f.Date.Format("2006-01-02"), File: f, Stored: s, Changes: changes})
+		}
+	}
+
+	for k, f := range fileQuotes {
+		if !matched[k] {
+			diffs = append(diffs, &QuoteDiff{Kind: DiffAdded, Ticker: f.Ticker, Date: f.Date.Format("2006-01-02"), File: f})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Ticker != diffs[j].Ticker {
+			return diffs[i].Ticker < diffs[j].Ticker
+		}
+		return diffs[i].Date < diffs[j].Date
+	})
+	return diffs
+}
+
+// compareQuotes describes the values that differ between the file quote f
+// and the stored quote s
+func compareQuotes(f, s *tiingo.Eod, tolerance float64) []string {
+	fields := []struct {
+		name   string
+		file   float64
+		stored float64
+	}{
+		{"open", f.Open, s.Open},
+		{"high", f.High, s.High},
+		{"low", f.Low, s.Low},
+		{"close", f.Close, s.Close},
+		{"volume", float64(f.Volume), float64(s.Volume)},
+		{"dividend", f.Dividend, s.Dividend},
+		{"split_factor", f.Split, s.Split},
+	}
+
+	changes := []string{}
+	for _, field := range fields {
+		if !nearlyEqual(field.file, field.stored, tolerance) {
+			changes = append(changes, fmt.Sprintf("%s %g -> %g", field.name, field.stored, field.file))
+		}
+	}
+	return changes
+}
+
+// nearlyEqual returns true if the relative difference of a and b is at most
+// tolerance
+func nearlyEqual(a, b, tolerance float64) bool {
+	if a == b {
+		return true
+	}
+	scale := math.Max(math.Abs(a), math.Abs(b))
+	return math.Abs(a-b)/scale <= tolerance
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	_ "github.com/marcboeker/go-duckdb"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// SaveToDuckDB upserts EOD quotes into the eod table of the DuckDB database
+// at fn, creating the table if needed; adjust is used to recompute the
+// adjustment factors of stored history when a new split or dividend arrives
+func SaveToDuckDB(ctx context.Context, quotes []*tiingo.Eod, fn string, adjust tiingo.AdjustOptions) error {
+	log.Info().Str("FileName", fn).Int("NumQuotes", len(quotes)).Msg("saving quotes to duckdb")
+
+	db, err := sql.Open("duckdb", fn)
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not open duckdb database")
+		return err
+	}
+	defer db.Close()
+
+	return upsertEodSQL(ctx, db, quotes, adjust)
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// Exporter writes EOD quotes to a file in a particular format
+type Exporter interface {
+	// Export writes quotes to fn, a local path or s3:// URI
+	Export(ctx context.Context, quotes []*tiingo.Eod, fn string) error
+}
+
+// Export formats
+const (
+	FormatParquet = "parquet"
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatArrow   = "arrow"
+)
+
+// NewExporter returns the exporter for format. When compress is true text
+// formats are gzip compressed; files ending in .gz are always compressed.
+// Parquet files are written with opts.Parquet and s3:// URIs with opts.S3.
+func NewExporter(format string, compress bool, opts *Options) (Exporter, error) {
+	switch format {
+	case FormatParquet:
+		return parquetExporter{opts: opts}, nil
+	case FormatCSV:
+		return &textExporter{compress: compress, encode: encodeCSV, opts: opts}, nil
+	case FormatJSONL:
+		return &textExporter{compress: compress, encode: encodeJSONL, opts: opts}, nil
+	case FormatArrow:
+		return arrowExporter{opts: opts}, nil
+	default:
+		return nil, fmt.Errorf("unknown export format '%s'", format)
+	}
+}
+
+type parquetExporter struct {
+	opts *Options
+}
+
+func (e parquetExporter) Export(ctx context.Context, quotes []*tiingo.Eod, fn string) error {
+	return SaveToParquet(ctx, quotes, fn, e.opts)
+}
+
+// textExporter writes line oriented formats, optionally gzip compressed
+type textExporter struct {
+	compress bool
+	encode   func(ctx context.Context, w io.Writer, records []*eodRecord) error
+	opts     *Options
+}
+
+func (e *textExporter) Export(ctx context.Context, quotes []*tiingo.Eod, fn string) error {
+	target, err := common.OpenOutput(ctx, fn, e.opts.S3)
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("cannot create export file")
+		return err
+	}
+
+	buffered := bufio.NewWriter(target.File)
+	var w io.Writer = buffered
+	var gz *gzip.Writer
+	if e.compress || strings.HasSuffix(fn, ".gz") {
+		gz = gzip.NewWriter(buffered)
+		w = gz
+	}
+
+	records := make([]*eodRecord, len(quotes))
+	for idx, quote := range quotes {
+		records[idx] = newEodRecord(quote)
+	}
+
+	err = e.encode(ctx, w, records)
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("export failed")
+		target.Abort()
+		return err
+	}
+
+	if err := target.Commit(); err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not save export file")
+		return err
+	}
+
+	log.Info().Int("NumRecords", len(records)).Str("FileName", fn).Msg("export finished")
+	return nil
+}
+
+// eodRecord is the flat representation of a quote used by text exports and
+// message publishers
+type eodRecord struct {
+	Date                 string   `json:"date" avro:"date"`
+	Ticker               string   `json:"ticker" avro:"ticker"`
+	CompositeFigi        string   `json:"composite_figi" avro:"composite_figi"`
+	Exchange             string   `json:"exchange" avro:"exchange"`
+	Currency             string   `json:"currency" avro:"currency"`
+	Open                 float64  `json:"open" avro:"open"`
+	High                 float64  `json:"high" avro:"high"`
+	Low                  float64  `json:"low" avro:"low"`
+	Close                float64  `json:"close" avro:"close"`
+	Volume               int64    `json:"volume" avro:"volume"`
+	Dividend             float64  `json:"dividend" avro:"dividend"`
+	Split                float64  `json:"split" avro:"split"`
+	SplitAdjustFactor    float64  `json:"split_adjust_factor" avro:"split_adjust_factor"`
+	DividendAdjustFactor float64  `json:"dividend_adjust_factor" avro:"dividend_adjust_factor"`
+	AdjustedVolume       *int64   `json:"adjusted_volume" avro:"adjusted_volume"`
+	AdjOpen              *float64 `json:"adj_open" avro:"adj_open"`
+	AdjHigh              *float64 `json:"adj_high" avro:"adj_high"`
+	AdjLow               *float64 `json:"adj_low" avro:"adj_low"`
+	AdjClose             *float64 `json:"adj_close" avro:"adj_close"`
+	RunID                string   `json:"run_id" avro:"run_id"`
+}
+
+var eodRecordHeader = []string{
+	"date", "ticker", "composite_figi", "exchange", "currency",
+	"open", "high", "low", "close", "volume", "dividend", "split",
+	"split_adjust_factor", "dividend_adjust_factor", "adjusted_volume",
+	"adj_open", "adj_high", "adj_low", "adj_close", "run_id",
+}
+
+func newEodRecord(q *tiingo.Eod) *eodRecord {
+	return &eodRecord{
+		Date:                 q.Date.Format("2006-01-02"),
+		Ticker:               q.Ticker,
+		CompositeFigi:        q.CompositeFigi,
+		Exchange:             q.Exchange,
+		Currency:             q.Currency,
+		Open:                 q.Open,
+		High:                 q.High,
+		Low:                  q.Low,
+		Close:                q.Close,
+		Volume:               q.Volume,
+		Dividend:             q.Dividend,
+		Split:                q.Split,
+		SplitAdjustFactor:    q.SplitAdjustFactor,
+		DividendAdjustFactor: q.DividendAdjustFactor,
+		AdjustedVolume:       q.AdjustedVolume,
+		AdjOpen:              q.AdjOpen,
+		AdjHigh:              q.AdjHigh,
+		AdjLow:               q.AdjLow,
+		AdjClose:             q.AdjClose,
+		RunID:                q.RunID,
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatOptionalFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return formatFloat(*v)
+}
+
+func formatInt(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func formatOptionalInt(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return formatInt(*v)
+}
+
+func (r *eodRecord) csv() []string {
+	return []string{
+		r.Date, r.Ticker, r.CompositeFigi, r.Exchange, r.Currency,
+		formatFloat(r.Open), formatFloat(r.High), formatFloat(r.Low), formatFloat(r.Close),
+		formatInt(r.Volume), formatFloat(r.Dividend), formatFloat(r.Split),
+		formatFloat(r.SplitAdjustFactor), formatFloat(r.DividendAdjustFactor), formatOptionalInt(r.AdjustedVolume),
+		formatOptionalFloat(r.AdjOpen), formatOptionalFloat(r.AdjHigh), formatOptionalFloat(r.AdjLow), formatOptionalFloat(r.AdjClose),
+		r.RunID,
+	}
+}
+
+func encodeCSV(ctx context.Context, w io.Writer, records []*eodRecord) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(eodRecordHeader); err != nil {
+		return err
+	}
+	for _, r := range records {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := writer.Write(r.csv()); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func encodeJSONL(ctx context.Context, w io.Writer, records []*eodRecord) error {
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
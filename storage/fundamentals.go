@@ -0,0 +1,84 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// SaveFundamentalsToParquet saves financial statements to a parquet file
+func SaveFundamentalsToParquet(ctx context.Context, records []*tiingo.Fundamentals, fn string, opts *Options) error {
+	return writeParquet(ctx, records, fn, opts)
+}
+
+// fundamentalsUpsertSQL builds the upsert statement for the fundamentals table
+func fundamentalsUpsertSQL() string {
+	columns := []string{"ticker", "composite_figi", "event_date", "year", "quarter"}
+	columns = append(columns, tiingo.FundamentalColumns()...)
+	columns = append(columns, "run_id")
+
+	quoted := make([]string, len(columns))
+	params := make([]string, len(columns))
+	updates := make([]string, 0, len(columns))
+	for idx, column := range columns {
+		quoted[idx] = fmt.Sprintf(`"%s"`, column)
+		params[idx] = fmt.Sprintf("$%d", idx+1)
+		if column != "composite_figi" && column != "event_date" && column != "quarter" {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+		}
+	}
+
+	return fmt.Sprintf(`INSERT INTO fundamentals (%s) VALUES (%s) ON CONFLICT ON CONSTRAINT fundamentals_pkey DO UPDATE SET %s`,
+		strings.Join(quoted, ", "), strings.Join(params, ", "), strings.Join(updates, ", "))
+}
+
+// SaveFundamentalsToDatabase saves financial statements to the fundamentals
+// table
+func SaveFundamentalsToDatabase(ctx context.Context, dsn string, records []*tiingo.Fundamentals) error {
+	log.Info().Msg("saving fundamentals to database")
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	sql := fundamentalsUpsertSQL()
+	numErrors := 0
+	for _, r := range records {
+		args := []interface{}{r.Ticker, r.CompositeFigi, r.Date, r.Year, r.Quarter}
+		for _, value := range r.Values() {
+			args = append(args, value)
+		}
+		args = append(args, r.RunID)
+
+		if _, err := conn.Exec(ctx, sql, args...); err != nil {
+			numErrors++
+			log.Error().Err(err).Str("Ticker", r.Ticker).Str("Date", r.DateStr).Int32("Quarter", r.Quarter).Msg("error saving fundamentals to database")
+		}
+	}
+
+	if numErrors > 0 {
+		return fmt.Errorf("%d fundamentals records could not be saved", numErrors)
+	}
+	return nil
+}
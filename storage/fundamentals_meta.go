@@ -0,0 +1,136 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// SaveFundamentalDefinitionsToDatabase upserts statement data code
+// definitions into the fundamental_definitions table
+func SaveFundamentalDefinitionsToDatabase(ctx context.Context, dsn string, records []*tiingo.FundamentalDefinition) error {
+	log.Info().Msg("saving fundamental definitions to database")
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	numErrors := 0
+	for _, r := range records {
+		_, err := conn.Exec(ctx, `INSERT INTO fundamental_definitions (
+			"data_code",
+			"name",
+			"description",
+			"statement_type",
+			"units",
+			"updated_at"
+		) VALUES (
+			$1, $2, $3, $4, $5, now()
+		) ON CONFLICT ON CONSTRAINT fundamental_definitions_pkey
+		DO UPDATE SET
+			name = EXCLUDED.name,
+			description = EXCLUDED.description,
+			statement_type = EXCLUDED.statement_type,
+			units = EXCLUDED.units,
+			updated_at = EXCLUDED.updated_at;`,
+			r.DataCode, r.Name, r.Description, r.StatementType, r.Units)
+		if err != nil {
+			numErrors++
+			log.Error().Err(err).Str("DataCode", r.DataCode).Msg("error saving fundamental definition to database")
+		}
+	}
+
+	if numErrors > 0 {
+		return fmt.Errorf("%d fundamental definitions could not be saved", numErrors)
+	}
+	return nil
+}
+
+// SaveFundamentalsMetaToDatabase upserts fundamentals meta data into the
+// fundamentals_meta table
+func SaveFundamentalsMetaToDatabase(ctx context.Context, dsn string, records []*tiingo.FundamentalsMeta) error {
+	log.Info().Msg("saving fundamentals meta to database")
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	numErrors := 0
+	for _, r := range records {
+		_, err := conn.Exec(ctx, `INSERT INTO fundamentals_meta (
+			"composite_figi",
+			"ticker",
+			"perma_ticker",
+			"name",
+			"is_active",
+			"is_adr",
+			"sector",
+			"industry",
+			"sic_code",
+			"sic_sector",
+			"sic_industry",
+			"reporting_currency",
+			"location",
+			"company_website",
+			"sec_filing_website",
+			"statement_last_updated",
+			"daily_last_updated",
+			"run_id"
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18
+		) ON CONFLICT ON CONSTRAINT fundamentals_meta_pkey
+		DO UPDATE SET
+			ticker = EXCLUDED.ticker,
+			perma_ticker = EXCLUDED.perma_ticker,
+			name = EXCLUDED.name,
+			is_active = EXCLUDED.is_active,
+			is_adr = EXCLUDED.is_adr,
+			sector = EXCLUDED.sector,
+			industry = EXCLUDED.industry,
+			sic_code = EXCLUDED.sic_code,
+			sic_sector = EXCLUDED.sic_sector,
+			sic_industry = EXCLUDED.sic_industry,
+			reporting_currency = EXCLUDED.reporting_currency,
+			location = EXCLUDED.location,
+			company_website = EXCLUDED.company_website,
+			sec_filing_website = EXCLUDED.sec_filing_website,
+			statement_last_updated = EXCLUDED.statement_last_updated,
+			daily_last_updated = EXCLUDED.daily_last_updated,
+			run_id = EXCLUDED.run_id;`,
+			r.CompositeFigi, r.Ticker, r.PermaTicker, r.Name, r.IsActive, r.IsADR,
+			r.Sector, r.Industry, r.SicCode, r.SicSector, r.SicIndustry,
+			r.ReportingCurrency, r.Location, r.CompanyWebsite, r.SecFilingWebsite,
+			r.StatementLastUpdated, r.DailyLastUpdated, r.RunID)
+		if err != nil {
+			numErrors++
+			log.Error().Err(err).Str("Ticker", r.Ticker).Msg("error saving fundamentals meta to database")
+		}
+	}
+
+	if numErrors > 0 {
+		return fmt.Errorf("%d fundamentals meta records could not be saved", numErrors)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// SaveFxToParquet saves forex rates to a parquet file
+func SaveFxToParquet(ctx context.Context, records []*tiingo.FxRate, fn string, opts *Options) error {
+	return writeParquet(ctx, records, fn, opts)
+}
+
+// SaveFxToDatabase saves forex rates to the currency_rates table
+func SaveFxToDatabase(ctx context.Context, dsn string, rates []*tiingo.FxRate) error {
+	log.Info().Msg("saving fx rates to database")
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	numErrors := 0
+	for _, rate := range rates {
+		_, err := conn.Exec(ctx, `INSERT INTO currency_rates (
+			"base_currency",
+			"quote_currency",
+			"event_date",
+			"open",
+			"high",
+			"low",
+			"close",
+			"source",
+			"run_id"
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9
+		) ON CONFLICT ON CONSTRAINT currency_rates_pkey
+		DO UPDATE SET
+			open = EXCLUDED.open,
+			high = EXCLUDED.high,
+			low = EXCLUDED.low,
+			close = EXCLUDED.close,
+			source = EXCLUDED.source,
+			run_id = EXCLUDED.run_id;`,
+			rate.BaseCurrency, rate.QuoteCurrency, rate.Date,
+			rate.Open, rate.High, rate.Low, rate.Close,
+			"api.tiingo.com", rate.RunID)
+		if err != nil {
+			numErrors++
+			log.Error().Err(err).Str("Pair", rate.Ticker).Str("Date", rate.DateStr).Msg("error saving fx rate to database")
+		}
+	}
+
+	if numErrors > 0 {
+		return fmt.Errorf("%d fx rates could not be saved", numErrors)
+	}
+	return nil
+}
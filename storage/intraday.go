@@ -0,0 +1,80 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// SaveIntradayToParquet saves intraday bars to a parquet file
+func SaveIntradayToParquet(ctx context.Context, records []*tiingo.IntradayBar, fn string, opts *Options) error {
+	return writeParquet(ctx, records, fn, opts)
+}
+
+// SaveIntradayToDatabase saves intraday bars to the intraday table
+func SaveIntradayToDatabase(ctx context.Context, dsn string, records []*tiingo.IntradayBar) error {
+	log.Info().Msg("saving intraday bars to database")
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	numErrors := 0
+	for _, bar := range records {
+		_, err := conn.Exec(ctx, `INSERT INTO intraday (
+			"ticker",
+			"composite_figi",
+			"event_time",
+			"frequency",
+			"open",
+			"high",
+			"low",
+			"close",
+			"volume",
+			"source",
+			"run_id"
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+		) ON CONFLICT ON CONSTRAINT intraday_pkey
+		DO UPDATE SET
+			open = EXCLUDED.open,
+			high = EXCLUDED.high,
+			low = EXCLUDED.low,
+			close = EXCLUDED.close,
+			volume = EXCLUDED.volume,
+			source = EXCLUDED.source,
+			run_id = EXCLUDED.run_id;`,
+			bar.Ticker, bar.CompositeFigi, bar.Date, bar.Frequency,
+			bar.Open, bar.High, bar.Low, bar.Close, bar.Volume,
+			"api.tiingo.com/iex", bar.RunID)
+		if err != nil {
+			numErrors++
+			log.Error().Err(err).Str("Ticker", bar.Ticker).Str("Date", bar.DateStr).Msg("error saving intraday bar to database")
+		}
+	}
+
+	if numErrors > 0 {
+		return fmt.Errorf("%d intraday bars could not be saved", numErrors)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaPublisher publishes quotes to a Kafka topic keyed by composite FIGI so
+// all quotes of an asset land in the same partition
+type KafkaPublisher struct {
+	writer    *kafka.Writer
+	encode    eodEncoder
+	encodeBar barEncoder
+
+	mu           sync.Mutex
+	numPublished int
+	numFailed    int
+}
+
+// NewKafkaPublisher creates a publisher for topic; format is json or avro
+func NewKafkaPublisher(brokers []string, topic, format string) (*KafkaPublisher, error) {
+	encode, err := newEodEncoder(format)
+	if err != nil {
+		return nil, err
+	}
+	encodeBar, err := newBarEncoder(format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: 100 * time.Millisecond,
+		},
+		encode:    encode,
+		encodeBar: encodeBar,
+	}, nil
+}
+
+// Publish sends quotes to the topic. It is safe to call concurrently; errors
+// are logged and reported by Close.
+func (p *KafkaPublisher) Publish(ctx context.Context, quotes []*tiingo.Eod) {
+	messages := make([]kafka.Message, 0, len(quotes))
+	for _, q := range quotes {
+		value, err := p.encode(q)
+		if err != nil {
+			log.Error().Err(err).Str("Ticker", q.Ticker).Str("Date", q.Date.Format("2006-01-02")).Msg("could not encode quote")
+			p.count(0, 1)
+			continue
+		}
+		messages = append(messages, kafka.Message{
+			Key:   []byte(q.CompositeFigi),
+			Value: value,
+		})
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	if err := p.writer.WriteMessages(ctx, messages...); err != nil {
+		log.Error().Err(err).Str("Ticker", quotes[0].Ticker).Int("NumQuotes", len(messages)).Msg("could not publish quotes to kafka")
+		p.count(0, len(messages))
+		return
+	}
+	p.count(len(messages), 0)
+}
+
+// PublishBars sends intraday bars to the topic keyed by composite FIGI. It is
+// safe to call concurrently; errors are logged and reported by Close.
+func (p *KafkaPublisher) PublishBars(ctx context.Context, bars []*tiingo.IntradayBar) {
+	messages := make([]kafka.Message, 0, len(bars))
+	for _, bar := range bars {
+		value, err := p.encodeBar(bar)
+		if err != nil {
+			log.Error().Err(err).Str("Ticker", bar.Ticker).Time("Time", bar.Date).Msg("could not encode intraday bar")
+			p.count(0, 1)
+			continue
+		}
+		messages = append(messages, kafka.Message{
+			Key:   []byte(bar.CompositeFigi),
+			Value: value,
+		})
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	if err := p.writer.WriteMessages(ctx, messages...); err != nil {
+		log.Error().Err(err).Int("NumBars", len(messages)).Msg("could not publish intraday bars to kafka")
+		p.count(0, len(messages))
+		return
+	}
+	p.count(len(messages), 0)
+}
+
+func (p *KafkaPublisher) count(published, failed int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.numPublished += published
+	p.numFailed += failed
+}
+
+// NumPublished returns the number of quotes acknowledged by the brokers
+func (p *KafkaPublisher) NumPublished() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.numPublished
+}
+
+// Close flushes pending messages and returns an error if any quote could not
+// be published
+func (p *KafkaPublisher) Close() error {
+	if err := p.writer.Close(); err != nil {
+		log.Error().Err(err).Msg("could not close kafka writer")
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.numFailed > 0 {
+		return fmt.Errorf("%d quotes could not be published to kafka", p.numFailed)
+	}
+	log.Info().Int("NumQuotes", p.numPublished).Str("Topic", p.writer.Topic).Msg("published quotes to kafka")
+	return nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// LatestQuoteDates returns the date of the most recent stored quote of each
+// asset, in the table of opts.Frequency, keyed by upper case ticker. Assets
+// without stored quotes are not included.
+func LatestQuoteDates(ctx context.Context, assets []*common.Asset, opts *Options) (map[string]time.Time, error) {
+	nyc, _ := time.LoadLocation("America/New_York")
+	latest := make(map[string]time.Time, len(assets))
+
+	type row struct {
+		Key       string `db:"key"`
+		EventDate string `db:"event_date"`
+	}
+	var rows []*row
+
+	table := tiingo.EodTable(opts.Frequency)
+	dsn := opts.ReadDSN()
+	if common.IsSQLiteDSN(dsn) {
+		// sqlite databases are keyed on ticker
+		tickers := make(map[string]bool, len(assets))
+		for _, asset := range assets {
+			tickers[strings.ToUpper(asset.Ticker)] = true
+		}
+
+		db, err := common.OpenSQLite(ctx, dsn)
+		if err != nil {
+			log.Error().Err(err).Msg("could not open sqlite database")
+			return nil, err
+		}
+		defer db.Close()
+
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(eodSchemaSQL, table)); err != nil {
+			return nil, err
+		}
+		result, err := db.QueryContext(ctx, `SELECT ticker, max(event_date) FROM `+table+` GROUP BY ticker`)
+		if err != nil {
+			log.Error().Err(err).Msg("could not query latest quote dates")
+			return nil, err
+		}
+		defer result.Close()
+		for result.Next() {
+			r := &row{}
+			if err := result.Scan(&r.Key, &r.EventDate); err != nil {
+				return nil, err
+			}
+			if tickers[strings.ToUpper(r.Key)] {
+				rows = append(rows, r)
+			}
+		}
+	} else {
+		figis := make([]string, 0, len(assets))
+		for _, asset := range assets {
+			if asset.CompositeFigi != "" {
+				figis = append(figis, asset.CompositeFigi)
+			}
+		}
+
+		conn, err := pgx.Connect(ctx, dsn)
+		if err != nil {
+			log.Error().Err(err).Msg("could not connect to database")
+			return nil, err
+		}
+		defer conn.Close(ctx)
+
+		err = pgxscan.Select(ctx, conn, &rows, `SELECT composite_figi AS key, to_char(max(event_date), 'YYYY-MM-DD') AS event_date
+			FROM `+table+` WHERE composite_figi = any($1) GROUP BY composite_figi`, figis)
+		if err != nil {
+			log.Error().Err(err).Msg("could not query latest quote dates")
+			return nil, err
+		}
+
+		byFigi := make(map[string]string, len(assets))
+		for _, asset := range assets {
+			byFigi[asset.CompositeFigi] = asset.Ticker
+		}
+		for _, r := range rows {
+			r.Key = byFigi[r.Key]
+		}
+	}
+
+	for _, r := range rows {
+		if len(r.EventDate) < 10 {
+			continue
+		}
+		date, err := time.ParseInLocation("2006-01-02", r.EventDate[:10], nyc)
+		if err != nil {
+			log.Warn().Err(err).Str("Key", r.Key).Str("Date", r.EventDate).Msg("could not parse latest quote date")
+			continue
+		}
+		latest[strings.ToUpper(r.Key)] = date
+	}
+
+	log.Info().Int("NumAssets", len(latest)).Msg("loaded latest stored quote dates")
+	return latest, nil
+}
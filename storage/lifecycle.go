@@ -0,0 +1,160 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// DelistingCandidate is an asset that has returned no data for consecutive
+// runs
+type DelistingCandidate struct {
+	Ticker        string
+	CompositeFigi string
+	LastSeen      *time.Time
+	MissingRuns   int
+}
+
+// UpdateAssetLifecycle records the outcome of a download in the assets
+// table. Assets that returned quotes have last_seen set to their most recent
+// quote and their missing run count reset; assets in missing (see
+// MissingTickers) are flagged as possibly delisted and their missing run
+// count is incremented. Other assets, e.g. those that failed because of rate
+// limiting, are left unchanged.
+func UpdateAssetLifecycle(ctx context.Context, dsn string, assets []*common.Asset, quotes []*tiingo.Eod, missing []string) error {
+	lastSeen := make(map[string]time.Time)
+	for _, quote := range quotes {
+		if quote.Date.After(lastSeen[quote.CompositeFigi]) {
+			lastSeen[quote.CompositeFigi] = quote.Date
+		}
+	}
+
+	isMissing := make(map[string]bool, len(missing))
+	for _, ticker := range missing {
+		isMissing[ticker] = true
+	}
+
+	seenFigis := []string{}
+	seenDates := []time.Time{}
+	missingFigis := []string{}
+	for _, asset := range assets {
+		if asset.CompositeFigi == "" {
+			continue
+		}
+		if date, ok := lastSeen[asset.CompositeFigi]; ok {
+			seenFigis = append(seenFigis, asset.CompositeFigi)
+			seenDates = append(seenDates, date)
+		} else if isMissing[asset.Ticker] {
+			missingFigis = append(missingFigis, asset.CompositeFigi)
+		}
+	}
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `UPDATE assets SET
+			last_seen = GREATEST(assets.last_seen, s.last_seen),
+			missing_runs = 0,
+			possibly_delisted = false
+		FROM (SELECT unnest($1::text[]) AS composite_figi, unnest($2::date[]) AS last_seen) s
+		WHERE assets.composite_figi = s.composite_figi`, seenFigis, seenDates); err != nil {
+		log.Error().Err(err).Msg("could not update last seen dates")
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE assets SET
+			missing_runs = COALESCE(missing_runs, 0) + 1,
+			possibly_delisted = true
+		WHERE composite_figi = any($1)`, missingFigis); err != nil {
+		log.Error().Err(err).Msg("could not flag possibly delisted assets")
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("could not commit asset lifecycle updates")
+		return err
+	}
+
+	log.Info().Int("NumSeen", len(seenFigis)).Int("NumMissing", len(missingFigis)).Msg("updated asset lifecycle")
+	return nil
+}
+
+// FindDelistingCandidates returns active assets that have returned no data
+// for at least minRuns consecutive runs
+func FindDelistingCandidates(ctx context.Context, dsn string, minRuns int) ([]*DelistingCandidate, error) {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	candidates := []*DelistingCandidate{}
+	err = pgxscan.Select(ctx, conn, &candidates, `SELECT ticker, composite_figi, last_seen, missing_runs
+		FROM assets
+		WHERE active = 't' AND possibly_delisted AND missing_runs >= $1
+		ORDER BY missing_runs DESC, ticker`, minRuns)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query delisting candidates")
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// DeactivateAssets marks the candidates inactive in the assets table and
+// records when they were deactivated
+func DeactivateAssets(ctx context.Context, dsn string, candidates []*DelistingCandidate) error {
+	figis := make([]string, len(candidates))
+	for idx, candidate := range candidates {
+		figis[idx] = candidate.CompositeFigi
+	}
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, `UPDATE assets SET
+			active = false,
+			delisting_date = COALESCE(delisting_date, last_seen),
+			deactivated_at = COALESCE(deactivated_at, now()),
+			last_updated = extract(epoch from now())::bigint
+		WHERE composite_figi = any($1)`, figis); err != nil {
+		log.Error().Err(err).Msg("could not deactivate assets")
+		return err
+	}
+	return nil
+}
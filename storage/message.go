@@ -0,0 +1,177 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	"github.com/penny-vault/import-tiingo/tiingo"
+)
+
+// Message encodings for published quotes
+const (
+	MessageJSON = "json"
+	MessageAvro = "avro"
+)
+
+// EodAvroSchema is the Avro schema of published quotes
+const EodAvroSchema = `{
+	"type": "record",
+	"name": "Eod",
+	"namespace": "com.pennyvault.tiingo",
+	"fields": [
+		{"name": "date", "type": "string"},
+		{"name": "ticker", "type": "string"},
+		{"name": "composite_figi", "type": "string"},
+		{"name": "exchange", "type": "string"},
+		{"name": "currency", "type": "string"},
+		{"name": "open", "type": "double"},
+		{"name": "high", "type": "double"},
+		{"name": "low", "type": "double"},
+		{"name": "close", "type": "double"},
+		{"name": "volume", "type": "long"},
+		{"name": "dividend", "type": "double"},
+		{"name": "split", "type": "double"},
+		{"name": "split_adjust_factor", "type": "double"},
+		{"name": "dividend_adjust_factor", "type": "double"},
+		{"name": "adjusted_volume", "type": ["null", "long"], "default": null},
+		{"name": "adj_open", "type": ["null", "double"], "default": null},
+		{"name": "adj_high", "type": ["null", "double"], "default": null},
+		{"name": "adj_low", "type": ["null", "double"], "default": null},
+		{"name": "adj_close", "type": ["null", "double"], "default": null},
+		{"name": "run_id", "type": "string"}
+	]
+}`
+
+// IntradayAvroSchema is the Avro schema of published intraday bars
+const IntradayAvroSchema = `{
+	"type": "record",
+	"name": "IntradayBar",
+	"namespace": "com.pennyvault.tiingo",
+	"fields": [
+		{"name": "time", "type": "string"},
+		{"name": "ticker", "type": "string"},
+		{"name": "composite_figi", "type": "string"},
+		{"name": "frequency", "type": "string"},
+		{"name": "open", "type": "double"},
+		{"name": "high", "type": "double"},
+		{"name": "low", "type": "double"},
+		{"name": "close", "type": "double"},
+		{"name": "volume", "type": "long"},
+		{"name": "run_id", "type": "string"}
+	]
+}`
+
+// Publisher streams quotes to a message broker
+type Publisher interface {
+	// Publish sends quotes to the broker; it is safe to call concurrently
+	Publish(ctx context.Context, quotes []*tiingo.Eod)
+
+	// PublishBars sends intraday bars to the broker; it is safe to call
+	// concurrently
+	PublishBars(ctx context.Context, bars []*tiingo.IntradayBar)
+
+	// NumPublished returns the number of quotes acknowledged by the broker
+	NumPublished() int
+
+	// Close flushes pending messages and returns an error if any quote
+	// could not be published
+	Close() error
+}
+
+var (
+	_ Publisher = (*KafkaPublisher)(nil)
+	_ Publisher = (*NatsPublisher)(nil)
+)
+
+// eodEncoder serializes a quote as a message body
+type eodEncoder func(q *tiingo.Eod) ([]byte, error)
+
+// newEodEncoder returns the encoder for the given message format
+func newEodEncoder(format string) (eodEncoder, error) {
+	switch format {
+	case "", MessageJSON:
+		return func(q *tiingo.Eod) ([]byte, error) {
+			return json.Marshal(newEodRecord(q))
+		}, nil
+	case MessageAvro:
+		schema, err := avro.Parse(EodAvroSchema)
+		if err != nil {
+			return nil, err
+		}
+		return func(q *tiingo.Eod) ([]byte, error) {
+			return avro.Marshal(schema, newEodRecord(q))
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown message format %q (expected %s or %s)", format, MessageJSON, MessageAvro)
+	}
+}
+
+// barRecord is the representation of an intraday bar in published messages
+type barRecord struct {
+	Time          string  `json:"time" avro:"time"`
+	Ticker        string  `json:"ticker" avro:"ticker"`
+	CompositeFigi string  `json:"composite_figi" avro:"composite_figi"`
+	Frequency     string  `json:"frequency" avro:"frequency"`
+	Open          float64 `json:"open" avro:"open"`
+	High          float64 `json:"high" avro:"high"`
+	Low           float64 `json:"low" avro:"low"`
+	Close         float64 `json:"close" avro:"close"`
+	Volume        int64   `json:"volume" avro:"volume"`
+	RunID         string  `json:"run_id" avro:"run_id"`
+}
+
+func newBarRecord(bar *tiingo.IntradayBar) *barRecord {
+	return &barRecord{
+		Time:          bar.Date.UTC().Format(time.RFC3339),
+		Ticker:        bar.Ticker,
+		CompositeFigi: bar.CompositeFigi,
+		Frequency:     bar.Frequency,
+		Open:          bar.Open,
+		High:          bar.High,
+		Low:           bar.Low,
+		Close:         bar.Close,
+		Volume:        bar.Volume,
+		RunID:         bar.RunID,
+	}
+}
+
+// barEncoder serializes an intraday bar as a message body
+type barEncoder func(bar *tiingo.IntradayBar) ([]byte, error)
+
+// newBarEncoder returns the bar encoder for the given message format
+func newBarEncoder(format string) (barEncoder, error) {
+	switch format {
+	case "", MessageJSON:
+		return func(bar *tiingo.IntradayBar) ([]byte, error) {
+			return json.Marshal(newBarRecord(bar))
+		}, nil
+	case MessageAvro:
+		schema, err := avro.Parse(IntradayAvroSchema)
+		if err != nil {
+			return nil, err
+		}
+		return func(bar *tiingo.IntradayBar) ([]byte, error) {
+			return avro.Marshal(schema, newBarRecord(bar))
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown message format %q (expected %s or %s)", format, MessageJSON, MessageAvro)
+	}
+}
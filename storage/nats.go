@@ -0,0 +1,228 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultNatsSubject is the default subject template for published quotes
+const DefaultNatsSubject = "eod.{exchange}.{ticker}"
+
+// subjectReplacer removes characters that have special meaning in NATS
+// subjects from template values; e.g. BRK.A is published as BRK-A
+var subjectReplacer = strings.NewReplacer(".", "-", "/", "-", " ", "-", "*", "-", ">", "-")
+
+// NatsPublisher publishes quotes to a NATS JetStream stream. Each publish is
+// acknowledged by the server and retried when no stream responds; messages
+// carry a Nats-Msg-Id of FIGI and date so retried publishes are de-duplicated
+// by the stream.
+type NatsPublisher struct {
+	conn      *nats.Conn
+	js        jetstream.JetStream
+	subject   string
+	encode    eodEncoder
+	encodeBar barEncoder
+
+	mu           sync.Mutex
+	numPublished int
+	numFailed    int
+}
+
+// NewNatsPublisher connects to the NATS server at url. subject is a template
+// that may reference {ticker}, {exchange}, {figi} and {currency}.
+func NewNatsPublisher(url, subject, format string) (*NatsPublisher, error) {
+	encode, err := newEodEncoder(format)
+	if err != nil {
+		return nil, err
+	}
+	encodeBar, err := newBarEncoder(format)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := nats.Connect(url, nats.Name("import-tiingo"))
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(conn, jetstream.WithPublishAsyncMaxPending(1024))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if subject == "" {
+		subject = DefaultNatsSubject
+	}
+
+	return &NatsPublisher{
+		conn:      conn,
+		js:        js,
+		subject:   subject,
+		encode:    encode,
+		encodeBar: encodeBar,
+	}, nil
+}
+
+// Subject expands the subject template for q
+func (p *NatsPublisher) Subject(q *tiingo.Eod) string {
+	exchange := q.Exchange
+	if exchange == "" {
+		exchange = "unknown"
+	}
+	return strings.NewReplacer(
+		"{ticker}", subjectReplacer.Replace(q.Ticker),
+		"{exchange}", subjectReplacer.Replace(exchange),
+		"{figi}", subjectReplacer.Replace(q.CompositeFigi),
+		"{currency}", subjectReplacer.Replace(q.Currency),
+	).Replace(p.subject)
+}
+
+// BarSubject expands the subject template for an intraday bar. Bars come
+// from IEX and are priced in USD; {exchange} and {currency} expand to IEX and
+// USD.
+func (p *NatsPublisher) BarSubject(bar *tiingo.IntradayBar) string {
+	return strings.NewReplacer(
+		"{ticker}", subjectReplacer.Replace(bar.Ticker),
+		"{exchange}", "IEX",
+		"{figi}", subjectReplacer.Replace(bar.CompositeFigi),
+		"{currency}", "USD",
+		"{frequency}", subjectReplacer.Replace(bar.Frequency),
+	).Replace(p.subject)
+}
+
+// Publish sends quotes to the stream and waits for each acknowledgement. It is
+// safe to call concurrently; errors are logged and reported by Close.
+func (p *NatsPublisher) Publish(ctx context.Context, quotes []*tiingo.Eod) {
+	futures := make([]jetstream.PubAckFuture, 0, len(quotes))
+	numFailed := 0
+	for _, q := range quotes {
+		data, err := p.encode(q)
+		if err != nil {
+			log.Error().Err(err).Str("Ticker", q.Ticker).Str("Date", q.Date.Format("2006-01-02")).Msg("could not encode quote")
+			numFailed++
+			continue
+		}
+
+		msg := &nats.Msg{Subject: p.Subject(q), Data: data}
+		future, err := p.js.PublishMsgAsync(msg,
+			jetstream.WithMsgID(fmt.Sprintf("%s.%s", q.CompositeFigi, q.Date.Format("2006-01-02"))),
+			jetstream.WithRetryAttempts(3))
+		if err != nil {
+			log.Error().Err(err).Str("Ticker", q.Ticker).Str("Subject", msg.Subject).Msg("could not publish quote to nats")
+			numFailed++
+			continue
+		}
+		futures = append(futures, future)
+	}
+
+	numPublished := 0
+	for _, future := range futures {
+		select {
+		case <-future.Ok():
+			numPublished++
+		case err := <-future.Err():
+			log.Error().Err(err).Str("Subject", future.Msg().Subject).Msg("nats did not acknowledge quote")
+			numFailed++
+		case <-ctx.Done():
+			numFailed++
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.numPublished += numPublished
+	p.numFailed += numFailed
+}
+
+// PublishBars sends intraday bars to the stream and waits for each
+// acknowledgement. Messages carry a Nats-Msg-Id of FIGI, frequency and bar
+// time. It is safe to call concurrently; errors are logged and reported by
+// Close.
+func (p *NatsPublisher) PublishBars(ctx context.Context, bars []*tiingo.IntradayBar) {
+	futures := make([]jetstream.PubAckFuture, 0, len(bars))
+	numFailed := 0
+	for _, bar := range bars {
+		data, err := p.encodeBar(bar)
+		if err != nil {
+			log.Error().Err(err).Str("Ticker", bar.Ticker).Time("Time", bar.Date).Msg("could not encode intraday bar")
+			numFailed++
+			continue
+		}
+
+		msg := &nats.Msg{Subject: p.BarSubject(bar), Data: data}
+		future, err := p.js.PublishMsgAsync(msg,
+			jetstream.WithMsgID(fmt.Sprintf("%s.%s.%d", bar.CompositeFigi, bar.Frequency, bar.Date.Unix())),
+			jetstream.WithRetryAttempts(3))
+		if err != nil {
+			log.Error().Err(err).Str("Ticker", bar.Ticker).Str("Subject", msg.Subject).Msg("could not publish intraday bar to nats")
+			numFailed++
+			continue
+		}
+		futures = append(futures, future)
+	}
+
+	numPublished := 0
+	for _, future := range futures {
+		select {
+		case <-future.Ok():
+			numPublished++
+		case err := <-future.Err():
+			log.Error().Err(err).Str("Subject", future.Msg().Subject).Msg("nats did not acknowledge intraday bar")
+			numFailed++
+		case <-ctx.Done():
+			numFailed++
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.numPublished += numPublished
+	p.numFailed += numFailed
+}
+
+// NumPublished returns the number of quotes acknowledged by the stream
+func (p *NatsPublisher) NumPublished() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.numPublished
+}
+
+// Close drains the connection and returns an error if any quote could not be
+// published
+func (p *NatsPublisher) Close() error {
+	if err := p.conn.Drain(); err != nil {
+		log.Error().Err(err).Msg("could not drain nats connection")
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.numFailed > 0 {
+		return fmt.Errorf("%d quotes could not be published to nats", p.numFailed)
+	}
+	log.Info().Int("NumQuotes", p.numPublished).Str("Subject", p.subject).Msg("published quotes to nats")
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// SaveNewsToDatabase saves news articles to the news table. Articles that
+// already exist (by ID) are updated.
+func SaveNewsToDatabase(ctx context.Context, dsn string, articles []*tiingo.NewsArticle) error {
+	log.Info().Msg("saving news to database")
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	numErrors := 0
+	for _, article := range articles {
+		_, err := conn.Exec(ctx, `INSERT INTO news (
+			"id",
+			"title",
+			"url",
+			"description",
+			"source",
+			"published_date",
+			"crawl_date",
+			"tickers",
+			"tags",
+			"run_id"
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+		) ON CONFLICT ON CONSTRAINT news_pkey
+		DO UPDATE SET
+			title = EXCLUDED.title,
+			url = EXCLUDED.url,
+			description = EXCLUDED.description,
+			source = EXCLUDED.source,
+			published_date = EXCLUDED.published_date,
+			crawl_date = EXCLUDED.crawl_date,
+			tickers = EXCLUDED.tickers,
+			tags = EXCLUDED.tags,
+			run_id = EXCLUDED.run_id;`,
+			article.ID, article.Title, article.Url, article.Description, article.Source,
+			article.PublishedDate, article.CrawlDate, article.Tickers, article.Tags, common.RunID)
+		if err != nil {
+			numErrors++
+			log.Error().Err(err).Int64("ID", article.ID).Msg("error saving news article to database")
+		}
+	}
+
+	if numErrors > 0 {
+		return fmt.Errorf("%d news articles could not be saved", numErrors)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+)
+
+// Options configures where and how quotes are saved and read. The import
+// command fills it from the database.*, parquet.* and s3.* settings.
+type Options struct {
+	// DatabaseURL is the database quotes are saved to; Targets are
+	// additional databases that SaveToDatabase writes to in parallel
+	DatabaseURL string
+	Targets     []string
+
+	// ReadURL (e.g. a replica) is preferred over DatabaseURL for reads
+	ReadURL string
+
+	// quotes are saved in batches of BatchSize by up to Writers concurrent
+	// connections; with Atomic set the whole save is a single transaction
+	Writers   int
+	BatchSize int
+	Atomic    bool
+
+	// Timescale stores daily quotes in a hypertable with chunks of
+	// TimescaleChunkInterval
+	Timescale              bool
+	TimescaleChunkInterval time.Duration
+
+	// TrackCorrections records overwritten daily quotes in eod_history;
+	// TrackRevisions records changes to close or volume larger than
+	// RevisionThreshold in eod_revisions
+	TrackCorrections  bool
+	TrackRevisions    bool
+	RevisionThreshold float64
+
+	// Frequency selects the eod table stored quotes are read from
+	Frequency string
+
+	// Adjust recomputes the adjustment factors of the stored history of
+	// assets with a newly saved split or dividend or whose saved quotes end
+	// before the stored history
+	Adjust tiingo.AdjustOptions
+
+	Parquet ParquetOptions
+
+	// S3 is used for output files with an s3:// URI
+	S3 common.S3Options
+}
+
+// ParquetOptions configure how parquet files are written
+type ParquetOptions struct {
+	// Compression is one of the ParquetCompressions; empty uses gzip
+	Compression string
+
+	// RowGroupSize and PageSize in bytes; zero uses 128 MB row groups and
+	// 8 kB pages
+	RowGroupSize int64
+	PageSize     int64
+
+	// Merge merges quotes into an existing file instead of replacing it;
+	// adjustment factors of the merged series are recomputed with Adjust
+	Merge  bool
+	Adjust tiingo.AdjustOptions
+}
+
+// ReadDSN returns the connection string used for reading: ReadURL when it is
+// set, otherwise DatabaseURL
+func (opts *Options) ReadDSN() string {
+	if opts.ReadURL != "" {
+		return opts.ReadURL
+	}
+	return opts.DatabaseURL
+}
@@ -0,0 +1,409 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// ParquetSchemaVersion is stored in the footer of every parquet file; it is
+// incremented whenever a column is added, removed or changes type so
+// downstream readers can detect format changes
+const ParquetSchemaVersion = "2"
+
+// floatParquetSchemaVersion is the last schema version that stored prices as
+// FLOAT and volumes as FLOAT; those files cannot be read into tiingo.Eod
+const floatParquetSchemaVersion = "1"
+
+// Footer metadata keys
+const (
+	MetadataSchemaVersion = "import_tiingo.schema_version"
+	MetadataRecordType    = "import_tiingo.record_type"
+	MetadataSource        = "import_tiingo.source"
+	MetadataVersion       = "import_tiingo.version"
+	MetadataRunID         = "import_tiingo.run_id"
+	MetadataCreated       = "import_tiingo.created"
+)
+
+// Parquet compression codecs
+var ParquetCompressions = map[string]parquet.CompressionCodec{
+	"none":   parquet.CompressionCodec_UNCOMPRESSED,
+	"snappy": parquet.CompressionCodec_SNAPPY,
+	"gzip":   parquet.CompressionCodec_GZIP,
+	"zstd":   parquet.CompressionCodec_ZSTD,
+}
+
+// ParquetCompression returns the codec named by name, one of the
+// ParquetCompressions; an empty name selects gzip
+func ParquetCompression(name string) (parquet.CompressionCodec, error) {
+	name = strings.ToLower(name)
+	if name == "" {
+		return parquet.CompressionCodec_GZIP, nil
+	}
+	codec, ok := ParquetCompressions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown parquet compression '%s'; use none, snappy, gzip or zstd", name)
+	}
+	return codec, nil
+}
+
+// parquetRow is implemented by the row types that store the records of
+// another package in parquet; recordType is the name of that record type
+type parquetRow interface {
+	recordType() string
+}
+
+// parquetEod is the parquet layout of a tiingo.Eod; the date is stored as the
+// tiingo timestamp string of the trading day
+type parquetEod struct {
+	Date                 string   `parquet:"name=date, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, omitstats=false"`
+	Ticker               string   `parquet:"name=ticker, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY, omitstats=false"`
+	CompositeFigi        string   `parquet:"name=compositeFigi, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Exchange             string   `parquet:"name=exchange, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Currency             string   `parquet:"name=currency, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Open                 float64  `parquet:"name=open, type=DOUBLE"`
+	High                 float64  `parquet:"name=high, type=DOUBLE"`
+	Low                  float64  `parquet:"name=low, type=DOUBLE"`
+	Close                float64  `parquet:"name=close, type=DOUBLE"`
+	Volume               int64    `parquet:"name=volume, type=INT64"`
+	Dividend             float64  `parquet:"name=dividend, type=DOUBLE"`
+	Split                float64  `parquet:"name=split, type=DOUBLE"`
+	Frequency            string   `parquet:"name=frequency, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	SplitAdjustFactor    float64  `parquet:"name=split_adjust_factor, type=DOUBLE"`
+	DividendAdjustFactor float64  `parquet:"name=dividend_adjust_factor, type=DOUBLE"`
+	AdjustedVolume       *int64   `parquet:"name=adjusted_volume, type=INT64, repetitiontype=OPTIONAL"`
+	AdjOpen              *float64 `parquet:"name=adj_open, type=DOUBLE, repetitiontype=OPTIONAL"`
+	AdjHigh              *float64 `parquet:"name=adj_high, type=DOUBLE, repetitiontype=OPTIONAL"`
+	AdjLow               *float64 `parquet:"name=adj_low, type=DOUBLE, repetitiontype=OPTIONAL"`
+	AdjClose             *float64 `parquet:"name=adj_close, type=DOUBLE, repetitiontype=OPTIONAL"`
+	RunID                string   `parquet:"name=run_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+func (parquetEod) recordType() string {
+	return "Eod"
+}
+
+func newParquetEod(q *tiingo.Eod) *parquetEod {
+	return &parquetEod{
+		Date:                 q.Date.Format("2006-01-02") + "T00:00:00.000Z",
+		Ticker:               q.Ticker,
+		CompositeFigi:        q.CompositeFigi,
+		Exchange:             q.Exchange,
+		Currency:             q.Currency,
+		Open:                 q.Open,
+		High:                 q.High,
+		Low:                  q.Low,
+		Close:                q.Close,
+		Volume:               q.Volume,
+		Dividend:             q.Dividend,
+		Split:                q.Split,
+		Frequency:            q.Frequency,
+		SplitAdjustFactor:    q.SplitAdjustFactor,
+		DividendAdjustFactor: q.DividendAdjustFactor,
+		AdjustedVolume:       q.AdjustedVolume,
+		AdjOpen:              q.AdjOpen,
+		AdjHigh:              q.AdjHigh,
+		AdjLow:               q.AdjLow,
+		AdjClose:             q.AdjClose,
+		RunID:                q.RunID,
+	}
+}
+
+// eod converts the row back to a quote dated at the close of its exchange
+func (r *parquetEod) eod() *tiingo.Eod {
+	q := &tiingo.Eod{
+		Ticker:               r.Ticker,
+		CompositeFigi:        r.CompositeFigi,
+		Exchange:             r.Exchange,
+		Currency:             r.Currency,
+		Open:                 r.Open,
+		High:                 r.High,
+		Low:                  r.Low,
+		Close:                r.Close,
+		Volume:               r.Volume,
+		Dividend:             r.Dividend,
+		Split:                r.Split,
+		Frequency:            r.Frequency,
+		SplitAdjustFactor:    r.SplitAdjustFactor,
+		DividendAdjustFactor: r.DividendAdjustFactor,
+		AdjustedVolume:       r.AdjustedVolume,
+		AdjOpen:              r.AdjOpen,
+		AdjHigh:              r.AdjHigh,
+		AdjLow:               r.AdjLow,
+		AdjClose:             r.AdjClose,
+		RunID:                r.RunID,
+	}
+	if date, err := time.Parse(time.RFC3339, r.Date); err == nil {
+		q.Date = common.CalendarFor(r.Exchange).CloseTime(date)
+	}
+	return q
+}
+
+// parquetMetadata returns the footer key-value metadata describing a file
+// of T records
+func parquetMetadata[T any]() []*parquet.KeyValue {
+	recordType := reflect.TypeOf((*T)(nil)).Elem().Name()
+	if r, ok := any(new(T)).(parquetRow); ok {
+		recordType = r.recordType()
+	}
+
+	values := [][2]string{
+		{MetadataSchemaVersion, ParquetSchemaVersion},
+		{MetadataRecordType, recordType},
+		{MetadataSource, "api.tiingo.com"},
+		{MetadataVersion, common.CurrentVersion.String()},
+		{MetadataRunID, common.RunID},
+		{MetadataCreated, time.Now().UTC().Format(time.RFC3339)},
+	}
+	metadata := make([]*parquet.KeyValue, len(values))
+	for idx, kv := range values {
+		value := kv[1]
+		metadata[idx] = &parquet.KeyValue{Key: kv[0], Value: &value}
+	}
+	return metadata
+}
+
+// writeParquet writes records to a parquet file using the parquet tags of T
+// as the schema. fn is a local path or an s3:// URI; the file only becomes
+// visible once it has been completely written and is discarded if ctx is
+// cancelled.
+//
+// The codec, row group size and page size are taken from opts.Parquet; the
+// schema version and source of the data are stored in the footer metadata.
+func writeParquet[T any](ctx context.Context, records []*T, fn string, opts *Options) error {
+	compression, err := ParquetCompression(opts.Parquet.Compression)
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("cannot create parquet file")
+		return err
+	}
+
+	target, err := common.OpenOutput(ctx, fn, opts.S3)
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("cannot create parquet file")
+		return err
+	}
+
+	pw, err := writer.NewParquetWriter(target.File, new(T), 4)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Parquet write failed")
+		target.Abort()
+		return err
+	}
+
+	pw.RowGroupSize = 128 * 1024 * 1024 // 128M
+	pw.PageSize = 8 * 1024              // 8k
+	if size := opts.Parquet.RowGroupSize; size > 0 {
+		pw.RowGroupSize = size
+	}
+	if size := opts.Parquet.PageSize; size > 0 {
+		pw.PageSize = size
+	}
+	pw.CompressionType = compression
+	pw.Footer.KeyValueMetadata = parquetMetadata[T]()
+
+	numErrors := 0
+	for _, r := range records {
+		if ctx.Err() != nil {
+			log.Warn().Err(ctx.Err()).Str("FileName", fn).Msg("parquet write cancelled")
+			pw.WriteStop()
+			target.Abort()
+			return ctx.Err()
+		}
+		if err = pw.Write(r); err != nil {
+			numErrors++
+			log.Error().
+				Err(err).
+				Interface("Record", r).
+				Msg("Parquet write failed for record")
+		}
+	}
+
+	if err = pw.WriteStop(); err != nil {
+		log.Error().Err(err).Msg("Parquet write failed")
+		target.Abort()
+		return err
+	}
+
+	if err = target.Commit(); err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not save parquet file")
+		return err
+	}
+
+	log.Info().Int("NumRecords", len(records)-numErrors).Int("NumErrors", numErrors).Str("FileName", fn).Msg("Parquet write finished")
+	return nil
+}
+
+// readParquet reads every record of the parquet file fn, a local path or
+// s3:// URI, using the parquet tags of T as the schema
+func readParquet[T any](ctx context.Context, fn string, opts *Options) ([]*T, error) {
+	fh, err := common.OpenInput(ctx, fn, opts.S3)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	pr, err := reader.NewParquetReader(fh, new(T), 4)
+	if err != nil {
+		return nil, fmt.Errorf("could not read parquet file %s: %w", fn, err)
+	}
+	defer pr.ReadStop()
+
+	values := make([]T, pr.GetNumRows())
+	if err := pr.Read(&values); err != nil {
+		return nil, fmt.Errorf("could not read parquet file %s: %w", fn, err)
+	}
+
+	records := make([]*T, len(values))
+	for idx := range values {
+		records[idx] = &values[idx]
+	}
+	return records, nil
+}
+
+// ReadParquetMetadata returns the footer key-value metadata and number of
+// rows of the parquet file fn
+func ReadParquetMetadata(ctx context.Context, fn string, opts *Options) (map[string]string, int64, error) {
+	fh, err := common.OpenInput(ctx, fn, opts.S3)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer fh.Close()
+
+	pr, err := reader.NewParquetReader(fh, nil, 1)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not read parquet file %s: %w", fn, err)
+	}
+	defer pr.ReadStop()
+
+	metadata := make(map[string]string, len(pr.Footer.KeyValueMetadata))
+	for _, kv := range pr.Footer.KeyValueMetadata {
+		if kv.Value != nil {
+			metadata[kv.Key] = *kv.Value
+		}
+	}
+	return metadata, pr.GetNumRows(), nil
+}
+
+// SaveToParquet saves EOD quotes to a parquet file. The file is written to a
+// temporary location and renamed on success.
+//
+// With opts.Parquet.Merge enabled the quotes already stored in fn are kept and
+// merged with records; quotes of the same ticker and date are replaced by
+// the newly downloaded quote and adjustment factors are recomputed over the
+// merged history.
+//
+// Records are sorted by ticker and date before writing so that the min/max
+// statistics and column indexes written for each page and row group are
+// tightly clustered, allowing query engines to prune on ticker and date.
+func SaveToParquet(ctx context.Context, records []*tiingo.Eod, fn string, opts *Options) error {
+	if opts.Parquet.Merge {
+		existing, err := ReadParquet(ctx, fn, opts)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			log.Debug().Str("FileName", fn).Msg("parquet file does not exist yet ... nothing to merge")
+		case err != nil:
+			log.Error().Err(err).Str("FileName", fn).Msg("could not read existing parquet file to merge")
+			return err
+		default:
+			// factors are recomputed over the merged history; work on copies
+			// so the caller's quotes are not modified
+			updated := make([]*tiingo.Eod, len(records))
+			for idx, q := range records {
+				copy := *q
+				updated[idx] = &copy
+			}
+			merged := MergeQuotes(existing, updated)
+			tiingo.ComputeAdjustmentFactors(merged, opts.Parquet.Adjust)
+			log.Info().Str("FileName", fn).Int("NumExisting", len(existing)).Int("NumNew", len(records)).Int("NumMerged", len(merged)).Msg("merged quotes with existing parquet file")
+			records = merged
+		}
+	}
+
+	sorted := make([]*tiingo.Eod, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Ticker != sorted[j].Ticker {
+			return sorted[i].Ticker < sorted[j].Ticker
+		}
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	rows := make([]*parquetEod, len(sorted))
+	for idx, q := range sorted {
+		rows[idx] = newParquetEod(q)
+	}
+	return writeParquet(ctx, rows, fn, opts)
+}
+
+// ReadParquet reads the EOD quotes saved by SaveToParquet from fn, a local
+// path or s3:// URI
+func ReadParquet(ctx context.Context, fn string, opts *Options) ([]*tiingo.Eod, error) {
+	metadata, _, err := ReadParquetMetadata(ctx, fn, opts)
+	if err != nil {
+		return nil, err
+	}
+	if version := metadata[MetadataSchemaVersion]; version == floatParquetSchemaVersion {
+		return nil, fmt.Errorf("parquet file %s uses schema version %s with float32 prices; re-import it to upgrade to version %s", fn, version, ParquetSchemaVersion)
+	}
+
+	rows, err := readParquet[parquetEod](ctx, fn, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := make([]*tiingo.Eod, len(rows))
+	for idx, r := range rows {
+		quotes[idx] = r.eod()
+	}
+	return quotes, nil
+}
+
+// MergeQuotes combines existing and updated quotes; when both contain a quote
+// for the same ticker and date the updated quote wins
+func MergeQuotes(existing, updated []*tiingo.Eod) []*tiingo.Eod {
+	key := func(q *tiingo.Eod) string {
+		return q.Ticker + "|" + q.Date.Format("2006-01-02")
+	}
+
+	replaced := make(map[string]bool, len(updated))
+	for _, q := range updated {
+		replaced[key(q)] = true
+	}
+
+	merged := make([]*tiingo.Eod, 0, len(existing)+len(updated))
+	for _, q := range existing {
+		if !replaced[key(q)] {
+			merged = append(merged, q)
+		}
+	}
+	return append(merged, updated...)
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// Parquet partitioning schemes
+const (
+	PartitionNone  = ""
+	PartitionYear  = "year"
+	PartitionMonth = "month"
+	PartitionDay   = "day"
+)
+
+// Partitions are the supported values of parquet.partition
+var Partitions = []string{PartitionYear, PartitionMonth, PartitionDay}
+
+// partitionFileName is the name of the file written to each partition
+const partitionFileName = "part-0.parquet"
+
+// ValidPartition returns true if partition is a supported partitioning
+// scheme; PartitionNone writes a single file
+func ValidPartition(partition string) bool {
+	if partition == PartitionNone {
+		return true
+	}
+	for _, p := range Partitions {
+		if p == partition {
+			return true
+		}
+	}
+	return false
+}
+
+// PartitionFile is a file written by SaveToParquetPartitioned
+type PartitionFile struct {
+	// FileName is the full path or s3:// URI of the file
+	FileName string
+
+	// Partition is the Hive-style directory of the file relative to the
+	// output directory, e.g. event_date=2023-01-02
+	Partition string
+
+	NumRecords int
+}
+
+// partitionDir returns the Hive-style directory of quote, e.g. year=2023,
+// year=2023/month=01 or event_date=2023-01-02
+func partitionDir(quote *tiingo.Eod, partition string) string {
+	date := quote.Date.Format("2006-01-02")
+
+	switch partition {
+	case PartitionYear:
+		return "year=" + date[:4]
+	case PartitionMonth:
+		return fmt.Sprintf("year=%s/month=%s", date[:4], date[5:7])
+	default:
+		return "event_date=" + date
+	}
+}
+
+// SaveToParquetPartitioned saves EOD quotes as a Hive-style partitioned
+// dataset under dir (a local directory or s3:// prefix) so query engines
+// such as Spark and DuckDB can prune on the partition columns. Each partition
+// holds a single part-0.parquet file; partitions that received no quotes in
+// this run are left untouched.
+func SaveToParquetPartitioned(ctx context.Context, records []*tiingo.Eod, dir, partition string, opts *Options) ([]*PartitionFile, error) {
+	if !ValidPartition(partition) || partition == PartitionNone {
+		return nil, fmt.Errorf("unknown parquet partition '%s'; use one of %s", partition, strings.Join(Partitions, ", "))
+	}
+
+	groups := make(map[string][]*tiingo.Eod)
+	for _, quote := range records {
+		key := partitionDir(quote, partition)
+		groups[key] = append(groups[key], quote)
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	files := make([]*PartitionFile, 0, len(keys))
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return files, ctx.Err()
+		}
+
+		var fn string
+		if common.IsS3URI(dir) {
+			fn = strings.TrimSuffix(dir, "/") + "/" + path.Join(key, partitionFileName)
+		} else {
+			fn = filepath.Join(dir, filepath.FromSlash(key), partitionFileName)
+			if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+				log.Error().Err(err).Str("Directory", filepath.Dir(fn)).Msg("could not create partition directory")
+				return files, err
+			}
+		}
+
+		if err := SaveToParquet(ctx, groups[key], fn, opts); err != nil {
+			return files, err
+		}
+		files = append(files, &PartitionFile{FileName: fn, Partition: key, NumRecords: len(groups[key])})
+	}
+
+	log.Info().Int("NumPartitions", len(files)).Str("Directory", dir).Msg("partitioned parquet write finished")
+	return files, nil
+}
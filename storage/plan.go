@@ -0,0 +1,82 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// SavePlan summarizes the effect saving quotes to a database would have
+type SavePlan struct {
+	Target    string
+	NumInsert int
+	NumUpdate int
+}
+
+// PlanEodSave determines how many of quotes would be inserted as new rows
+// and how many would update existing rows of the eod table in the database
+// identified by url. Nothing is written.
+func PlanEodSave(ctx context.Context, quotes []*tiingo.Eod, url string) (*SavePlan, error) {
+	plan := &SavePlan{Target: common.RedactDSN(url)}
+
+	type key struct {
+		figi string
+		date string
+	}
+	unique := make(map[key]bool, len(quotes))
+	figis := make([]string, 0, len(quotes))
+	dates := make([]time.Time, 0, len(quotes))
+	for _, quote := range quotes {
+		k := key{quote.CompositeFigi, quote.Date.Format("2006-01-02")}
+		if unique[k] {
+			continue
+		}
+		unique[k] = true
+		figis = append(figis, quote.CompositeFigi)
+		dates = append(dates, quote.Date)
+	}
+
+	if common.IsSQLiteDSN(url) || common.IsClickHouseDSN(url) {
+		// sqlite targets are keyed on ticker and clickhouse appends new row
+		// versions; treat every quote as an insert
+		plan.NumInsert = len(unique)
+		return plan, nil
+	}
+
+	conn, err := pgx.Connect(ctx, url)
+	if err != nil {
+		log.Error().Err(err).Str("Target", plan.Target).Msg("Could not connect to database")
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	err = conn.QueryRow(ctx, `SELECT count(*) FROM eod e
+		JOIN (SELECT unnest($1::text[]) AS composite_figi, unnest($2::date[]) AS event_date) q
+		ON e.composite_figi = q.composite_figi AND e.event_date = q.event_date`, figis, dates).Scan(&plan.NumUpdate)
+	if err != nil {
+		log.Error().Err(err).Str("Target", plan.Target).Msg("could not count existing quotes")
+		return nil, err
+	}
+
+	plan.NumInsert = len(unique) - plan.NumUpdate
+	return plan, nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	PruneDelete     = "delete"
+	PruneArchive    = "archive"
+	PruneDeactivate = "deactivate"
+)
+
+// OrphanedTicker is a ticker with eod rows that is no longer part of the
+// active asset universe
+type OrphanedTicker struct {
+	Ticker        string `db:"ticker"`
+	CompositeFigi string `db:"composite_figi"`
+	NumRows       int64  `db:"num_rows"`
+}
+
+// FindOrphanedTickers returns tickers downloaded from tiingo that have eod rows
+// but are no longer active in the assets table. When softDeleted is true rows
+// that were already deactivated are ignored.
+func FindOrphanedTickers(ctx context.Context, dsn string, softDeleted bool) ([]*OrphanedTicker, error) {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("could not connect to database")
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	activeFilter := ""
+	if softDeleted {
+		activeFilter = "AND eod.active = 't'"
+	}
+
+	var orphans []*OrphanedTicker
+	err = pgxscan.Select(ctx, conn, &orphans, fmt.Sprintf(`SELECT ticker, composite_figi, count(*) AS num_rows
+	FROM eod
+	WHERE source = 'api.tiingo.com' %s AND NOT EXISTS (
+		SELECT 1 FROM assets WHERE assets.active='t' AND assets.composite_figi = eod.composite_figi
+	)
+	GROUP BY ticker, composite_figi
+	ORDER BY ticker`, activeFilter))
+	if err != nil {
+		log.Error().Err(err).Msg("could not query orphaned tickers")
+		return nil, err
+	}
+
+	return orphans, nil
+}
+
+// PruneOrphanedTickers removes eod rows for the given tickers according to
+// policy. The archive policy copies rows into eod_archive before deleting them;
+// the deactivate policy keeps the rows and marks them inactive so historical
+// analysis keyed on FIGI still resolves.
+func PruneOrphanedTickers(ctx context.Context, dsn string, orphans []*OrphanedTicker, policy string) error {
+	if policy != PruneDelete && policy != PruneArchive && policy != PruneDeactivate {
+		return fmt.Errorf("unknown prune policy '%s'", policy)
+	}
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	figis := make([]string, len(orphans))
+	for idx, orphan := range orphans {
+		figis[idx] = orphan.CompositeFigi
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if policy == PruneArchive {
+		if _, err := tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS eod_archive (LIKE eod INCLUDING ALL)`); err != nil {
+			log.Error().Err(err).Msg("could not create eod_archive table")
+			return err
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO eod_archive SELECT * FROM eod WHERE source = 'api.tiingo.com' AND composite_figi = any($1) ON CONFLICT DO NOTHING`, figis); err != nil {
+			log.Error().Err(err).Msg("could not archive eod rows")
+			return err
+		}
+	}
+
+	var tag pgconn.CommandTag
+	if policy == PruneDeactivate {
+		tag, err = tx.Exec(ctx, `UPDATE eod SET active = 'f', deactivated_at = now() WHERE source = 'api.tiingo.com' AND active = 't' AND composite_figi = any($1)`, figis)
+		if err != nil {
+			log.Error().Err(err).Msg("could not deactivate eod rows")
+			return err
+		}
+	} else {
+		tag, err = tx.Exec(ctx, `DELETE FROM eod WHERE source = 'api.tiingo.com' AND composite_figi = any($1)`, figis)
+		if err != nil {
+			log.Error().Err(err).Msg("could not delete eod rows")
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("could not commit prune transaction")
+		return err
+	}
+
+	log.Info().Str("Policy", policy).Int("NumTickers", len(orphans)).Int64("NumRows", tag.RowsAffected()).Msg("pruned tickers no longer in universe")
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/validate"
+	"github.com/rs/zerolog/log"
+)
+
+// SaveAssetQualityToDatabase upserts the quality score of each asset into
+// the asset_quality table, replacing the score of a previous run
+func SaveAssetQualityToDatabase(ctx context.Context, dsn string, scores []*validate.Quality) error {
+	log.Info().Msg("saving asset quality to database")
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	numErrors := 0
+	for _, q := range scores {
+		if q.CompositeFigi == "" {
+			continue
+		}
+		_, err := conn.Exec(ctx, `INSERT INTO asset_quality (
+			"composite_figi",
+			"ticker",
+			"start_date",
+			"end_date",
+			"trading_days",
+			"num_quotes",
+			"coverage",
+			"stale_closes",
+			"zero_volume_days",
+			"validation_failures",
+			"score",
+			"computed_at",
+			"run_id"
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, now(), $12
+		) ON CONFLICT ON CONSTRAINT asset_quality_pkey
+		DO UPDATE SET
+			ticker = EXCLUDED.ticker,
+			start_date = EXCLUDED.start_date,
+			end_date = EXCLUDED.end_date,
+			trading_days = EXCLUDED.trading_days,
+			num_quotes = EXCLUDED.num_quotes,
+			coverage = EXCLUDED.coverage,
+			stale_closes = EXCLUDED.stale_closes,
+			zero_volume_days = EXCLUDED.zero_volume_days,
+			validation_failures = EXCLUDED.validation_failures,
+			score = EXCLUDED.score,
+			computed_at = EXCLUDED.computed_at,
+			run_id = EXCLUDED.run_id;`,
+			q.CompositeFigi, q.Ticker, q.StartDate, q.EndDate, q.TradingDays, q.NumQuotes, q.Coverage,
+			q.StaleCloses, q.ZeroVolumeDays, q.ValidationFailures, q.Score, common.RunID)
+		if err != nil {
+			numErrors++
+			log.Error().Err(err).Str("Ticker", q.Ticker).Msg("error saving asset quality to database")
+		}
+	}
+
+	if numErrors > 0 {
+		return fmt.Errorf("%d asset quality scores could not be saved", numErrors)
+	}
+	return nil
+}
+
+// WorstAssetQuality returns the limit assets with the lowest quality score,
+// only including assets scoring below maxScore
+func WorstAssetQuality(ctx context.Context, dsn string, limit int, maxScore float64) ([]*validate.Quality, error) {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	var scores []*validate.Quality
+	err = pgxscan.Select(ctx, conn, &scores, `SELECT ticker, composite_figi, start_date, end_date, trading_days,
+		num_quotes, coverage, stale_closes, zero_volume_days, validation_failures, score
+	FROM asset_quality
+	WHERE score < $1
+	ORDER BY score, ticker
+	LIMIT $2`, maxScore, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("could not query asset quality")
+		return nil, err
+	}
+
+	return scores, nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultRedisPrefix is the default prefix of the per-asset latest quote keys
+const DefaultRedisPrefix = "eod:latest:"
+
+// setLatestScript replaces the latest quote hash unless the stored quote is
+// more recent; dates are ISO formatted so they compare as strings
+var setLatestScript = redis.NewScript(`
+local stored = redis.call('HGET', KEYS[1], 'date')
+if stored and stored > ARGV[2] then
+	return 0
+end
+redis.call('HSET', KEYS[1], unpack(ARGV))
+return 1
+`)
+
+// SaveLatestToRedis writes the most recent quote of each asset to a redis hash
+// named prefix + composite FIGI with the fields ticker, date, close,
+// adj_close, volume and run_id. Hashes holding a more recent quote (e.g. when
+// backfilling) are left unchanged. Quotes without a composite FIGI are skipped.
+// The number of assets sent to redis is returned.
+func SaveLatestToRedis(ctx context.Context, quotes []*tiingo.Eod, url, prefix string) (int, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		log.Error().Err(err).Msg("invalid redis url")
+		return 0, err
+	}
+	client := redis.NewClient(opts)
+	defer client.Close()
+
+	if err := setLatestScript.Load(ctx, client).Err(); err != nil {
+		log.Error().Err(err).Msg("could not connect to redis")
+		return 0, err
+	}
+
+	pipe := client.Pipeline()
+	numAssets := 0
+	for _, assetQuotes := range tiingo.GroupByAsset(quotes) {
+		latest := assetQuotes[len(assetQuotes)-1]
+		if latest.CompositeFigi == "" {
+			log.Debug().Str("Ticker", latest.Ticker).Msg("asset has no composite figi ... skipping redis update")
+			continue
+		}
+
+		adjClose := latest.Close * latest.SplitAdjustFactor * latest.DividendAdjustFactor
+		if latest.AdjClose != nil {
+			adjClose = *latest.AdjClose
+		}
+
+		// date must remain the second argument; the script compares it
+		setLatestScript.EvalSha(ctx, pipe, []string{prefix + latest.CompositeFigi},
+			"date", latest.Date.Format("2006-01-02"),
+			"ticker", latest.Ticker,
+			"close", formatFloat(latest.Close),
+			"adj_close", formatFloat(adjClose),
+			"volume", formatInt(latest.Volume),
+			"run_id", latest.RunID,
+		)
+		numAssets++
+	}
+
+	cmds, err := pipe.Exec(ctx)
+	if err != nil {
+		numFailed := 0
+		for _, cmd := range cmds {
+			if cmd.Err() != nil {
+				numFailed++
+			}
+		}
+		log.Error().Err(err).Int("NumFailed", numFailed).Msg("could not save latest quotes to redis")
+		return numAssets - numFailed, fmt.Errorf("%d latest quotes could not be saved to redis: %w", numFailed, err)
+	}
+
+	log.Info().Int("NumAssets", numAssets).Msg("saved latest quotes to redis")
+	return numAssets, nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// eodSchemaSQL creates an eod table in the embedded (DuckDB and sqlite)
+// databases
+const eodSchemaSQL = `CREATE TABLE IF NOT EXISTS %s (
+	ticker VARCHAR NOT NULL,
+	composite_figi VARCHAR,
+	currency VARCHAR,
+	event_date DATE NOT NULL,
+	open DOUBLE,
+	high DOUBLE,
+	low DOUBLE,
+	close DOUBLE,
+	volume BIGINT,
+	dividend DOUBLE,
+	split_factor DOUBLE,
+	split_adjust_factor DOUBLE,
+	dividend_adjust_factor DOUBLE,
+	adjusted_volume BIGINT,
+	adj_open DOUBLE,
+	adj_high DOUBLE,
+	adj_low DOUBLE,
+	adj_close DOUBLE,
+	source VARCHAR,
+	run_id VARCHAR,
+	PRIMARY KEY (ticker, event_date)
+)`
+
+// eodUpsertSQL returns an INSERT statement into table with ? placeholders that
+// updates existing quotes keyed on (ticker, event_date)
+func eodUpsertSQL(table string) string {
+	placeholders := make([]string, len(eodColumns))
+	updates := make([]string, 0, len(eodColumns))
+	for idx, column := range eodColumns {
+		placeholders[idx] = "?"
+		if column != "ticker" && column != "event_date" {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+		}
+	}
+
+	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)
+	ON CONFLICT (ticker, event_date)
+	DO UPDATE SET %s`, table, strings.Join(eodColumns, ", "), strings.Join(placeholders, ", "), strings.Join(updates, ", "))
+}
+
+// upsertEodSQL writes quotes to the eod table of their frequency in db in a
+// single transaction, creating the table if needed. The adjustment factors
+// of assets with a new split or dividend are recomputed over their stored
+// history.
+func upsertEodSQL(ctx context.Context, db *sql.DB, quotes []*tiingo.Eod, adjust tiingo.AdjustOptions) error {
+	table := quotesTable(quotes)
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(eodSchemaSQL, table)); err != nil {
+		log.Error().Err(err).Str("Table", table).Msg("could not create eod table")
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("could not begin transaction")
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, eodUpsertSQL(table))
+	if err != nil {
+		log.Error().Err(err).Msg("could not prepare eod upsert")
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, quote := range quotes {
+		row := eodRow(quote)
+		// drivers expect a date rather than a timestamp in the exchange timezone
+		row[3] = quote.Date.Format("2006-01-02")
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			log.Error().Err(err).Str("Ticker", quote.Ticker).Str("Date", row[3].(string)).Msg("could not save quote")
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := recomputeStoredFactorsSQL(ctx, tx, table, quotes, adjust); err != nil {
+		log.Error().Err(err).Msg("could not recompute adjustment factors of stored history")
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("could not commit eod upsert")
+		return err
+	}
+
+	return nil
+}
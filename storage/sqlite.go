@@ -0,0 +1,45 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// saveToSQLite upserts EOD quotes into the eod table of the sqlite database
+// selected by dsn, creating the table if needed. Quotes are keyed on
+// (ticker, event_date) with the same update semantics as postgres.
+func saveToSQLite(ctx context.Context, quotes []*tiingo.Eod, dsn string, adjust tiingo.AdjustOptions) error {
+	log.Info().Str("Target", dsn).Int("NumQuotes", len(quotes)).Msg("saving to sqlite database")
+
+	db, err := common.OpenSQLite(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Str("Target", dsn).Msg("could not open sqlite database")
+		return err
+	}
+	defer db.Close()
+
+	if err := upsertEodSQL(ctx, db, quotes, adjust); err != nil {
+		return err
+	}
+
+	log.Info().Str("Target", dsn).Int("NumSaved", len(quotes)).Msg("finished saving to database")
+	return nil
+}
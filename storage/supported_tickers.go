@@ -0,0 +1,121 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// SaveSupportedTickersToDatabase upserts supported tickers into the assets
+// table. Existing assets are matched on ticker; when tiingo lists a ticker more
+// than once (e.g. a delisted and a current listing) the most recently priced
+// listing is used. New assets are inserted without a composite FIGI.
+func SaveSupportedTickersToDatabase(ctx context.Context, dsn string, tickers []*tiingo.SupportedTicker) error {
+	log.Info().Msg("saving supported tickers to database")
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `CREATE TEMP TABLE supported_tickers_staging (
+		ticker text,
+		exchange text,
+		asset_type text,
+		currency text,
+		listing_date date,
+		delisting_date date,
+		active boolean
+	) ON COMMIT DROP`); err != nil {
+		log.Error().Err(err).Msg("could not create staging table")
+		return err
+	}
+
+	rows := make([][]interface{}, len(tickers))
+	for idx, ticker := range tickers {
+		var delistingDate *time.Time
+		if !ticker.IsActive() {
+			delistingDate = ticker.EndDate
+		}
+		rows[idx] = []interface{}{
+			ticker.Ticker, ticker.Exchange, string(ticker.AssetType), ticker.PriceCurrency,
+			ticker.StartDate, delistingDate, ticker.IsActive(),
+		}
+	}
+
+	columns := []string{"ticker", "exchange", "asset_type", "currency", "listing_date", "delisting_date", "active"}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"supported_tickers_staging"}, columns, pgx.CopyFromRows(rows)); err != nil {
+		log.Error().Err(err).Msg("could not copy supported tickers")
+		return err
+	}
+
+	// keep the most recently priced listing of each ticker
+	if _, err := tx.Exec(ctx, `DELETE FROM supported_tickers_staging a USING supported_tickers_staging b
+		WHERE a.ticker = b.ticker AND (
+			COALESCE(a.delisting_date, 'infinity'::date) < COALESCE(b.delisting_date, 'infinity'::date) OR
+			(COALESCE(a.delisting_date, 'infinity'::date) = COALESCE(b.delisting_date, 'infinity'::date) AND a.ctid < b.ctid)
+		)`); err != nil {
+		log.Error().Err(err).Msg("could not de-duplicate supported tickers")
+		return err
+	}
+
+	updated, err := tx.Exec(ctx, `UPDATE assets SET
+			primary_exchange = s.exchange,
+			asset_type = s.asset_type,
+			currency = s.currency,
+			listing_date = s.listing_date,
+			delisting_date = s.delisting_date,
+			active = s.active,
+			last_updated = extract(epoch from now())::bigint
+		FROM supported_tickers_staging s
+		WHERE assets.ticker = s.ticker`)
+	if err != nil {
+		log.Error().Err(err).Msg("could not update assets")
+		return err
+	}
+
+	inserted, err := tx.Exec(ctx, `INSERT INTO assets (
+			ticker, primary_exchange, asset_type, currency, listing_date, delisting_date, active, source, last_updated
+		) SELECT
+			s.ticker, s.exchange, s.asset_type, s.currency, s.listing_date, s.delisting_date, s.active, 'api.tiingo.com', extract(epoch from now())::bigint
+		FROM supported_tickers_staging s
+		WHERE NOT EXISTS (SELECT 1 FROM assets WHERE assets.ticker = s.ticker)`)
+	if err != nil {
+		log.Error().Err(err).Msg("could not insert assets")
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("could not commit supported tickers")
+		return err
+	}
+
+	log.Info().Int64("NumUpdated", updated.RowsAffected()).Int64("NumInserted", inserted.RowsAffected()).Msg("synced supported tickers")
+	return nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// SaveTickerChanges records changes in the ticker_changes table and renames
+// the assets so subsequent downloads use the new symbol
+func SaveTickerChanges(ctx context.Context, dsn string, changes []*tiingo.TickerChange) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+		return err
+	}
+	defer conn.Close(ctx)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("could not start transaction")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, change := range changes {
+		if _, err := tx.Exec(ctx, `INSERT INTO ticker_changes (
+				composite_figi, old_ticker, new_ticker, change_date, source, run_id
+			) VALUES ($1, $2, $3, $4, 'api.tiingo.com', $5)
+			ON CONFLICT (composite_figi, old_ticker, new_ticker) DO NOTHING`,
+			change.CompositeFigi, change.OldTicker, change.NewTicker, change.ChangeDate, common.RunID); err != nil {
+			log.Error().Err(err).Str("CompositeFigi", change.CompositeFigi).Msg("could not record ticker change")
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE assets SET
+				ticker = $1,
+				last_updated = extract(epoch from now())::bigint
+			WHERE composite_figi = $2 AND ticker = $3`,
+			change.NewTicker, change.CompositeFigi, change.OldTicker); err != nil {
+			log.Error().Err(err).Str("CompositeFigi", change.CompositeFigi).Msg("could not rename asset")
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
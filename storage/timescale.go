@@ -0,0 +1,120 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/penny-vault/import-tiingo/tiingo"
+	"github.com/rs/zerolog/log"
+)
+
+// timescaleSchemaSQL creates the eod table in postgres before it is converted
+// to a hypertable; the primary key includes event_date as required by
+// timescale
+const timescaleSchemaSQL = `CREATE TABLE IF NOT EXISTS eod (
+	ticker TEXT NOT NULL,
+	composite_figi TEXT NOT NULL,
+	currency TEXT,
+	event_date DATE NOT NULL,
+	open DOUBLE PRECISION,
+	high DOUBLE PRECISION,
+	low DOUBLE PRECISION,
+	close DOUBLE PRECISION,
+	volume BIGINT,
+	dividend DOUBLE PRECISION,
+	split_factor DOUBLE PRECISION,
+	split_adjust_factor DOUBLE PRECISION,
+	dividend_adjust_factor DOUBLE PRECISION,
+	adjusted_volume BIGINT,
+	adj_open DOUBLE PRECISION,
+	adj_high DOUBLE PRECISION,
+	adj_low DOUBLE PRECISION,
+	adj_close DOUBLE PRECISION,
+	source TEXT,
+	run_id TEXT,
+	CONSTRAINT eod_pkey PRIMARY KEY (composite_figi, event_date)
+)`
+
+// ensureHypertable creates the eod table as a timescale hypertable
+// partitioned by event_date if it is not one already. An existing plain eod
+// table is migrated in place.
+func ensureHypertable(ctx context.Context, pool *pgxpool.Pool, chunkInterval time.Duration) error {
+	var isHypertable bool
+	err := pool.QueryRow(ctx, `SELECT EXISTS (
+		SELECT 1 FROM pg_extension WHERE extname = 'timescaledb'
+	) AND EXISTS (
+		SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_name = 'eod'
+	)`).Scan(&isHypertable)
+	if err != nil {
+		// timescaledb_information does not exist until the extension is
+		// created; fall through and create it
+		isHypertable = false
+	}
+	if isHypertable {
+		return nil
+	}
+
+	log.Info().Str("ChunkInterval", chunkInterval.String()).Msg("creating eod hypertable")
+	if _, err := pool.Exec(ctx, `CREATE EXTENSION IF NOT EXISTS timescaledb`); err != nil {
+		return err
+	}
+	if _, err := pool.Exec(ctx, timescaleSchemaSQL); err != nil {
+		return err
+	}
+	interval := fmt.Sprintf("%d microseconds", chunkInterval.Microseconds())
+	_, err = pool.Exec(ctx, `SELECT create_hypertable('eod', 'event_date',
+		chunk_time_interval => $1::interval, if_not_exists => TRUE, migrate_data => TRUE)`, interval)
+	return err
+}
+
+// chunkBatches orders quotes by event_date and splits them into batches that
+// each fall within a single hypertable chunk so every COPY touches one chunk.
+// Chunks are aligned to multiples of chunkInterval since the unix epoch, as
+// timescale aligns fixed size chunks. Batches within a chunk hold at most
+// batchSize quotes. The sorted copy of quotes the ranges refer to is
+// returned; the input slice is not modified.
+func chunkBatches(quotes []*tiingo.Eod, chunkInterval time.Duration, batchSize int) ([]*tiingo.Eod, []batchRange) {
+	if chunkInterval <= 0 {
+		return quotes, fixedBatches(len(quotes), batchSize)
+	}
+
+	sorted := make([]*tiingo.Eod, len(quotes))
+	copy(sorted, quotes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	chunkOf := func(q *tiingo.Eod) int64 {
+		day := time.Date(q.Date.Year(), q.Date.Month(), q.Date.Day(), 0, 0, 0, 0, time.UTC)
+		return day.UnixMicro() / chunkInterval.Microseconds()
+	}
+
+	ranges := []batchRange{}
+	start := 0
+	for idx := 1; idx <= len(sorted); idx++ {
+		if idx < len(sorted) && idx-start < batchSize && chunkOf(sorted[idx]) == chunkOf(sorted[start]) {
+			continue
+		}
+		ranges = append(ranges, batchRange{start: start, end: idx})
+		start = idx
+	}
+	return sorted, ranges
+}
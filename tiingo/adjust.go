@@ -0,0 +1,136 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import "math"
+
+// Sources of adjusted prices
+const (
+	AdjustNone   = "none"
+	AdjustTiingo = "tiingo"
+	AdjustLocal  = "local"
+)
+
+// ComputeAdjustmentFactors fills in the derived split and dividend adjustment
+// factors for each quote. The raw Dividend and Split values reported by tiingo
+// are never modified so that adjustments can always be recomputed from the
+// original vendor data.
+//
+// Factors are backward looking and relative to the most recent quote of each
+// asset in the provided set; e.g. after a 2:1 split all prior quotes receive a
+// split adjustment factor of 0.5. Factors of a partial history are therefore
+// only consistent with quotes adjusted in the same call; the database writers
+// recompute them over the stored history when a split or dividend is saved.
+//
+// When adjust.Volume is enabled the split-adjusted volume (raw volume scaled
+// by the chain of subsequent splits) is also computed.
+//
+// adjust.Prices selects where adjusted open, high, low, close and volume come
+// from: "tiingo" keeps the adjOpen/adjHigh/adjLow/adjClose/adjVolume values
+// returned by the API, "local" back-adjusts the raw prices using the computed
+// factors, and "none" omits them.
+func ComputeAdjustmentFactors(quotes []*Eod, adjust AdjustOptions) {
+	for _, assetQuotes := range GroupByAsset(quotes) {
+		computeAssetFactors(assetQuotes, adjust)
+	}
+}
+
+// computeAssetFactors computes the adjustment factors of the date ordered
+// quotes of a single asset and returns the cumulative factors that apply to
+// the session before the first quote
+func computeAssetFactors(assetQuotes []*Eod, adjust AdjustOptions) (float64, float64) {
+	adjustedPrices := adjust.Prices
+	adjustVolume := adjust.Volume || adjustedPrices == AdjustLocal
+
+	splitFactor := 1.0
+	dividendFactor := 1.0
+	for idx := len(assetQuotes) - 1; idx >= 0; idx-- {
+		quote := assetQuotes[idx]
+		quote.SplitAdjustFactor = splitFactor
+		quote.DividendAdjustFactor = dividendFactor
+		if adjustVolume {
+			adjustedVolume := int64(math.Round(float64(quote.Volume) / splitFactor))
+			quote.AdjustedVolume = &adjustedVolume
+		}
+
+		switch adjustedPrices {
+		case AdjustTiingo:
+			if quote.VendorAdjVolume != nil {
+				adjustedVolume := int64(math.Round(*quote.VendorAdjVolume))
+				quote.AdjustedVolume = &adjustedVolume
+			}
+		case AdjustLocal:
+			factor := splitFactor * dividendFactor
+			adjOpen, adjHigh, adjLow, adjClose := quote.Open*factor, quote.High*factor, quote.Low*factor, quote.Close*factor
+			quote.AdjOpen, quote.AdjHigh, quote.AdjLow, quote.AdjClose = &adjOpen, &adjHigh, &adjLow, &adjClose
+		default:
+			quote.AdjOpen, quote.AdjHigh, quote.AdjLow, quote.AdjClose = nil, nil, nil, nil
+		}
+
+		// events on this day affect all prior days
+		if quote.Split != 0 && quote.Split != 1 {
+			splitFactor /= quote.Split
+		}
+
+		if quote.Dividend != 0 {
+			// without the prior session the close before the ex-date
+			// is approximated by the ex-date close plus the dividend
+			prevClose := quote.Close + quote.Dividend
+			if idx > 0 {
+				prevClose = assetQuotes[idx-1].Close
+			}
+			if prevClose > 0 {
+				dividendFactor *= 1 - (quote.Dividend / prevClose)
+			}
+		}
+	}
+	return splitFactor, dividendFactor
+}
+
+// RebaseAdjustmentFactors scales adjustment factors that were computed
+// relative to the last quote of a partial history so they are relative to a
+// later point; splitFactor and dividendFactor are the cumulative factors at
+// the end of the partial history. Locally adjusted prices and volume are
+// rescaled to match.
+func RebaseAdjustmentFactors(quotes []*Eod, splitFactor, dividendFactor float64, adjust AdjustOptions) {
+	localPrices := adjust.Prices == AdjustLocal
+	vendorVolume := adjust.Prices == AdjustTiingo
+	for _, quote := range quotes {
+		quote.SplitAdjustFactor *= splitFactor
+		quote.DividendAdjustFactor *= dividendFactor
+
+		// values reported by tiingo are already relative to the full history
+		if quote.AdjustedVolume != nil && !(vendorVolume && quote.VendorAdjVolume != nil) {
+			adjustedVolume := int64(math.Round(float64(*quote.AdjustedVolume) / splitFactor))
+			quote.AdjustedVolume = &adjustedVolume
+		}
+		if localPrices {
+			factor := splitFactor * dividendFactor
+			quote.AdjOpen = scale(quote.AdjOpen, factor)
+			quote.AdjHigh = scale(quote.AdjHigh, factor)
+			quote.AdjLow = scale(quote.AdjLow, factor)
+			quote.AdjClose = scale(quote.AdjClose, factor)
+		}
+	}
+}
+
+func scale(value *float64, factor float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	scaled := *value * factor
+	return &scaled
+}
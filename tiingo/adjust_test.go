@@ -0,0 +1,344 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// adjustQuote returns a quote of ticker on the given day of January 2024
+func adjustQuote(ticker string, day int, close, dividend, split float64) *Eod {
+	return &Eod{
+		Date:   time.Date(2024, 1, day, 16, 0, 0, 0, time.UTC),
+		Ticker: ticker,
+		Open:   close, High: close, Low: close, Close: close,
+		Volume:   1000,
+		Dividend: dividend,
+		Split:    split,
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestComputeAdjustmentFactors(t *testing.T) {
+	tests := []struct {
+		name         string
+		quotes       []*Eod
+		adjust       AdjustOptions
+		wantSplit    []float64
+		wantDividend []float64
+		wantAdjClose []float64
+		wantVolume   []int64
+	}{
+		{
+			name: "no events",
+			quotes: []*Eod{
+				adjustQuote("AAA", 2, 10, 0, 1),
+				adjustQuote("AAA", 3, 11, 0, 1),
+				adjustQuote("AAA", 4, 12, 0, 1),
+			},
+			wantSplit:    []float64{1, 1, 1},
+			wantDividend: []float64{1, 1, 1},
+		},
+		{
+			name: "split applies to prior days only",
+			quotes: []*Eod{
+				adjustQuote("AAA", 2, 100, 0, 1),
+				adjustQuote("AAA", 3, 100, 0, 1),
+				adjustQuote("AAA", 4, 50, 0, 2),
+			},
+			adjust:       AdjustOptions{Prices: AdjustLocal},
+			wantSplit:    []float64{0.5, 0.5, 1},
+			wantDividend: []float64{1, 1, 1},
+			wantAdjClose: []float64{50, 50, 50},
+			wantVolume:   []int64{2000, 2000, 1000},
+		},
+		{
+			name: "split chain",
+			quotes: []*Eod{
+				adjustQuote("AAA", 2, 60, 0, 1),
+				adjustQuote("AAA", 3, 60, 0, 1),
+				adjustQuote("AAA", 4, 30, 0, 2),
+				adjustQuote("AAA", 5, 30, 0, 1),
+				adjustQuote("AAA", 8, 10, 0, 3),
+			},
+			adjust:       AdjustOptions{Volume: true},
+			wantSplit:    []float64{1.0 / 6, 1.0 / 6, 1.0 / 3, 1.0 / 3, 1},
+			wantDividend: []float64{1, 1, 1, 1, 1},
+			wantVolume:   []int64{6000, 6000, 3000, 3000, 1000},
+		},
+		{
+			name: "dividend uses the previous close",
+			quotes: []*Eod{
+				adjustQuote("AAA", 2, 100, 0, 1),
+				adjustQuote("AAA", 3, 98, 2, 1),
+			},
+			adjust:       AdjustOptions{Prices: AdjustLocal},
+			wantSplit:    []float64{1, 1},
+			wantDividend: []float64{0.98, 1},
+			wantAdjClose: []float64{98, 98},
+		},
+		{
+			name: "dividend after a split",
+			quotes: []*Eod{
+				adjustQuote("AAA", 2, 100, 0, 1),
+				adjustQuote("AAA", 3, 50, 0, 2),
+				adjustQuote("AAA", 4, 49, 1, 1),
+			},
+			adjust:       AdjustOptions{Prices: AdjustLocal},
+			wantSplit:    []float64{0.5, 1, 1},
+			wantDividend: []float64{0.98, 0.98, 1},
+			wantAdjClose: []float64{49, 49, 49},
+		},
+		{
+			name: "dividend chain",
+			quotes: []*Eod{
+				adjustQuote("AAA", 2, 100, 0, 1),
+				adjustQuote("AAA", 3, 99, 1, 1),
+				adjustQuote("AAA", 4, 50, 0, 1),
+				adjustQuote("AAA", 5, 49, 1, 1),
+			},
+			wantSplit:    []float64{1, 1, 1, 1},
+			wantDividend: []float64{0.99 * 0.98, 0.98, 0.98, 1},
+		},
+		{
+			name: "quotes are ordered by date",
+			quotes: []*Eod{
+				adjustQuote("AAA", 4, 50, 0, 2),
+				adjustQuote("AAA", 2, 100, 0, 1),
+				adjustQuote("AAA", 3, 100, 0, 1),
+			},
+			wantSplit:    []float64{1, 0.5, 0.5},
+			wantDividend: []float64{1, 1, 1},
+		},
+		{
+			name: "assets are adjusted independently",
+			quotes: []*Eod{
+				adjustQuote("AAA", 2, 100, 0, 1),
+				adjustQuote("BBB", 2, 100, 0, 1),
+				adjustQuote("AAA", 3, 50, 0, 2),
+				adjustQuote("BBB", 3, 95, 5, 1),
+			},
+			wantSplit:    []float64{0.5, 1, 1, 1},
+			wantDividend: []float64{1, 0.95, 1, 1},
+		},
+		{
+			name: "none omits adjusted prices",
+			quotes: []*Eod{
+				adjustQuote("AAA", 2, 100, 0, 1),
+				adjustQuote("AAA", 3, 50, 0, 2),
+			},
+			adjust:       AdjustOptions{Prices: AdjustNone},
+			wantSplit:    []float64{0.5, 1},
+			wantDividend: []float64{1, 1},
+			wantAdjClose: []float64{math.NaN(), math.NaN()},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ComputeAdjustmentFactors(test.quotes, test.adjust)
+			for idx, quote := range test.quotes {
+				if !approxEqual(quote.SplitAdjustFactor, test.wantSplit[idx]) {
+					t.Errorf("quote %d: expected split adjust factor %v, got %v", idx, test.wantSplit[idx], quote.SplitAdjustFactor)
+				}
+				if !approxEqual(quote.DividendAdjustFactor, test.wantDividend[idx]) {
+					t.Errorf("quote %d: expected dividend adjust factor %v, got %v", idx, test.wantDividend[idx], quote.DividendAdjustFactor)
+				}
+				if test.wantAdjClose != nil {
+					switch want := test.wantAdjClose[idx]; {
+					case math.IsNaN(want) && quote.AdjClose != nil:
+						t.Errorf("quote %d: expected no adjusted close, got %v", idx, *quote.AdjClose)
+					case !math.IsNaN(want) && (quote.AdjClose == nil || !approxEqual(*quote.AdjClose, want)):
+						t.Errorf("quote %d: expected adjusted close %v, got %v", idx, want, quote.AdjClose)
+					}
+				}
+				if test.wantVolume != nil && (quote.AdjustedVolume == nil || *quote.AdjustedVolume != test.wantVolume[idx]) {
+					t.Errorf("quote %d: expected adjusted volume %d, got %v", idx, test.wantVolume[idx], quote.AdjustedVolume)
+				}
+			}
+		})
+	}
+}
+
+func TestComputeAdjustmentFactorsKeepsVendorValues(t *testing.T) {
+	adjClose := 45.0
+	vendorVolume := 2000.0
+	quotes := []*Eod{adjustQuote("AAA", 2, 100, 0, 1), adjustQuote("AAA", 3, 50, 0, 2)}
+	quotes[0].AdjClose = &adjClose
+	quotes[0].VendorAdjVolume = &vendorVolume
+
+	ComputeAdjustmentFactors(quotes, AdjustOptions{Prices: AdjustTiingo})
+
+	if quotes[0].AdjClose == nil || *quotes[0].AdjClose != 45 {
+		t.Errorf("expected the adjusted close reported by tiingo to be kept, got %v", quotes[0].AdjClose)
+	}
+	if quotes[0].AdjustedVolume == nil || *quotes[0].AdjustedVolume != 2000 {
+		t.Errorf("expected the adjusted volume reported by tiingo, got %v", quotes[0].AdjustedVolume)
+	}
+	if quotes[0].Dividend != 0 || quotes[1].Split != 2 {
+		t.Errorf("expected raw dividend and split values to be untouched, got %v %v", quotes[0].Dividend, quotes[1].Split)
+	}
+}
+
+func TestComputeAssetFactorsBeforeFirstQuote(t *testing.T) {
+	tests := []struct {
+		name         string
+		quotes       []*Eod
+		wantSplit    float64
+		wantDividend float64
+	}{
+		{
+			name:         "no events",
+			quotes:       []*Eod{adjustQuote("AAA", 2, 100, 0, 1)},
+			wantSplit:    1,
+			wantDividend: 1,
+		},
+		{
+			name:         "split on the first quote",
+			quotes:       []*Eod{adjustQuote("AAA", 2, 50, 0, 2), adjustQuote("AAA", 3, 50, 0, 1)},
+			wantSplit:    0.5,
+			wantDividend: 1,
+		},
+		{
+			// the close before the ex-date is approximated by 98 + 2
+			name:         "dividend on the first quote without a previous close",
+			quotes:       []*Eod{adjustQuote("AAA", 2, 98, 2, 1), adjustQuote("AAA", 3, 99, 0, 1)},
+			wantSplit:    1,
+			wantDividend: 0.98,
+		},
+		{
+			name:         "dividend on a later quote",
+			quotes:       []*Eod{adjustQuote("AAA", 2, 80, 0, 1), adjustQuote("AAA", 3, 78, 2, 1)},
+			wantSplit:    1,
+			wantDividend: 0.975,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			splitFactor, dividendFactor := computeAssetFactors(test.quotes, AdjustOptions{})
+			if !approxEqual(splitFactor, test.wantSplit) {
+				t.Errorf("expected split factor %v, got %v", test.wantSplit, splitFactor)
+			}
+			if !approxEqual(dividendFactor, test.wantDividend) {
+				t.Errorf("expected dividend factor %v, got %v", test.wantDividend, dividendFactor)
+			}
+		})
+	}
+}
+
+func TestRebaseAdjustmentFactors(t *testing.T) {
+	tests := []struct {
+		name           string
+		adjust         AdjustOptions
+		splitFactor    float64
+		dividendFactor float64
+		wantSplit      []float64
+		wantDividend   []float64
+		wantAdjClose   []float64
+		wantVolume     []int64
+	}{
+		{
+			name:           "identity",
+			adjust:         AdjustOptions{Prices: AdjustLocal},
+			splitFactor:    1,
+			dividendFactor: 1,
+			wantSplit:      []float64{0.5, 1},
+			wantDividend:   []float64{1, 1},
+			wantAdjClose:   []float64{50, 50},
+			wantVolume:     []int64{2000, 1000},
+		},
+		{
+			name:           "later split and dividend",
+			adjust:         AdjustOptions{Prices: AdjustLocal},
+			splitFactor:    0.25,
+			dividendFactor: 0.9,
+			wantSplit:      []float64{0.125, 0.25},
+			wantDividend:   []float64{0.9, 0.9},
+			wantAdjClose:   []float64{50 * 0.25 * 0.9, 50 * 0.25 * 0.9},
+			wantVolume:     []int64{8000, 4000},
+		},
+		{
+			name:           "split adjusted volume without local prices",
+			adjust:         AdjustOptions{Volume: true},
+			splitFactor:    0.5,
+			dividendFactor: 0.9,
+			wantSplit:      []float64{0.25, 0.5},
+			wantDividend:   []float64{0.9, 0.9},
+			wantVolume:     []int64{4000, 2000},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quotes := []*Eod{adjustQuote("AAA", 2, 100, 0, 1), adjustQuote("AAA", 3, 50, 0, 2)}
+			ComputeAdjustmentFactors(quotes, test.adjust)
+			RebaseAdjustmentFactors(quotes, test.splitFactor, test.dividendFactor, test.adjust)
+			for idx, quote := range quotes {
+				if !approxEqual(quote.SplitAdjustFactor, test.wantSplit[idx]) {
+					t.Errorf("quote %d: expected split adjust factor %v, got %v", idx, test.wantSplit[idx], quote.SplitAdjustFactor)
+				}
+				if !approxEqual(quote.DividendAdjustFactor, test.wantDividend[idx]) {
+					t.Errorf("quote %d: expected dividend adjust factor %v, got %v", idx, test.wantDividend[idx], quote.DividendAdjustFactor)
+				}
+				if test.wantAdjClose != nil && (quote.AdjClose == nil || !approxEqual(*quote.AdjClose, test.wantAdjClose[idx])) {
+					t.Errorf("quote %d: expected adjusted close %v, got %v", idx, test.wantAdjClose[idx], quote.AdjClose)
+				}
+				if quote.AdjustedVolume == nil || *quote.AdjustedVolume != test.wantVolume[idx] {
+					t.Errorf("quote %d: expected adjusted volume %d, got %v", idx, test.wantVolume[idx], quote.AdjustedVolume)
+				}
+			}
+		})
+	}
+}
+
+func TestRebaseMatchesFullHistory(t *testing.T) {
+	// adjusting a window and rebasing it onto the factors of the first stored
+	// quote after it gives the same factors as adjusting the full history
+	full := []*Eod{
+		adjustQuote("AAA", 2, 100, 0, 1),
+		adjustQuote("AAA", 3, 98, 2, 1),
+		adjustQuote("AAA", 4, 49, 0, 2),
+		adjustQuote("AAA", 5, 48, 1, 1),
+		adjustQuote("AAA", 8, 50, 0, 1),
+	}
+	ComputeAdjustmentFactors(full, AdjustOptions{})
+
+	window := []*Eod{adjustQuote("AAA", 2, 100, 0, 1), adjustQuote("AAA", 3, 98, 2, 1)}
+	ComputeAdjustmentFactors(window, AdjustOptions{})
+	next := &StoredQuote{
+		SplitFactor:          full[2].Split,
+		Dividend:             full[2].Dividend,
+		SplitAdjustFactor:    full[2].SplitAdjustFactor,
+		DividendAdjustFactor: full[2].DividendAdjustFactor,
+	}
+	splitFactor, dividendFactor := next.factorsBefore(window[len(window)-1].Close)
+	RebaseAdjustmentFactors(window, splitFactor, dividendFactor, AdjustOptions{})
+
+	for idx, quote := range window {
+		if !approxEqual(quote.SplitAdjustFactor, full[idx].SplitAdjustFactor) {
+			t.Errorf("quote %d: expected split adjust factor %v, got %v", idx, full[idx].SplitAdjustFactor, quote.SplitAdjustFactor)
+		}
+		if !approxEqual(quote.DividendAdjustFactor, full[idx].DividendAdjustFactor) {
+			t.Errorf("quote %d: expected dividend adjust factor %v, got %v", idx, full[idx].DividendAdjustFactor, quote.DividendAdjustFactor)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+)
+
+// Gap is a run of consecutive trading days missing from the eod table
+type Gap struct {
+	Asset     *common.Asset
+	StartDate time.Time
+	EndDate   time.Time
+	NumDays   int
+
+	// first stored quote after the gap; nil if the gap runs through the end
+	// of the checked window
+	Next *StoredQuote
+}
+
+// StoredQuote is the split, dividend and adjustment factors of a quote
+// already in the eod table
+type StoredQuote struct {
+	CompositeFigi        string  `db:"composite_figi"`
+	EventDate            string  `db:"event_date"`
+	SplitFactor          float64 `db:"split_factor"`
+	Dividend             float64 `db:"dividend"`
+	SplitAdjustFactor    float64 `db:"split_adjust_factor"`
+	DividendAdjustFactor float64 `db:"dividend_adjust_factor"`
+}
+
+// Backfill downloads the quotes missing in each gap. Adjustment factors are
+// computed for each gap and rebased onto the factors of the first stored quote
+// after the gap so they line up with the existing history.
+func (t *TiingoApi) Backfill(ctx context.Context, gaps []*Gap) ([]*Eod, []*TickerError) {
+	requests := make([]*EodRequest, len(gaps))
+	for idx, gap := range gaps {
+		requests[idx] = &EodRequest{Asset: gap.Asset, StartDate: gap.StartDate, EndDate: gap.EndDate}
+	}
+
+	quotes, errs := t.FetchEodRanges(ctx, requests)
+
+	// assign downloaded quotes to the gap they fill
+	byGap := make(map[*Gap][]*Eod, len(gaps))
+	for _, quote := range quotes {
+		date, err := time.Parse("2006-01-02", quote.Date.Format("2006-01-02"))
+		if err != nil {
+			continue
+		}
+		for _, gap := range gaps {
+			if gap.Asset.CompositeFigi == quote.CompositeFigi && !date.Before(gap.StartDate) && !date.After(gap.EndDate) {
+				byGap[gap] = append(byGap[gap], quote)
+				break
+			}
+		}
+	}
+
+	filled := make([]*Eod, 0, len(quotes))
+	for gap, gapQuotes := range byGap {
+		ComputeAdjustmentFactors(gapQuotes, t.options.Adjust)
+		if gap.Next != nil {
+			sorted := GroupByAsset(gapQuotes)[gap.Asset.CompositeFigi]
+			splitFactor, dividendFactor := gap.Next.factorsBefore(sorted[len(sorted)-1].Close)
+			RebaseAdjustmentFactors(gapQuotes, splitFactor, dividendFactor, t.options.Adjust)
+		}
+		filled = append(filled, gapQuotes...)
+	}
+
+	if t.options.TrimZeroVolume && HasColumn(t.options.Columns, "volume") {
+		filled = TrimZeroVolume(filled)
+	}
+
+	return filled, errs
+}
+
+// factorsBefore returns the cumulative split and dividend adjustment factors
+// that apply to the day before the stored quote; prevClose is the close of
+// that day
+func (q *StoredQuote) factorsBefore(prevClose float64) (float64, float64) {
+	splitFactor := q.SplitAdjustFactor
+	if q.SplitFactor != 0 && q.SplitFactor != 1 {
+		splitFactor /= q.SplitFactor
+	}
+
+	dividendFactor := q.DividendAdjustFactor
+	if q.Dividend != 0 && prevClose > 0 {
+		dividendFactor *= 1 - (q.Dividend / prevClose)
+	}
+
+	return splitFactor, dividendFactor
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// cacheExt is the extension of cached response files
+const cacheExt = ".resp"
+
+// cacheTransport is an http.RoundTripper that stores successful GET
+// responses on disk and serves them until they are older than ttl. Responses
+// are keyed by URL (which includes the requested date range) without the API
+// token.
+type cacheTransport struct {
+	dir  string
+	ttl  time.Duration
+	next http.RoundTripper
+}
+
+func newCacheTransport(dir string, ttl time.Duration, next http.RoundTripper) *cacheTransport {
+	return &cacheTransport{dir: dir, ttl: ttl, next: next}
+}
+
+func (c *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return c.next.RoundTrip(req)
+	}
+
+	fn := filepath.Join(c.dir, cacheKey(req.URL)+cacheExt)
+	if info, err := os.Stat(fn); err == nil && (c.ttl <= 0 || time.Since(info.ModTime()) < c.ttl) {
+		if data, err := os.ReadFile(fn); err == nil {
+			if resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req); err == nil {
+				log.Debug().Str("URL", redactURL(req.URL)).Msg("serving response from cache")
+				return resp, nil
+			}
+		}
+	}
+
+	resp, err := c.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	data, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		log.Warn().Err(err).Str("URL", redactURL(req.URL)).Msg("could not cache response")
+		return resp, nil
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		log.Warn().Err(err).Str("Dir", c.dir).Msg("could not create cache directory")
+		return resp, nil
+	}
+
+	tmp := common.TempPath(fn)
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Warn().Err(err).Str("FileName", fn).Msg("could not write cache file")
+		return resp, nil
+	}
+	if err := common.CommitFile(tmp, fn); err != nil {
+		log.Warn().Err(err).Str("FileName", fn).Msg("could not write cache file")
+	}
+	return resp, nil
+}
+
+// cacheKey hashes u without the token parameter
+func cacheKey(u *url.URL) string {
+	sum := sha256.Sum256([]byte(redactURL(u)))
+	return hex.EncodeToString(sum[:])
+}
+
+// redactURL returns u without the token query parameter
+func redactURL(u *url.URL) string {
+	clean := *u
+	query := clean.Query()
+	query.Del("token")
+	clean.RawQuery = query.Encode()
+	return clean.String()
+}
+
+// ClearCache removes all cached responses from dir and returns the number of
+// files removed
+func ClearCache(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	numRemoved := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), cacheExt) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return numRemoved, err
+		}
+		numRemoved++
+	}
+	return numRemoved, nil
+}
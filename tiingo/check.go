@@ -0,0 +1,51 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"fmt"
+)
+
+// CheckToken verifies the API token, and each additional token, with
+// tiingo's /api/test endpoint; an invalid token returns ErrUnauthorized
+func (t *TiingoApi) CheckToken(ctx context.Context) error {
+	if t.tokens == nil {
+		return t.checkToken(ctx)
+	}
+	for idx, token := range t.tokens.tokens {
+		if err := t.checkToken(withToken(ctx, token.token)); err != nil {
+			return fmt.Errorf("token %d of %d: %w", idx+1, len(t.tokens.tokens), err)
+		}
+	}
+	return nil
+}
+
+func (t *TiingoApi) checkToken(ctx context.Context) error {
+	url := fmt.Sprintf("%s/api/test?token=%s", t.baseURL, t.token)
+	resp, err := t.newClient().
+		R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		Get(url)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrRequestFailed, tokenPattern.ReplaceAllString(err.Error(), "token=REDACTED"))
+	}
+	if resp.StatusCode() >= 400 {
+		return fmt.Errorf("%w (status %d)", statusError(resp.StatusCode()), resp.StatusCode())
+	}
+	return nil
+}
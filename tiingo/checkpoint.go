@@ -0,0 +1,197 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Checkpoint is a journal of completed downloads. Each completed request is
+// appended to the journal file along with its quotes so an interrupted run can
+// be resumed without downloading those assets again.
+//
+// The first line of the journal records the run ID and the download range;
+// each following line is a JSON checkpointEntry. Requests are keyed by their
+// start date, so a resumed run must download the journaled range (which
+// without an explicit start date depends on the day the run started).
+type Checkpoint struct {
+	RunID     string
+	StartDate time.Time
+	EndDate   time.Time
+
+	fn        string
+	mu        sync.Mutex
+	fh        *os.File
+	enc       *json.Encoder
+	completed map[string][]Eod
+}
+
+type checkpointHeader struct {
+	RunID     string    `json:"run_id"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+}
+
+type checkpointEntry struct {
+	Key    string `json:"key"`
+	Quotes []Eod  `json:"quotes"`
+}
+
+// OpenCheckpoint opens the journal at fn. When resume is true previously
+// completed requests are loaded and the journal's run ID and download range
+// are kept; otherwise any existing journal is replaced and runID, startDate
+// and endDate are recorded.
+func OpenCheckpoint(fn string, runID string, startDate, endDate time.Time, resume bool) (*Checkpoint, error) {
+	cp := &Checkpoint{
+		RunID:     runID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		fn:        fn,
+		completed: make(map[string][]Eod),
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		existed, err := cp.load()
+		if err != nil {
+			return nil, err
+		}
+		if existed {
+			flags = os.O_WRONLY | os.O_APPEND
+		}
+	}
+
+	fh, err := os.OpenFile(fn, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	cp.fh = fh
+	cp.enc = json.NewEncoder(fh)
+
+	if flags&os.O_TRUNC != 0 {
+		if err := cp.enc.Encode(&checkpointHeader{RunID: cp.RunID, StartDate: cp.StartDate, EndDate: cp.EndDate}); err != nil {
+			fh.Close()
+			return nil, err
+		}
+	}
+
+	log.Info().Str("FileName", fn).Str("CheckpointRunID", cp.RunID).Int("NumCompleted", len(cp.completed)).Msg("opened checkpoint")
+	return cp, nil
+}
+
+// load reads a previously written journal and reports whether one with a
+// valid header exists; a missing journal is not an error
+func (cp *Checkpoint) load() (bool, error) {
+	fh, err := os.Open(cp.fn)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Warn().Str("FileName", cp.fn).Msg("no checkpoint to resume from ... starting a new run")
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer fh.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(fh))
+	var header checkpointHeader
+	if err := dec.Decode(&header); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, fmt.Errorf("invalid checkpoint header: %w", err)
+	}
+	if header.RunID != "" {
+		cp.RunID = header.RunID
+	}
+	// journals written before the range was recorded keep the current range
+	if !header.StartDate.IsZero() {
+		cp.StartDate, cp.EndDate = header.StartDate, header.EndDate
+	}
+
+	for {
+		var entry checkpointEntry
+		if err := dec.Decode(&entry); err != nil {
+			if err != io.EOF {
+				// a partially written last entry is expected when the
+				// previous run was killed
+				log.Warn().Err(err).Str("FileName", cp.fn).Msg("ignoring truncated checkpoint entry")
+			}
+			break
+		}
+		cp.completed[entry.Key] = entry.Quotes
+	}
+
+	return true, nil
+}
+
+// Completed returns the quotes of a request recorded in the journal
+func (cp *Checkpoint) Completed(key string) ([]Eod, bool) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	quotes, ok := cp.completed[key]
+	return quotes, ok
+}
+
+// Record appends a completed request to the journal
+func (cp *Checkpoint) Record(key string, quotes []Eod) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if quotes == nil {
+		quotes = []Eod{}
+	}
+	cp.completed[key] = quotes
+	if err := cp.enc.Encode(&checkpointEntry{Key: key, Quotes: quotes}); err != nil {
+		log.Error().Err(err).Str("FileName", cp.fn).Str("Key", key).Msg("could not write checkpoint")
+	}
+}
+
+// Close closes the journal file
+func (cp *Checkpoint) Close() error {
+	return cp.fh.Close()
+}
+
+// Remove closes and deletes the journal; call it once results are saved
+func (cp *Checkpoint) Remove() error {
+	cp.fh.Close()
+	return os.Remove(cp.fn)
+}
+
+// checkpointKey identifies a request in the checkpoint journal
+func checkpointKey(request *EodRequest, frequency string) string {
+	key := fmt.Sprintf("%s|%s|%s", request.Asset.Ticker, request.Asset.CompositeFigi, request.StartDate.Format("2006-01-02"))
+	if !request.EndDate.IsZero() {
+		key += "|" + request.EndDate.Format("2006-01-02")
+	}
+	if frequency != "" && frequency != FrequencyDaily {
+		key += "|" + frequency
+	}
+	return key
+}
+
+// SetCheckpoint journals completed EOD downloads to cp and skips requests that
+// cp already contains
+func (t *TiingoApi) SetCheckpoint(cp *Checkpoint) {
+	t.checkpoint = cp
+}
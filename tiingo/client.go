@@ -0,0 +1,169 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/penny-vault/import-tiingo/common"
+)
+
+// DefaultBaseURL is the root of the tiingo REST API
+const DefaultBaseURL = "https://api.tiingo.com"
+
+// TiingoClient is the tiingo API used by the commands; TiingoApi is the
+// implementation backed by the REST API.
+type TiingoClient interface {
+	FetchEodQuotes(ctx context.Context, assets []*common.Asset, startDate, endDate time.Time, startDates map[string]time.Time) ([]*Eod, []*TickerError)
+	FetchEodRanges(ctx context.Context, requests []*EodRequest) ([]*Eod, []*TickerError)
+	FetchFullHistory(ctx context.Context, assets []*common.Asset, endDate time.Time, chunk time.Duration) ([]*Eod, []*TickerError)
+	FetchTickerMeta(ctx context.Context, assets []*common.Asset) (map[string]*TickerMeta, []*TickerError)
+	Backfill(ctx context.Context, gaps []*Gap) ([]*Eod, []*TickerError)
+	FetchFundamentals(ctx context.Context, assets []*common.Asset, startDate, endDate time.Time) ([]*Fundamentals, []*TickerError)
+	FetchDailyMetrics(ctx context.Context, assets []*common.Asset, startDate, endDate time.Time) ([]*DailyMetrics, []*TickerError)
+	FetchFundamentalDefinitions(ctx context.Context) ([]*FundamentalDefinition, error)
+	FetchFundamentalsMeta(ctx context.Context, assets []*common.Asset) ([]*FundamentalsMeta, []*TickerError)
+	FetchDistributions(ctx context.Context, assets []*common.Asset, startExDate, endExDate time.Time) ([]*Distribution, []*TickerError)
+	FetchSplits(ctx context.Context, assets []*common.Asset, startExDate, endExDate time.Time) ([]*Split, []*TickerError)
+	FetchIntradayBars(ctx context.Context, assets []*common.Asset, startDate, endDate time.Time, frequency string) ([]*IntradayBar, []*TickerError)
+	FetchCryptoEod(ctx context.Context, pairs []string, exchanges []string, startDate, endDate time.Time) ([]*Eod, []*TickerError)
+	FetchFxRates(ctx context.Context, pairs []string, startDate, endDate time.Time) ([]*FxRate, []*TickerError)
+	FetchNews(ctx context.Context, tickers []string, tags []string, startDate, endDate time.Time) []*NewsArticle
+	FetchSupportedTickers(ctx context.Context) ([]*SupportedTicker, error)
+	StreamIEX(ctx context.Context, tickers []string, thresholdLevel int, handler StreamHandler) error
+	StreamCrypto(ctx context.Context, pairs []string, handler CryptoTradeHandler, onGap GapHandler) error
+	FetchCryptoBars(ctx context.Context, exchanges map[string][]string, from, to time.Time) ([]*CryptoBar, []*TickerError)
+	CheckToken(ctx context.Context) error
+	SetCheckpoint(cp *Checkpoint)
+	SetQuoteHandler(handler QuoteHandler)
+	MissingTickers(errs []*TickerError) []string
+	Metrics() []*RequestMetrics
+	APICalls() int64
+	Quota() *Quota
+}
+
+var _ TiingoClient = (*TiingoApi)(nil)
+
+// Option configures a TiingoApi
+type Option func(*TiingoApi)
+
+// WithBaseURL sends API requests to baseURL instead of DefaultBaseURL, e.g.
+// an httptest server or a caching proxy
+func WithBaseURL(baseURL string) Option {
+	return func(t *TiingoApi) {
+		t.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithSupportedTickersURL downloads the supported tickers list from url
+func WithSupportedTickersURL(url string) Option {
+	return func(t *TiingoApi) {
+		t.supportedTickersURL = url
+	}
+}
+
+// WithStreamURL connects to the IEX websocket at url instead of
+// DefaultStreamURL
+func WithStreamURL(url string) Option {
+	return func(t *TiingoApi) {
+		t.streamURL = url
+	}
+}
+
+// WithCryptoStreamURL connects to the crypto websocket at url instead of
+// DefaultCryptoStreamURL
+func WithCryptoStreamURL(url string) Option {
+	return func(t *TiingoApi) {
+		t.cryptoStreamURL = url
+	}
+}
+
+// WithHTTPClient makes requests with client; the response cache and
+// recording settings are applied on top of its transport
+func WithHTTPClient(client *http.Client) Option {
+	return func(t *TiingoApi) {
+		t.httpClient = client
+	}
+}
+
+// WithRestyClient makes all requests with client as is
+func WithRestyClient(client *resty.Client) Option {
+	return func(t *TiingoApi) {
+		t.restyClient = client
+	}
+}
+
+// newClient returns the HTTP client used for tiingo requests. With
+// ReplayDir set responses are read from a previous recording instead of the
+// network; otherwise responses are archived to RecordDir and cached on disk
+// in CacheDir for CacheTTL when those are set. Requests that reach the
+// network are cut off at the run deadline, counted, limited to the hourly and
+// daily request budgets, and their quota headers tracked. Throttled requests
+// and server errors are retried up to MaxRetries times.
+func (t *TiingoApi) newClient() *resty.Client {
+	if t.restyClient != nil {
+		return t.restyClient
+	}
+
+	client := resty.New()
+	var transport http.RoundTripper = http.DefaultTransport
+	if t.httpClient != nil {
+		client = resty.NewWithClient(t.httpClient)
+		if t.httpClient.Transport != nil {
+			transport = t.httpClient.Transport
+		}
+	}
+
+	if dir := t.options.ReplayDir; dir != "" {
+		return client.SetTransport(&replayTransport{dir: dir}).SetRetryCount(0)
+	}
+
+	if t.tokens != nil {
+		transport = &tokenTransport{pool: t.tokens, limiter: t.adaptive, logRequests: t.options.LogRequests, next: transport}
+	} else {
+		transport = &quotaTransport{quota: &t.quota, logRequests: t.options.LogRequests, next: transport}
+		if t.adaptive != nil || len(t.budgets) > 0 {
+			transport = &rateTransport{limiter: t.adaptive, budgets: t.budgets, next: transport}
+		}
+	}
+	transport = &countingTransport{count: &t.apiCalls, next: transport}
+	if !t.options.Deadline.IsZero() {
+		transport = &deadlineTransport{deadline: t.options.Deadline, next: transport}
+	}
+	if dir := t.options.RecordDir; dir != "" {
+		transport = &recordTransport{dir: dir, s3: t.options.S3, next: transport}
+	}
+	if dir := t.options.CacheDir; dir != "" {
+		transport = newCacheTransport(dir, t.options.CacheTTL, transport)
+	}
+	if timeout := t.options.RequestTimeout; timeout > 0 {
+		client.SetTimeout(timeout)
+	}
+	if retries := t.options.MaxRetries; retries > 0 {
+		client.
+			SetRetryCount(retries).
+			SetRetryWaitTime(minRetryWait).
+			SetRetryMaxWaitTime(max(t.options.MaxRetryWait, minRetryWait)).
+			SetRetryAfter(retryAfter).
+			AddRetryCondition(retryable).
+			AddRetryHook(logRetries(retries))
+	}
+	return client.SetTransport(transport)
+}
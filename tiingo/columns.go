@@ -0,0 +1,86 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EodColumns are the columns of the daily prices endpoint that can be
+// selected with Options.Columns
+var EodColumns = []string{
+	"date",
+	"open",
+	"high",
+	"low",
+	"close",
+	"volume",
+	"adjOpen",
+	"adjHigh",
+	"adjLow",
+	"adjClose",
+	"adjVolume",
+	"divCash",
+	"splitFactor",
+}
+
+// ValidateColumns returns an error if any of columns is not in EodColumns
+func ValidateColumns(columns []string) error {
+	for _, column := range columns {
+		valid := false
+		for _, eodColumn := range EodColumns {
+			if column == eodColumn {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown eod column '%s'; use %s", column, strings.Join(EodColumns, ", "))
+		}
+	}
+	return nil
+}
+
+// HasColumn returns true if column is downloaded; all columns are downloaded
+// unless columns selects a subset. Fields of columns that are not downloaded
+// are left at their zero value.
+func HasColumn(columns []string, column string) bool {
+	if len(columns) == 0 || column == "date" {
+		return true
+	}
+	for _, selected := range columns {
+		if selected == column {
+			return true
+		}
+	}
+	return false
+}
+
+// columnsParam returns the value of the columns query parameter, or an empty
+// string when all columns are downloaded. The date is always requested.
+func columnsParam(columns []string) string {
+	if len(columns) == 0 {
+		return ""
+	}
+	selected := []string{"date"}
+	for _, column := range columns {
+		if column != "date" {
+			selected = append(selected, column)
+		}
+	}
+	return strings.Join(selected, ",")
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import "time"
+
+// Corporate action types
+const (
+	ActionDividend = "dividend"
+	ActionSplit    = "split"
+)
+
+// CorporateAction is a dividend or split event; Value is the cash dividend
+// per share or the split factor (e.g. 2 for a 2:1 split)
+type CorporateAction struct {
+	CompositeFigi string
+	Ticker        string
+	ExDate        time.Time
+	Type          string
+	Value         float64
+	RunID         string
+}
+
+// ExtractCorporateActions returns the non-zero dividends and splits reported
+// in quotes
+func ExtractCorporateActions(quotes []*Eod) []*CorporateAction {
+	actions := []*CorporateAction{}
+	for _, quote := range quotes {
+		if quote.Dividend != 0 {
+			actions = append(actions, &CorporateAction{
+				CompositeFigi: quote.CompositeFigi,
+				Ticker:        quote.Ticker,
+				ExDate:        quote.Date,
+				Type:          ActionDividend,
+				Value:         quote.Dividend,
+				RunID:         quote.RunID,
+			})
+		}
+		if quote.Split != 0 && quote.Split != 1 {
+			actions = append(actions, &CorporateAction{
+				CompositeFigi: quote.CompositeFigi,
+				Ticker:        quote.Ticker,
+				ExDate:        quote.Date,
+				Type:          ActionSplit,
+				Value:         quote.Split,
+				RunID:         quote.RunID,
+			})
+		}
+	}
+	return actions
+}
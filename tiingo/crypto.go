@@ -0,0 +1,130 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// CryptoAggregateExchange is the exchange recorded for prices aggregated
+// across all exchanges
+const CryptoAggregateExchange = "aggregate"
+
+type cryptoPriceResponse struct {
+	Ticker        string `json:"ticker"`
+	BaseCurrency  string `json:"baseCurrency"`
+	QuoteCurrency string `json:"quoteCurrency"`
+	PriceData     []Eod  `json:"priceData"`
+}
+
+// FetchCryptoEod downloads daily crypto prices for each pair (e.g. btcusd)
+// between startDate and endDate (zero for the latest price). When exchanges is
+// empty prices are aggregated across all exchanges; otherwise a separate series
+// is downloaded for each exchange. Results use the Eod schema with Ticker set to the pair and
+// Currency set to the quote currency. Pairs that fail are returned as errors.
+func (t *TiingoApi) FetchCryptoEod(ctx context.Context, pairs []string, exchanges []string, startDate, endDate time.Time) ([]*Eod, []*TickerError) {
+	client := t.newClient()
+	var errs errorCollector
+	quotes := []*Eod{}
+
+	if len(exchanges) == 0 {
+		exchanges = []string{""}
+	}
+
+	progress := t.progress("crypto", len(pairs)*len(exchanges))
+	defer progress.Finish()
+
+	for _, pair := range pairs {
+		for _, exchange := range exchanges {
+			if ctx.Err() != nil {
+				log.Warn().Err(ctx.Err()).Msg("download cancelled")
+				return quotes, errs.errors
+			}
+
+			t.rate.Take()
+			progress.Current(pair)
+
+			params := url.Values{}
+			params.Set("tickers", strings.ToLower(pair))
+			params.Set("startDate", startDate.Format("2006-01-02"))
+			if !endDate.IsZero() {
+				params.Set("endDate", endDate.Format("2006-01-02"))
+			}
+			params.Set("resampleFreq", "1day")
+			params.Set("token", t.token)
+			if exchange != "" {
+				params.Set("exchanges", strings.ToUpper(exchange))
+			}
+
+			resp, err := client.
+				R().
+				SetContext(ctx).
+				SetHeader("Accept", "application/json").
+				Get(t.baseURL + "/tiingo/crypto/prices?" + params.Encode())
+			if resp != nil {
+				progress.AddBytes(len(resp.Body()))
+			}
+			if err != nil {
+				log.Error().Err(err).Str("Pair", pair).Str("Exchange", exchange).Msg("error when requesting crypto prices")
+				errs.add(pair, 0, fmt.Errorf("%w: %w", ErrRequestFailed, err))
+				progress.Error()
+				continue
+			}
+			if resp.StatusCode() >= 400 {
+				log.Error().Int("StatusCode", resp.StatusCode()).Str("Pair", pair).Str("Exchange", exchange).Bytes("Body", resp.Body()).Msg("error when requesting crypto prices")
+				errs.add(pair, resp.StatusCode(), statusError(resp.StatusCode()))
+				progress.Error()
+				continue
+			}
+
+			var result []*cryptoPriceResponse
+			if err := json.Unmarshal(resp.Body(), &result); err != nil {
+				log.Error().Err(err).Str("Pair", pair).Msg("could not unmarshal crypto json")
+				errs.add(pair, resp.StatusCode(), fmt.Errorf("%w: %v", ErrInvalidResponse, err))
+				progress.Error()
+				continue
+			}
+
+			exchangeName := strings.ToUpper(exchange)
+			if exchangeName == "" {
+				exchangeName = CryptoAggregateExchange
+			}
+
+			for _, series := range result {
+				for idx := range series.PriceData {
+					q := series.PriceData[idx]
+					q.Ticker = series.Ticker
+					q.Exchange = exchangeName
+					q.Currency = strings.ToUpper(series.QuoteCurrency)
+					q.RunID = common.RunID
+					q.Date = common.Crypto.CloseTime(q.Date)
+					quotes = append(quotes, &q)
+				}
+			}
+			progress.Add(1)
+		}
+	}
+
+	return quotes, errs.errors
+}
@@ -0,0 +1,364 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultCryptoStreamURL is tiingo's crypto websocket
+const DefaultCryptoStreamURL = "wss://api.tiingo.com/crypto"
+
+// cryptoTradesThreshold subscribes to the trades of every exchange
+const cryptoTradesThreshold = 2
+
+// CryptoTrade is a trade on a single exchange from the crypto websocket
+type CryptoTrade struct {
+	Ticker   string
+	Exchange string
+	Time     time.Time
+	Price    float64
+	Size     float64
+}
+
+// CryptoTradeHandler receives trades from the crypto websocket; it is called
+// from a single goroutine
+type CryptoTradeHandler func(trade *CryptoTrade)
+
+// CryptoBar is a one minute OHLCV bar of a pair on a single exchange
+type CryptoBar struct {
+	Date      time.Time
+	Ticker    string
+	Exchange  string
+	Open      float64
+	High      float64
+	Low       float64
+	Close     float64
+	Volume    float64
+	NumTrades int
+	Source    string
+	RunID     string
+}
+
+// Sources of crypto bars
+const (
+	CryptoSourceStream = "wss://api.tiingo.com/crypto"
+	CryptoSourceREST   = "api.tiingo.com/tiingo/crypto/prices"
+)
+
+// StreamCrypto subscribes to the crypto websocket for pairs (e.g. btcusd) and
+// calls handler for every trade until ctx is cancelled. Dropped connections
+// are re-established with exponential backoff and onGap is called with the
+// period whose trades were missed; an error is only returned if tiingo
+// rejects the subscription.
+func (t *TiingoApi) StreamCrypto(ctx context.Context, pairs []string, handler CryptoTradeHandler, onGap GapHandler) error {
+	subscription := make([]string, len(pairs))
+	for idx, pair := range pairs {
+		subscription[idx] = strings.ToLower(pair)
+	}
+
+	eventData := map[string]any{
+		"thresholdLevel": cryptoTradesThreshold,
+		"tickers":        subscription,
+	}
+	handle := func(data json.RawMessage) {
+		trade, err := parseCryptoTrade(data)
+		if err != nil {
+			log.Warn().Err(err).RawJSON("Data", data).Msg("could not parse crypto update")
+			return
+		}
+		if trade != nil {
+			handler(trade)
+		}
+	}
+
+	return t.stream(ctx, t.cryptoStreamURL, eventData, handle, onGap)
+}
+
+// parseCryptoTrade decodes the positional data array of a crypto websocket
+// trade: type, ticker, date, exchange, size and price. Quote updates are
+// ignored and return nil.
+func parseCryptoTrade(data json.RawMessage) (*CryptoTrade, error) {
+	var fields []any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 || fields[0] != "T" {
+		return nil, nil
+	}
+	if len(fields) < 6 {
+		return nil, fmt.Errorf("%w: crypto trade has %d fields, expected 6", ErrInvalidResponse, len(fields))
+	}
+
+	str := func(idx int) string {
+		s, _ := fields[idx].(string)
+		return s
+	}
+	num := func(idx int) float64 {
+		f, _ := fields[idx].(float64)
+		return f
+	}
+
+	tradeTime, err := time.Parse(time.RFC3339Nano, str(2))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+
+	return &CryptoTrade{
+		Ticker:   strings.ToLower(str(1)),
+		Time:     tradeTime,
+		Exchange: strings.ToUpper(str(3)),
+		Size:     num(4),
+		Price:    num(5),
+	}, nil
+}
+
+// cryptoBarKey identifies the bar of a pair on an exchange starting at a
+// given minute
+type cryptoBarKey struct {
+	ticker   string
+	exchange string
+	start    time.Time
+}
+
+// CryptoBarAggregator buffers crypto trades and aggregates them into one
+// minute bars per pair and exchange
+type CryptoBarAggregator struct {
+	exchanges map[string]bool
+
+	mu        sync.Mutex
+	bars      map[cryptoBarKey]*CryptoBar
+	seen      map[string]map[string]bool
+	numTrades int
+}
+
+// NewCryptoBarAggregator creates an aggregator that keeps the trades of the
+// given exchanges, or of every exchange when exchanges is empty
+func NewCryptoBarAggregator(exchanges []string) *CryptoBarAggregator {
+	a := &CryptoBarAggregator{
+		exchanges: make(map[string]bool, len(exchanges)),
+		bars:      make(map[cryptoBarKey]*CryptoBar),
+		seen:      make(map[string]map[string]bool),
+	}
+	for _, exchange := range exchanges {
+		a.exchanges[strings.ToUpper(exchange)] = true
+	}
+	return a
+}
+
+// Add buffers a trade
+func (a *CryptoBarAggregator) Add(trade *CryptoTrade) {
+	if len(a.exchanges) > 0 && !a.exchanges[trade.Exchange] {
+		return
+	}
+	if trade.Price <= 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.numTrades++
+	if a.seen[trade.Ticker] == nil {
+		a.seen[trade.Ticker] = make(map[string]bool)
+	}
+	a.seen[trade.Ticker][trade.Exchange] = true
+
+	key := cryptoBarKey{ticker: trade.Ticker, exchange: trade.Exchange, start: trade.Time.UTC().Truncate(time.Minute)}
+	bar, ok := a.bars[key]
+	if !ok {
+		a.bars[key] = &CryptoBar{
+			Date:      key.start,
+			Ticker:    trade.Ticker,
+			Exchange:  trade.Exchange,
+			Open:      trade.Price,
+			High:      trade.Price,
+			Low:       trade.Price,
+			Close:     trade.Price,
+			Volume:    trade.Size,
+			NumTrades: 1,
+			Source:    CryptoSourceStream,
+			RunID:     common.RunID,
+		}
+		return
+	}
+
+	bar.High = max(bar.High, trade.Price)
+	bar.Low = min(bar.Low, trade.Price)
+	bar.Close = trade.Price
+	bar.Volume += trade.Size
+	bar.NumTrades++
+}
+
+// Flush returns the buffered bars whose minute ended at or before now, or
+// every buffered bar when all is true, ordered by time, pair and exchange
+func (a *CryptoBarAggregator) Flush(now time.Time, all bool) []*CryptoBar {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	bars := []*CryptoBar{}
+	for key, bar := range a.bars {
+		if all || !key.start.Add(time.Minute).After(now) {
+			bars = append(bars, bar)
+			delete(a.bars, key)
+		}
+	}
+
+	sortCryptoBars(bars)
+	return bars
+}
+
+// Exchanges returns the exchanges each pair has traded on
+func (a *CryptoBarAggregator) Exchanges() map[string][]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	exchanges := make(map[string][]string, len(a.seen))
+	for ticker, seen := range a.seen {
+		for exchange := range seen {
+			exchanges[ticker] = append(exchanges[ticker], exchange)
+		}
+		sort.Strings(exchanges[ticker])
+	}
+	return exchanges
+}
+
+// NumTrades returns the number of trades aggregated
+func (a *CryptoBarAggregator) NumTrades() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.numTrades
+}
+
+func sortCryptoBars(bars []*CryptoBar) {
+	sort.Slice(bars, func(i, j int) bool {
+		if !bars[i].Date.Equal(bars[j].Date) {
+			return bars[i].Date.Before(bars[j].Date)
+		}
+		if bars[i].Ticker != bars[j].Ticker {
+			return bars[i].Ticker < bars[j].Ticker
+		}
+		return bars[i].Exchange < bars[j].Exchange
+	})
+}
+
+// cryptoBarResponse is a bar of the crypto prices endpoint
+type cryptoBarResponse struct {
+	Date       time.Time `json:"date"`
+	Open       float64   `json:"open"`
+	High       float64   `json:"high"`
+	Low        float64   `json:"low"`
+	Close      float64   `json:"close"`
+	Volume     float64   `json:"volume"`
+	TradesDone float64   `json:"tradesDone"`
+}
+
+// FetchCryptoBars downloads the one minute bars of each pair on each of its
+// exchanges between from (inclusive) and to (exclusive); it is used to fill
+// gaps in a websocket stream. Pairs that fail are returned as errors.
+func (t *TiingoApi) FetchCryptoBars(ctx context.Context, exchanges map[string][]string, from, to time.Time) ([]*CryptoBar, []*TickerError) {
+	client := t.newClient()
+	var errs errorCollector
+	bars := []*CryptoBar{}
+
+	from = from.UTC().Truncate(time.Minute)
+	to = to.UTC()
+
+	pairs := make([]string, 0, len(exchanges))
+	for pair := range exchanges {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	for _, pair := range pairs {
+		for _, exchange := range exchanges[pair] {
+			if ctx.Err() != nil {
+				log.Warn().Err(ctx.Err()).Msg("download cancelled")
+				return bars, errs.errors
+			}
+
+			t.rate.Take()
+
+			params := url.Values{}
+			params.Set("tickers", pair)
+			params.Set("startDate", from.Format("2006-01-02T15:04:05"))
+			params.Set("endDate", to.Format("2006-01-02T15:04:05"))
+			params.Set("resampleFreq", "1min")
+			params.Set("exchanges", exchange)
+			params.Set("token", t.token)
+
+			resp, err := client.
+				R().
+				SetContext(ctx).
+				SetHeader("Accept", "application/json").
+				Get(t.baseURL + "/tiingo/crypto/prices?" + params.Encode())
+			if err != nil {
+				log.Error().Err(err).Str("Pair", pair).Str("Exchange", exchange).Msg("error when requesting crypto bars")
+				errs.add(pair, 0, fmt.Errorf("%w: %w", ErrRequestFailed, err))
+				continue
+			}
+			if resp.StatusCode() >= 400 {
+				log.Error().Int("StatusCode", resp.StatusCode()).Str("Pair", pair).Str("Exchange", exchange).Bytes("Body", resp.Body()).Msg("error when requesting crypto bars")
+				errs.add(pair, resp.StatusCode(), statusError(resp.StatusCode()))
+				continue
+			}
+
+			var result []*struct {
+				Ticker    string               `json:"ticker"`
+				PriceData []*cryptoBarResponse `json:"priceData"`
+			}
+			if err := json.Unmarshal(resp.Body(), &result); err != nil {
+				log.Error().Err(err).Str("Pair", pair).Msg("could not unmarshal crypto bars json")
+				errs.add(pair, resp.StatusCode(), fmt.Errorf("%w: %v", ErrInvalidResponse, err))
+				continue
+			}
+
+			for _, series := range result {
+				for _, price := range series.PriceData {
+					if price.Date.Before(from) || !price.Date.Before(to) {
+						continue
+					}
+					bars = append(bars, &CryptoBar{
+						Date:      price.Date.UTC(),
+						Ticker:    pair,
+						Exchange:  exchange,
+						Open:      price.Open,
+						High:      price.High,
+						Low:       price.Low,
+						Close:     price.Close,
+						Volume:    price.Volume,
+						NumTrades: int(price.TradesDone),
+						Source:    CryptoSourceREST,
+						RunID:     common.RunID,
+					})
+				}
+			}
+		}
+	}
+
+	sortCryptoBars(bars)
+	return bars, errs.errors
+}
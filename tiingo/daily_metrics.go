@@ -0,0 +1,121 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// DailyMetrics holds the valuation metrics tiingo computes for an asset each
+// trading day. Metrics not reported by tiingo are nil.
+type DailyMetrics struct {
+	Date            time.Time
+	DateStr         string   `json:"date" parquet:"name=date, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Ticker          string   `json:"-" parquet:"name=ticker, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	CompositeFigi   string   `json:"-" parquet:"name=compositeFigi, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	MarketCap       *float64 `json:"marketCap" parquet:"name=market_cap, type=DOUBLE, repetitiontype=OPTIONAL"`
+	EnterpriseValue *float64 `json:"enterpriseVal" parquet:"name=enterprise_value, type=DOUBLE, repetitiontype=OPTIONAL"`
+	PERatio         *float64 `json:"peRatio" parquet:"name=pe_ratio, type=DOUBLE, repetitiontype=OPTIONAL"`
+	PBRatio         *float64 `json:"pbRatio" parquet:"name=pb_ratio, type=DOUBLE, repetitiontype=OPTIONAL"`
+	PEGRatio        *float64 `json:"trailingPEG1Y" parquet:"name=peg_ratio, type=DOUBLE, repetitiontype=OPTIONAL"`
+	RunID           string   `json:"-" parquet:"name=run_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+// FetchDailyMetrics downloads the daily market cap, enterprise value, P/E,
+// P/B and trailing PEG ratios of each asset between startDate and endDate
+// (zero for the latest day), along with an error for each asset whose
+// metrics could not be downloaded
+func (t *TiingoApi) FetchDailyMetrics(ctx context.Context, assets []*common.Asset, startDate, endDate time.Time) ([]*DailyMetrics, []*TickerError) {
+	metrics := []*DailyMetrics{}
+	client := t.newClient()
+	var errs errorCollector
+	startDateStr := startDate.Format("2006-01-02")
+
+	progress := t.progress("metrics", len(assets))
+	defer progress.Finish()
+
+	results := make(chan *DailyMetrics, 100)
+	go func() {
+		defer close(results)
+		t.forEach(ctx, len(assets), func(idx int) {
+			asset := assets[idx]
+
+			// rate limiting
+			t.rate.Take()
+
+			progress.Current(asset.Ticker)
+
+			ticker := strings.ReplaceAll(asset.Ticker, "/", "-")
+			url := fmt.Sprintf("%s/tiingo/fundamentals/%s/daily?startDate=%s&token=%s", t.baseURL, ticker, startDateStr, t.token)
+			if !endDate.IsZero() {
+				url += "&endDate=" + endDate.Format("2006-01-02")
+			}
+			resp, err := client.
+				R().
+				SetContext(ctx).
+				SetHeader("Accept", "application/json").
+				Get(url)
+			if resp != nil {
+				progress.AddBytes(len(resp.Body()))
+			}
+			if err != nil {
+				log.Error().Err(err).Str("Ticker", asset.Ticker).Msg("error when requesting daily metrics")
+				errs.add(asset.Ticker, 0, fmt.Errorf("%w: %w", ErrRequestFailed, err))
+				progress.Error()
+				return
+			}
+			if resp.StatusCode() >= 400 {
+				log.Error().Int("StatusCode", resp.StatusCode()).Str("Ticker", asset.Ticker).Bytes("Body", resp.Body()).Msg("error when requesting daily metrics")
+				errs.add(asset.Ticker, resp.StatusCode(), statusError(resp.StatusCode()))
+				progress.Error()
+				return
+			}
+
+			var days []*DailyMetrics
+			if err = json.Unmarshal(resp.Body(), &days); err != nil {
+				log.Error().Err(err).Str("Ticker", asset.Ticker).Msg("could not unmarshal daily metrics json")
+				errs.add(asset.Ticker, resp.StatusCode(), fmt.Errorf("%w: %v", ErrInvalidResponse, err))
+				progress.Error()
+				return
+			}
+
+			for _, day := range days {
+				day.Ticker = asset.Ticker
+				day.CompositeFigi = asset.CompositeFigi
+				day.RunID = common.RunID
+				if date, err := time.Parse(time.RFC3339, day.DateStr); err == nil {
+					day.Date = date
+				}
+				results <- day
+			}
+
+			progress.Add(1)
+		})
+	}()
+
+	for val := range results {
+		metrics = append(metrics, val)
+	}
+
+	return metrics, errs.errors
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/ratelimit"
+)
+
+// deadlineLimiter stops rate limiting once the deadline has passed so the
+// remaining requests fail immediately instead of waiting for their turn
+type deadlineLimiter struct {
+	deadline time.Time
+	next     ratelimit.Limiter
+}
+
+func (l *deadlineLimiter) Take() time.Time {
+	now := time.Now()
+	if !now.Before(l.deadline) {
+		return now
+	}
+	return l.next.Take()
+}
+
+// deadlineTransport fails requests with ErrDeadline once the deadline has
+// passed and cancels requests still in flight when it is reached
+type deadlineTransport struct {
+	deadline time.Time
+	next     http.RoundTripper
+}
+
+func (d *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !time.Now().Before(d.deadline) {
+		return nil, ErrDeadline
+	}
+
+	ctx, cancel := context.WithDeadline(req.Context(), d.deadline)
+	resp, err := d.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, d.wrap(err)
+	}
+	// the body is read after RoundTrip returns; keep the context alive until
+	// it is closed
+	resp.Body = &deadlineBody{ReadCloser: resp.Body, transport: d, cancel: cancel}
+	return resp, nil
+}
+
+// wrap marks err as caused by the deadline once it has passed
+func (d *deadlineTransport) wrap(err error) error {
+	if time.Now().Before(d.deadline) || errors.Is(err, ErrDeadline) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrDeadline, err)
+}
+
+type deadlineBody struct {
+	io.ReadCloser
+	transport *deadlineTransport
+	cancel    context.CancelFunc
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = b.transport.wrap(err)
+	}
+	return n, err
+}
+
+func (b *deadlineBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Distribution is a cash dividend or other distribution from tiingo's
+// corporate actions endpoint, including distributions that have been
+// announced but have not gone ex yet. Dates tiingo does not report are nil.
+type Distribution struct {
+	Ticker          string     `json:"-"`
+	CompositeFigi   string     `json:"-"`
+	ExDate          time.Time  `json:"exDate"`
+	PaymentDate     *time.Time `json:"paymentDate"`
+	RecordDate      *time.Time `json:"recordDate"`
+	DeclarationDate *time.Time `json:"declarationDate"`
+	Amount          float64    `json:"distribution"`
+	Frequency       string     `json:"distributionFreqency"`
+}
+
+// Split is a stock split from tiingo's corporate actions endpoint; a 2:1
+// split has SplitFrom 1, SplitTo 2 and SplitFactor 2
+type Split struct {
+	Ticker        string    `json:"-"`
+	CompositeFigi string    `json:"-"`
+	ExDate        time.Time `json:"exDate"`
+	SplitFrom     float64   `json:"splitFrom"`
+	SplitTo       float64   `json:"splitTo"`
+	SplitFactor   float64   `json:"splitFactor"`
+	Status        string    `json:"splitStatus"`
+}
+
+// FetchDistributions downloads the distributions of each asset that go ex
+// between startExDate and endExDate (either may be zero for no limit), along
+// with an error for each asset whose distributions could not be downloaded
+func (t *TiingoApi) FetchDistributions(ctx context.Context, assets []*common.Asset, startExDate, endExDate time.Time) ([]*Distribution, []*TickerError) {
+	distributions, errs := fetchCorporateActions[Distribution](ctx, t, assets, "distributions", startExDate, endExDate,
+		func(d *Distribution, asset *common.Asset) {
+			d.Ticker = asset.Ticker
+			d.CompositeFigi = asset.CompositeFigi
+		})
+
+	sort.SliceStable(distributions, func(i, j int) bool {
+		return distributions[i].ExDate.Before(distributions[j].ExDate)
+	})
+	return distributions, errs
+}
+
+// FetchSplits downloads the splits of each asset that go ex between
+// startExDate and endExDate (either may be zero for no limit), along with an
+// error for each asset whose splits could not be downloaded
+func (t *TiingoApi) FetchSplits(ctx context.Context, assets []*common.Asset, startExDate, endExDate time.Time) ([]*Split, []*TickerError) {
+	splits, errs := fetchCorporateActions[Split](ctx, t, assets, "splits", startExDate, endExDate,
+		func(s *Split, asset *common.Asset) {
+			s.Ticker = asset.Ticker
+			s.CompositeFigi = asset.CompositeFigi
+		})
+
+	sort.SliceStable(splits, func(i, j int) bool {
+		return splits[i].ExDate.Before(splits[j].ExDate)
+	})
+	return splits, errs
+}
+
+// fetchCorporateActions downloads the events of the given kind
+// (distributions or splits) of each asset from the corporate actions
+// endpoint; assign fills in the asset of each event
+func fetchCorporateActions[T any](ctx context.Context, t *TiingoApi, assets []*common.Asset, kind string, startExDate, endExDate time.Time, assign func(event *T, asset *common.Asset)) ([]*T, []*TickerError) {
+	events := []*T{}
+	client := t.newClient()
+	var errs errorCollector
+
+	progress := t.progress(kind, len(assets))
+	defer progress.Finish()
+
+	results := make(chan *T, 100)
+	go func() {
+		defer close(results)
+		t.forEach(ctx, len(assets), func(idx int) {
+			asset := assets[idx]
+
+			// rate limiting
+			t.rate.Take()
+
+			progress.Current(asset.Ticker)
+
+			params := url.Values{}
+			if !startExDate.IsZero() {
+				params.Set("startExDate", startExDate.Format("2006-01-02"))
+			}
+			if !endExDate.IsZero() {
+				params.Set("endExDate", endExDate.Format("2006-01-02"))
+			}
+			params.Set("token", t.token)
+
+			ticker := strings.ReplaceAll(asset.Ticker, "/", "-")
+			resp, err := client.
+				R().
+				SetContext(ctx).
+				SetHeader("Accept", "application/json").
+				Get(fmt.Sprintf("%s/tiingo/corporate-actions/%s/%s?%s", t.baseURL, ticker, kind, params.Encode()))
+			if resp != nil {
+				progress.AddBytes(len(resp.Body()))
+			}
+			if err != nil {
+				log.Error().Err(err).Str("Ticker", asset.Ticker).Str("Kind", kind).Msg("error when requesting corporate actions")
+				errs.add(asset.Ticker, 0, fmt.Errorf("%w: %w", ErrRequestFailed, err))
+				progress.Error()
+				return
+			}
+			if resp.StatusCode() >= 400 {
+				log.Error().Int("StatusCode", resp.StatusCode()).Str("Ticker", asset.Ticker).Str("Kind", kind).Bytes("Body", resp.Body()).Msg("error when requesting corporate actions")
+				errs.add(asset.Ticker, resp.StatusCode(), statusError(resp.StatusCode()))
+				progress.Error()
+				return
+			}
+
+			var assetEvents []*T
+			if err = json.Unmarshal(resp.Body(), &assetEvents); err != nil {
+				log.Error().Err(err).Str("Ticker", asset.Ticker).Str("Kind", kind).Msg("could not unmarshal corporate actions json")
+				errs.add(asset.Ticker, resp.StatusCode(), fmt.Errorf("%w: %v", ErrInvalidResponse, err))
+				progress.Error()
+				return
+			}
+
+			for _, event := range assetEvents {
+				assign(event, asset)
+				results <- event
+			}
+
+			progress.Add(1)
+		})
+	}()
+
+	for val := range results {
+		events = append(events, val)
+	}
+
+	return events, errs.errors
+}
@@ -19,217 +19,524 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-resty/resty/v2"
-	"github.com/jackc/pgx/v4"
 	"github.com/penny-vault/import-tiingo/common"
 	"github.com/rs/zerolog/log"
-	"github.com/schollz/progressbar/v3"
-	"github.com/spf13/viper"
-	"github.com/xitongsys/parquet-go-source/local"
-	"github.com/xitongsys/parquet-go/parquet"
-	"github.com/xitongsys/parquet-go/writer"
 	"go.uber.org/ratelimit"
 )
 
 type TiingoApi struct {
-	token string
-	rate  ratelimit.Limiter
+	token   string
+	rate    ratelimit.Limiter
+	otcRate ratelimit.Limiter
+	metrics metricsCollector
+	workers int
+	options Options
+
+	apiCalls atomic.Int64
+	quota    quotaTracker
+	tokens   *tokenPool
+	adaptive *adaptiveLimiter
+	budgets  []*requestBudget
+
+	checkpoint   *Checkpoint
+	quoteHandler QuoteHandler
+
+	baseURL             string
+	supportedTickersURL string
+	streamURL           string
+	cryptoStreamURL     string
+	httpClient          *http.Client
+	restyClient         *resty.Client
+
+	emptyMu sync.Mutex
+	empty   []string
 }
 
+// QuoteHandler receives the quotes of a single asset during a download
+type QuoteHandler func(quotes []*Eod)
+
+// Eod is a daily (or resampled) price bar. Date is the close of the trading
+// session on the asset's exchange; see common.CalendarFor.
 type Eod struct {
-	Date          time.Time
-	DateStr       string  `json:"date" parquet:"name=date, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	Ticker        string  `json:"ticker" parquet:"name=ticker, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	CompositeFigi string  `json:"compositeFigi" parquet:"name=compositeFigi, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	Open          float32 `json:"open" parquet:"name=open, type=FLOAT"`
-	High          float32 `json:"high" parquet:"name=high, type=FLOAT"`
-	Low           float32 `json:"low" parquet:"name=low, type=FLOAT"`
-	Close         float32 `json:"close" parquet:"name=close, type=FLOAT"`
-	Volume        float32 `json:"volume" parquet:"name=volume, type=FLOAT"`
-	Dividend      float32 `json:"divCash" parquet:"name=dividend, type=FLOAT"`
-	Split         float32 `json:"splitFactor" parquet:"name=split, type=FLOAT"`
+	Date          time.Time `json:"date"`
+	Ticker        string    `json:"ticker"`
+	CompositeFigi string    `json:"compositeFigi"`
+	Exchange      string    `json:"exchange"`
+	Currency      string    `json:"currency"`
+	Open          float64   `json:"open"`
+	High          float64   `json:"high"`
+	Low           float64   `json:"low"`
+	Close         float64   `json:"close"`
+	Volume        int64     `json:"volume"`
+	Dividend      float64   `json:"divCash"`
+	Split         float64   `json:"splitFactor"`
+	Frequency     string    `json:"-"`
+
+	// derived values; see ComputeAdjustmentFactors
+	SplitAdjustFactor    float64 `json:"-"`
+	DividendAdjustFactor float64 `json:"-"`
+	AdjustedVolume       *int64  `json:"-"`
+
+	// adjusted prices; see AdjustOptions
+	AdjOpen         *float64 `json:"adjOpen"`
+	AdjHigh         *float64 `json:"adjHigh"`
+	AdjLow          *float64 `json:"adjLow"`
+	AdjClose        *float64 `json:"adjClose"`
+	VendorAdjVolume *float64 `json:"adjVolume"`
+
+	// lineage
+	RunID string `json:"-"`
+}
+
+// eodFields are the JSON fields decoded into an Eod; ignoredEodFields are
+// returned by some endpoints (crypto) but not stored
+var (
+	eodFields        = jsonFields(Eod{})
+	ignoredEodFields = map[string]bool{"volumeNotional": true, "tradesDone": true}
+)
+
+// unexpectedFields records the unknown JSON fields that have been reported so
+// each is only logged once
+var unexpectedFields sync.Map
+
+// UnmarshalJSON decodes a tiingo price bar. The date is parsed into Date
+// (RFC 3339 timestamps and plain YYYY-MM-DD dates are accepted) and volume is
+// stored as a whole number of shares; fractional volumes (e.g. crypto) are
+// rounded. Fields tiingo adds to the response are logged once so schema
+// changes are noticed.
+func (e *Eod) UnmarshalJSON(data []byte) error {
+	type eod Eod
+	aux := struct {
+		*eod
+		Date   string  `json:"date"`
+		Volume float64 `json:"volume"`
+	}{eod: (*eod)(e)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	date, err := parseDate(aux.Date)
+	if err != nil {
+		return err
+	}
+	e.Date = date
+	e.Volume = int64(math.Round(aux.Volume))
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for name := range fields {
+		if eodFields[name] || ignoredEodFields[name] {
+			continue
+		}
+		if _, seen := unexpectedFields.LoadOrStore(name, true); !seen {
+			log.Warn().Str("Field", name).Msg("unexpected field in tiingo price data ... ignoring")
+		}
+	}
+	return nil
 }
 
-func New(token string, rateLimit int) *TiingoApi {
+// parseDate parses the dates returned by tiingo, either RFC 3339 timestamps
+// or YYYY-MM-DD
+func parseDate(value string) (time.Time, error) {
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date '%s'", value)
+	}
+	return date, nil
+}
+
+// jsonFields returns the names of the JSON fields of the struct v
+func jsonFields(v any) map[string]bool {
+	fields := make(map[string]bool)
+	typ := reflect.TypeOf(v)
+	for idx := 0; idx < typ.NumField(); idx++ {
+		name, _, _ := strings.Cut(typ.Field(idx).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// New creates a client for the tiingo API that makes at most rateLimit
+// requests per second
+func New(token string, rateLimit int, options Options, opts ...Option) *TiingoApi {
 	t := &TiingoApi{
-		token: token,
-		rate:  ratelimit.New(rateLimit),
+		token:               token,
+		rate:                ratelimit.New(rateLimit),
+		workers:             options.Workers,
+		options:             options,
+		baseURL:             DefaultBaseURL,
+		supportedTickersURL: SupportedTickersURL,
+		streamURL:           DefaultStreamURL,
+		cryptoStreamURL:     DefaultCryptoStreamURL,
+		budgets:             newRequestBudgets(options),
+	}
+	t.quota.options = options.Quota
+	if options.BaseURL != "" {
+		t.baseURL = strings.TrimSuffix(options.BaseURL, "/")
+	}
+
+	// with several tokens each is limited to rateLimit, so together they
+	// may send that many times as many requests
+	if tokens := newTokenPool(append([]string{token}, options.Tokens...), rateLimit, options); len(tokens.tokens) > 1 {
+		t.tokens = tokens
+		rateLimit *= len(tokens.tokens)
+		t.rate = ratelimit.New(rateLimit)
+	}
+
+	// back off when tiingo responds with 429s and ramp back up to rateLimit
+	if options.AdaptiveRateLimit {
+		t.adaptive = newAdaptiveLimiter(rateLimit, options.MinRateLimit)
+		t.rate = t.adaptive
+	}
+
+	// OTC tickers are rate limited separately (in addition to the global limit)
+	if options.OTC.RateLimit > 0 {
+		t.otcRate = ratelimit.New(options.OTC.RateLimit)
+	}
+
+	// skip the rate limit once the deadline has passed so the remaining
+	// requests fail right away
+	if !options.Deadline.IsZero() {
+		t.rate = &deadlineLimiter{deadline: options.Deadline, next: t.rate}
+		if t.otcRate != nil {
+			t.otcRate = &deadlineLimiter{deadline: options.Deadline, next: t.otcRate}
+		}
+	}
+
+	for _, opt := range opts {
+		opt(t)
 	}
 	return t
 }
 
-func (t *TiingoApi) FetchEodQuotes(assets []*common.Asset, startDate time.Time) []*Eod {
-	nyc, _ := time.LoadLocation("America/New_York")
-	quotes := []*Eod{}
-	client := resty.New()
-	startDateStr := startDate.Format("2006-01-02")
+// EodRequest is a range of quotes to download for an asset. A zero EndDate
+// downloads through the most recent quote.
+type EodRequest struct {
+	Asset     *common.Asset
+	StartDate time.Time
+	EndDate   time.Time
+}
 
-	var bar *progressbar.ProgressBar
-	if !viper.GetBool("display.hide_progress") {
-		bar = progressbar.Default(int64(len(assets)))
+// FetchEodQuotes downloads end-of-day quotes for each asset beginning at
+// startDate and ending at endDate (a zero endDate downloads through the most
+// recent quote). Assets listed in startDates use their mapped start date
+// instead.
+// Tickers that could not be downloaded are returned as a list of errors.
+func (t *TiingoApi) FetchEodQuotes(ctx context.Context, assets []*common.Asset, startDate, endDate time.Time, startDates map[string]time.Time) ([]*Eod, []*TickerError) {
+	requests := make([]*EodRequest, len(assets))
+	for idx, asset := range assets {
+		assetStartDate := startDate
+		if mapped, ok := startDates[strings.ToUpper(asset.Ticker)]; ok {
+			assetStartDate = mapped
+		}
+		requests[idx] = &EodRequest{Asset: asset, StartDate: assetStartDate, EndDate: endDate}
 	}
-	chans := make([]chan Eod, 0, len(assets))
-	for _, asset := range assets {
-		// rate limiting
-		t.rate.Take()
 
-		// update progress
-		if bar != nil {
-			bar.Add(1)
+	var onAsset func(*EodRequest, []Eod)
+	if t.quoteHandler != nil {
+		// each asset's full series is available once its request completes,
+		// so adjustments can be computed before the whole download finishes
+		onAsset = func(request *EodRequest, series []Eod) {
+			if len(series) == 0 {
+				return
+			}
+			assetQuotes := make([]*Eod, len(series))
+			for idx := range series {
+				q := series[idx]
+				assetQuotes[idx] = &q
+			}
+			t.quoteHandler(t.prepareEodQuotes(assetQuotes))
 		}
+	}
+
+	quotes, errs := t.fetchEodRanges(ctx, requests, onAsset)
+	return t.prepareEodQuotes(quotes), errs
+}
+
+// prepareEodQuotes trims zero volume quotes when configured and computes
+// adjustment factors
+func (t *TiingoApi) prepareEodQuotes(quotes []*Eod) []*Eod {
+	// without the volume column every quote has zero volume
+	if t.options.TrimZeroVolume && HasColumn(t.options.Columns, "volume") {
+		quotes = TrimZeroVolume(quotes)
+	}
+
+	ComputeAdjustmentFactors(quotes, t.options.Adjust)
+	return quotes
+}
+
+// SetQuoteHandler registers a handler that FetchEodQuotes calls with each
+// asset's quotes as soon as they are downloaded, e.g. to stream them to a
+// message broker. The handler is called concurrently from download workers.
+func (t *TiingoApi) SetQuoteHandler(handler QuoteHandler) {
+	t.quoteHandler = handler
+}
+
+// FetchEodRanges downloads the requested ranges of end-of-day quotes. Unlike
+// FetchEodQuotes adjustment factors are not computed; callers downloading
+// partial histories must compute them relative to the surrounding data.
+func (t *TiingoApi) FetchEodRanges(ctx context.Context, requests []*EodRequest) ([]*Eod, []*TickerError) {
+	return t.fetchEodRanges(ctx, requests, nil)
+}
+
+// fetchEodRanges downloads the requested ranges; onAsset, when not nil, is
+// called with the quotes of each successfully completed request
+func (t *TiingoApi) fetchEodRanges(ctx context.Context, requests []*EodRequest, onAsset func(*EodRequest, []Eod)) ([]*Eod, []*TickerError) {
+	quotes := []*Eod{}
+	client := t.newClient()
+	frequency := t.options.Frequency
+	if frequency == "" {
+		frequency = FrequencyDaily
+	}
+	columns := columnsParam(t.options.Columns)
+	var errs errorCollector
+
+	progress := t.progress("download", len(requests))
+	defer progress.Finish()
+
+	results := make(chan Eod, 100)
+	go func() {
+		defer close(results)
+		t.forEach(ctx, len(requests), func(idx int) {
+			request := requests[idx]
+			asset := request.Asset
+
+			// don't request ranges without a trading session
+			cal := common.CalendarFor(asset.PrimaryExchange)
+			endDate := request.EndDate
+			if endDate.IsZero() {
+				endDate = time.Now()
+			}
+			if len(cal.TradingDays(request.StartDate.In(cal.Location), endDate.In(cal.Location))) == 0 {
+				log.Debug().Str("Ticker", asset.Ticker).Str("StartDate", request.StartDate.Format("2006-01-02")).Msg("market closed for the requested range ... skipping")
+				progress.Add(1)
+				if onAsset != nil {
+					onAsset(request, nil)
+				}
+				return
+			}
+
+			// skip requests completed by a previous run
+			if t.checkpoint != nil {
+				if completed, ok := t.checkpoint.Completed(checkpointKey(request, frequency)); ok {
+					progress.Add(1)
+					restored := make([]Eod, len(completed))
+					for idx, q := range completed {
+						// the frequency is not journaled; it is part of the key
+						q.Frequency = frequency
+						q.RunID = common.RunID
+						restored[idx] = q
+					}
+					if onAsset != nil {
+						onAsset(request, restored)
+					}
+					for _, q := range restored {
+						results <- q
+					}
+					return
+				}
+			}
 
-		// run download in parallel
-		resultChan := make(chan Eod, 10)
-		chans = append(chans, resultChan)
+			// rate limiting
+			if asset.IsOTC() && t.otcRate != nil {
+				t.otcRate.Take()
+			}
+			t.rate.Take()
+
+			progress.Current(asset.Ticker)
 
-		go func(myAsset *common.Asset, myResultChan chan Eod) {
-			defer close(myResultChan)
 			// translate ticker to Tiingo ticker format; i.e. / turns to -
-			ticker := strings.ReplaceAll(myAsset.Ticker, "/", "-")
-			url := fmt.Sprintf("https://api.tiingo.com/tiingo/daily/%s/prices?startDate=%s&token=%s", ticker, startDateStr, t.token)
-			resp, err := client.
+			ticker := strings.ReplaceAll(asset.Ticker, "/", "-")
+			url := fmt.Sprintf("%s/tiingo/daily/%s/prices?startDate=%s&token=%s", t.baseURL, ticker, request.StartDate.Format("2006-01-02"), t.token)
+			if !request.EndDate.IsZero() {
+				url += "&endDate=" + request.EndDate.Format("2006-01-02")
+			}
+			if frequency != FrequencyDaily {
+				url += "&resampleFreq=" + frequency
+			}
+			if columns != "" {
+				url += "&columns=" + columns
+			}
+			requestStart := time.Now()
+			req := client.
 				R().
-				SetHeader("Accept", "application/json").
-				Get(url)
+				SetContext(ctx).
+				SetHeader("Accept", "application/json")
+			resp, err := req.Get(url)
+			metrics := &RequestMetrics{
+				Ticker:  asset.Ticker,
+				Latency: time.Since(requestStart),
+				Retries: max(req.Attempt-1, 0),
+			}
+			defer t.metrics.record(metrics)
+			if resp != nil {
+				progress.AddBytes(len(resp.Body()))
+			}
 			if err != nil {
-				log.Error().Err(err).Str("Url", url).Msg("error when requesting eod quote")
+				metrics.Error = err.Error()
+				log.Error().Err(err).Str("Ticker", asset.Ticker).Msg("error when requesting eod quote")
+				errs.add(asset.Ticker, 0, fmt.Errorf("%w: %w", ErrRequestFailed, err))
+				progress.Error()
+				return
+			}
+			metrics.StatusCode = resp.StatusCode()
+			metrics.Bytes = len(resp.Body())
+
+			if resp.StatusCode() == 404 && asset.IsOTC() {
+				// OTC tickers frequently 404; don't treat those as errors
+				log.Warn().Str("Ticker", asset.Ticker).Str("PrimaryExchange", asset.PrimaryExchange).Msg("OTC ticker not found")
+				t.recordEmpty(asset.Ticker)
+				if t.checkpoint != nil {
+					t.checkpoint.Record(checkpointKey(request, frequency), nil)
+				}
+				if onAsset != nil {
+					onAsset(request, nil)
+				}
+				progress.Add(1)
 				return
 			}
 			if resp.StatusCode() >= 400 {
-				log.Error().Int("StatusCode", resp.StatusCode()).Str("Url", url).Bytes("Body", resp.Body()).Msg("error when requesting eod quote")
+				log.Error().Int("StatusCode", resp.StatusCode()).Str("Ticker", asset.Ticker).Bytes("Body", resp.Body()).Msg("error when requesting eod quote")
+				errs.add(asset.Ticker, resp.StatusCode(), statusError(resp.StatusCode()))
+				progress.Error()
 				return
 			}
 			data := resp.Body()
 			var quote []Eod
 			if err = json.Unmarshal(data, &quote); err != nil {
-				log.Error().Err(err).Str("Ticker", myAsset.Ticker).Msg("could not unmarshal json")
+				log.Error().Err(err).Str("Ticker", asset.Ticker).Msg("could not unmarshal json")
+				errs.add(asset.Ticker, resp.StatusCode(), fmt.Errorf("%w: %v", ErrInvalidResponse, err))
+				progress.Error()
 			} else {
+				isOTC := asset.IsOTC()
+				metrics.NumBars = len(quote)
+				if len(quote) == 0 {
+					t.recordEmpty(asset.Ticker)
+				}
+				accepted := make([]Eod, 0, len(quote))
 				for _, q := range quote {
-					q.Ticker = myAsset.Ticker
-					q.CompositeFigi = myAsset.CompositeFigi
-					date, err := time.Parse(time.RFC3339, q.DateStr)
-					if err == nil {
-						q.Date = time.Date(date.Year(), date.Month(), date.Day(), 16, 0, 0, 0, nyc)
+					if isOTC && !t.passesOTCThresholds(&q) {
+						continue
+					}
+					q.Ticker = asset.Ticker
+					q.CompositeFigi = asset.CompositeFigi
+					q.Exchange = asset.PrimaryExchange
+					q.Currency = asset.QuoteCurrency()
+					q.Frequency = frequency
+					q.RunID = common.RunID
+					q.Date = cal.CloseTime(q.Date)
+					keep, err := t.checkTimestamp(&q, request.StartDate)
+					if err != nil {
+						log.Error().Err(err).Str("Ticker", asset.Ticker).Str("Policy", TimestampFail).Msg("quote outside the requested window")
+						errs.add(asset.Ticker, resp.StatusCode(), err)
+						progress.Error()
+						return
+					}
+					if keep {
+						accepted = append(accepted, q)
 					}
-					myResultChan <- q
 				}
+
+				if t.checkpoint != nil {
+					t.checkpoint.Record(checkpointKey(request, frequency), accepted)
+				}
+				if onAsset != nil {
+					onAsset(request, accepted)
+				}
+				for _, q := range accepted {
+					results <- q
+				}
+				progress.Add(1)
 			}
-		}(asset, resultChan)
+		})
+	}()
+
+	for val := range results {
+		copy := val
+		quotes = append(quotes, &copy)
 	}
 
-	for _, ch := range chans {
-		// read individual eod values
-		for val := range ch {
-			copy := val
-			quotes = append(quotes, &copy)
+	// workers finish in any order; keep each asset's quotes together
+	sort.SliceStable(quotes, func(i, j int) bool {
+		if quotes[i].Ticker != quotes[j].Ticker {
+			return quotes[i].Ticker < quotes[j].Ticker
 		}
-	}
+		return quotes[i].Date.Before(quotes[j].Date)
+	})
 
-	return quotes
+	return quotes, errs.errors
 }
 
-// SaveToParquet saves EOD quotes to a parquet file
-func SaveToParquet(records []*Eod, fn string) error {
-	var err error
+// Timestamp policies; an empty policy warns
+const (
+	TimestampWarn = "warn"
+	TimestampDrop = "drop"
+	TimestampFail = "fail"
+)
 
-	fh, err := local.NewLocalFileWriter(fn)
-	if err != nil {
-		log.Error().Err(err).Str("FileName", fn).Msg("cannot create local file")
-		return err
-	}
-	defer fh.Close()
+// TimestampPolicies lists the supported values of Options.TimestampPolicy
+var TimestampPolicies = []string{TimestampWarn, TimestampDrop, TimestampFail}
 
-	pw, err := writer.NewParquetWriter(fh, new(Eod), 4)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Msg("Parquet write failed")
-		return err
-	}
+// ValidTimestampPolicy returns true if policy is empty or one of
+// TimestampPolicies
+func ValidTimestampPolicy(policy string) bool {
+	return policy == "" || slices.Contains(TimestampPolicies, policy)
+}
 
-	pw.RowGroupSize = 128 * 1024 * 1024 // 128M
-	pw.PageSize = 8 * 1024              // 8k
-	pw.CompressionType = parquet.CompressionCodec_GZIP
+// checkTimestamp flags quotes dated in the future or before the requested
+// window (beyond the configured tolerance). Depending on the timestamp policy
+// the quote is kept with a warning or dropped; with TimestampFail an
+// ErrInvalidTimestamp error is returned and the ticker fails. Returns false
+// if the quote should be dropped.
+func (t *TiingoApi) checkTimestamp(quote *Eod, startDate time.Time) (bool, error) {
+	tolerance := t.options.TimestampTolerance
+	now := time.Now()
 
-	for _, r := range records {
-		if err = pw.Write(r); err != nil {
-			log.Error().
-				Err(err).
-				Str("EventDate", r.DateStr).
-				Str("Ticker", r.Ticker).
-				Str("CompositeFigi", r.CompositeFigi).
-				Msg("Parquet write failed for record")
-		}
+	var reason string
+	switch {
+	case quote.Date.IsZero():
+		reason = "quote has an invalid date"
+	case quote.Date.After(now.Add(tolerance)):
+		reason = "quote is dated in the future"
+	case quote.Date.Before(startDate.Add(-tolerance)):
+		reason = "quote is dated before the requested window"
+	default:
+		return true, nil
 	}
 
-	if err = pw.WriteStop(); err != nil {
-		log.Error().Err(err).Msg("Parquet write failed")
-		return err
+	policy := t.options.TimestampPolicy
+	if policy == TimestampFail {
+		return false, fmt.Errorf("%w: %s (%s)", ErrInvalidTimestamp, reason, quote.Date.Format("2006-01-02"))
 	}
+	log.Warn().Str("Ticker", quote.Ticker).Str("Date", quote.Date.Format("2006-01-02")).Str("StartDate", startDate.Format("2006-01-02")).Str("Policy", policy).Msg(reason)
 
-	log.Info().Int("NumRecords", len(records)).Msg("Parquet write finished")
-	return nil
+	return policy != TimestampDrop, nil
 }
 
-// SaveToDatabase saves EOD quotes to the penny vault database
-func SaveToDatabase(quotes []*Eod) error {
-	log.Info().Msg("saving to database")
-	conn, err := pgx.Connect(context.Background(), viper.GetString("database.url"))
-	if err != nil {
-		log.Error().Err(err).Msg("Could not connect to database")
-	}
-	defer conn.Close(context.Background())
-
-	for _, quote := range quotes {
-		_, err := conn.Exec(context.Background(),
-			`INSERT INTO eod (
-			"ticker",
-			"composite_figi",
-			"event_date",
-			"open",
-			"high",
-			"low",
-			"close",
-			"volume",
-			"dividend",
-			"split_factor",
-			"source"
-		) VALUES (
-			$1,
-			$2,
-			$3,
-			$4,
-			$5,
-			$6,
-			$7,
-			$8,
-			$9,
-			$10,
-			$11
-		) ON CONFLICT ON CONSTRAINT eod_pkey
-		DO UPDATE SET
-			open = EXCLUDED.open,
-			high = EXCLUDED.high,
-			low = EXCLUDED.low,
-			close = EXCLUDED.close,
-			volume = EXCLUDED.volume,
-			dividend = EXCLUDED.dividend,
-			split_factor = EXCLUDED.split_factor,
-			source = EXCLUDED.source;`,
-			quote.Ticker, quote.CompositeFigi, quote.Date,
-			quote.Open, quote.High, quote.Low, quote.Close, quote.Volume,
-			quote.Dividend, quote.Split, "api.tiingo.com")
-		if err != nil {
-			query := fmt.Sprintf(`INSERT INTO eod_v1 ("ticker", "composite_figi", "event_date", "open", "high", "low", "close", "volume", "dividend", "split_factor", "source") VALUES ('%s', '%s', '%s', %.5f, %.5f, %.5f, %.5f, %d, %.5f, %.5f, '%s') ON CONFLICT ON CONSTRAINT eod_v1_pkey DO UPDATE SET open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low, close = EXCLUDED.close, volume = EXCLUDED.volume, dividend = EXCLUDED.dividend, split_factor = EXCLUDED.split_factor, source = EXCLUDED.source;`,
-				quote.Ticker, quote.CompositeFigi, quote.Date,
-				quote.Open, quote.High, quote.Low, quote.Close, int(quote.Volume),
-				quote.Dividend, quote.Split, "api.tiingo.com")
-			log.Error().Err(err).Str("Query", query).Msg("error saving EOD quote to database")
-		}
+// passesOTCThresholds checks OTC quotes against the configured minimum price
+// and volume; OTC quotes are frequently stale prints that should be ignored
+func (t *TiingoApi) passesOTCThresholds(quote *Eod) bool {
+	if quote.Close < t.options.OTC.MinPrice || quote.Volume < t.options.OTC.MinVolume {
+		log.Debug().Str("Date", quote.Date.Format("2006-01-02")).Float64("Close", quote.Close).Int64("Volume", quote.Volume).Msg("OTC quote below threshold ... skipping")
+		return false
 	}
-
-	return nil
+	return true
 }
@@ -0,0 +1,105 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+)
+
+func TestFetchEodQuotes(t *testing.T) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		switch r.URL.Path {
+		case "/tiingo/daily/BRK-B/prices":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`[
+				{"date":"2024-01-02T00:00:00.000Z","open":362.5,"high":363.9,"low":360.1,"close":362.6,"volume":3712100,
+				 "adjOpen":362.5,"adjHigh":363.9,"adjLow":360.1,"adjClose":362.6,"adjVolume":3712100,"divCash":0.0,"splitFactor":1.0},
+				{"date":"2024-01-03T00:00:00.000Z","open":361.0,"high":362.0,"low":358.2,"close":359.1,"volume":4113700,
+				 "adjOpen":361.0,"adjHigh":362.0,"adjLow":358.2,"adjClose":359.1,"adjVolume":4113700,"divCash":0.25,"splitFactor":2.0}
+			]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := New("secret-token", 1000, Options{BaseURL: server.URL, Adjust: AdjustOptions{Prices: AdjustTiingo}})
+	assets := []*common.Asset{
+		{Ticker: "BRK/B", CompositeFigi: "BBG000MM2P62", PrimaryExchange: "NYSE"},
+		{Ticker: "MISSING", CompositeFigi: "BBG000000000", PrimaryExchange: "NASDAQ"},
+	}
+	startDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	quotes, errs := client.FetchEodQuotes(context.Background(), assets, startDate, endDate, nil)
+
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	for _, r := range requests {
+		query := r.URL.Query()
+		if got := query.Get("token"); got != "secret-token" {
+			t.Errorf("%s: expected token secret-token, got %q", r.URL.Path, got)
+		}
+		if got := query.Get("startDate"); got != "2024-01-01" {
+			t.Errorf("%s: expected startDate 2024-01-01, got %q", r.URL.Path, got)
+		}
+		if got := query.Get("endDate"); got != "2024-01-05" {
+			t.Errorf("%s: expected endDate 2024-01-05, got %q", r.URL.Path, got)
+		}
+		if query.Has("resampleFreq") {
+			t.Errorf("%s: daily quotes should not be resampled", r.URL.Path)
+		}
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 ticker error, got %d", len(errs))
+	}
+	if errs[0].Ticker != "MISSING" || errs[0].StatusCode != http.StatusNotFound || !errors.Is(errs[0].Err, ErrNotFound) {
+		t.Errorf("expected MISSING to fail with ErrNotFound, got %s %d %v", errs[0].Ticker, errs[0].StatusCode, errs[0].Err)
+	}
+
+	if len(quotes) != 2 {
+		t.Fatalf("expected 2 quotes, got %d", len(quotes))
+	}
+	quote := quotes[1]
+	// dates are moved to the close of the trading day
+	nyc, _ := time.LoadLocation("America/New_York")
+	if !quote.Date.Equal(time.Date(2024, 1, 3, 16, 0, 0, 0, nyc)) {
+		t.Errorf("expected date 2024-01-03 16:00 New York, got %s", quote.Date)
+	}
+	if quote.Ticker != "BRK/B" || quote.CompositeFigi != "BBG000MM2P62" || quote.Exchange != "NYSE" {
+		t.Errorf("expected the asset to be copied onto the quote, got %s %s %s", quote.Ticker, quote.CompositeFigi, quote.Exchange)
+	}
+	if quote.Open != 361.0 || quote.High != 362.0 || quote.Low != 358.2 || quote.Close != 359.1 || quote.Volume != 4113700 {
+		t.Errorf("unexpected prices %+v", quote)
+	}
+	if quote.Dividend != 0.25 || quote.Split != 2.0 {
+		t.Errorf("expected divCash 0.25 and splitFactor 2, got %v %v", quote.Dividend, quote.Split)
+	}
+	if quote.AdjClose == nil || *quote.AdjClose != 359.1 {
+		t.Errorf("expected adjClose 359.1, got %v", quote.AdjClose)
+	}
+}
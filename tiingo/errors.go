@@ -0,0 +1,90 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// Errors returned (wrapped in a TickerError) when downloading a ticker fails.
+// Use errors.Is to determine the kind of failure.
+var (
+	ErrUnauthorized     = errors.New("unauthorized")
+	ErrRateLimited      = errors.New("rate limited")
+	ErrNotFound         = errors.New("not found")
+	ErrServer           = errors.New("server error")
+	ErrRequestFailed    = errors.New("request failed")
+	ErrInvalidResponse  = errors.New("invalid response")
+	ErrInvalidTimestamp = errors.New("quote outside the requested window")
+	ErrDeadline         = errors.New("run deadline exceeded")
+)
+
+// TickerError describes why the download of a single ticker failed
+type TickerError struct {
+	Ticker     string
+	StatusCode int
+	Err        error
+}
+
+// tokenPattern matches the API token in request URLs embedded in error messages
+var tokenPattern = regexp.MustCompile(`token=[^&"\s]+`)
+
+// Reason returns the underlying error message with the API token redacted so
+// it is safe to include in reports
+func (e *TickerError) Reason() string {
+	return tokenPattern.ReplaceAllString(e.Err.Error(), "token=REDACTED")
+}
+
+func (e *TickerError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s: %s (status %d)", e.Ticker, e.Err, e.StatusCode)
+	}
+	return fmt.Sprintf("%s: %s", e.Ticker, e.Err)
+}
+
+func (e *TickerError) Unwrap() error {
+	return e.Err
+}
+
+// statusError maps an HTTP status code to one of the typed errors
+func statusError(statusCode int) error {
+	switch {
+	case statusCode == 401 || statusCode == 403:
+		return ErrUnauthorized
+	case statusCode == 404:
+		return ErrNotFound
+	case statusCode == 429:
+		return ErrRateLimited
+	case statusCode >= 500:
+		return ErrServer
+	default:
+		return ErrRequestFailed
+	}
+}
+
+type errorCollector struct {
+	mu     sync.Mutex
+	errors []*TickerError
+}
+
+func (c *errorCollector) add(ticker string, statusCode int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors = append(c.errors, &TickerError{Ticker: ticker, StatusCode: statusCode, Err: err})
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"sort"
+
+	"github.com/rs/zerolog/log"
+)
+
+// GroupByAsset splits quotes by asset (composite figi, falling back to
+// ticker) with each group sorted by date ascending
+func GroupByAsset(quotes []*Eod) map[string][]*Eod {
+	byAsset := make(map[string][]*Eod)
+	for _, quote := range quotes {
+		key := quote.CompositeFigi
+		if key == "" {
+			key = quote.Ticker
+		}
+		byAsset[key] = append(byAsset[key], quote)
+	}
+
+	for _, assetQuotes := range byAsset {
+		sort.Slice(assetQuotes, func(i, j int) bool {
+			return assetQuotes[i].Date.Before(assetQuotes[j].Date)
+		})
+	}
+
+	return byAsset
+}
+
+// TrimZeroVolume removes runs of zero-volume placeholder bars at the start
+// and end of each asset's series. These are commonly reported before an asset
+// lists or after it is delisted and distort liquidity statistics.
+func TrimZeroVolume(quotes []*Eod) []*Eod {
+	trimmed := make([]*Eod, 0, len(quotes))
+	for _, assetQuotes := range GroupByAsset(quotes) {
+		first := 0
+		for first < len(assetQuotes) && assetQuotes[first].Volume == 0 {
+			first++
+		}
+
+		last := len(assetQuotes) - 1
+		for last >= first && assetQuotes[last].Volume == 0 {
+			last--
+		}
+
+		if numRemoved := len(assetQuotes) - (last - first + 1); numRemoved > 0 {
+			log.Debug().Str("Ticker", assetQuotes[0].Ticker).Int("NumRemoved", numRemoved).Msg("trimmed zero-volume padding bars")
+		}
+
+		trimmed = append(trimmed, assetQuotes[first:last+1]...)
+	}
+
+	return trimmed
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+// Bar frequencies supported by tiingo's resampleFreq parameter on the daily
+// prices endpoint
+const (
+	FrequencyDaily    = "daily"
+	FrequencyWeekly   = "weekly"
+	FrequencyMonthly  = "monthly"
+	FrequencyAnnually = "annually"
+)
+
+// Frequencies lists the supported values of Options.Frequency
+var Frequencies = []string{FrequencyDaily, FrequencyWeekly, FrequencyMonthly, FrequencyAnnually}
+
+// ValidFrequency returns true if frequency is one of Frequencies
+func ValidFrequency(frequency string) bool {
+	for _, valid := range Frequencies {
+		if frequency == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// EodTable returns the table quotes of the given frequency are stored in.
+// Daily quotes are stored in eod and resampled bars in a table of the same
+// shape per frequency (eod_weekly, eod_monthly, eod_annually) so queries of
+// the daily history are unaffected.
+func EodTable(frequency string) string {
+	if frequency == "" || frequency == FrequencyDaily {
+		return "eod"
+	}
+	return "eod_" + frequency
+}
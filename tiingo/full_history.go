@@ -0,0 +1,205 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultFullHistoryChunk is the length of each request made when
+// downloading an asset's full history
+const DefaultFullHistoryChunk = 5 * 365 * 24 * time.Hour
+
+// TickerMeta is the description of a ticker returned by tiingo's meta
+// endpoint; StartDate and EndDate bound the available price history
+type TickerMeta struct {
+	Ticker       string `json:"ticker"`
+	Name         string `json:"name"`
+	ExchangeCode string `json:"exchangeCode"`
+	Description  string `json:"description"`
+	StartDateStr string `json:"startDate"`
+	EndDateStr   string `json:"endDate"`
+	StartDate    time.Time
+	EndDate      time.Time
+}
+
+// FetchTickerMeta downloads the meta data of each asset keyed by ticker;
+// tickers that could not be looked up are returned as errors
+func (t *TiingoApi) FetchTickerMeta(ctx context.Context, assets []*common.Asset) (map[string]*TickerMeta, []*TickerError) {
+	client := t.newClient()
+	var errs errorCollector
+	var mu sync.Mutex
+	meta := make(map[string]*TickerMeta, len(assets))
+
+	progress := t.progress("meta", len(assets))
+	defer progress.Finish()
+
+	t.forEach(ctx, len(assets), func(idx int) {
+		asset := assets[idx]
+
+		t.rate.Take()
+		progress.Current(asset.Ticker)
+
+		ticker := strings.ReplaceAll(asset.Ticker, "/", "-")
+		url := fmt.Sprintf("%s/tiingo/daily/%s?token=%s", t.baseURL, ticker, t.token)
+		resp, err := client.
+			R().
+			SetContext(ctx).
+			SetHeader("Accept", "application/json").
+			Get(url)
+		if resp != nil {
+			progress.AddBytes(len(resp.Body()))
+		}
+		if err != nil {
+			log.Error().Err(err).Str("Ticker", asset.Ticker).Msg("error when requesting ticker meta data")
+			errs.add(asset.Ticker, 0, fmt.Errorf("%w: %w", ErrRequestFailed, err))
+			progress.Error()
+			return
+		}
+		if resp.StatusCode() >= 400 {
+			log.Error().Int("StatusCode", resp.StatusCode()).Str("Ticker", asset.Ticker).Bytes("Body", resp.Body()).Msg("error when requesting ticker meta data")
+			errs.add(asset.Ticker, resp.StatusCode(), statusError(resp.StatusCode()))
+			progress.Error()
+			return
+		}
+
+		tickerMeta := &TickerMeta{}
+		if err := json.Unmarshal(resp.Body(), tickerMeta); err != nil {
+			log.Error().Err(err).Str("Ticker", asset.Ticker).Msg("could not unmarshal ticker meta json")
+			errs.add(asset.Ticker, resp.StatusCode(), fmt.Errorf("%w: %v", ErrInvalidResponse, err))
+			progress.Error()
+			return
+		}
+		if date, err := time.Parse("2006-01-02", tickerMeta.StartDateStr); err == nil {
+			tickerMeta.StartDate = date
+		}
+		if date, err := time.Parse("2006-01-02", tickerMeta.EndDateStr); err == nil {
+			tickerMeta.EndDate = date
+		}
+
+		mu.Lock()
+		meta[asset.Ticker] = tickerMeta
+		mu.Unlock()
+
+		progress.Add(1)
+	})
+
+	return meta, errs.errors
+}
+
+// FetchFullHistory downloads the entire price history of each asset, from
+// the start date reported by the meta endpoint through endDate (zero for the
+// most recent quote), in requests of at most chunk each. Chunks are
+// journaled to the checkpoint so an interrupted load resumes where it
+// stopped. Adjustment factors are computed over the complete series.
+func (t *TiingoApi) FetchFullHistory(ctx context.Context, assets []*common.Asset, endDate time.Time, chunk time.Duration) ([]*Eod, []*TickerError) {
+	if chunk < 24*time.Hour {
+		chunk = DefaultFullHistoryChunk
+	}
+
+	meta, errs := t.FetchTickerMeta(ctx, assets)
+	if ctx.Err() != nil {
+		return []*Eod{}, errs
+	}
+
+	requests := []*EodRequest{}
+	numChunks := make(map[string]int, len(assets))
+	for _, asset := range assets {
+		tickerMeta, ok := meta[asset.Ticker]
+		if !ok {
+			continue
+		}
+		if tickerMeta.StartDate.IsZero() {
+			log.Warn().Str("Ticker", asset.Ticker).Msg("tiingo has no price history for ticker ... skipping")
+			continue
+		}
+		chunks := historyChunks(asset, tickerMeta.StartDate, endDate, chunk)
+		if len(chunks) == 0 {
+			continue
+		}
+		requests = append(requests, chunks...)
+		numChunks[asset.Ticker] = len(chunks)
+	}
+
+	log.Info().Int("NumAssets", len(numChunks)).Int("NumRequests", len(requests)).Msg("downloading full history")
+
+	// report each ticker as soon as all of its chunks are downloaded
+	var mu sync.Mutex
+	completed := make(map[string]int, len(numChunks))
+	numQuotes := make(map[string]int, len(numChunks))
+	series := make(map[string][]Eod)
+	onRequest := func(request *EodRequest, quotes []Eod) {
+		ticker := request.Asset.Ticker
+		mu.Lock()
+		completed[ticker]++
+		numQuotes[ticker] += len(quotes)
+		if t.quoteHandler != nil {
+			series[ticker] = append(series[ticker], quotes...)
+		}
+		done := completed[ticker] == numChunks[ticker]
+		assetQuotes := series[ticker]
+		if done {
+			delete(series, ticker)
+		}
+		total := numQuotes[ticker]
+		mu.Unlock()
+
+		if !done {
+			return
+		}
+		log.Info().Str("Ticker", ticker).Int("NumChunks", numChunks[ticker]).Int("NumQuotes", total).Msg("downloaded full history")
+
+		if t.quoteHandler != nil && len(assetQuotes) > 0 {
+			handlerQuotes := make([]*Eod, len(assetQuotes))
+			for idx := range assetQuotes {
+				q := assetQuotes[idx]
+				handlerQuotes[idx] = &q
+			}
+			t.quoteHandler(t.prepareEodQuotes(handlerQuotes))
+		}
+	}
+
+	quotes, fetchErrs := t.fetchEodRanges(ctx, requests, onRequest)
+	return t.prepareEodQuotes(quotes), append(errs, fetchErrs...)
+}
+
+// historyChunks splits the range from startDate through endDate into
+// requests of at most chunk each; chunk boundaries only depend on startDate
+// so they match the checkpoint of a previous run. The last request is open
+// ended when endDate is zero.
+func historyChunks(asset *common.Asset, startDate, endDate time.Time, chunk time.Duration) []*EodRequest {
+	requests := []*EodRequest{}
+	if !endDate.IsZero() && endDate.Before(startDate) {
+		return requests
+	}
+	for chunkStart := startDate; ; {
+		chunkEnd := chunkStart.Add(chunk).AddDate(0, 0, -1)
+		if (endDate.IsZero() && chunkEnd.After(time.Now())) || (!endDate.IsZero() && !chunkEnd.Before(endDate)) {
+			requests = append(requests, &EodRequest{Asset: asset, StartDate: chunkStart, EndDate: endDate})
+			return requests
+		}
+		requests = append(requests, &EodRequest{Asset: asset, StartDate: chunkStart, EndDate: chunkEnd})
+		chunkStart = chunkEnd.AddDate(0, 0, 1)
+	}
+}
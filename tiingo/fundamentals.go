@@ -0,0 +1,266 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Fundamentals holds a single quarterly or annual financial statement. A
+// Quarter of 0 indicates an annual statement. Fields not reported by tiingo
+// are nil.
+type Fundamentals struct {
+	Date          time.Time
+	DateStr       string `json:"date" parquet:"name=date, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Ticker        string `json:"ticker" parquet:"name=ticker, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	CompositeFigi string `json:"compositeFigi" parquet:"name=compositeFigi, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Year          int32  `json:"year" parquet:"name=year, type=INT32"`
+	Quarter       int32  `json:"quarter" parquet:"name=quarter, type=INT32"`
+
+	// income statement
+	Revenue                  *float64 `parquet:"name=revenue, type=DOUBLE, repetitiontype=OPTIONAL"`
+	CostOfRevenue            *float64 `parquet:"name=cost_of_revenue, type=DOUBLE, repetitiontype=OPTIONAL"`
+	GrossProfit              *float64 `parquet:"name=gross_profit, type=DOUBLE, repetitiontype=OPTIONAL"`
+	OperatingExpenses        *float64 `parquet:"name=operating_expenses, type=DOUBLE, repetitiontype=OPTIONAL"`
+	OperatingIncome          *float64 `parquet:"name=operating_income, type=DOUBLE, repetitiontype=OPTIONAL"`
+	ResearchAndDevelopment   *float64 `parquet:"name=research_and_development, type=DOUBLE, repetitiontype=OPTIONAL"`
+	SellingGeneralAdmin      *float64 `parquet:"name=selling_general_admin, type=DOUBLE, repetitiontype=OPTIONAL"`
+	InterestExpense          *float64 `parquet:"name=interest_expense, type=DOUBLE, repetitiontype=OPTIONAL"`
+	TaxExpense               *float64 `parquet:"name=tax_expense, type=DOUBLE, repetitiontype=OPTIONAL"`
+	Ebit                     *float64 `parquet:"name=ebit, type=DOUBLE, repetitiontype=OPTIONAL"`
+	Ebitda                   *float64 `parquet:"name=ebitda, type=DOUBLE, repetitiontype=OPTIONAL"`
+	NetIncome                *float64 `parquet:"name=net_income, type=DOUBLE, repetitiontype=OPTIONAL"`
+	Eps                      *float64 `parquet:"name=eps, type=DOUBLE, repetitiontype=OPTIONAL"`
+	EpsDiluted               *float64 `parquet:"name=eps_diluted, type=DOUBLE, repetitiontype=OPTIONAL"`
+	SharesWeightedAvg        *float64 `parquet:"name=shares_weighted_avg, type=DOUBLE, repetitiontype=OPTIONAL"`
+	SharesWeightedAvgDiluted *float64 `parquet:"name=shares_weighted_avg_diluted, type=DOUBLE, repetitiontype=OPTIONAL"`
+
+	// balance sheet
+	TotalAssets        *float64 `parquet:"name=total_assets, type=DOUBLE, repetitiontype=OPTIONAL"`
+	CurrentAssets      *float64 `parquet:"name=current_assets, type=DOUBLE, repetitiontype=OPTIONAL"`
+	CashAndEquivalents *float64 `parquet:"name=cash_and_equivalents, type=DOUBLE, repetitiontype=OPTIONAL"`
+	Inventory          *float64 `parquet:"name=inventory, type=DOUBLE, repetitiontype=OPTIONAL"`
+	AccountsReceivable *float64 `parquet:"name=accounts_receivable, type=DOUBLE, repetitiontype=OPTIONAL"`
+	TotalLiabilities   *float64 `parquet:"name=total_liabilities, type=DOUBLE, repetitiontype=OPTIONAL"`
+	CurrentLiabilities *float64 `parquet:"name=current_liabilities, type=DOUBLE, repetitiontype=OPTIONAL"`
+	AccountsPayable    *float64 `parquet:"name=accounts_payable, type=DOUBLE, repetitiontype=OPTIONAL"`
+	TotalDebt          *float64 `parquet:"name=total_debt, type=DOUBLE, repetitiontype=OPTIONAL"`
+	Equity             *float64 `parquet:"name=equity, type=DOUBLE, repetitiontype=OPTIONAL"`
+	RetainedEarnings   *float64 `parquet:"name=retained_earnings, type=DOUBLE, repetitiontype=OPTIONAL"`
+	SharesOutstanding  *float64 `parquet:"name=shares_outstanding, type=DOUBLE, repetitiontype=OPTIONAL"`
+
+	// cash flow
+	OperatingCashFlow        *float64 `parquet:"name=operating_cash_flow, type=DOUBLE, repetitiontype=OPTIONAL"`
+	InvestingCashFlow        *float64 `parquet:"name=investing_cash_flow, type=DOUBLE, repetitiontype=OPTIONAL"`
+	FinancingCashFlow        *float64 `parquet:"name=financing_cash_flow, type=DOUBLE, repetitiontype=OPTIONAL"`
+	CapitalExpenditure       *float64 `parquet:"name=capital_expenditure, type=DOUBLE, repetitiontype=OPTIONAL"`
+	FreeCashFlow             *float64 `parquet:"name=free_cash_flow, type=DOUBLE, repetitiontype=OPTIONAL"`
+	DividendsPaid            *float64 `parquet:"name=dividends_paid, type=DOUBLE, repetitiontype=OPTIONAL"`
+	StockBasedCompensation   *float64 `parquet:"name=stock_based_compensation, type=DOUBLE, repetitiontype=OPTIONAL"`
+	DepreciationAmortization *float64 `parquet:"name=depreciation_amortization, type=DOUBLE, repetitiontype=OPTIONAL"`
+
+	RunID string `json:"-" parquet:"name=run_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+// fundamentalField maps a tiingo statement data code to a Fundamentals field
+// and its database column
+type fundamentalField struct {
+	statement string
+	dataCode  string
+	column    string
+	field     func(f *Fundamentals) **float64
+}
+
+var fundamentalFields = []fundamentalField{
+	{"incomeStatement", "revenue", "revenue", func(f *Fundamentals) **float64 { return &f.Revenue }},
+	{"incomeStatement", "costRev", "cost_of_revenue", func(f *Fundamentals) **float64 { return &f.CostOfRevenue }},
+	{"incomeStatement", "grossProfit", "gross_profit", func(f *Fundamentals) **float64 { return &f.GrossProfit }},
+	{"incomeStatement", "opex", "operating_expenses", func(f *Fundamentals) **float64 { return &f.OperatingExpenses }},
+	{"incomeStatement", "opinc", "operating_income", func(f *Fundamentals) **float64 { return &f.OperatingIncome }},
+	{"incomeStatement", "rnd", "research_and_development", func(f *Fundamentals) **float64 { return &f.ResearchAndDevelopment }},
+	{"incomeStatement", "sga", "selling_general_admin", func(f *Fundamentals) **float64 { return &f.SellingGeneralAdmin }},
+	{"incomeStatement", "intexp", "interest_expense", func(f *Fundamentals) **float64 { return &f.InterestExpense }},
+	{"incomeStatement", "taxExp", "tax_expense", func(f *Fundamentals) **float64 { return &f.TaxExpense }},
+	{"incomeStatement", "ebit", "ebit", func(f *Fundamentals) **float64 { return &f.Ebit }},
+	{"incomeStatement", "ebitda", "ebitda", func(f *Fundamentals) **float64 { return &f.Ebitda }},
+	{"incomeStatement", "netinc", "net_income", func(f *Fundamentals) **float64 { return &f.NetIncome }},
+	{"incomeStatement", "eps", "eps", func(f *Fundamentals) **float64 { return &f.Eps }},
+	{"incomeStatement", "epsDil", "eps_diluted", func(f *Fundamentals) **float64 { return &f.EpsDiluted }},
+	{"incomeStatement", "shareswa", "shares_weighted_avg", func(f *Fundamentals) **float64 { return &f.SharesWeightedAvg }},
+	{"incomeStatement", "shareswaDil", "shares_weighted_avg_diluted", func(f *Fundamentals) **float64 { return &f.SharesWeightedAvgDiluted }},
+
+	{"balanceSheet", "totalAssets", "total_assets", func(f *Fundamentals) **float64 { return &f.TotalAssets }},
+	{"balanceSheet", "assetsCurrent", "current_assets", func(f *Fundamentals) **float64 { return &f.CurrentAssets }},
+	{"balanceSheet", "cashAndEq", "cash_and_equivalents", func(f *Fundamentals) **float64 { return &f.CashAndEquivalents }},
+	{"balanceSheet", "inventory", "inventory", func(f *Fundamentals) **float64 { return &f.Inventory }},
+	{"balanceSheet", "acctRec", "accounts_receivable", func(f *Fundamentals) **float64 { return &f.AccountsReceivable }},
+	{"balanceSheet", "totalLiabilities", "total_liabilities", func(f *Fundamentals) **float64 { return &f.TotalLiabilities }},
+	{"balanceSheet", "liabilitiesCurrent", "current_liabilities", func(f *Fundamentals) **float64 { return &f.CurrentLiabilities }},
+	{"balanceSheet", "acctPay", "accounts_payable", func(f *Fundamentals) **float64 { return &f.AccountsPayable }},
+	{"balanceSheet", "debt", "total_debt", func(f *Fundamentals) **float64 { return &f.TotalDebt }},
+	{"balanceSheet", "equity", "equity", func(f *Fundamentals) **float64 { return &f.Equity }},
+	{"balanceSheet", "retainedEarnings", "retained_earnings", func(f *Fundamentals) **float64 { return &f.RetainedEarnings }},
+	{"balanceSheet", "sharesBasic", "shares_outstanding", func(f *Fundamentals) **float64 { return &f.SharesOutstanding }},
+
+	{"cashFlow", "ncfo", "operating_cash_flow", func(f *Fundamentals) **float64 { return &f.OperatingCashFlow }},
+	{"cashFlow", "ncfi", "investing_cash_flow", func(f *Fundamentals) **float64 { return &f.InvestingCashFlow }},
+	{"cashFlow", "ncff", "financing_cash_flow", func(f *Fundamentals) **float64 { return &f.FinancingCashFlow }},
+	{"cashFlow", "capex", "capital_expenditure", func(f *Fundamentals) **float64 { return &f.CapitalExpenditure }},
+	{"cashFlow", "freeCashFlow", "free_cash_flow", func(f *Fundamentals) **float64 { return &f.FreeCashFlow }},
+	{"cashFlow", "payDiv", "dividends_paid", func(f *Fundamentals) **float64 { return &f.DividendsPaid }},
+	{"cashFlow", "sbcomp", "stock_based_compensation", func(f *Fundamentals) **float64 { return &f.StockBasedCompensation }},
+	{"cashFlow", "depamor", "depreciation_amortization", func(f *Fundamentals) **float64 { return &f.DepreciationAmortization }},
+}
+
+// FundamentalColumns returns the database columns of the statement values in
+// the order returned by Fundamentals.Values
+func FundamentalColumns() []string {
+	columns := make([]string, len(fundamentalFields))
+	for idx, field := range fundamentalFields {
+		columns[idx] = field.column
+	}
+	return columns
+}
+
+// Values returns the statement values of f in the order of
+// FundamentalColumns; values that were not reported are nil
+func (f *Fundamentals) Values() []*float64 {
+	values := make([]*float64, len(fundamentalFields))
+	for idx, field := range fundamentalFields {
+		values[idx] = *field.field(f)
+	}
+	return values
+}
+
+type statementValue struct {
+	DataCode string  `json:"dataCode"`
+	Value    float64 `json:"value"`
+}
+
+type statementResponse struct {
+	Date          string                       `json:"date"`
+	Year          int32                        `json:"year"`
+	Quarter       int32                        `json:"quarter"`
+	StatementData map[string][]*statementValue `json:"statementData"`
+}
+
+// parse converts the tiingo statement response into a Fundamentals record
+func (s *statementResponse) parse() *Fundamentals {
+	f := &Fundamentals{
+		DateStr: s.Date,
+		Year:    s.Year,
+		Quarter: s.Quarter,
+	}
+
+	for _, field := range fundamentalFields {
+		for _, item := range s.StatementData[field.statement] {
+			if item.DataCode == field.dataCode {
+				value := item.Value
+				*field.field(f) = &value
+				break
+			}
+		}
+	}
+
+	return f
+}
+
+// FetchFundamentals downloads quarterly and annual financial statements for
+// each asset with a period end between startDate and endDate (zero for no
+// limit), along with an error for each asset whose statements could not be
+// downloaded
+func (t *TiingoApi) FetchFundamentals(ctx context.Context, assets []*common.Asset, startDate, endDate time.Time) ([]*Fundamentals, []*TickerError) {
+	fundamentals := []*Fundamentals{}
+	client := t.newClient()
+	var errs errorCollector
+	startDateStr := startDate.Format("2006-01-02")
+
+	progress := t.progress("fundamentals", len(assets))
+	defer progress.Finish()
+
+	results := make(chan *Fundamentals, 100)
+	go func() {
+		defer close(results)
+		t.forEach(ctx, len(assets), func(idx int) {
+			asset := assets[idx]
+
+			// rate limiting
+			t.rate.Take()
+
+			progress.Current(asset.Ticker)
+
+			ticker := strings.ReplaceAll(asset.Ticker, "/", "-")
+			url := fmt.Sprintf("%s/tiingo/fundamentals/%s/statements?startDate=%s&token=%s", t.baseURL, ticker, startDateStr, t.token)
+			if !endDate.IsZero() {
+				url += "&endDate=" + endDate.Format("2006-01-02")
+			}
+			resp, err := client.
+				R().
+				SetContext(ctx).
+				SetHeader("Accept", "application/json").
+				Get(url)
+			if resp != nil {
+				progress.AddBytes(len(resp.Body()))
+			}
+			if err != nil {
+				log.Error().Err(err).Str("Ticker", asset.Ticker).Msg("error when requesting fundamentals")
+				errs.add(asset.Ticker, 0, fmt.Errorf("%w: %w", ErrRequestFailed, err))
+				progress.Error()
+				return
+			}
+			if resp.StatusCode() >= 400 {
+				log.Error().Int("StatusCode", resp.StatusCode()).Str("Ticker", asset.Ticker).Bytes("Body", resp.Body()).Msg("error when requesting fundamentals")
+				errs.add(asset.Ticker, resp.StatusCode(), statusError(resp.StatusCode()))
+				progress.Error()
+				return
+			}
+
+			var statements []*statementResponse
+			if err = json.Unmarshal(resp.Body(), &statements); err != nil {
+				log.Error().Err(err).Str("Ticker", asset.Ticker).Msg("could not unmarshal fundamentals json")
+				errs.add(asset.Ticker, resp.StatusCode(), fmt.Errorf("%w: %v", ErrInvalidResponse, err))
+				progress.Error()
+				return
+			}
+
+			for _, statement := range statements {
+				f := statement.parse()
+				f.Ticker = asset.Ticker
+				f.CompositeFigi = asset.CompositeFigi
+				f.RunID = common.RunID
+				if date, err := time.Parse("2006-01-02", f.DateStr); err == nil {
+					f.Date = date
+				}
+				results <- f
+			}
+
+			progress.Add(1)
+		})
+	}()
+
+	for val := range results {
+		fundamentals = append(fundamentals, val)
+	}
+
+	return fundamentals, errs.errors
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// metaBatchSize is the number of tickers requested in a single call to the
+// fundamentals meta endpoint
+const metaBatchSize = 100
+
+// FundamentalDefinition describes a data code that appears in tiingo's
+// financial statements
+type FundamentalDefinition struct {
+	DataCode      string `json:"dataCode"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	StatementType string `json:"statementType"`
+	Units         string `json:"units"`
+}
+
+// FundamentalsMeta holds tiingo's fundamentals coverage of a company: its
+// classification, reporting currency and when its statements and daily
+// metrics were last updated. Fields not reported by tiingo are nil or empty.
+type FundamentalsMeta struct {
+	PermaTicker          string     `json:"permaTicker"`
+	Ticker               string     `json:"ticker"`
+	CompositeFigi        string     `json:"-"`
+	Name                 string     `json:"name"`
+	IsActive             bool       `json:"isActive"`
+	IsADR                bool       `json:"isADR"`
+	Sector               string     `json:"sector"`
+	Industry             string     `json:"industry"`
+	SicCode              *int32     `json:"sicCode"`
+	SicSector            string     `json:"sicSector"`
+	SicIndustry          string     `json:"sicIndustry"`
+	ReportingCurrency    string     `json:"reportingCurrency"`
+	Location             string     `json:"location"`
+	CompanyWebsite       string     `json:"companyWebsite"`
+	SecFilingWebsite     string     `json:"secFilingWebsite"`
+	StatementLastUpdated *time.Time `json:"statementLastUpdated"`
+	DailyLastUpdated     *time.Time `json:"dailyLastUpdated"`
+	RunID                string     `json:"-"`
+}
+
+// FetchFundamentalDefinitions downloads the definitions of every data code
+// used in tiingo's financial statements
+func (t *TiingoApi) FetchFundamentalDefinitions(ctx context.Context) ([]*FundamentalDefinition, error) {
+	t.rate.Take()
+
+	url := fmt.Sprintf("%s/tiingo/fundamentals/definitions?token=%s", t.baseURL, t.token)
+	resp, err := t.newClient().
+		R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		Get(url)
+	if err != nil {
+		log.Error().Err(err).Msg("error when requesting fundamental definitions")
+		return nil, fmt.Errorf("%w: %w", ErrRequestFailed, err)
+	}
+	if resp.StatusCode() >= 400 {
+		log.Error().Int("StatusCode", resp.StatusCode()).Bytes("Body", resp.Body()).Msg("error when requesting fundamental definitions")
+		return nil, statusError(resp.StatusCode())
+	}
+
+	definitions := []*FundamentalDefinition{}
+	if err := json.Unmarshal(resp.Body(), &definitions); err != nil {
+		log.Error().Err(err).Msg("could not unmarshal fundamental definitions json")
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+
+	return definitions, nil
+}
+
+// FetchFundamentalsMeta downloads the fundamentals meta data of each asset,
+// along with an error for each asset whose meta data could not be downloaded.
+// Tickers are requested in batches of metaBatchSize; assets that tiingo does
+// not cover are omitted from the result.
+func (t *TiingoApi) FetchFundamentalsMeta(ctx context.Context, assets []*common.Asset) ([]*FundamentalsMeta, []*TickerError) {
+	meta := []*FundamentalsMeta{}
+	client := t.newClient()
+	var errs errorCollector
+
+	batches := make([][]*common.Asset, 0, len(assets)/metaBatchSize+1)
+	for start := 0; start < len(assets); start += metaBatchSize {
+		batches = append(batches, assets[start:min(start+metaBatchSize, len(assets))])
+	}
+
+	progress := t.progress("fundamentals meta", len(assets))
+	defer progress.Finish()
+
+	results := make(chan *FundamentalsMeta, 100)
+	go func() {
+		defer close(results)
+		t.forEach(ctx, len(batches), func(idx int) {
+			batch := batches[idx]
+
+			// rate limiting
+			t.rate.Take()
+
+			byTicker := make(map[string]*common.Asset, len(batch))
+			tickers := make([]string, len(batch))
+			for ii, asset := range batch {
+				tickers[ii] = strings.ReplaceAll(asset.Ticker, "/", "-")
+				byTicker[strings.ToLower(tickers[ii])] = asset
+			}
+			progress.Current(tickers[0])
+
+			failed := func(statusCode int, err error) {
+				for _, asset := range batch {
+					errs.add(asset.Ticker, statusCode, err)
+				}
+				progress.Error()
+			}
+
+			url := fmt.Sprintf("%s/tiingo/fundamentals/meta?tickers=%s&token=%s", t.baseURL, strings.Join(tickers, ","), t.token)
+			resp, err := client.
+				R().
+				SetContext(ctx).
+				SetHeader("Accept", "application/json").
+				Get(url)
+			if resp != nil {
+				progress.AddBytes(len(resp.Body()))
+			}
+			if err != nil {
+				log.Error().Err(err).Int("NumTickers", len(batch)).Msg("error when requesting fundamentals meta")
+				failed(0, fmt.Errorf("%w: %w", ErrRequestFailed, err))
+				return
+			}
+			if resp.StatusCode() >= 400 {
+				log.Error().Int("StatusCode", resp.StatusCode()).Int("NumTickers", len(batch)).Bytes("Body", resp.Body()).Msg("error when requesting fundamentals meta")
+				failed(resp.StatusCode(), statusError(resp.StatusCode()))
+				return
+			}
+
+			var companies []*FundamentalsMeta
+			if err = json.Unmarshal(resp.Body(), &companies); err != nil {
+				log.Error().Err(err).Int("NumTickers", len(batch)).Msg("could not unmarshal fundamentals meta json")
+				failed(resp.StatusCode(), fmt.Errorf("%w: %v", ErrInvalidResponse, err))
+				return
+			}
+
+			for _, company := range companies {
+				asset, ok := byTicker[strings.ToLower(company.Ticker)]
+				if !ok {
+					log.Warn().Str("Ticker", company.Ticker).Msg("tiingo returned fundamentals meta for a ticker that was not requested")
+					continue
+				}
+				company.Ticker = asset.Ticker
+				company.CompositeFigi = asset.CompositeFigi
+				company.RunID = common.RunID
+				results <- company
+			}
+
+			progress.Add(len(batch))
+		})
+	}()
+
+	for val := range results {
+		meta = append(meta, val)
+	}
+
+	return meta, errs.errors
+}
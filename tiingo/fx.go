@@ -0,0 +1,120 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// FxRate is a daily forex rate; Close is the number of QuoteCurrency units per
+// unit of BaseCurrency
+type FxRate struct {
+	Date          time.Time
+	DateStr       string  `json:"date" parquet:"name=date, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Ticker        string  `json:"ticker" parquet:"name=ticker, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	BaseCurrency  string  `json:"-" parquet:"name=base_currency, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	QuoteCurrency string  `json:"-" parquet:"name=quote_currency, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Open          float64 `json:"open" parquet:"name=open, type=DOUBLE"`
+	High          float64 `json:"high" parquet:"name=high, type=DOUBLE"`
+	Low           float64 `json:"low" parquet:"name=low, type=DOUBLE"`
+	Close         float64 `json:"close" parquet:"name=close, type=DOUBLE"`
+	RunID         string  `json:"-" parquet:"name=run_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+// FetchFxRates downloads daily forex rates for each currency pair (e.g.
+// eurusd) between startDate and endDate (zero for the latest rate); pairs that
+// fail are returned as errors
+func (t *TiingoApi) FetchFxRates(ctx context.Context, pairs []string, startDate, endDate time.Time) ([]*FxRate, []*TickerError) {
+	client := t.newClient()
+	var errs errorCollector
+	rates := []*FxRate{}
+	startDateStr := startDate.Format("2006-01-02")
+
+	progress := t.progress("fx", len(pairs))
+	defer progress.Finish()
+
+	for _, pair := range pairs {
+		if ctx.Err() != nil {
+			log.Warn().Err(ctx.Err()).Msg("download cancelled")
+			break
+		}
+
+		t.rate.Take()
+
+		pair = strings.ToLower(pair)
+		progress.Current(pair)
+		if len(pair) != 6 {
+			log.Error().Str("Pair", pair).Msg("currency pair must be 6 characters, e.g. eurusd")
+			errs.add(pair, 0, fmt.Errorf("%w: currency pair must be 6 characters", ErrRequestFailed))
+			progress.Error()
+			continue
+		}
+
+		url := fmt.Sprintf("%s/tiingo/fx/%s/prices?startDate=%s&resampleFreq=1day&token=%s", t.baseURL, pair, startDateStr, t.token)
+		if !endDate.IsZero() {
+			url += "&endDate=" + endDate.Format("2006-01-02")
+		}
+		resp, err := client.
+			R().
+			SetContext(ctx).
+			SetHeader("Accept", "application/json").
+			Get(url)
+		if resp != nil {
+			progress.AddBytes(len(resp.Body()))
+		}
+		if err != nil {
+			log.Error().Err(err).Str("Pair", pair).Msg("error when requesting fx rates")
+			errs.add(pair, 0, fmt.Errorf("%w: %w", ErrRequestFailed, err))
+			progress.Error()
+			continue
+		}
+		if resp.StatusCode() >= 400 {
+			log.Error().Int("StatusCode", resp.StatusCode()).Str("Pair", pair).Bytes("Body", resp.Body()).Msg("error when requesting fx rates")
+			errs.add(pair, resp.StatusCode(), statusError(resp.StatusCode()))
+			progress.Error()
+			continue
+		}
+
+		var result []*FxRate
+		if err := json.Unmarshal(resp.Body(), &result); err != nil {
+			log.Error().Err(err).Str("Pair", pair).Msg("could not unmarshal fx json")
+			errs.add(pair, resp.StatusCode(), fmt.Errorf("%w: %v", ErrInvalidResponse, err))
+			progress.Error()
+			continue
+		}
+
+		for _, rate := range result {
+			rate.Ticker = pair
+			rate.BaseCurrency = strings.ToUpper(pair[:3])
+			rate.QuoteCurrency = strings.ToUpper(pair[3:])
+			rate.RunID = common.RunID
+			if date, err := time.Parse(time.RFC3339, rate.DateStr); err == nil {
+				rate.Date = date
+			}
+			rates = append(rates, rate)
+		}
+		progress.Add(1)
+	}
+
+	return rates, errs.errors
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// IntradayFrequencies lists the resample frequencies supported by the
+// intraday download
+var IntradayFrequencies = []string{"1min", "5min", "30min", "1hour"}
+
+// IntradayBar is a single OHLCV bar from the tiingo IEX endpoint
+type IntradayBar struct {
+	Date          time.Time
+	DateStr       string  `json:"date" parquet:"name=date, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Ticker        string  `json:"ticker" parquet:"name=ticker, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	CompositeFigi string  `json:"compositeFigi" parquet:"name=compositeFigi, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Frequency     string  `json:"frequency" parquet:"name=frequency, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Open          float64 `json:"open" parquet:"name=open, type=DOUBLE"`
+	High          float64 `json:"high" parquet:"name=high, type=DOUBLE"`
+	Low           float64 `json:"low" parquet:"name=low, type=DOUBLE"`
+	Close         float64 `json:"close" parquet:"name=close, type=DOUBLE"`
+	Volume        int64   `json:"volume" parquet:"name=volume, type=INT64"`
+	RunID         string  `json:"-" parquet:"name=run_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+// UnmarshalJSON decodes an IEX bar, rounding the volume to whole shares
+func (b *IntradayBar) UnmarshalJSON(data []byte) error {
+	type intradayBar IntradayBar
+	aux := struct {
+		*intradayBar
+		Volume float64 `json:"volume"`
+	}{intradayBar: (*intradayBar)(b)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	b.Volume = int64(math.Round(aux.Volume))
+	return nil
+}
+
+// ValidIntradayFrequency returns true if freq is a supported resample
+// frequency
+func ValidIntradayFrequency(freq string) bool {
+	for _, valid := range IntradayFrequencies {
+		if freq == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// intradayIntervals is the length of a bar of each intraday frequency
+var intradayIntervals = map[string]time.Duration{
+	"1min":  time.Minute,
+	"5min":  5 * time.Minute,
+	"30min": 30 * time.Minute,
+	"1hour": time.Hour,
+}
+
+// FetchIntradayBars downloads intraday bars from the IEX endpoint between
+// startDate and endDate (zero for the latest bar) resampled to frequency.
+// Failed tickers are returned as errors.
+func (t *TiingoApi) FetchIntradayBars(ctx context.Context, assets []*common.Asset, startDate, endDate time.Time, frequency string) ([]*IntradayBar, []*TickerError) {
+	bars := []*IntradayBar{}
+	client := t.newClient()
+	var errs errorCollector
+	startDateStr := startDate.Format("2006-01-02")
+
+	progress := t.progress("intraday", len(assets))
+	defer progress.Finish()
+
+	results := make(chan *IntradayBar, 100)
+	go func() {
+		defer close(results)
+		t.forEach(ctx, len(assets), func(idx int) {
+			asset := assets[idx]
+
+			// rate limiting
+			t.rate.Take()
+
+			progress.Current(asset.Ticker)
+
+			ticker := strings.ReplaceAll(asset.Ticker, "/", "-")
+			url := fmt.Sprintf("%s/iex/%s/prices?startDate=%s&resampleFreq=%s&columns=open,high,low,close,volume&token=%s", t.baseURL, ticker, startDateStr, frequency, t.token)
+			if !endDate.IsZero() {
+				url += "&endDate=" + endDate.Format("2006-01-02")
+			}
+			resp, err := client.
+				R().
+				SetContext(ctx).
+				SetHeader("Accept", "application/json").
+				Get(url)
+			if resp != nil {
+				progress.AddBytes(len(resp.Body()))
+			}
+			if err != nil {
+				log.Error().Err(err).Str("Ticker", asset.Ticker).Msg("error when requesting intraday bars")
+				errs.add(asset.Ticker, 0, fmt.Errorf("%w: %w", ErrRequestFailed, err))
+				progress.Error()
+				return
+			}
+			if resp.StatusCode() >= 400 {
+				log.Error().Int("StatusCode", resp.StatusCode()).Str("Ticker", asset.Ticker).Bytes("Body", resp.Body()).Msg("error when requesting intraday bars")
+				errs.add(asset.Ticker, resp.StatusCode(), statusError(resp.StatusCode()))
+				progress.Error()
+				return
+			}
+
+			var result []*IntradayBar
+			if err = json.Unmarshal(resp.Body(), &result); err != nil {
+				log.Error().Err(err).Str("Ticker", asset.Ticker).Msg("could not unmarshal intraday json")
+				errs.add(asset.Ticker, resp.StatusCode(), fmt.Errorf("%w: %v", ErrInvalidResponse, err))
+				progress.Error()
+				return
+			}
+
+			for _, bar := range result {
+				bar.Ticker = asset.Ticker
+				bar.CompositeFigi = asset.CompositeFigi
+				bar.Frequency = frequency
+				bar.RunID = common.RunID
+				if date, err := time.Parse(time.RFC3339, bar.DateStr); err == nil {
+					bar.Date = date
+				}
+				results <- bar
+			}
+
+			progress.Add(1)
+		})
+	}()
+
+	for val := range results {
+		bars = append(bars, val)
+	}
+
+	return bars, errs.errors
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import "errors"
+
+func (t *TiingoApi) recordEmpty(ticker string) {
+	t.emptyMu.Lock()
+	defer t.emptyMu.Unlock()
+	t.empty = append(t.empty, ticker)
+}
+
+// MissingTickers returns the tickers that tiingo has no data for: those in
+// errs that were not found and those that returned an empty series
+func (t *TiingoApi) MissingTickers(errs []*TickerError) []string {
+	t.emptyMu.Lock()
+	missing := append([]string{}, t.empty...)
+	t.emptyMu.Unlock()
+
+	for _, err := range errs {
+		if errors.Is(err, ErrNotFound) {
+			missing = append(missing, err.Ticker)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RequestMetrics records performance information about the download of a
+// single ticker; Retries counts the throttled or failed attempts that were
+// retried and Error is set when the request itself failed
+type RequestMetrics struct {
+	Ticker     string
+	StatusCode int
+	Bytes      int
+	Latency    time.Duration
+	NumBars    int
+	Retries    int
+	Error      string
+}
+
+type metricsCollector struct {
+	mu      sync.Mutex
+	metrics []*RequestMetrics
+}
+
+func (c *metricsCollector) record(m *RequestMetrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = append(c.metrics, m)
+}
+
+// Metrics returns the per-ticker request metrics collected so far
+func (t *TiingoApi) Metrics() []*RequestMetrics {
+	t.metrics.mu.Lock()
+	defer t.metrics.mu.Unlock()
+	result := make([]*RequestMetrics, len(t.metrics.metrics))
+	copy(result, t.metrics.metrics)
+	return result
+}
+
+// APICalls returns the number of HTTP requests sent to tiingo so far,
+// including retries; responses served from the cache are not counted
+func (t *TiingoApi) APICalls() int64 {
+	return t.apiCalls.Load()
+}
+
+// countingTransport counts the requests that reach the network
+type countingTransport struct {
+	count *atomic.Int64
+	next  http.RoundTripper
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.count.Add(1)
+	return c.next.RoundTrip(req)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// newsPageSize is the maximum number of articles tiingo returns per request
+const newsPageSize = 1000
+
+// NewsArticle is a single article from the tiingo news API
+type NewsArticle struct {
+	ID            int64     `json:"id"`
+	Title         string    `json:"title"`
+	Url           string    `json:"url"`
+	Description   string    `json:"description"`
+	Source        string    `json:"source"`
+	PublishedDate time.Time `json:"publishedDate"`
+	CrawlDate     time.Time `json:"crawlDate"`
+	Tickers       []string  `json:"tickers"`
+	Tags          []string  `json:"tags"`
+}
+
+// FetchNews downloads news articles published between startDate and endDate
+// that mention any of tickers and match any of tags. Either filter may be
+// empty. Articles are de-duplicated by ID.
+func (t *TiingoApi) FetchNews(ctx context.Context, tickers []string, tags []string, startDate, endDate time.Time) []*NewsArticle {
+	client := t.newClient()
+	articles := []*NewsArticle{}
+	seen := make(map[int64]bool)
+
+	params := url.Values{}
+	if len(tickers) > 0 {
+		params.Set("tickers", strings.ToLower(strings.Join(tickers, ",")))
+	}
+	if len(tags) > 0 {
+		params.Set("tags", strings.Join(tags, ","))
+	}
+	params.Set("startDate", startDate.Format("2006-01-02"))
+	params.Set("endDate", endDate.Format("2006-01-02"))
+	params.Set("limit", fmt.Sprintf("%d", newsPageSize))
+	params.Set("token", t.token)
+
+	for offset := 0; ; offset += newsPageSize {
+		if ctx.Err() != nil {
+			log.Warn().Err(ctx.Err()).Msg("download cancelled")
+			break
+		}
+
+		t.rate.Take()
+
+		params.Set("offset", fmt.Sprintf("%d", offset))
+		resp, err := client.
+			R().
+			SetContext(ctx).
+			SetHeader("Accept", "application/json").
+			Get(t.baseURL + "/tiingo/news?" + params.Encode())
+		if err != nil {
+			log.Error().Err(err).Int("Offset", offset).Msg("error when requesting news")
+			break
+		}
+		if resp.StatusCode() >= 400 {
+			log.Error().Int("StatusCode", resp.StatusCode()).Int("Offset", offset).Bytes("Body", resp.Body()).Msg("error when requesting news")
+			break
+		}
+
+		var page []*NewsArticle
+		if err := json.Unmarshal(resp.Body(), &page); err != nil {
+			log.Error().Err(err).Int("Offset", offset).Msg("could not unmarshal news json")
+			break
+		}
+
+		for _, article := range page {
+			if !seen[article.ID] {
+				seen[article.ID] = true
+				articles = append(articles, article)
+			}
+		}
+
+		if len(page) < newsPageSize {
+			break
+		}
+	}
+
+	log.Info().Int("NumArticles", len(articles)).Msg("downloaded news")
+	return articles
+}
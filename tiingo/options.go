@@ -0,0 +1,149 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+)
+
+// Options configures a TiingoApi. The zero value downloads every column of
+// the daily quotes with a single worker and no progress display; the import
+// command fills it from the tiingo.*, otc.*, quota.*, cache.*, record.* and
+// replay.* settings.
+type Options struct {
+	// Workers is the number of concurrent downloads
+	Workers int
+
+	// BaseURL replaces DefaultBaseURL when set
+	BaseURL string
+
+	// Tokens are additional API tokens; requests rotate among them and the
+	// token passed to New, each with its own rate limit, request budgets and
+	// quota tracking
+	Tokens []string
+
+	// AdaptiveRateLimit backs off when tiingo responds with 429s and ramps
+	// back up to the rate limit, but never below MinRateLimit
+	AdaptiveRateLimit bool
+	MinRateLimit      int
+
+	// Plan, HourlyBudget and DailyBudget limit the number of requests; see
+	// Budgets
+	Plan         string
+	HourlyBudget int
+	DailyBudget  int
+
+	// Frequency of the downloaded quotes; empty downloads daily quotes
+	Frequency string
+
+	// Columns selects a subset of EodColumns; empty downloads all of them
+	Columns []string
+
+	// TrimZeroVolume removes the leading and trailing zero volume quotes of
+	// each asset
+	TrimZeroVolume bool
+
+	Adjust AdjustOptions
+	OTC    OTCOptions
+	Quota  QuotaOptions
+
+	// TimestampTolerance and TimestampPolicy control how quotes dated
+	// outside the requested window are handled; see checkTimestamp
+	TimestampTolerance time.Duration
+	TimestampPolicy    string
+
+	// LogRequests logs every request sent to tiingo
+	LogRequests bool
+
+	// RequestTimeout limits each request, including reading the response;
+	// zero waits forever
+	RequestTimeout time.Duration
+
+	// MaxRetries is the number of times a request that tiingo throttled
+	// (429) or failed with a server error is retried. Retries wait for the
+	// Retry-After header or back off exponentially, at most MaxRetryWait.
+	MaxRetries   int
+	MaxRetryWait time.Duration
+
+	// Deadline is when the run must stop downloading. Requests still in
+	// flight are cancelled and later requests fail with ErrDeadline without
+	// waiting for the rate limit; the zero time has no deadline.
+	Deadline time.Time
+
+	// CacheDir caches responses on disk for CacheTTL; RecordDir archives
+	// every response and ReplayDir serves responses from such an archive
+	// instead of the network
+	CacheDir  string
+	CacheTTL  time.Duration
+	RecordDir string
+	ReplayDir string
+
+	// S3 is used when RecordDir is an s3:// URI
+	S3 common.S3Options
+
+	// Progress creates the progress reporter of each download phase;
+	// common.NoProgress is used when nil
+	Progress common.ProgressFunc
+}
+
+// AdjustOptions selects the adjusted values computed by
+// ComputeAdjustmentFactors
+type AdjustOptions struct {
+	// Prices is one of AdjustNone, AdjustTiingo or AdjustLocal
+	Prices string
+
+	// Volume computes the split-adjusted volume
+	Volume bool
+}
+
+// OTCOptions are the limits applied to over-the-counter tickers
+type OTCOptions struct {
+	// RateLimit is the number of OTC requests per second, in addition to the
+	// global limit; zero is unlimited
+	RateLimit int
+
+	// quotes below MinPrice or MinVolume are skipped
+	MinPrice  float64
+	MinVolume int64
+}
+
+// QuotaOptions configure how the API quota reported by tiingo is tracked
+type QuotaOptions struct {
+	// names of the quota response headers; empty uses the defaults
+	RemainingHeader string
+	LimitHeader     string
+	ResetHeader     string
+
+	// PauseBelow holds requests once the remaining quota falls to this
+	// value until the quota resets, or for PauseDuration if tiingo does not
+	// report a reset
+	PauseBelow    int64
+	PauseDuration time.Duration
+
+	// WarnThresholds are percentages of the limit at which a warning is
+	// logged
+	WarnThresholds []int
+}
+
+// progress creates the progress reporter for the named phase
+func (t *TiingoApi) progress(phase string, total int) common.Progress {
+	if t.options.Progress == nil {
+		return common.NoProgress(phase, total)
+	}
+	return t.options.Progress(phase, total)
+}
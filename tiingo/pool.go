@@ -0,0 +1,60 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// forEach calls fn with the indexes 0..n-1 on a fixed pool of t.workers
+// goroutines and returns once all calls have finished. No further work is
+// started after ctx is cancelled.
+func (t *TiingoApi) forEach(ctx context.Context, n int, fn func(idx int)) {
+	workers := t.workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				fn(idx)
+			}
+		}()
+	}
+
+submit:
+	for idx := 0; idx < n; idx++ {
+		select {
+		case jobs <- idx:
+		case <-ctx.Done():
+			log.Warn().Err(ctx.Err()).Msg("download cancelled")
+			break submit
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Default names of the response headers that report the API quota
+const (
+	DefaultQuotaLimitHeader     = "X-RateLimit-Limit"
+	DefaultQuotaRemainingHeader = "X-RateLimit-Remaining"
+	DefaultQuotaResetHeader     = "X-RateLimit-Reset"
+)
+
+// Quota is the most recent API quota reported by tiingo
+type Quota struct {
+	Limit     int64
+	Remaining int64
+	Reset     time.Time
+	UpdatedAt time.Time
+}
+
+// quotaTracker records the quota headers of each response, warns as the
+// remaining quota crosses the warning thresholds (percent of the limit) and,
+// when PauseBelow is set, holds further requests until the quota
+// resets rather than letting them fail with 429s
+type quotaTracker struct {
+	options QuotaOptions
+
+	mu     sync.Mutex
+	quota  *Quota
+	warned map[int]bool
+	paused time.Time
+}
+
+// Quota returns the most recently reported API quota or nil if tiingo has
+// not reported one
+func (t *TiingoApi) Quota() *Quota {
+	if t.tokens != nil {
+		return t.tokens.quota()
+	}
+	return t.quota.current()
+}
+
+// current returns a copy of the most recently reported quota or nil
+func (q *quotaTracker) current() *Quota {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.quota == nil {
+		return nil
+	}
+	quota := *q.quota
+	return &quota
+}
+
+// update parses the quota headers of resp
+func (q *quotaTracker) update(resp *http.Response) *Quota {
+	remaining, ok := headerInt(resp.Header, q.options.RemainingHeader, DefaultQuotaRemainingHeader)
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+	quota := &Quota{Remaining: remaining, UpdatedAt: now}
+	quota.Limit, _ = headerInt(resp.Header, q.options.LimitHeader, DefaultQuotaLimitHeader)
+	if reset, ok := headerInt(resp.Header, q.options.ResetHeader, DefaultQuotaResetHeader); ok {
+		// the reset is either a unix timestamp or the seconds until the reset
+		if reset > 1_000_000_000 {
+			quota.Reset = time.Unix(reset, 0)
+		} else {
+			quota.Reset = now.Add(time.Duration(reset) * time.Second)
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.quota = quota
+	q.warn(quota)
+
+	if pauseBelow := q.options.PauseBelow; pauseBelow > 0 && quota.Remaining <= pauseBelow && q.paused.IsZero() {
+		resume := quota.Reset
+		if !resume.After(now) {
+			resume = now.Add(q.options.PauseDuration)
+		}
+		q.paused = resume
+		log.Warn().Int64("Remaining", quota.Remaining).Int64("PauseBelow", pauseBelow).Time("Resume", resume).Msg("tiingo API quota nearly exhausted ... pausing requests")
+	}
+
+	return quota
+}
+
+// warn logs a warning the first time the remaining quota falls to or below
+// each threshold; q.mu must be held
+func (q *quotaTracker) warn(quota *Quota) {
+	if quota.Limit <= 0 {
+		return
+	}
+	if q.warned == nil {
+		q.warned = make(map[int]bool)
+	}
+
+	percent := float64(quota.Remaining) / float64(quota.Limit) * 100
+	for _, threshold := range q.options.WarnThresholds {
+		if percent <= float64(threshold) && !q.warned[threshold] {
+			q.warned[threshold] = true
+			log.Warn().Int64("Remaining", quota.Remaining).Int64("Limit", quota.Limit).Int("Threshold", threshold).Msg("tiingo API quota is running low")
+		}
+	}
+}
+
+// isPaused returns true while requests are held until the quota resets
+func (q *quotaTracker) isPaused() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return time.Now().Before(q.paused)
+}
+
+// wait blocks while requests are paused or until req is cancelled
+func (q *quotaTracker) wait(req *http.Request) error {
+	q.mu.Lock()
+	resume := q.paused
+	q.mu.Unlock()
+	if resume.IsZero() {
+		return nil
+	}
+
+	if delay := time.Until(resume); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return req.Context().Err()
+		}
+	}
+
+	q.mu.Lock()
+	if q.paused.Equal(resume) {
+		log.Info().Msg("resuming tiingo requests")
+		q.paused = time.Time{}
+	}
+	q.mu.Unlock()
+	return nil
+}
+
+// quotaTransport tracks the API quota reported by each response and, with
+// logRequests enabled, logs every request sent to tiingo
+type quotaTransport struct {
+	quota       *quotaTracker
+	logRequests bool
+	next        http.RoundTripper
+}
+
+func (q *quotaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := q.quota.wait(req); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := q.next.RoundTrip(req)
+	if err != nil {
+		if q.logRequests {
+			log.Info().Err(err).Str("Method", req.Method).Str("URL", redactURL(req.URL)).Dur("Latency", time.Since(start)).Msg("tiingo request failed")
+		}
+		return resp, err
+	}
+
+	quota := q.quota.update(resp)
+	if q.logRequests {
+		event := log.Info().
+			Str("Method", req.Method).
+			Str("URL", redactURL(req.URL)).
+			Int("StatusCode", resp.StatusCode).
+			Dur("Latency", time.Since(start))
+		if resp.ContentLength >= 0 {
+			event = event.Int64("Bytes", resp.ContentLength)
+		}
+		if quota != nil {
+			event = event.Int64("QuotaRemaining", quota.Remaining)
+			if quota.Limit > 0 {
+				event = event.Int64("QuotaLimit", quota.Limit)
+			}
+		}
+		event.Msg("tiingo request")
+	}
+	return resp, err
+}
+
+// headerInt parses the integer value of the header name, falling back to
+// defaultName when name is empty
+func headerInt(header http.Header, name, defaultName string) (int64, bool) {
+	if name == "" {
+		name = defaultName
+	}
+	value := header.Get(name)
+	if value == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
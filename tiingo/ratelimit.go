@@ -0,0 +1,238 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Plan is the request budget of a tiingo subscription tier
+type Plan struct {
+	HourlyBudget int
+	DailyBudget  int
+}
+
+// Plans are the published request limits of tiingo's subscription tiers;
+// Options.HourlyBudget and Options.DailyBudget override them
+var Plans = map[string]Plan{
+	"free":  {HourlyBudget: 50, DailyBudget: 1000},
+	"power": {HourlyBudget: 10000, DailyBudget: 100000},
+}
+
+// PlanNames returns the names of the known plans in sorted order
+func PlanNames() []string {
+	names := make([]string, 0, len(Plans))
+	for name := range Plans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Budgets returns the hourly and daily request budgets of the plan, with
+// HourlyBudget and DailyBudget overriding it when set; zero is unlimited
+func (opts Options) Budgets() (hourly, daily int) {
+	plan := Plans[opts.Plan]
+	hourly, daily = plan.HourlyBudget, plan.DailyBudget
+	if opts.HourlyBudget > 0 {
+		hourly = opts.HourlyBudget
+	}
+	if opts.DailyBudget > 0 {
+		daily = opts.DailyBudget
+	}
+	return hourly, daily
+}
+
+// rampAfter is the number of seconds of successful requests at the current
+// rate before the adaptive limiter speeds up again
+const rampAfter = 10
+
+// adaptiveLimiter is a ratelimit.Limiter that halves its rate whenever tiingo
+// responds with 429 Too Many Requests and ramps back up towards max after a
+// run of successful requests
+type adaptiveLimiter struct {
+	mu          sync.Mutex
+	max         int
+	min         int
+	rate        int
+	last        time.Time
+	lastBackoff time.Time
+	successes   int
+}
+
+func newAdaptiveLimiter(max, min int) *adaptiveLimiter {
+	if max < 1 {
+		max = 1
+	}
+	if min < 1 || min > max {
+		min = 1
+	}
+	return &adaptiveLimiter{max: max, min: min, rate: max}
+}
+
+// Take blocks until the next request may be sent at the current rate
+func (l *adaptiveLimiter) Take() time.Time {
+	l.mu.Lock()
+	now := time.Now()
+	next := l.last.Add(time.Second / time.Duration(l.rate))
+	if next.Before(now) {
+		next = now
+	}
+	l.last = next
+	l.mu.Unlock()
+
+	time.Sleep(time.Until(next))
+	return next
+}
+
+// backoff halves the rate; 429s that arrive within a second of the previous
+// backoff were sent at the old rate and are ignored
+func (l *adaptiveLimiter) backoff() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.successes = 0
+	if time.Since(l.lastBackoff) < time.Second {
+		return
+	}
+	l.lastBackoff = time.Now()
+
+	rate := l.rate / 2
+	if rate < l.min {
+		rate = l.min
+	}
+	if rate != l.rate {
+		log.Warn().Int("RateLimit", rate).Int("PreviousRateLimit", l.rate).Msg("rate limited by tiingo ... backing off")
+		l.rate = rate
+	}
+}
+
+// success counts a successful request and increases the rate by a tenth of
+// max once rampAfter seconds worth of requests have succeeded
+func (l *adaptiveLimiter) success() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate >= l.max {
+		return
+	}
+	l.successes++
+	if l.successes < l.rate*rampAfter {
+		return
+	}
+	l.successes = 0
+
+	step := l.max / 10
+	if step < 1 {
+		step = 1
+	}
+	l.rate += step
+	if l.rate > l.max {
+		l.rate = l.max
+	}
+	log.Info().Int("RateLimit", l.rate).Msg("increasing tiingo rate limit")
+}
+
+// requestBudget caps the number of requests sent per period; once the budget
+// is spent requests wait for the next period
+type requestBudget struct {
+	name   string
+	limit  int
+	period time.Duration
+
+	mu    sync.Mutex
+	start time.Time
+	count int
+}
+
+// wait blocks until the budget allows another request or req is cancelled
+func (b *requestBudget) wait(req *http.Request) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		if b.start.IsZero() || !now.Before(b.start.Add(b.period)) {
+			b.start = now
+			b.count = 0
+		}
+		if b.count < b.limit {
+			b.count++
+			b.mu.Unlock()
+			return nil
+		}
+		resume := b.start.Add(b.period)
+		b.mu.Unlock()
+
+		log.Warn().Str("Budget", b.name).Int("Limit", b.limit).Time("Resume", resume).Msg("tiingo request budget exhausted ... waiting")
+		timer := time.NewTimer(time.Until(resume))
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return req.Context().Err()
+		}
+	}
+}
+
+// exhausted returns true if the budget of the current period is spent
+func (b *requestBudget) exhausted() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.count >= b.limit && time.Now().Before(b.start.Add(b.period))
+}
+
+// rateTransport enforces the request budgets and feeds the status of each
+// response to the adaptive limiter
+type rateTransport struct {
+	limiter *adaptiveLimiter
+	budgets []*requestBudget
+	next    http.RoundTripper
+}
+
+func (r *rateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for _, budget := range r.budgets {
+		if err := budget.wait(req); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil || r.limiter == nil {
+		return resp, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		r.limiter.backoff()
+	case resp.StatusCode < 400:
+		r.limiter.success()
+	}
+	return resp, err
+}
+
+// newRequestBudgets returns the configured hourly and daily budgets
+func newRequestBudgets(opts Options) []*requestBudget {
+	hourly, daily := opts.Budgets()
+	budgets := []*requestBudget{}
+	if hourly > 0 {
+		budgets = append(budgets, &requestBudget{name: "hourly", limit: hourly, period: time.Hour})
+	}
+	if daily > 0 {
+		budgets = append(budgets, &requestBudget{name: "daily", limit: daily, period: 24 * time.Hour})
+	}
+	return budgets
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// recordingExt is the extension of recorded responses
+const recordingExt = ".http"
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// recordingName returns the file name a response to u is recorded under. The
+// name includes the request path for readability and a hash of the full URL
+// (without the API token) so that different date ranges are kept apart.
+func recordingName(u *url.URL) string {
+	sum := sha256.Sum256([]byte(redactURL(u)))
+	name := unsafeNameChars.ReplaceAllString(strings.Trim(u.Path, "/"), "_")
+	return fmt.Sprintf("%s-%s%s", name, hex.EncodeToString(sum[:8]), recordingExt)
+}
+
+// recordTransport archives every response, including errors, to dir (a
+// local directory or s3:// URI) before returning it
+type recordTransport struct {
+	dir  string
+	s3   common.S3Options
+	next http.RoundTripper
+}
+
+func (r *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	data, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		log.Warn().Err(err).Str("URL", redactURL(req.URL)).Msg("could not record response")
+		return resp, nil
+	}
+
+	fn := strings.TrimSuffix(r.dir, "/") + "/" + recordingName(req.URL)
+	if !strings.HasPrefix(r.dir, "s3://") {
+		if err := os.MkdirAll(r.dir, 0o755); err != nil {
+			log.Warn().Err(err).Str("Dir", r.dir).Msg("could not create recording directory")
+			return resp, nil
+		}
+	}
+
+	target, err := common.OpenOutput(req.Context(), fn, r.s3)
+	if err != nil {
+		log.Warn().Err(err).Str("FileName", fn).Msg("could not record response")
+		return resp, nil
+	}
+	if _, err := target.File.Write(data); err != nil {
+		log.Warn().Err(err).Str("FileName", fn).Msg("could not record response")
+		target.Abort()
+		return resp, nil
+	}
+	if err := target.Commit(); err != nil {
+		log.Warn().Err(err).Str("FileName", fn).Msg("could not record response")
+	}
+	return resp, nil
+}
+
+// replayTransport serves responses previously recorded to dir instead of
+// making requests
+type replayTransport struct {
+	dir string
+}
+
+func (r *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fn := path.Join(r.dir, recordingName(req.URL))
+	if _, err := os.Stat(fn); err != nil {
+		// the date range of a replayed run usually differs from the recorded
+		// run; fall back to the only recording of the same endpoint
+		name := recordingName(req.URL)
+		prefix := name[:strings.LastIndex(name, "-")+1]
+		matches, _ := filepath.Glob(path.Join(r.dir, prefix+"*"+recordingExt))
+		if len(matches) != 1 {
+			return nil, fmt.Errorf("no recorded response for %s", redactURL(req.URL))
+		}
+		fn = matches[0]
+	}
+
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recorded response %s: %w", fn, err)
+	}
+	return resp, nil
+}
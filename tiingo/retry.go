@@ -0,0 +1,71 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// minRetryWait is the shortest time waited before a retry when tiingo does
+// not send a Retry-After header
+const minRetryWait = time.Second
+
+// retryable returns true for responses that are retried: 429 Too Many
+// Requests and server errors. Transport errors (including the run deadline
+// and cancellation) are not retried.
+func retryable(resp *resty.Response, err error) bool {
+	if err != nil || resp == nil {
+		return false
+	}
+	status := resp.StatusCode()
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryAfter honours the Retry-After header (seconds or an HTTP date) of a
+// throttled response; zero falls back to exponential backoff with jitter
+func retryAfter(_ *resty.Client, resp *resty.Response) (time.Duration, error) {
+	header := resp.Header().Get("Retry-After")
+	if header == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return time.Until(date), nil
+	}
+	return 0, nil
+}
+
+// logRetries returns a retry hook that logs each request that is about to
+// be retried; resty also calls it after the last attempt, which is not logged
+func logRetries(maxRetries int) resty.OnRetryFunc {
+	return func(resp *resty.Response, err error) {
+		if resp == nil || resp.Request.Attempt > maxRetries {
+			return
+		}
+		log.Warn().
+			Int("StatusCode", resp.StatusCode()).
+			Int("Attempt", resp.Request.Attempt).
+			Str("RetryAfter", resp.Header().Get("Retry-After")).
+			Msg("tiingo request failed ... retrying")
+	}
+}
@@ -0,0 +1,427 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultStreamURL is tiingo's IEX websocket
+const DefaultStreamURL = "wss://api.tiingo.com/iex"
+
+// Threshold levels of the IEX websocket
+const (
+	// StreamQuotesAndTrades sends every top-of-book quote and last trade
+	StreamQuotesAndTrades = 5
+
+	// StreamTrades only sends last trade updates
+	StreamTrades = 6
+)
+
+// Update types of the IEX websocket
+const (
+	IEXQuote = "Q"
+	IEXTrade = "T"
+	IEXBreak = "B"
+)
+
+const (
+	// streamReadTimeout is how long to wait for a message (tiingo sends a
+	// heartbeat every 30 seconds) before reconnecting
+	streamReadTimeout = 2 * time.Minute
+
+	// maxStreamBackoff caps the delay between reconnection attempts
+	maxStreamBackoff = time.Minute
+)
+
+// IEXUpdate is a top-of-book quote or last trade from the IEX websocket.
+// Quotes carry the bid, mid and ask; trades and trade breaks the last price
+// and size.
+type IEXUpdate struct {
+	Type      string
+	Time      time.Time
+	Ticker    string
+	BidSize   float64
+	BidPrice  float64
+	MidPrice  float64
+	AskPrice  float64
+	AskSize   float64
+	LastPrice float64
+	LastSize  int64
+}
+
+// StreamHandler receives updates from the IEX websocket; it is called from a
+// single goroutine
+type StreamHandler func(update *IEXUpdate)
+
+// streamMessage is a message sent by the websocket
+type streamMessage struct {
+	MessageType string `json:"messageType"`
+	Service     string `json:"service"`
+	Response    struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"response"`
+	Data json.RawMessage `json:"data"`
+}
+
+// GapHandler is called after a dropped websocket connection has been
+// re-established with the period during which no updates were received
+type GapHandler func(from, to time.Time)
+
+// StreamIEX subscribes to the IEX websocket for tickers and calls handler for
+// every update until ctx is cancelled or the run deadline passes. Dropped
+// connections are re-established with exponential backoff; an error is only
+// returned if tiingo rejects the subscription.
+func (t *TiingoApi) StreamIEX(ctx context.Context, tickers []string, thresholdLevel int, handler StreamHandler) error {
+	subscription := make([]string, len(tickers))
+	for idx, ticker := range tickers {
+		subscription[idx] = strings.ToLower(strings.ReplaceAll(ticker, "/", "-"))
+	}
+
+	eventData := map[string]any{
+		"thresholdLevel": thresholdLevel,
+		"tickers":        subscription,
+	}
+	handle := func(data json.RawMessage) {
+		update, err := parseIEXUpdate(data)
+		if err != nil {
+			log.Warn().Err(err).RawJSON("Data", data).Msg("could not parse iex update")
+			return
+		}
+		handler(update)
+	}
+	onGap := func(from, to time.Time) {
+		log.Warn().Time("From", from).Time("To", to).Msg("iex updates were missed while the websocket was disconnected")
+	}
+
+	return t.stream(ctx, t.streamURL, eventData, handle, onGap)
+}
+
+// stream subscribes to the websocket at url and calls handle with the data of
+// every update until ctx is cancelled or the run deadline passes. Dropped
+// connections are re-established with exponential backoff and onGap is called
+// once the subscription is accepted again; an error is only returned if
+// tiingo rejects the subscription.
+func (t *TiingoApi) stream(ctx context.Context, url string, eventData map[string]any, handle func(data json.RawMessage), onGap GapHandler) error {
+	// streams end cleanly at the run deadline
+	if !t.options.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, t.options.Deadline)
+		defer cancel()
+	}
+
+	backoff := time.Second
+	var lastUpdate, disconnectedAt time.Time
+	received := func(data json.RawMessage) {
+		lastUpdate = time.Now()
+		handle(data)
+	}
+	onSubscribed := func() {
+		if !disconnectedAt.IsZero() {
+			onGap(disconnectedAt, time.Now())
+			disconnectedAt = time.Time{}
+		}
+	}
+
+	for {
+		connected, err := t.streamOnce(ctx, url, eventData, received, onSubscribed)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, ErrUnauthorized) {
+			return err
+		}
+		if connected {
+			// the gap starts with the last update received; a connection
+			// that went quiet is only detected by the read timeout
+			backoff = time.Second
+			disconnectedAt = lastUpdate
+			if disconnectedAt.IsZero() {
+				disconnectedAt = time.Now()
+			}
+		}
+
+		log.Warn().Err(err).Str("URL", url).Dur("Backoff", backoff).Msg("websocket disconnected; reconnecting")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxStreamBackoff)
+	}
+}
+
+// dialer returns the websocket dialer; it uses the proxy and TLS settings of
+// the transport passed to WithHTTPClient
+func (t *TiingoApi) dialer() *websocket.Dialer {
+	dialer := *websocket.DefaultDialer
+	if t.httpClient != nil {
+		if transport, ok := t.httpClient.Transport.(*http.Transport); ok {
+			dialer.Proxy = transport.Proxy
+			dialer.TLSClientConfig = transport.TLSClientConfig
+		}
+	}
+	return &dialer
+}
+
+// streamOnce reads updates from a single websocket connection until it is
+// closed; connected reports whether the subscription was accepted
+func (t *TiingoApi) streamOnce(ctx context.Context, url string, eventData map[string]any, handle func(data json.RawMessage), onSubscribed func()) (connected bool, err error) {
+	conn, _, err := t.dialer().DialContext(ctx, url, nil)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+	}
+	defer conn.Close()
+
+	// unblock ReadMessage when the context is cancelled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	subscribe := map[string]any{
+		"eventName":     "subscribe",
+		"authorization": t.token,
+		"eventData":     eventData,
+	}
+	if err := conn.WriteJSON(subscribe); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+	}
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return connected, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+		}
+
+		var msg streamMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			log.Warn().Err(err).Bytes("Message", data).Msg("could not unmarshal websocket message")
+			continue
+		}
+
+		switch msg.MessageType {
+		case "I":
+			connected = true
+			log.Info().Str("URL", url).Msg("subscribed to websocket")
+			onSubscribed()
+		case "H":
+		case "E":
+			log.Error().Int("Code", msg.Response.Code).Str("Message", msg.Response.Message).Str("URL", url).Msg("websocket returned an error")
+			return connected, fmt.Errorf("%w: %s", statusError(msg.Response.Code), msg.Response.Message)
+		case "A":
+			handle(msg.Data)
+		}
+	}
+}
+
+// parseIEXUpdate decodes the positional data array of an IEX websocket
+// message: type, date, nanoseconds, ticker, bid size, bid price, mid price,
+// ask price, ask size, last price and last size, followed by flags that are
+// not used
+func parseIEXUpdate(data json.RawMessage) (*IEXUpdate, error) {
+	var fields []any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if len(fields) < 11 {
+		return nil, fmt.Errorf("%w: iex update has %d fields, expected at least 11", ErrInvalidResponse, len(fields))
+	}
+
+	str := func(idx int) string {
+		s, _ := fields[idx].(string)
+		return s
+	}
+	num := func(idx int) float64 {
+		f, _ := fields[idx].(float64)
+		return f
+	}
+
+	eventTime, err := time.Parse(time.RFC3339Nano, str(1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+
+	return &IEXUpdate{
+		Type:      str(0),
+		Time:      eventTime,
+		Ticker:    strings.ToUpper(str(3)),
+		BidSize:   num(4),
+		BidPrice:  num(5),
+		MidPrice:  num(6),
+		AskPrice:  num(7),
+		AskSize:   num(8),
+		LastPrice: num(9),
+		LastSize:  int64(num(10)),
+	}, nil
+}
+
+// barKey identifies the bar of a ticker starting at a given time
+type barKey struct {
+	ticker string
+	start  time.Time
+}
+
+// BarAggregator buffers IEX updates and aggregates them into intraday bars.
+// Bars are built from trades; intervals in which a ticker only had quote
+// updates produce a zero-volume bar of mid prices so illiquid tickers can
+// still be valued.
+type BarAggregator struct {
+	frequency string
+	interval  time.Duration
+	assets    map[string]*common.Asset
+
+	mu        sync.Mutex
+	trades    map[barKey]*IntradayBar
+	quotes    map[barKey]*IntradayBar
+	numTrades int
+	numQuotes int
+}
+
+// NewBarAggregator creates an aggregator of bars of the given intraday
+// frequency for assets; updates of other tickers are ignored
+func NewBarAggregator(frequency string, assets []*common.Asset) (*BarAggregator, error) {
+	interval, ok := intradayIntervals[frequency]
+	if !ok {
+		return nil, fmt.Errorf("unsupported intraday frequency %q", frequency)
+	}
+
+	byTicker := make(map[string]*common.Asset, len(assets))
+	for _, asset := range assets {
+		byTicker[strings.ToUpper(strings.ReplaceAll(asset.Ticker, "/", "-"))] = asset
+	}
+
+	return &BarAggregator{
+		frequency: frequency,
+		interval:  interval,
+		assets:    byTicker,
+		trades:    make(map[barKey]*IntradayBar),
+		quotes:    make(map[barKey]*IntradayBar),
+	}, nil
+}
+
+// Add buffers an update. Trade breaks are ignored.
+func (a *BarAggregator) Add(update *IEXUpdate) {
+	asset, ok := a.assets[update.Ticker]
+	if !ok {
+		return
+	}
+
+	var price float64
+	var volume int64
+	var bars map[barKey]*IntradayBar
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch update.Type {
+	case IEXTrade:
+		price, volume, bars = update.LastPrice, update.LastSize, a.trades
+		a.numTrades++
+	case IEXQuote:
+		price, bars = update.MidPrice, a.quotes
+		a.numQuotes++
+	default:
+		return
+	}
+	if price <= 0 {
+		return
+	}
+
+	key := barKey{ticker: asset.Ticker, start: update.Time.Truncate(a.interval)}
+	bar, ok := bars[key]
+	if !ok {
+		bars[key] = &IntradayBar{
+			Date:          key.start,
+			DateStr:       key.start.Format(time.RFC3339),
+			Ticker:        asset.Ticker,
+			CompositeFigi: asset.CompositeFigi,
+			Frequency:     a.frequency,
+			Open:          price,
+			High:          price,
+			Low:           price,
+			Close:         price,
+			Volume:        volume,
+			RunID:         common.RunID,
+		}
+		return
+	}
+
+	bar.High = max(bar.High, price)
+	bar.Low = min(bar.Low, price)
+	bar.Close = price
+	bar.Volume += volume
+}
+
+// Flush returns the buffered bars whose interval ended at or before now, or
+// every buffered bar when all is true, ordered by time and ticker
+func (a *BarAggregator) Flush(now time.Time, all bool) []*IntradayBar {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	complete := func(key barKey) bool {
+		return all || !key.start.Add(a.interval).After(now)
+	}
+
+	bars := []*IntradayBar{}
+	for key, bar := range a.trades {
+		if complete(key) {
+			bars = append(bars, bar)
+			delete(a.trades, key)
+			delete(a.quotes, key)
+		}
+	}
+	for key, bar := range a.quotes {
+		if complete(key) {
+			bars = append(bars, bar)
+			delete(a.quotes, key)
+		}
+	}
+
+	sort.Slice(bars, func(i, j int) bool {
+		if !bars[i].Date.Equal(bars[j].Date) {
+			return bars[i].Date.Before(bars[j].Date)
+		}
+		return bars[i].Ticker < bars[j].Ticker
+	})
+	return bars
+}
+
+// Counts returns the number of trades and quotes received
+func (a *BarAggregator) Counts() (trades, quotes int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.numTrades, a.numQuotes
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// SupportedTickersURL is the location of the list of all tickers supported by
+// the tiingo EOD endpoint
+const SupportedTickersURL = "https://apimedia.tiingo.com/docs/tiingo/daily/supported_tickers.zip"
+
+// activeWindow is how recent a ticker's last price must be for it to be
+// considered actively traded
+const activeWindow = 7 * 24 * time.Hour
+
+// SupportedTicker is a row from tiingo's supported_tickers.csv
+type SupportedTicker struct {
+	Ticker        string
+	Exchange      string
+	AssetType     common.AssetType
+	PriceCurrency string
+	StartDate     *time.Time
+	EndDate       *time.Time
+}
+
+// IsActive returns true if the ticker has been priced recently
+func (s *SupportedTicker) IsActive() bool {
+	return s.EndDate != nil && time.Since(*s.EndDate) < activeWindow
+}
+
+// tiingoAssetTypes maps tiingo asset types to the asset types used by the
+// assets table
+var tiingoAssetTypes = map[string]common.AssetType{
+	"Stock":       common.CommonStock,
+	"ETF":         common.ETF,
+	"Mutual Fund": common.MutualFund,
+}
+
+// FetchSupportedTickers downloads and parses the list of tickers supported by
+// tiingo
+func (t *TiingoApi) FetchSupportedTickers(ctx context.Context) ([]*SupportedTicker, error) {
+	t.rate.Take()
+
+	resp, err := t.newClient().
+		R().
+		SetContext(ctx).
+		Get(t.supportedTickersURL)
+	if err != nil {
+		log.Error().Err(err).Msg("error when requesting supported tickers")
+		return nil, fmt.Errorf("%w: %w", ErrRequestFailed, err)
+	}
+	if resp.StatusCode() >= 400 {
+		log.Error().Int("StatusCode", resp.StatusCode()).Msg("error when requesting supported tickers")
+		return nil, statusError(resp.StatusCode())
+	}
+
+	data := resp.Body()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		log.Error().Err(err).Msg("could not open supported tickers zip")
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+
+	for _, file := range archive.File {
+		if !strings.HasSuffix(file.Name, ".csv") {
+			continue
+		}
+
+		fh, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer fh.Close()
+
+		return parseSupportedTickers(fh)
+	}
+
+	return nil, fmt.Errorf("%w: no csv file in supported tickers zip", ErrInvalidResponse)
+}
+
+// parseSupportedTickers reads supported_tickers.csv; the expected columns are
+// ticker, exchange, assetType, priceCurrency, startDate, endDate
+func parseSupportedTickers(r io.Reader) ([]*SupportedTicker, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for idx, name := range header {
+		columns[strings.TrimSpace(name)] = idx
+	}
+	for _, name := range []string{"ticker", "exchange", "assetType", "priceCurrency", "startDate", "endDate"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: supported tickers csv is missing column '%s'", ErrInvalidResponse, name)
+		}
+	}
+
+	tickers := []*SupportedTicker{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			if idx := columns[name]; idx < len(record) {
+				return strings.TrimSpace(record[idx])
+			}
+			return ""
+		}
+
+		ticker := strings.ToUpper(field("ticker"))
+		if ticker == "" {
+			continue
+		}
+
+		assetType, ok := tiingoAssetTypes[field("assetType")]
+		if !ok {
+			assetType = common.AssetType(field("assetType"))
+		}
+
+		tickers = append(tickers, &SupportedTicker{
+			Ticker:        ticker,
+			Exchange:      field("exchange"),
+			AssetType:     assetType,
+			PriceCurrency: strings.ToUpper(field("priceCurrency")),
+			StartDate:     parseOptionalDate(field("startDate")),
+			EndDate:       parseOptionalDate(field("endDate")),
+		})
+	}
+
+	return tickers, nil
+}
+
+func parseOptionalDate(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+	date, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil
+	}
+	return &date
+}
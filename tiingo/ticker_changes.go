@@ -0,0 +1,98 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/rs/zerolog/log"
+)
+
+// TickerChange is a new symbol for an existing asset
+type TickerChange struct {
+	CompositeFigi string
+	OldTicker     string
+	NewTicker     string
+	ChangeDate    time.Time
+}
+
+// TickerLookup returns the ticker each composite FIGI currently trades
+// under, e.g. (*openfigi.OpenFigiApi).CurrentTickers
+type TickerLookup func(ctx context.Context, figis []string) (map[string]string, error)
+
+// DetectTickerChanges finds assets whose symbol has changed. Active assets
+// whose ticker is no longer an active tiingo ticker are looked up by composite
+// FIGI; when the FIGI now trades under a different ticker that tiingo does
+// support the asset was renamed.
+func DetectTickerChanges(ctx context.Context, supported []*SupportedTicker, assets []*common.Asset, lookup TickerLookup) ([]*TickerChange, error) {
+	active := make(map[string]*SupportedTicker, len(supported))
+	for _, ticker := range supported {
+		if ticker.IsActive() {
+			active[strings.ToUpper(ticker.Ticker)] = ticker
+		}
+	}
+
+	vanished := make(map[string]*common.Asset)
+	figis := []string{}
+	for _, asset := range assets {
+		if asset.CompositeFigi == "" || active[strings.ToUpper(asset.Ticker)] != nil {
+			continue
+		}
+		vanished[asset.CompositeFigi] = asset
+		figis = append(figis, asset.CompositeFigi)
+	}
+
+	if len(figis) == 0 {
+		return []*TickerChange{}, nil
+	}
+
+	log.Info().Int("NumVanished", len(figis)).Msg("looking up current tickers of assets tiingo no longer lists")
+	current, err := lookup(ctx, figis)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []*TickerChange{}
+	for figi, ticker := range current {
+		asset := vanished[figi]
+		// tiingo uses - where openfigi uses / for share classes
+		newTicker := strings.ToUpper(strings.ReplaceAll(ticker, "/", "-"))
+		if newTicker == strings.ToUpper(asset.Ticker) {
+			continue
+		}
+		listing, ok := active[newTicker]
+		if !ok {
+			continue
+		}
+
+		change := &TickerChange{
+			CompositeFigi: figi,
+			OldTicker:     asset.Ticker,
+			NewTicker:     listing.Ticker,
+			ChangeDate:    time.Now(),
+		}
+		if listing.StartDate != nil && listing.StartDate.After(time.Now().AddDate(-1, 0, 0)) {
+			change.ChangeDate = *listing.StartDate
+		}
+		changes = append(changes, change)
+		log.Info().Str("CompositeFigi", figi).Str("OldTicker", change.OldTicker).Str("NewTicker", change.NewTicker).Msg("detected ticker change")
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tiingo
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"go.uber.org/ratelimit"
+)
+
+// apiToken is one of the tokens requests rotate among; each token is rate
+// limited and budgeted separately and tracks the quota of its own account
+type apiToken struct {
+	token   string
+	rate    ratelimit.Limiter
+	quota   quotaTracker
+	budgets []*requestBudget
+}
+
+// available returns false while the token's quota is paused or one of its
+// request budgets is spent
+func (a *apiToken) available() bool {
+	if a.quota.isPaused() {
+		return false
+	}
+	for _, budget := range a.budgets {
+		if budget.exhausted() {
+			return false
+		}
+	}
+	return true
+}
+
+// tokenPool rotates requests among several API tokens
+type tokenPool struct {
+	tokens []*apiToken
+	next   atomic.Uint64
+}
+
+// newTokenPool returns a pool of the distinct, non-empty tokens; each token
+// gets the full rate limit, request budgets and quota options
+func newTokenPool(tokens []string, rateLimit int, options Options) *tokenPool {
+	pool := &tokenPool{}
+	seen := make(map[string]bool)
+	for _, token := range tokens {
+		if token == "" || seen[token] {
+			continue
+		}
+		seen[token] = true
+		apiToken := &apiToken{
+			token:   token,
+			rate:    ratelimit.New(rateLimit),
+			budgets: newRequestBudgets(options),
+		}
+		apiToken.quota.options = options.Quota
+		pool.tokens = append(pool.tokens, apiToken)
+	}
+	return pool
+}
+
+// pick returns the next token in round-robin order, skipping tokens that are
+// paused or out of budget; when every token is unavailable the next one is
+// used and its request waits
+func (p *tokenPool) pick() *apiToken {
+	start := p.next.Add(1) - 1
+	for offset := range uint64(len(p.tokens)) {
+		token := p.tokens[(start+offset)%uint64(len(p.tokens))]
+		if token.available() {
+			return token
+		}
+	}
+	return p.tokens[start%uint64(len(p.tokens))]
+}
+
+// lookup returns the pool's entry for token or nil
+func (p *tokenPool) lookup(token string) *apiToken {
+	for _, apiToken := range p.tokens {
+		if apiToken.token == token {
+			return apiToken
+		}
+	}
+	return nil
+}
+
+// quota sums the most recent quota reported for each token; Reset is the
+// earliest reset and UpdatedAt the latest update
+func (p *tokenPool) quota() *Quota {
+	var total *Quota
+	for _, token := range p.tokens {
+		quota := token.quota.current()
+		if quota == nil {
+			continue
+		}
+		if total == nil {
+			total = quota
+			continue
+		}
+		total.Limit += quota.Limit
+		total.Remaining += quota.Remaining
+		if !quota.Reset.IsZero() && (total.Reset.IsZero() || quota.Reset.Before(total.Reset)) {
+			total.Reset = quota.Reset
+		}
+		if quota.UpdatedAt.After(total.UpdatedAt) {
+			total.UpdatedAt = quota.UpdatedAt
+		}
+	}
+	return total
+}
+
+type tokenKey struct{}
+
+// withToken pins the requests made with ctx to token instead of rotating
+func withToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// tokenTransport replaces the token query parameter of each request with the
+// next token of the pool, then applies that token's rate limit, request
+// budgets and quota tracking
+type tokenTransport struct {
+	pool        *tokenPool
+	limiter     *adaptiveLimiter
+	logRequests bool
+	next        http.RoundTripper
+}
+
+func (r *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var token *apiToken
+	if pinned, ok := req.Context().Value(tokenKey{}).(string); ok {
+		token = r.pool.lookup(pinned)
+	}
+	if token == nil {
+		token = r.pool.pick()
+	}
+
+	params := req.URL.Query()
+	if params.Has("token") {
+		req = req.Clone(req.Context())
+		params.Set("token", token.token)
+		req.URL.RawQuery = params.Encode()
+	}
+
+	var transport http.RoundTripper = &quotaTransport{quota: &token.quota, logRequests: r.logRequests, next: r.next}
+	if r.limiter != nil || len(token.budgets) > 0 {
+		transport = &rateTransport{limiter: r.limiter, budgets: token.budgets, next: transport}
+	}
+
+	token.rate.Take()
+	return transport.RoundTrip(req)
+}
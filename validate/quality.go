@@ -0,0 +1,130 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package validate
+
+import (
+	"sort"
+	"time"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+)
+
+// Quality summarizes the data quality of the quotes of an asset between its
+// first and last quote. Score is 100 for an asset with a quote on every
+// trading day and no stale closes, zero-volume days or validation failures;
+// coverage scales the score and each problem day lowers it in proportion to
+// the number of trading days.
+type Quality struct {
+	Ticker        string
+	CompositeFigi string
+	StartDate     time.Time
+	EndDate       time.Time
+
+	// TradingDays is the number of sessions on the asset's exchange between
+	// StartDate and EndDate and NumQuotes the number of those with a quote
+	TradingDays int
+	NumQuotes   int
+	Coverage    float64
+
+	// StaleCloses counts quotes whose close equals the previous close,
+	// ZeroVolumeDays trading days without volume and ValidationFailures
+	// quotes with at least one validation issue
+	StaleCloses        int
+	ZeroVolumeDays     int
+	ValidationFailures int
+
+	Score float64
+}
+
+// Score computes the quality of each asset's quotes; issues are the
+// validation issues found in quotes. Assets are ordered from the lowest to
+// the highest score.
+func Score(quotes []*tiingo.Eod, issues []*Issue) []*Quality {
+	failed := make(map[*tiingo.Eod]bool, len(issues))
+	for _, issue := range issues {
+		failed[issue.Quote] = true
+	}
+
+	byAsset := make(map[string][]*tiingo.Eod)
+	for _, quote := range quotes {
+		key := quote.CompositeFigi
+		if key == "" {
+			key = quote.Ticker
+		}
+		byAsset[key] = append(byAsset[key], quote)
+	}
+
+	scores := make([]*Quality, 0, len(byAsset))
+	for _, assetQuotes := range byAsset {
+		sort.Slice(assetQuotes, func(i, j int) bool {
+			return assetQuotes[i].Date.Before(assetQuotes[j].Date)
+		})
+
+		first := assetQuotes[0]
+		last := assetQuotes[len(assetQuotes)-1]
+		cal := common.CalendarFor(first.Exchange)
+
+		q := &Quality{
+			Ticker:        first.Ticker,
+			CompositeFigi: first.CompositeFigi,
+			StartDate:     first.Date,
+			EndDate:       last.Date,
+			TradingDays:   len(cal.TradingDays(first.Date, last.Date)),
+		}
+
+		var prev *tiingo.Eod
+		for _, quote := range assetQuotes {
+			if cal.IsTradingDay(quote.Date) {
+				q.NumQuotes++
+				if quote.Volume == 0 {
+					q.ZeroVolumeDays++
+				}
+			}
+			if prev != nil && quote.Close == prev.Close {
+				q.StaleCloses++
+			}
+			if failed[quote] {
+				q.ValidationFailures++
+			}
+			prev = quote
+		}
+
+		q.Coverage = 1
+		if q.TradingDays > 0 {
+			q.Coverage = min(float64(q.NumQuotes)/float64(q.TradingDays), 1)
+		}
+		q.Score = score(q)
+		scores = append(scores, q)
+	}
+
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score < scores[j].Score
+		}
+		return scores[i].Ticker < scores[j].Ticker
+	})
+
+	return scores
+}
+
+// score combines coverage and the share of problem days into a value
+// between 0 and 100
+func score(q *Quality) float64 {
+	days := max(q.TradingDays, q.NumQuotes, 1)
+	problems := float64(q.StaleCloses+q.ZeroVolumeDays+q.ValidationFailures) / float64(days)
+	return 100 * q.Coverage * max(1-problems, 0)
+}
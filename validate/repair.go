@@ -0,0 +1,153 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package validate
+
+import (
+	"math"
+	"sort"
+
+	"github.com/penny-vault/import-tiingo/tiingo"
+)
+
+// Spike severities
+const (
+	SeverityMinor = "minor"
+	SeverityMajor = "major"
+)
+
+// Repair policies applied to spikes
+const (
+	RepairNone        = "none"
+	RepairFlag        = "flag"
+	RepairInterpolate = "interpolate"
+	RepairRefetch     = "refetch"
+)
+
+// RepairPolicies lists every repair policy
+var RepairPolicies = []string{RepairNone, RepairFlag, RepairInterpolate, RepairRefetch}
+
+// Spike is a quote whose close moved away from the previous session and
+// returned on the next one, without a split to explain it
+type Spike struct {
+	Quote    *tiingo.Eod
+	Prev     *tiingo.Eod
+	Next     *tiingo.Eod
+	Move     float64
+	Severity string
+}
+
+// FindSpikes returns the single-session spikes in quotes: quotes whose close
+// moved more than threshold (a fraction) from the previous close while the
+// next close is within half of threshold of the previous close. Spikes
+// moving at least majorThreshold are major, the rest minor.
+func FindSpikes(quotes []*tiingo.Eod, threshold, majorThreshold float64) []*Spike {
+	byAsset := make(map[string][]*tiingo.Eod)
+	for _, quote := range quotes {
+		key := quote.CompositeFigi
+		if key == "" {
+			key = quote.Ticker
+		}
+		byAsset[key] = append(byAsset[key], quote)
+	}
+
+	spikes := []*Spike{}
+	for _, assetQuotes := range byAsset {
+		sort.Slice(assetQuotes, func(i, j int) bool {
+			return assetQuotes[i].Date.Before(assetQuotes[j].Date)
+		})
+
+		for idx := 1; idx+1 < len(assetQuotes); idx++ {
+			prev, quote, next := assetQuotes[idx-1], assetQuotes[idx], assetQuotes[idx+1]
+			if !isSpike(prev, quote, next, threshold) {
+				continue
+			}
+
+			move := quote.Close/prev.Close - 1
+			severity := SeverityMinor
+			if math.Abs(move) >= majorThreshold {
+				severity = SeverityMajor
+			}
+			spikes = append(spikes, &Spike{Quote: quote, Prev: prev, Next: next, Move: move, Severity: severity})
+		}
+	}
+
+	sort.Slice(spikes, func(i, j int) bool {
+		if spikes[i].Quote.Ticker != spikes[j].Quote.Ticker {
+			return spikes[i].Quote.Ticker < spikes[j].Quote.Ticker
+		}
+		return spikes[i].Quote.Date.Before(spikes[j].Quote.Date)
+	})
+
+	return spikes
+}
+
+// isSpike returns true if quote moved more than threshold from prev and next
+// returned to within half of threshold of prev
+func isSpike(prev, quote, next *tiingo.Eod, threshold float64) bool {
+	if prev.Close <= 0 || threshold <= 0 {
+		return false
+	}
+	if isSplit(quote) || isSplit(next) {
+		return false
+	}
+	return math.Abs(quote.Close/prev.Close-1) > threshold && math.Abs(next.Close/prev.Close-1) <= threshold/2
+}
+
+func isSplit(quote *tiingo.Eod) bool {
+	return quote.Split != 0 && quote.Split != 1
+}
+
+// Persists returns true if quote, e.g. the same session downloaded again,
+// would still be a spike between the neighbouring sessions
+func (s *Spike) Persists(quote *tiingo.Eod, threshold float64) bool {
+	return isSpike(s.Prev, quote, s.Next, threshold)
+}
+
+// Interpolate replaces the open, high, low and close of the spike with the
+// average of the neighbouring sessions
+func (s *Spike) Interpolate() {
+	s.setPrices(
+		(s.Prev.Open+s.Next.Open)/2,
+		(s.Prev.High+s.Next.High)/2,
+		(s.Prev.Low+s.Next.Low)/2,
+		(s.Prev.Close+s.Next.Close)/2,
+	)
+}
+
+// Replace copies the open, high, low and close of quote into the spike
+func (s *Spike) Replace(quote *tiingo.Eod) {
+	s.setPrices(quote.Open, quote.High, quote.Low, quote.Close)
+}
+
+// setPrices updates the spike's prices, keeping high and low consistent with
+// open and close, and scales adjusted prices by the same ratio
+func (s *Spike) setPrices(open, high, low, close float64) {
+	q := s.Quote
+	high = max(high, open, close)
+	low = min(low, open, close)
+
+	scale := func(adjusted *float64, before, after float64) {
+		if adjusted != nil && before != 0 {
+			*adjusted *= after / before
+		}
+	}
+	scale(q.AdjOpen, q.Open, open)
+	scale(q.AdjHigh, q.High, high)
+	scale(q.AdjLow, q.Low, low)
+	scale(q.AdjClose, q.Close, close)
+
+	q.Open, q.High, q.Low, q.Close = open, high, low, close
+}
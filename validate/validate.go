@@ -0,0 +1,195 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package validate checks downloaded quotes for values that are likely to be
+// bad prints before they are saved
+package validate
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/penny-vault/import-tiingo/common"
+	"github.com/penny-vault/import-tiingo/tiingo"
+)
+
+// Rule names
+const (
+	HighLow       = "high_low"
+	CloseRange    = "close_range"
+	NegativePrice = "negative_price"
+	ZeroVolume    = "zero_volume"
+	LargeMove     = "large_move"
+)
+
+// AllRules lists every available rule
+var AllRules = []string{HighLow, CloseRange, NegativePrice, ZeroVolume, LargeMove}
+
+// ruleColumns lists the tiingo columns (see tiingo.EodColumns) each rule
+// needs; a rule can't be applied when one of them isn't downloaded
+var ruleColumns = map[string][]string{
+	HighLow:    {"high", "low"},
+	CloseRange: {"close", "high", "low"},
+	ZeroVolume: {"volume"},
+	LargeMove:  {"close"},
+}
+
+// RequiredColumns returns the tiingo columns the named rule needs
+func RequiredColumns(rule string) []string {
+	return ruleColumns[rule]
+}
+
+// Issue is a rule violation found in a quote
+type Issue struct {
+	Quote   *tiingo.Eod
+	Rule    string
+	Message string
+}
+
+// rule checks quote; prev is the previous quote of the same asset or nil.
+// An empty string is returned when the quote passes.
+type rule func(v *Validator, prev, quote *tiingo.Eod) string
+
+var rules = map[string]rule{
+	HighLow:       checkHighLow,
+	CloseRange:    checkCloseRange,
+	NegativePrice: checkNegativePrice,
+	ZeroVolume:    checkZeroVolume,
+	LargeMove:     checkLargeMove,
+}
+
+// Validator applies a set of rules to quotes
+type Validator struct {
+	// MaxMove is the largest single-day close-to-close move, as a fraction,
+	// allowed on a day without a split
+	MaxMove float64
+
+	rules []string
+}
+
+// New returns a validator that applies the named rules
+func New(ruleNames []string, maxMove float64) (*Validator, error) {
+	for _, name := range ruleNames {
+		if _, ok := rules[name]; !ok {
+			return nil, fmt.Errorf("unknown validation rule '%s'", name)
+		}
+	}
+	return &Validator{MaxMove: maxMove, rules: ruleNames}, nil
+}
+
+// Validate checks each quote against the configured rules and returns all
+// violations ordered by ticker and date
+func (v *Validator) Validate(quotes []*tiingo.Eod) []*Issue {
+	byAsset := make(map[string][]*tiingo.Eod)
+	for _, quote := range quotes {
+		key := quote.CompositeFigi
+		if key == "" {
+			key = quote.Ticker
+		}
+		byAsset[key] = append(byAsset[key], quote)
+	}
+
+	issues := []*Issue{}
+	for _, assetQuotes := range byAsset {
+		sort.Slice(assetQuotes, func(i, j int) bool {
+			return assetQuotes[i].Date.Before(assetQuotes[j].Date)
+		})
+
+		var prev *tiingo.Eod
+		for _, quote := range assetQuotes {
+			for _, name := range v.rules {
+				if msg := rules[name](v, prev, quote); msg != "" {
+					issues = append(issues, &Issue{Quote: quote, Rule: name, Message: msg})
+				}
+			}
+			prev = quote
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Quote.Ticker != issues[j].Quote.Ticker {
+			return issues[i].Quote.Ticker < issues[j].Quote.Ticker
+		}
+		return issues[i].Quote.Date.Before(issues[j].Quote.Date)
+	})
+
+	return issues
+}
+
+// Reject returns quotes without any quote that has an issue
+func Reject(quotes []*tiingo.Eod, issues []*Issue) []*tiingo.Eod {
+	if len(issues) == 0 {
+		return quotes
+	}
+
+	bad := make(map[*tiingo.Eod]bool, len(issues))
+	for _, issue := range issues {
+		bad[issue.Quote] = true
+	}
+
+	valid := make([]*tiingo.Eod, 0, len(quotes)-len(bad))
+	for _, quote := range quotes {
+		if !bad[quote] {
+			valid = append(valid, quote)
+		}
+	}
+	return valid
+}
+
+func checkHighLow(v *Validator, prev, quote *tiingo.Eod) string {
+	if quote.High < quote.Low {
+		return fmt.Sprintf("high %g is below low %g", quote.High, quote.Low)
+	}
+	return ""
+}
+
+func checkCloseRange(v *Validator, prev, quote *tiingo.Eod) string {
+	if quote.Close < quote.Low || quote.Close > quote.High {
+		return fmt.Sprintf("close %g is outside of [%g, %g]", quote.Close, quote.Low, quote.High)
+	}
+	return ""
+}
+
+func checkNegativePrice(v *Validator, prev, quote *tiingo.Eod) string {
+	if quote.Open < 0 || quote.High < 0 || quote.Low < 0 || quote.Close < 0 {
+		return "negative price"
+	}
+	return ""
+}
+
+func checkZeroVolume(v *Validator, prev, quote *tiingo.Eod) string {
+	if quote.Volume != 0 {
+		return ""
+	}
+	if common.CalendarFor(quote.Exchange).IsTradingDay(quote.Date) {
+		return "zero volume on a trading day"
+	}
+	return ""
+}
+
+func checkLargeMove(v *Validator, prev, quote *tiingo.Eod) string {
+	if prev == nil || prev.Close <= 0 || v.MaxMove <= 0 {
+		return ""
+	}
+	if quote.Split != 0 && quote.Split != 1 {
+		return ""
+	}
+	move := quote.Close/prev.Close - 1
+	if math.Abs(move) > v.MaxMove {
+		return fmt.Sprintf("close moved %.1f%% without a split", move*100)
+	}
+	return ""
+}